@@ -0,0 +1,430 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+type ActivityTrend string
+
+const (
+	ActivityTrendSharpRise ActivityTrend = "SHARP_RISE"
+	ActivityTrendSharpDrop ActivityTrend = "SHARP_DROP"
+	ActivityTrendPlateau   ActivityTrend = "PLATEAU"
+)
+
+const (
+	ActivityBaselineDays         = 28
+	ActivityRecentHours          = 6
+	ActivityChangePointHours     = 24
+	ActivityMinBucketSamples     = 3
+	ActivityZScoreThreshold      = 2.0
+	ActivitySpikeZScore          = 3.0
+	ActivityEWMALambda           = 0.3
+	ActivityEWMALimit            = 3.0
+	ActivityCUSUMSlack           = 0.5
+	ActivityCUSUMThreshold       = 4.0
+	ActivityFudShareRatio        = 2.0
+	ActivityFudShareMin          = 0.1
+	ActivityFudShareMinMessages  = 5
+	ActivityFullRefreshInterval  = 24 * time.Hour
+	activityDetectorCount        = 4
+	activityMinBaselineHours     = 24
+	activityMillisecondThreshold = 1_000_000_000_000
+)
+
+type ActivityAnalysis struct {
+	Trend              ActivityTrend `json:"trend"`
+	AverageCount       float64       `json:"average_count"`
+	RecentAverageCount float64       `json:"recent_average_count"`
+	ChangePercent      float64       `json:"change_percent"`
+	ExpectedCount      float64       `json:"expected_count"`
+	ZScore             float64       `json:"z_score"`
+	EWMA               float64       `json:"ewma"`
+	EWMALimit          float64       `json:"ewma_limit"`
+	CUSUMHigh          float64       `json:"cusum_high"`
+	CUSUMLow           float64       `json:"cusum_low"`
+	ChangePoint        ActivityTrend `json:"change_point,omitempty"`
+	ChangePointAt      *time.Time    `json:"change_point_at,omitempty"`
+	SpikeCount         int           `json:"spike_count"`
+	MaxSpikeZScore     float64       `json:"max_spike_z_score"`
+	Baseline           string        `json:"baseline"`
+	BaselineHours      int           `json:"baseline_hours"`
+	Confidence         float64       `json:"confidence"`
+	Description        string        `json:"description"`
+}
+
+type FudShareAnalysis struct {
+	RecentShare   float64 `json:"recent_share"`
+	BaselineShare float64 `json:"baseline_share"`
+	ShareRatio    float64 `json:"share_ratio"`
+	RecentFud     int     `json:"recent_fud"`
+	RecentTotal   int     `json:"recent_total"`
+	Elevated      bool    `json:"elevated"`
+	Description   string  `json:"description"`
+}
+
+type seasonalBaseline struct {
+	mean  float64
+	std   float64
+	kind  string
+	count int
+}
+
+// AnalyzeActivityTrend scores the last ActivityRecentHours of an hourly series
+// against hour-of-week baselines built from the older part of the series.
+// EWMA and CUSUM run over the standardized residuals to catch gradual shifts,
+// single-hour outliers are reported as spikes.
+func AnalyzeActivityTrend(data []ActivityDataPoint) ActivityAnalysis {
+	analysis := ActivityAnalysis{
+		Trend:     ActivityTrendPlateau,
+		EWMALimit: ActivityEWMALimit * math.Sqrt(ActivityEWMALambda/(2-ActivityEWMALambda)),
+	}
+
+	points := sortedActivityPoints(data)
+	if len(points) == 0 {
+		analysis.Description = "No activity data"
+		return analysis
+	}
+
+	recentCount := ActivityRecentHours
+	if recentCount > len(points)/2 {
+		recentCount = len(points) / 2
+	}
+	if recentCount == 0 {
+		analysis.AverageCount = float64(points[0].MessageCount)
+		analysis.RecentAverageCount = float64(points[0].MessageCount)
+		analysis.Description = "Not enough activity data for a baseline"
+		return analysis
+	}
+
+	history := points[:len(points)-recentCount]
+	recent := points[len(points)-recentCount:]
+	analysis.BaselineHours = len(history)
+	analysis.AverageCount = averageActivity(history)
+	analysis.RecentAverageCount = averageActivity(recent)
+	if analysis.AverageCount > 0 {
+		analysis.ChangePercent = (analysis.RecentAverageCount - analysis.AverageCount) / analysis.AverageCount * 100
+	}
+
+	weekBuckets, dayBuckets := buildActivityBuckets(history)
+	overall := newSeasonalBaseline(activityCounts(history), "overall")
+	kinds := map[string]int{}
+	residuals := make([]float64, len(points))
+	baselines := make([]seasonalBaseline, len(points))
+	for i, p := range points {
+		baseline := selectActivityBaseline(activityPointTime(p.Timestamp), weekBuckets, dayBuckets, overall)
+		baselines[i] = baseline
+		residuals[i] = (float64(p.MessageCount) - baseline.mean) / baseline.std
+		if i >= len(history) {
+			kinds[baseline.kind]++
+		}
+	}
+	analysis.Baseline = dominantBaselineKind(kinds)
+
+	var observed, expected, variance float64
+	for i := len(history); i < len(points); i++ {
+		observed += float64(points[i].MessageCount)
+		expected += baselines[i].mean
+		variance += baselines[i].std * baselines[i].std
+		z := residuals[i]
+		if z >= ActivitySpikeZScore {
+			analysis.SpikeCount++
+		}
+		if z > analysis.MaxSpikeZScore {
+			analysis.MaxSpikeZScore = z
+		}
+	}
+	analysis.ExpectedCount = expected / float64(recentCount)
+	if variance > 0 {
+		analysis.ZScore = (observed - expected) / math.Sqrt(variance)
+	}
+
+	ewma := 0.0
+	cusumHigh, cusumLow := 0.0, 0.0
+	changePointFrom := len(points) - ActivityChangePointHours
+	for i, z := range residuals {
+		ewma = ActivityEWMALambda*z + (1-ActivityEWMALambda)*ewma
+		cusumHigh = math.Max(0, cusumHigh+z-ActivityCUSUMSlack)
+		cusumLow = math.Max(0, cusumLow-z-ActivityCUSUMSlack)
+
+		var alarm ActivityTrend
+		if cusumHigh > ActivityCUSUMThreshold {
+			alarm = ActivityTrendSharpRise
+		} else if cusumLow > ActivityCUSUMThreshold {
+			alarm = ActivityTrendSharpDrop
+		}
+		if alarm == "" {
+			continue
+		}
+		if i >= changePointFrom {
+			at := activityPointTime(points[i].Timestamp)
+			analysis.ChangePoint = alarm
+			analysis.ChangePointAt = &at
+		}
+		cusumHigh, cusumLow = 0, 0
+	}
+	analysis.EWMA = ewma
+	analysis.CUSUMHigh = cusumHigh
+	analysis.CUSUMLow = cusumLow
+
+	riseVotes, dropVotes := 0, 0
+	if analysis.ZScore >= ActivityZScoreThreshold {
+		riseVotes++
+	} else if analysis.ZScore <= -ActivityZScoreThreshold {
+		dropVotes++
+	}
+	if analysis.EWMA >= analysis.EWMALimit {
+		riseVotes++
+	} else if analysis.EWMA <= -analysis.EWMALimit {
+		dropVotes++
+	}
+	switch analysis.ChangePoint {
+	case ActivityTrendSharpRise:
+		riseVotes++
+	case ActivityTrendSharpDrop:
+		dropVotes++
+	}
+	if analysis.SpikeCount > 0 {
+		riseVotes++
+	}
+
+	// The window z-score has to pass its threshold and agree with at least one
+	// other detector, so a lone spike or a stale change point does not flip
+	// the trend on its own. Both sides share one denominator: spikes only vote
+	// for rises, so equal evidence gives equal confidence and a drop tops out
+	// at 3/4.
+	switch {
+	case riseVotes >= 2 && analysis.ZScore >= ActivityZScoreThreshold:
+		analysis.Trend = ActivityTrendSharpRise
+		analysis.Confidence = float64(riseVotes) / activityDetectorCount
+	case dropVotes >= 2 && analysis.ZScore <= -ActivityZScoreThreshold:
+		analysis.Trend = ActivityTrendSharpDrop
+		analysis.Confidence = float64(dropVotes) / activityDetectorCount
+	default:
+		analysis.Trend = ActivityTrendPlateau
+		analysis.Confidence = 1 - math.Min(1, math.Abs(analysis.ZScore)/ActivityZScoreThreshold)
+	}
+
+	coverage := math.Min(1, float64(len(history))/float64(ActivityBaselineDays*24))
+	if len(history) < activityMinBaselineHours {
+		coverage = coverage / 2
+	}
+	analysis.Confidence = math.Round(analysis.Confidence*(0.5+0.5*coverage)*100) / 100
+
+	analysis.Description = fmt.Sprintf("%s: last %dh avg %.1f vs expected %.1f (z %.2f, EWMA %.2f/±%.2f, %d spikes, %s baseline over %dh)",
+		analysis.Trend, recentCount, analysis.RecentAverageCount, analysis.ExpectedCount, analysis.ZScore,
+		analysis.EWMA, analysis.EWMALimit, analysis.SpikeCount, analysis.Baseline, analysis.BaselineHours)
+	if analysis.ChangePoint != "" {
+		analysis.Description += fmt.Sprintf(", CUSUM change point %s at %s", analysis.ChangePoint, analysis.ChangePointAt.Format("2006-01-02 15:04"))
+	}
+
+	return analysis
+}
+
+func AnalyzeFudActivityTrend(data []ActivityDataPoint) ActivityAnalysis {
+	return AnalyzeActivityTrend(data)
+}
+
+// AnalyzeFudShare compares the share of FUD messages in total activity over the
+// recent window with the share over the rest of the series.
+func AnalyzeFudShare(activityData, fudData []ActivityDataPoint) FudShareAnalysis {
+	analysis := FudShareAnalysis{}
+
+	totals := make(map[int64]int, len(activityData))
+	for _, p := range activityData {
+		totals[activityPointTime(p.Timestamp).Truncate(time.Hour).Unix()] += p.MessageCount
+	}
+	fuds := make(map[int64]int, len(fudData))
+	for _, p := range fudData {
+		fuds[activityPointTime(p.Timestamp).Truncate(time.Hour).Unix()] += p.MessageCount
+	}
+
+	hours := make([]int64, 0, len(totals))
+	for hour := range totals {
+		hours = append(hours, hour)
+	}
+	sort.Slice(hours, func(i, j int) bool { return hours[i] < hours[j] })
+	if len(hours) == 0 {
+		analysis.Description = "No activity data"
+		return analysis
+	}
+
+	recentFrom := len(hours) - ActivityRecentHours
+	if recentFrom < 0 {
+		recentFrom = 0
+	}
+	baselineFud, baselineTotal := 0, 0
+	for i, hour := range hours {
+		if i >= recentFrom {
+			analysis.RecentFud += fuds[hour]
+			analysis.RecentTotal += totals[hour]
+		} else {
+			baselineFud += fuds[hour]
+			baselineTotal += totals[hour]
+		}
+	}
+
+	if analysis.RecentTotal > 0 {
+		analysis.RecentShare = float64(analysis.RecentFud) / float64(analysis.RecentTotal)
+	}
+	if baselineTotal > 0 {
+		analysis.BaselineShare = float64(baselineFud) / float64(baselineTotal)
+	}
+	if analysis.BaselineShare > 0 {
+		analysis.ShareRatio = analysis.RecentShare / analysis.BaselineShare
+	} else if analysis.RecentShare > 0 {
+		analysis.ShareRatio = math.Inf(1)
+	}
+
+	analysis.Elevated = analysis.RecentFud >= ActivityFudShareMinMessages &&
+		analysis.RecentShare >= ActivityFudShareMin &&
+		analysis.RecentShare >= analysis.BaselineShare*ActivityFudShareRatio
+
+	ratioText := "n/a"
+	if !math.IsInf(analysis.ShareRatio, 0) {
+		ratioText = fmt.Sprintf("%.2fx", analysis.ShareRatio)
+	} else {
+		// JSON cannot encode +Inf, keep the ratio finite for prompts and the API.
+		analysis.ShareRatio = 0
+	}
+	analysis.Description = fmt.Sprintf("FUD share %.1f%% of last %dh activity (%d/%d) vs baseline %.1f%% (%s)",
+		analysis.RecentShare*100, len(hours)-recentFrom, analysis.RecentFud, analysis.RecentTotal, analysis.BaselineShare*100, ratioText)
+	if analysis.Elevated {
+		analysis.Description += " - elevated"
+	}
+
+	return analysis
+}
+
+func activityPointTime(timestamp int64) time.Time {
+	if timestamp >= activityMillisecondThreshold {
+		return time.UnixMilli(timestamp).UTC()
+	}
+	return time.Unix(timestamp, 0).UTC()
+}
+
+// mergeActivityPoints adds fresh hourly points to the cached ones, a fresh
+// point replaces the cached point of the same hour. Points before windowStart
+// are dropped, the result is sorted by time.
+func mergeActivityPoints(cached, fresh []ActivityDataPoint, windowStart time.Time) []ActivityDataPoint {
+	byHour := make(map[int64]ActivityDataPoint, len(cached)+len(fresh))
+	for _, points := range [][]ActivityDataPoint{cached, fresh} {
+		for _, p := range points {
+			hour := activityPointTime(p.Timestamp).Truncate(time.Hour)
+			if hour.Before(windowStart.Truncate(time.Hour)) {
+				continue
+			}
+			byHour[hour.Unix()] = p
+		}
+	}
+
+	merged := make([]ActivityDataPoint, 0, len(byHour))
+	for _, p := range byHour {
+		merged = append(merged, p)
+	}
+	return sortedActivityPoints(merged)
+}
+
+// latestActivityHour is the hour of the newest point, zero without points.
+func latestActivityHour(points []ActivityDataPoint) time.Time {
+	var latest time.Time
+	for _, p := range points {
+		if hour := activityPointTime(p.Timestamp).Truncate(time.Hour); hour.After(latest) {
+			latest = hour
+		}
+	}
+	return latest
+}
+
+func sortedActivityPoints(data []ActivityDataPoint) []ActivityDataPoint {
+	points := make([]ActivityDataPoint, len(data))
+	copy(points, data)
+	sort.SliceStable(points, func(i, j int) bool { return points[i].Timestamp < points[j].Timestamp })
+	return points
+}
+
+func buildActivityBuckets(history []ActivityDataPoint) (map[int][]float64, map[int][]float64) {
+	weekBuckets := make(map[int][]float64)
+	dayBuckets := make(map[int][]float64)
+	for _, p := range history {
+		t := activityPointTime(p.Timestamp)
+		weekBuckets[hourOfWeek(t)] = append(weekBuckets[hourOfWeek(t)], float64(p.MessageCount))
+		dayBuckets[t.Hour()] = append(dayBuckets[t.Hour()], float64(p.MessageCount))
+	}
+	return weekBuckets, dayBuckets
+}
+
+// selectActivityBaseline prefers the hour-of-week bucket and falls back to
+// hour-of-day and then the overall mean while the history is too short.
+func selectActivityBaseline(t time.Time, weekBuckets, dayBuckets map[int][]float64, overall seasonalBaseline) seasonalBaseline {
+	if values := weekBuckets[hourOfWeek(t)]; len(values) >= ActivityMinBucketSamples {
+		return newSeasonalBaseline(values, "hour_of_week")
+	}
+	if values := dayBuckets[t.Hour()]; len(values) >= ActivityMinBucketSamples {
+		return newSeasonalBaseline(values, "hour_of_day")
+	}
+	return overall
+}
+
+// newSeasonalBaseline floors the deviation at the Poisson noise level so quiet
+// buckets with near-constant counts do not produce huge z-scores.
+func newSeasonalBaseline(values []float64, kind string) seasonalBaseline {
+	baseline := seasonalBaseline{kind: kind, count: len(values), std: 1}
+	if len(values) == 0 {
+		return baseline
+	}
+
+	for _, v := range values {
+		baseline.mean += v
+	}
+	baseline.mean /= float64(len(values))
+
+	variance := 0.0
+	for _, v := range values {
+		variance += (v - baseline.mean) * (v - baseline.mean)
+	}
+	if len(values) > 1 {
+		variance /= float64(len(values) - 1)
+	}
+
+	baseline.std = math.Max(math.Sqrt(variance), math.Max(math.Sqrt(baseline.mean), 1))
+	return baseline
+}
+
+func dominantBaselineKind(kinds map[string]int) string {
+	best, bestCount := "overall", 0
+	for _, kind := range []string{"hour_of_week", "hour_of_day", "overall"} {
+		if kinds[kind] > bestCount {
+			best, bestCount = kind, kinds[kind]
+		}
+	}
+	return best
+}
+
+func hourOfWeek(t time.Time) int {
+	return int(t.Weekday())*24 + t.Hour()
+}
+
+func activityCounts(data []ActivityDataPoint) []float64 {
+	counts := make([]float64, len(data))
+	for i, p := range data {
+		counts[i] = float64(p.MessageCount)
+	}
+	return counts
+}
+
+func averageActivity(data []ActivityDataPoint) float64 {
+	if len(data) == 0 {
+		return 0
+	}
+
+	sum := 0
+	for _, point := range data {
+		sum += point.MessageCount
+	}
+
+	return float64(sum) / float64(len(data))
+}
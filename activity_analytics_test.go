@@ -0,0 +1,189 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// activitySeries returns a 28 day hourly baseline of count messages per hour
+// followed by the recent counts, ending at a fixed hour.
+func activitySeries(count func(t time.Time) int, recent ...int) []ActivityDataPoint {
+	end := time.Date(2025, 10, 9, 12, 0, 0, 0, time.UTC)
+	history := ActivityBaselineDays * 24
+	points := make([]ActivityDataPoint, 0, history+len(recent))
+	for i := 0; i < history+len(recent); i++ {
+		at := end.Add(-time.Duration(history+len(recent)-1-i) * time.Hour)
+		messages := count(at)
+		if i >= history {
+			messages = recent[i-history]
+		}
+		points = append(points, ActivityDataPoint{Timestamp: at.Unix(), MessageCount: messages})
+	}
+	return points
+}
+
+func flat(n int) func(time.Time) int {
+	return func(time.Time) int { return n }
+}
+
+func TestAnalyzeActivityTrend_Detectors(t *testing.T) {
+	tests := []struct {
+		name       string
+		data       []ActivityDataPoint
+		trend      ActivityTrend
+		confidence float64
+		check      func(t *testing.T, analysis ActivityAnalysis)
+	}{
+		{
+			name:       "sharp rise trips every detector",
+			data:       activitySeries(flat(10), 40, 40, 40, 40, 40, 40),
+			trend:      ActivityTrendSharpRise,
+			confidence: 1,
+			check: func(t *testing.T, analysis ActivityAnalysis) {
+				assert.InDelta(t, 180/math.Sqrt(60), analysis.ZScore, 1e-9, "6h of +30 against a Poisson variance of 10 per hour")
+				assert.Greater(t, analysis.EWMA, analysis.EWMALimit)
+				assert.Equal(t, ActivityTrendSharpRise, analysis.ChangePoint)
+				assert.Equal(t, 6, analysis.SpikeCount)
+			},
+		},
+		{
+			name:       "sharp drop gets the same confidence per detector",
+			data:       activitySeries(flat(10), 0, 0, 0, 0, 0, 0),
+			trend:      ActivityTrendSharpDrop,
+			confidence: 0.75,
+			check: func(t *testing.T, analysis ActivityAnalysis) {
+				assert.Less(t, analysis.ZScore, -ActivityZScoreThreshold)
+				assert.Less(t, analysis.EWMA, -analysis.EWMALimit)
+				assert.Equal(t, ActivityTrendSharpDrop, analysis.ChangePoint)
+				require.NotNil(t, analysis.ChangePointAt)
+				assert.Zero(t, analysis.SpikeCount)
+			},
+		},
+		{
+			name:  "lone spike is reported but does not flip the trend",
+			data:  activitySeries(flat(10), 22, 10, 10, 10, 10, 10),
+			trend: ActivityTrendPlateau,
+			check: func(t *testing.T, analysis ActivityAnalysis) {
+				assert.Equal(t, 1, analysis.SpikeCount)
+				assert.InDelta(t, 12/math.Sqrt(10), analysis.MaxSpikeZScore, 1e-9)
+				assert.Less(t, analysis.ZScore, ActivityZScoreThreshold)
+				assert.Less(t, analysis.EWMA, analysis.EWMALimit)
+				assert.Empty(t, analysis.ChangePoint)
+			},
+		},
+		{
+			name:  "spike and change point without a window z-score",
+			data:  activitySeries(flat(10), 40, 7, 7, 7, 7, 7),
+			trend: ActivityTrendPlateau,
+			check: func(t *testing.T, analysis ActivityAnalysis) {
+				assert.Equal(t, 1, analysis.SpikeCount)
+				assert.Equal(t, ActivityTrendSharpRise, analysis.ChangePoint)
+				assert.Positive(t, analysis.ZScore)
+				assert.Less(t, analysis.ZScore, ActivityZScoreThreshold, "two votes need the window z-score past its threshold")
+			},
+		},
+		{
+			name: "daily peak is expected from the hour-of-week baseline",
+			data: activitySeries(func(t time.Time) int {
+				if t.Hour() >= 9 && t.Hour() <= 12 {
+					return 50
+				}
+				return 5
+			}, 5, 5, 50, 50, 50, 50),
+			trend: ActivityTrendPlateau,
+			check: func(t *testing.T, analysis ActivityAnalysis) {
+				assert.Equal(t, "hour_of_week", analysis.Baseline)
+				assert.InDelta(t, 0, analysis.ZScore, 1e-9)
+				assert.Greater(t, analysis.ChangePercent, 100.0, "the old half-split would call this a sharp rise")
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			analysis := AnalyzeActivityTrend(tt.data)
+			assert.Equal(t, tt.trend, analysis.Trend, analysis.Description)
+			if tt.confidence > 0 {
+				assert.InDelta(t, tt.confidence, analysis.Confidence, 1e-9)
+			}
+			tt.check(t, analysis)
+		})
+	}
+}
+
+func TestAnalyzeActivityTrend_CUSUMCatchesGradualShift(t *testing.T) {
+	analysis := AnalyzeActivityTrend(activitySeries(flat(10), 15, 15, 15, 15, 15, 15))
+
+	assert.Equal(t, ActivityTrendSharpRise, analysis.ChangePoint, "a steady shift below the spike level accumulates")
+	assert.Zero(t, analysis.SpikeCount)
+	assert.Equal(t, ActivityTrendSharpRise, analysis.Trend, analysis.Description)
+	assert.InDelta(t, 0.75, analysis.Confidence, 1e-9)
+}
+
+func TestAnalyzeActivityTrend_ShortHistory(t *testing.T) {
+	assert.Equal(t, "No activity data", AnalyzeActivityTrend(nil).Description)
+
+	analysis := AnalyzeActivityTrend([]ActivityDataPoint{{Timestamp: 1760000000000, MessageCount: 7}})
+	assert.Equal(t, ActivityTrendPlateau, analysis.Trend)
+	assert.Equal(t, 7.0, analysis.RecentAverageCount, "millisecond timestamps are accepted")
+}
+
+// rangedActivity serves the hourly points between timestamp_from and
+// timestamp_to and records the requested ranges.
+type rangedActivity struct {
+	points []ActivityDataPoint
+	froms  []time.Time
+}
+
+func (r *rangedActivity) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	from, _ := strconv.ParseInt(req.URL.Query().Get("timestamp_from"), 10, 64)
+	to, _ := strconv.ParseInt(req.URL.Query().Get("timestamp_to"), 10, 64)
+	r.froms = append(r.froms, time.UnixMilli(from).UTC())
+	var served []ActivityDataPoint
+	for _, p := range r.points {
+		if p.Timestamp*1000 >= from && p.Timestamp*1000 <= to {
+			served = append(served, p)
+		}
+	}
+	json.NewEncoder(w).Encode(ActivityResponse{Status: "success", Data: served})
+}
+
+func TestFetchActivityHistory_Incremental(t *testing.T) {
+	source := &rangedActivity{points: activitySeries(flat(10), 10)}
+	client := NewExternalActivityClientWithTransport("http://grufender.test", handlerTransport{source})
+	pair := scenarioPair()
+	state := &TradingState{}
+	now := time.Date(2025, 10, 9, 12, 30, 0, 0, time.UTC)
+
+	activity, fud, err := fetchActivityHistory(client, pair, state, now)
+	require.NoError(t, err)
+	require.Len(t, source.froms, 2)
+	assert.Equal(t, now.Add(-ActivityBaselineDays*24*time.Hour), source.froms[0], "the first cycle downloads the whole baseline")
+	assert.Len(t, activity, ActivityBaselineDays*24)
+	assert.Len(t, fud, ActivityBaselineDays*24)
+
+	source.froms = nil
+	source.points[len(source.points)-1].MessageCount = 12
+	next := time.Unix(source.points[len(source.points)-1].Timestamp, 0).UTC().Add(time.Hour)
+	source.points = append(source.points, ActivityDataPoint{Timestamp: next.Unix(), MessageCount: 30})
+	now = next.Add(2*time.Hour + 5*time.Minute)
+
+	activity, _, err = fetchActivityHistory(client, pair, state, now)
+	require.NoError(t, err)
+	assert.Equal(t, []time.Time{next.Add(-time.Hour), next.Add(-time.Hour)}, source.froms, "later cycles only fetch from the newest cached hour")
+	windowStart := now.Add(-ActivityBaselineDays * 24 * time.Hour).Truncate(time.Hour)
+	assert.Equal(t, windowStart, time.Unix(activity[0].Timestamp, 0).UTC(), "older hours leave the window")
+	assert.Equal(t, 12, activity[len(activity)-2].MessageCount, "the still counting hour is refreshed")
+	assert.Equal(t, 30, activity[len(activity)-1].MessageCount)
+
+	source.froms = nil
+	_, _, err = fetchActivityHistory(client, pair, state, now.Add(ActivityFullRefreshInterval))
+	require.NoError(t, err)
+	assert.Equal(t, now.Add(ActivityFullRefreshInterval-ActivityBaselineDays*24*time.Hour), source.froms[0], "the full window is fetched again once a day")
+}
@@ -42,6 +42,8 @@ func handleAPIRoutes(w http.ResponseWriter, r *http.Request) {
 		handlePositions(w, r)
 	case strings.HasPrefix(path, "/position-snapshots"):
 		handlePositionSnapshots(w, r)
+	case strings.HasPrefix(path, "/fud-participants"):
+		handleFudParticipants(w, r)
 	case strings.HasPrefix(path, "/fud-attacks"):
 		handleFudAttacks(w, r)
 	case strings.HasPrefix(path, "/pnl-history"):
@@ -54,6 +56,22 @@ func handleAPIRoutes(w http.ResponseWriter, r *http.Request) {
 		handleAICloseAnalyses(w, r)
 	case strings.HasPrefix(path, "/ai-close-analyses-by-position"):
 		handleAICloseAnalysesByPosition(w, r)
+	case strings.HasPrefix(path, "/regime-history"):
+		handleRegimeHistory(w, r)
+	case strings.HasPrefix(path, "/fud-states"):
+		handleFudStates(w, r)
+	case strings.HasPrefix(path, "/fud-outcomes"):
+		handleFudOutcomes(w, r)
+	case strings.HasPrefix(path, "/shadow-comparison"):
+		handleShadowComparison(w, r)
+	case strings.HasPrefix(path, "/analysis-shadow"):
+		handleAnalysisShadow(w, r)
+	case strings.HasPrefix(path, "/tweets"):
+		handleTweets(w, r)
+	case strings.HasPrefix(path, "/llm-costs"):
+		handleLLMCosts(w, r)
+	case strings.HasPrefix(path, "/research"):
+		handleResearch(w, r)
 	default:
 		http.NotFound(w, r)
 	}
@@ -92,11 +110,17 @@ func handlePairs(w http.ResponseWriter, r *http.Request) {
 
 	pairs := make([]map[string]interface{}, len(TradingPairs))
 	for i, pair := range TradingPairs {
+		strategyName := pair.Strategy.Name
+		if strategyName == "" {
+			strategyName = StrategySentimentIchimoku
+		}
 		pairs[i] = map[string]interface{}{
-			"symbol":       pair.Symbol,
-			"community_id": pair.CommunityID,
-			"leverage":     pair.Leverage,
-			"quantity":     pair.Quantity,
+			"symbol":          pair.Symbol,
+			"community_id":    pair.CommunityID,
+			"leverage":        pair.Leverage,
+			"quantity":        pair.Quantity,
+			"strategy":        strategyName,
+			"strategy_params": pair.Strategy.Params,
 		}
 	}
 
@@ -241,18 +265,25 @@ func handleDecisions(w http.ResponseWriter, r *http.Request) {
 
 	for _, decision := range decisions {
 		item := map[string]interface{}{
-			"id":            decision.ID,
-			"symbol":        decision.Symbol,
-			"position_uuid": decision.PositionUUID,
-			"decision":      decision.FinalDecision,
-			"btc_ichimoku":  decision.BTCIchimoku,
-			"coin_ichimoku": decision.CoinIchimoku,
-			"activity":      decision.Activity,
-			"fud_activity":  decision.FudActivity,
-			"sentiment":     decision.Sentiment,
-			"fud_attack":    decision.FudAttack,
-			"explanation":   decision.DecisionExplanation,
-			"created_at":    decision.CreatedAt,
+			"id":                  decision.ID,
+			"symbol":              decision.Symbol,
+			"position_uuid":       decision.PositionUUID,
+			"decision":            decision.FinalDecision,
+			"btc_ichimoku":        decision.BTCIchimoku,
+			"coin_ichimoku":       decision.CoinIchimoku,
+			"activity":            decision.Activity,
+			"fud_activity":        decision.FudActivity,
+			"fud_share":           decision.FudShare,
+			"activity_z_score":    decision.ActivityZScore,
+			"activity_confidence": decision.ActivityConfidence,
+			"sentiment":           decision.Sentiment,
+			"fud_attack":          decision.FudAttack,
+			"regime":              decision.Regime,
+			"coupling":            decision.Coupling,
+			"btc_correlation":     decision.BTCCorrelation,
+			"btc_beta":            decision.BTCBeta,
+			"explanation":         decision.DecisionExplanation,
+			"created_at":          decision.CreatedAt,
 		}
 		grouped[decision.Symbol] = append(grouped[decision.Symbol], item)
 	}
@@ -376,8 +407,15 @@ func handlePositionDecisions(w http.ResponseWriter, r *http.Request) {
 		CoinIchimoku        string    `json:"coin_ichimoku"`
 		Activity            string    `json:"activity"`
 		FudActivity         string    `json:"fud_activity"`
+		FudShare            string    `json:"fud_share"`
+		ActivityZScore      float64   `json:"activity_z_score"`
+		ActivityConfidence  float64   `json:"activity_confidence"`
 		Sentiment           string    `json:"sentiment"`
 		FudAttack           string    `json:"fud_attack"`
+		Regime              string    `json:"regime"`
+		Coupling            string    `json:"coupling"`
+		BTCCorrelation      float64   `json:"btc_correlation"`
+		BTCBeta             float64   `json:"btc_beta"`
 		FinalDecision       string    `json:"final_decision"`
 		DecisionExplanation string    `json:"decision_explanation"`
 		CreatedAt           time.Time `json:"created_at"`
@@ -392,8 +430,15 @@ func handlePositionDecisions(w http.ResponseWriter, r *http.Request) {
 			CoinIchimoku:        d.CoinIchimoku,
 			Activity:            d.Activity,
 			FudActivity:         d.FudActivity,
+			FudShare:            d.FudShare,
+			ActivityZScore:      d.ActivityZScore,
+			ActivityConfidence:  d.ActivityConfidence,
 			Sentiment:           d.Sentiment,
 			FudAttack:           d.FudAttack,
+			Regime:              d.Regime,
+			Coupling:            d.Coupling,
+			BTCCorrelation:      d.BTCCorrelation,
+			BTCBeta:             d.BTCBeta,
 			FinalDecision:       d.FinalDecision,
 			DecisionExplanation: d.DecisionExplanation,
 			CreatedAt:           d.CreatedAt,
@@ -464,6 +509,8 @@ func handlePositions(w http.ResponseWriter, r *http.Request) {
 		Duration          int64      `json:"duration"`
 		OpenReason        string     `json:"open_reason"`
 		CloseReason       string     `json:"close_reason"`
+		BTCCorrelation    float64    `json:"btc_correlation"`
+		BTCBeta           float64    `json:"btc_beta"`
 	}
 
 	positions := make([]PositionItem, len(allPositions))
@@ -510,6 +557,8 @@ func handlePositions(w http.ResponseWriter, r *http.Request) {
 			Duration:          p.Duration,
 			OpenReason:        p.OpenReason,
 			CloseReason:       p.CloseReason,
+			BTCCorrelation:    p.BTCCorrelation,
+			BTCBeta:           p.BTCBeta,
 		}
 
 		pnl := p.CurrentPnL
@@ -652,18 +701,20 @@ func handleFudAttacks(w http.ResponseWriter, r *http.Request) {
 	}
 
 	type FudAttackItem struct {
-		ID              uint       `json:"id"`
-		Symbol          string     `json:"symbol"`
-		HasAttack       bool       `json:"has_attack"`
-		Confidence      float64    `json:"confidence"`
-		MessageCount    int        `json:"message_count"`
-		FudType         string     `json:"fud_type"`
-		Theme           string     `json:"theme"`
-		StartedHoursAgo int        `json:"started_hours_ago"`
-		LastAttackTime  *time.Time `json:"last_attack_time,omitempty"`
-		Justification   string     `json:"justification"`
-		Participants    string     `json:"participants"`
-		CreatedAt       time.Time  `json:"created_at"`
+		ID                uint       `json:"id"`
+		Symbol            string     `json:"symbol"`
+		HasAttack         bool       `json:"has_attack"`
+		Confidence        float64    `json:"confidence"`
+		RawConfidence     float64    `json:"raw_confidence"`
+		CredibilityWeight float64    `json:"credibility_weight"`
+		MessageCount      int        `json:"message_count"`
+		FudType           string     `json:"fud_type"`
+		Theme             string     `json:"theme"`
+		StartedHoursAgo   int        `json:"started_hours_ago"`
+		LastAttackTime    *time.Time `json:"last_attack_time,omitempty"`
+		Justification     string     `json:"justification"`
+		Participants      string     `json:"participants"`
+		CreatedAt         time.Time  `json:"created_at"`
 	}
 
 	attackItems := make([]FudAttackItem, len(attacks))
@@ -674,18 +725,20 @@ func handleFudAttacks(w http.ResponseWriter, r *http.Request) {
 		}
 
 		attackItems[i] = FudAttackItem{
-			ID:              a.ID,
-			Symbol:          a.Symbol,
-			HasAttack:       a.HasAttack,
-			Confidence:      a.Confidence,
-			MessageCount:    a.MessageCount,
-			FudType:         a.FudType,
-			Theme:           a.Theme,
-			StartedHoursAgo: a.StartedHoursAgo,
-			LastAttackTime:  lastAttackTime,
-			Justification:   a.Justification,
-			Participants:    a.Participants,
-			CreatedAt:       a.CreatedAt,
+			ID:                a.ID,
+			Symbol:            a.Symbol,
+			HasAttack:         a.HasAttack,
+			Confidence:        a.Confidence,
+			RawConfidence:     a.RawConfidence,
+			CredibilityWeight: a.CredibilityWeight,
+			MessageCount:      a.MessageCount,
+			FudType:           a.FudType,
+			Theme:             a.Theme,
+			StartedHoursAgo:   a.StartedHoursAgo,
+			LastAttackTime:    lastAttackTime,
+			Justification:     a.Justification,
+			Participants:      a.Participants,
+			CreatedAt:         a.CreatedAt,
 		}
 	}
 
@@ -1050,3 +1103,584 @@ func handleAICloseAnalysesByPosition(w http.ResponseWriter, r *http.Request) {
 		"ai_close_analyses": items,
 	})
 }
+
+func handleRegimeHistory(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	symbol := r.URL.Query().Get("symbol")
+	hoursBack := 168
+	if hoursStr := r.URL.Query().Get("hours"); hoursStr != "" {
+		if parsedHours, err := strconv.Atoi(hoursStr); err == nil && parsedHours > 0 {
+			hoursBack = parsedHours
+		}
+	}
+
+	records, err := GetMarketRegimeHistory(symbol, hoursBack)
+	if err != nil {
+		http.Error(w, "Failed to get regime history", http.StatusInternalServerError)
+		return
+	}
+
+	type RegimePoint struct {
+		ID                    uint      `json:"id"`
+		Regime                string    `json:"regime"`
+		Confidence            float64   `json:"confidence"`
+		ATRPercent            float64   `json:"atr_percent"`
+		ATRRatio              float64   `json:"atr_ratio"`
+		ADX                   float64   `json:"adx"`
+		CloudThicknessPercent float64   `json:"cloud_thickness_percent"`
+		BarsInCloud           int       `json:"bars_in_cloud"`
+		VolumeRatio           float64   `json:"volume_ratio"`
+		BTCSignal             string    `json:"btc_signal"`
+		Description           string    `json:"description"`
+		CreatedAt             time.Time `json:"created_at"`
+	}
+
+	grouped := make(map[string][]RegimePoint)
+	for _, rec := range records {
+		grouped[rec.Symbol] = append(grouped[rec.Symbol], RegimePoint{
+			ID:                    rec.ID,
+			Regime:                rec.Regime,
+			Confidence:            rec.Confidence,
+			ATRPercent:            rec.ATRPercent,
+			ATRRatio:              rec.ATRRatio,
+			ADX:                   rec.ADX,
+			CloudThicknessPercent: rec.CloudThicknessPercent,
+			BarsInCloud:           rec.BarsInCloud,
+			VolumeRatio:           rec.VolumeRatio,
+			BTCSignal:             rec.BTCSignal,
+			Description:           rec.Description,
+			CreatedAt:             rec.CreatedAt,
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"regimes": grouped,
+	})
+}
+
+func handleResearch(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if researchExchanges == nil {
+		http.Error(w, "Research is not available until the exchange client is initialized", http.StatusServiceUnavailable)
+		return
+	}
+
+	days := ResearchDefaultDays
+	if daysStr := r.URL.Query().Get("days"); daysStr != "" {
+		if parsedDays, err := strconv.Atoi(daysStr); err == nil && parsedDays > 0 {
+			days = parsedDays
+		}
+	}
+
+	pairs := TradingPairs
+	if symbol := r.URL.Query().Get("symbol"); symbol != "" {
+		pairs = nil
+		for _, pair := range TradingPairs {
+			if pair.Symbol == symbol {
+				pairs = append(pairs, pair)
+			}
+		}
+		if len(pairs) == 0 {
+			http.Error(w, "Unknown symbol", http.StatusBadRequest)
+			return
+		}
+	}
+
+	report := RunSentimentPriceResearch(researchExchanges, pairs, days)
+
+	if r.URL.Query().Get("format") == "csv" {
+		w.Header().Set("Content-Type", "text/csv")
+		w.Header().Set("Content-Disposition", "attachment; filename=\"research.csv\"")
+		if err := WriteResearchCSV(w, report); err != nil {
+			log.Printf("Failed to write research CSV: %v", err)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
+
+func handleFudStates(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	symbol := r.URL.Query().Get("symbol")
+	hoursBack := 168
+	if hoursStr := r.URL.Query().Get("hours"); hoursStr != "" {
+		if parsedHours, err := strconv.Atoi(hoursStr); err == nil && parsedHours > 0 {
+			hoursBack = parsedHours
+		}
+	}
+
+	records, err := GetFudStateTransitions(symbol, hoursBack)
+	if err != nil {
+		http.Error(w, "Failed to get FUD state transitions", http.StatusInternalServerError)
+		return
+	}
+
+	type TransitionItem struct {
+		ID                 uint      `json:"id"`
+		PositionUUID       string    `json:"position_uuid"`
+		FromState          string    `json:"from_state"`
+		ToState            string    `json:"to_state"`
+		Strategy           string    `json:"strategy"`
+		Trigger            string    `json:"trigger"`
+		Details            string    `json:"details"`
+		AttackConfidence   float64   `json:"attack_confidence"`
+		AttackMessages     int       `json:"attack_messages"`
+		AttackParticipants int       `json:"attack_participants"`
+		CreatedAt          time.Time `json:"created_at"`
+	}
+
+	grouped := make(map[string][]TransitionItem)
+	for _, rec := range records {
+		grouped[rec.Symbol] = append(grouped[rec.Symbol], TransitionItem{
+			ID:                 rec.ID,
+			PositionUUID:       rec.PositionUUID,
+			FromState:          rec.FromState,
+			ToState:            rec.ToState,
+			Strategy:           rec.Strategy,
+			Trigger:            rec.Trigger,
+			Details:            rec.Details,
+			AttackConfidence:   rec.AttackConfidence,
+			AttackMessages:     rec.AttackMessages,
+			AttackParticipants: rec.AttackParticipants,
+			CreatedAt:          rec.CreatedAt,
+		})
+	}
+
+	type PairState struct {
+		State           string    `json:"state"`
+		Since           time.Time `json:"since,omitempty"`
+		Strategy        string    `json:"strategy"`
+		MinConfidence   float64   `json:"min_confidence"`
+		MinParticipants int       `json:"min_participants"`
+		MinMessages     int       `json:"min_messages"`
+		MaxAttackAgeMin float64   `json:"max_attack_age_minutes"`
+		QuietPeriodH    float64   `json:"quiet_period_hours"`
+		MaxHoldTimeH    float64   `json:"max_hold_time_hours"`
+	}
+
+	current := make(map[string]PairState)
+	for _, pair := range TradingPairs {
+		if symbol != "" && pair.Symbol != symbol {
+			continue
+		}
+		config := GetFudModeConfig(pair)
+		item := PairState{
+			State:           string(FudStateIdle),
+			Strategy:        string(config.Strategy),
+			MinConfidence:   config.MinConfidence,
+			MinParticipants: config.MinParticipants,
+			MinMessages:     config.MinMessages,
+			MaxAttackAgeMin: config.MaxAttackAge.Minutes(),
+			QuietPeriodH:    config.QuietPeriod.Hours(),
+			MaxHoldTimeH:    config.MaxHoldTime.Hours(),
+		}
+		if last, err := GetLatestFudStateTransition(pair.Symbol); err == nil && last != nil {
+			item.State = last.ToState
+			item.Since = last.CreatedAt
+		}
+		current[pair.Symbol] = item
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"transitions": grouped,
+		"current":     current,
+	})
+}
+
+func handleFudParticipants(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	minAttacks := 2
+	if minStr := r.URL.Query().Get("min_attacks"); minStr != "" {
+		if parsed, err := strconv.Atoi(minStr); err == nil && parsed > 0 {
+			minAttacks = parsed
+		}
+	}
+	limit := 100
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		if parsed, err := strconv.Atoi(limitStr); err == nil && parsed > 0 {
+			limit = parsed
+		}
+	}
+	hoursBack := 24 * 30
+	if hoursStr := r.URL.Query().Get("hours"); hoursStr != "" {
+		if parsed, err := strconv.Atoi(hoursStr); err == nil && parsed > 0 {
+			hoursBack = parsed
+		}
+	}
+
+	participants, err := GetRepeatFudParticipants(minAttacks, limit)
+	if err != nil {
+		http.Error(w, "Failed to get FUD participants", http.StatusInternalServerError)
+		return
+	}
+
+	type ParticipantItem struct {
+		Username         string    `json:"username"`
+		Communities      []string  `json:"communities"`
+		FirstSeen        time.Time `json:"first_seen"`
+		LastSeen         time.Time `json:"last_seen"`
+		AttackCount      int       `json:"attack_count"`
+		TotalMessages    int       `json:"total_messages"`
+		EvaluatedAttacks int       `json:"evaluated_attacks"`
+		DropsAfter       int       `json:"drops_after"`
+		AvgPriceMove24h  float64   `json:"avg_price_move_24h"`
+		Credibility      float64   `json:"credibility"`
+	}
+
+	items := make([]ParticipantItem, 0, len(participants))
+	for _, p := range participants {
+		communities := []string{}
+		if p.Communities != "" {
+			communities = strings.Split(p.Communities, ",")
+		}
+		items = append(items, ParticipantItem{
+			Username:         p.Username,
+			Communities:      communities,
+			FirstSeen:        p.FirstSeen,
+			LastSeen:         p.LastSeen,
+			AttackCount:      p.AttackCount,
+			TotalMessages:    p.TotalMessages,
+			EvaluatedAttacks: p.EvaluatedAttacks,
+			DropsAfter:       p.DropsAfter,
+			AvgPriceMove24h:  p.AvgPriceMove24h,
+			Credibility:      p.Credibility,
+		})
+	}
+
+	participations, err := GetFudParticipations(hoursBack)
+	if err != nil {
+		http.Error(w, "Failed to get FUD participations", http.StatusInternalServerError)
+		return
+	}
+	groups := FindCoordinatedFudGroups(participations)
+	if groups == nil {
+		groups = []FudGroup{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"participants": items,
+		"groups":       groups,
+	})
+}
+
+func handleFudOutcomes(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	symbol := r.URL.Query().Get("symbol")
+	source := r.URL.Query().Get("source")
+	hoursBack := 24 * ResearchMaxDays
+	if hoursStr := r.URL.Query().Get("hours"); hoursStr != "" {
+		if parsedHours, err := strconv.Atoi(hoursStr); err == nil && parsedHours > 0 {
+			hoursBack = parsedHours
+		}
+	}
+
+	records, err := GetFudModeOutcomes(symbol, source, hoursBack)
+	if err != nil {
+		http.Error(w, "Failed to get FUD mode outcomes", http.StatusInternalServerError)
+		return
+	}
+
+	type OutcomeItem struct {
+		ID              uint      `json:"id"`
+		Symbol          string    `json:"symbol"`
+		Strategy        string    `json:"strategy"`
+		Source          string    `json:"source"`
+		PositionUUID    string    `json:"position_uuid"`
+		Side            string    `json:"side"`
+		AttackStartedAt time.Time `json:"attack_started_at"`
+		EnteredAt       time.Time `json:"entered_at"`
+		ExitedAt        time.Time `json:"exited_at"`
+		EntryPrice      float64   `json:"entry_price"`
+		ExitPrice       float64   `json:"exit_price"`
+		ReturnPercent   float64   `json:"return_percent"`
+		RealizedPL      float64   `json:"realized_pl"`
+		ExitReason      string    `json:"exit_reason"`
+		HoldMinutes     float64   `json:"hold_minutes"`
+		Skipped         bool      `json:"skipped"`
+	}
+
+	items := make([]OutcomeItem, 0, len(records))
+	for _, rec := range records {
+		items = append(items, OutcomeItem{
+			ID:              rec.ID,
+			Symbol:          rec.Symbol,
+			Strategy:        rec.Strategy,
+			Source:          rec.Source,
+			PositionUUID:    rec.PositionUUID,
+			Side:            rec.Side,
+			AttackStartedAt: rec.AttackStartedAt,
+			EnteredAt:       rec.EnteredAt,
+			ExitedAt:        rec.ExitedAt,
+			EntryPrice:      rec.EntryPrice,
+			ExitPrice:       rec.ExitPrice,
+			ReturnPercent:   rec.ReturnPercent,
+			RealizedPL:      rec.RealizedPL,
+			ExitReason:      rec.ExitReason,
+			HoldMinutes:     rec.HoldMinutes,
+			Skipped:         rec.Skipped,
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"summary":  SummarizeFudOutcomes(records),
+		"outcomes": items,
+	})
+}
+
+func handleShadowComparison(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	symbol := r.URL.Query().Get("symbol")
+	hoursBack := 168
+	if hoursStr := r.URL.Query().Get("hours"); hoursStr != "" {
+		if parsedHours, err := strconv.Atoi(hoursStr); err == nil && parsedHours > 0 {
+			hoursBack = parsedHours
+		}
+	}
+
+	comparison, err := CompareShadowPerformance(symbol, hoursBack)
+	if err != nil {
+		http.Error(w, "Failed to compare shadow strategies", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"hours":      hoursBack,
+		"comparison": comparison,
+	})
+}
+
+func handleAnalysisShadow(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	symbol := r.URL.Query().Get("symbol")
+	hoursBack := 168
+	if hoursStr := r.URL.Query().Get("hours"); hoursStr != "" {
+		if parsedHours, err := strconv.Atoi(hoursStr); err == nil && parsedHours > 0 {
+			hoursBack = parsedHours
+		}
+	}
+
+	records, err := GetAnalysisShadowRecords(symbol, hoursBack)
+	if err != nil {
+		http.Error(w, "Failed to get analysis shadow records", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"hours":   hoursBack,
+		"summary": SummarizeAnalysisShadow(records),
+		"records": records,
+	})
+}
+
+func handleTweets(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	params := r.URL.Query()
+	query := TweetQuery{
+		CommunityID: params.Get("community"),
+		Search:      params.Get("q"),
+		FudOnly:     params.Get("fud") == "1" || params.Get("fud") == "true",
+		Limit:       100,
+	}
+	if symbol := params.Get("symbol"); symbol != "" {
+		for _, pair := range TradingPairs {
+			if pair.Symbol == symbol {
+				query.CommunityID = pair.CommunityID
+			}
+		}
+		if query.CommunityID == "" {
+			http.Error(w, "Unknown symbol", http.StatusBadRequest)
+			return
+		}
+	}
+	if hoursStr := params.Get("hours"); hoursStr != "" {
+		if parsedHours, err := strconv.Atoi(hoursStr); err == nil && parsedHours > 0 {
+			query.From = time.Now().Add(-time.Duration(parsedHours) * time.Hour)
+		}
+	}
+	for name, target := range map[string]*time.Time{"from": &query.From, "to": &query.To} {
+		if value := params.Get(name); value != "" {
+			parsed, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				http.Error(w, "Invalid "+name+" time, use RFC3339", http.StatusBadRequest)
+				return
+			}
+			*target = parsed
+		}
+	}
+	if limitStr := params.Get("limit"); limitStr != "" {
+		if parsedLimit, err := strconv.Atoi(limitStr); err == nil && parsedLimit > 0 {
+			query.Limit = min(parsedLimit, 1000)
+		}
+	}
+	if offsetStr := params.Get("offset"); offsetStr != "" {
+		if parsedOffset, err := strconv.Atoi(offsetStr); err == nil && parsedOffset >= 0 {
+			query.Offset = parsedOffset
+		}
+	}
+
+	records, total, err := SearchTweets(query)
+	if err != nil {
+		http.Error(w, "Failed to search tweets", http.StatusInternalServerError)
+		return
+	}
+
+	type TweetItem struct {
+		CommunityID string    `json:"community_id"`
+		TweetID     string    `json:"tweet_id"`
+		Text        string    `json:"text"`
+		Sentiment   int       `json:"sentiment"`
+		IsFud       bool      `json:"is_fud"`
+		TweetedAt   time.Time `json:"tweeted_at"`
+	}
+
+	tweets := make([]TweetItem, len(records))
+	for i, record := range records {
+		tweets[i] = TweetItem{
+			CommunityID: record.CommunityID,
+			TweetID:     record.TweetID,
+			Text:        record.Text,
+			Sentiment:   record.Sentiment,
+			IsFud:       record.IsFud,
+			TweetedAt:   record.TweetedAt,
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"total":  total,
+		"tweets": tweets,
+	})
+}
+
+func handleLLMCosts(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	hoursBack := 168
+	if hoursStr := r.URL.Query().Get("hours"); hoursStr != "" {
+		if parsedHours, err := strconv.Atoi(hoursStr); err == nil && parsedHours > 0 {
+			hoursBack = parsedHours
+		}
+	}
+
+	calls, err := GetLLMCallsSince(time.Now().Add(-time.Duration(hoursBack) * time.Hour))
+	if err != nil {
+		http.Error(w, "Failed to get LLM calls", http.StatusInternalServerError)
+		return
+	}
+
+	seen := make(map[string]bool)
+	var uuids []string
+	for _, call := range calls {
+		if call.PositionUUID != "" && !seen[call.PositionUUID] {
+			seen[call.PositionUUID] = true
+			uuids = append(uuids, call.PositionUUID)
+		}
+	}
+	positions, err := GetPositionsByUUIDs(uuids)
+	if err != nil {
+		http.Error(w, "Failed to get positions", http.StatusInternalServerError)
+		return
+	}
+
+	todayCost, err := GetLLMCostSince(time.Now().UTC().Truncate(24 * time.Hour))
+	if err != nil {
+		http.Error(w, "Failed to get LLM spending", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"hours":        hoursBack,
+		"today_cost":   todayCost,
+		"daily_budget": getEnvAsFloat(ENV_LLM_DAILY_BUDGET, 0),
+		"summary":      SummarizeLLMCosts(calls, positions),
+	})
+}
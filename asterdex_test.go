@@ -2,29 +2,31 @@ package main
 
 import (
 	"fmt"
-	"github.com/stretchr/testify/assert"
 	"log"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
 	"testing"
-	"time"
 
-	"github.com/joho/godotenv"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
-func TestAsterDexPositions(t *testing.T) {
-	if err := godotenv.Load(); err != nil {
-		t.Skip("No .env file found, skipping test")
-	}
-
-	apiKey := os.Getenv(ENV_DEX_KEY)
-	secretKey := os.Getenv(ENV_DEX_SECRET)
-
-	if apiKey == "" || secretKey == "" {
-		t.Skip("ASTERDEX_API_KEY or ASTERDEX_SECRET_KEY not set, skipping test")
-	}
+// newMockAsterDexExchange points an AsterDex client at a fresh local mock
+// exchange, so the tests never reach real funds.
+func newMockAsterDexExchange(t *testing.T) (*FapiExchange, *MockFapiServer) {
+	mock := NewMockFapiServer()
+	server := httptest.NewServer(mock.Handler())
+	t.Cleanup(server.Close)
+
+	exchange, err := newFapiExchange(VenueAsterDex, ExchangeEndpoint{BaseURL: server.URL, RecvWindow: DefaultRecvWindowMs},
+		"test-key", "test-secret", "", NewWeightLimiter(AsterDexWeightLimit), &ServerClock{})
+	require.NoError(t, err)
+	return exchange, mock
+}
 
-	// Create exchange instance
-	exchange := NewAsterDexExchange(apiKey, secretKey)
+func TestAsterDexPositions(t *testing.T) {
+	exchange, _ := newMockAsterDexExchange(t)
 
 	symbol := "SOLUSDT"
 	leverage := 10
@@ -77,10 +79,6 @@ func TestAsterDexPositions(t *testing.T) {
 	log.Printf("  Entry Price: %.2f", position.EntryPrice)
 	log.Printf("  Leverage: %dx", position.Leverage)
 
-	// Wait a bit
-	log.Println("\nWaiting 3 seconds...")
-	time.Sleep(3 * time.Second)
-
 	// 5. Check position status
 	log.Println("\n5. Checking position status...")
 	currentPosition, err := exchange.GetPosition(symbol)
@@ -96,12 +94,14 @@ func TestAsterDexPositions(t *testing.T) {
 		log.Printf("  Unrealized P/L: %.2f USDT", currentPosition.UnrealizedPL)
 		log.Printf("  Leverage: %dx", currentPosition.Leverage)
 	} else {
-		log.Println("No position found")
+		t.Fatalf("No position found")
 	}
 
-	// Wait a bit
-	log.Println("\nWaiting 2 seconds...")
-	time.Sleep(2 * time.Second)
+	// 6. Close position
+	log.Println("\n6. Closing LONG position...")
+	if err := exchange.ClosePosition(symbol, side); err != nil {
+		t.Fatalf("Failed to close position: %v", err)
+	}
 
 	// 7. Verify position is closed
 	log.Println("\n7. Verifying position is closed...")
@@ -112,45 +112,41 @@ func TestAsterDexPositions(t *testing.T) {
 	if finalPosition == nil {
 		log.Println("✓ Position successfully closed")
 	} else {
-		log.Printf("⚠ Position still exists: Amount=%.6f", finalPosition.Amount)
+		t.Errorf("Position still exists: Amount=%.6f", finalPosition.Amount)
 	}
 
 	log.Println("\n=== Test completed ===")
 }
 func TestAsterDexExchange_ClosePosition(t *testing.T) {
-	if err := godotenv.Load(); err != nil {
-		t.Skip("No .env file found, skipping test")
-	}
-
-	apiKey := os.Getenv(ENV_DEX_KEY)
-	secretKey := os.Getenv(ENV_DEX_SECRET)
-
-	if apiKey == "" || secretKey == "" {
-		t.Skip("ASTERDEX_API_KEY or ASTERDEX_SECRET_KEY not set, skipping test")
-	}
-
-	// Create exchange instance
-	exchange := NewAsterDexExchange(apiKey, secretKey)
-	time.Sleep(time.Second * 10)
-	exchange.ClosePosition("SOLUSDT", PositionSideLong)
+	exchange, _ := newMockAsterDexExchange(t)
+	_, err := exchange.OpenPosition("SOLUSDT", PositionSideLong, 10, 0.5)
+	require.NoError(t, err)
+
+	require.NoError(t, exchange.ClosePosition("SOLUSDT", PositionSideLong))
+	position, err := exchange.GetPosition("SOLUSDT")
+	require.NoError(t, err)
+	assert.Nil(t, position)
+	assert.Error(t, exchange.ClosePosition("SOLUSDT", PositionSideLong), "nothing left to close")
 }
 
 func TestAsterDexExchange_GetKlines(t *testing.T) {
-	godotenv.Load()
-	exchange := NewAsterDexExchange(os.Getenv(ENV_DEX_KEY), os.Getenv(ENV_DEX_SECRET))
+	exchange, _ := newMockAsterDexExchange(t)
 	result, err := exchange.Klines("BTCUSDT", "4h", 0, 0, 200)
 	assert.NoError(t, err)
+	assert.Len(t, result, 200)
+	chart := filepath.Join(t.TempDir(), "chart.svg")
 	svg := GenerateCandlestickSVG(result, 800, 600)
-	os.WriteFile("chart.svg", []byte(svg), 0655)
+	os.WriteFile(chart, []byte(svg), 0655)
 	cloud := CalculateIchimoku(result)
 	aga := GenerateIchimokuSVG(result, cloud.Data, 800, 600)
-	os.WriteFile("chart.svg", []byte(aga), 0655)
+	os.WriteFile(chart, []byte(aga), 0655)
 	fmt.Printf("analyze %+v", cloud.Analysis)
 }
 func TestAsterDexExchange_GetAllBalances(t *testing.T) {
-	godotenv.Load()
-	exchange := NewAsterDexExchange(os.Getenv(ENV_DEX_KEY), os.Getenv(ENV_DEX_SECRET))
+	exchange, _ := newMockAsterDexExchange(t)
 	balances, err := exchange.GetAllBalances()
 	assert.NoError(t, err)
+	require.Len(t, balances, 1)
+	assert.Equal(t, MockExchangeWalletBalance, balances[0].Balance)
 	fmt.Printf("%+v\n", balances)
 }
@@ -0,0 +1,22 @@
+package main
+
+const (
+	BinanceFuturesBaseURL = "https://fapi.binance.com"
+	// BinanceWeightLimit is the USDⓈ-M request weight allowed per minute and IP.
+	BinanceWeightLimit = 2400
+)
+
+var (
+	sharedBinanceLimiter = NewWeightLimiter(BinanceWeightLimit)
+	sharedBinanceClock   = &ServerClock{}
+)
+
+// NewBinanceExchange connects to Binance USDⓈ-M futures, which AsterDex
+// mirrors endpoint for endpoint, in the EXCHANGE_ENV environment.
+func NewBinanceExchange(apiKey, secretKey, proxyDSN string) (*FapiExchange, error) {
+	endpoint, err := ResolveExchangeEndpoint(VenueBinance)
+	if err != nil {
+		return nil, err
+	}
+	return newFapiExchange(VenueBinance, endpoint, apiKey, secretKey, proxyDSN, sharedBinanceLimiter, sharedBinanceClock)
+}
@@ -3,11 +3,10 @@ package main
 import (
 	"fmt"
 	"math"
-	"strconv"
 	"strings"
 )
 
-func GenerateCandlestickSVG(klines []AsterDexKline, width, height int) string {
+func GenerateCandlestickSVG(klines []Candle, width, height int) string {
 	if len(klines) == 0 {
 		return ""
 	}
@@ -30,10 +29,7 @@ func GenerateCandlestickSVG(klines []AsterDexKline, width, height int) string {
 	svg.WriteString(fmt.Sprintf(`<rect width="%d" height="%d" fill="#1a1a1a"/>`, width, height))
 
 	for i, kline := range klines {
-		open, _ := strconv.ParseFloat(kline.Open, 64)
-		high, _ := strconv.ParseFloat(kline.High, 64)
-		low, _ := strconv.ParseFloat(kline.Low, 64)
-		close, _ := strconv.ParseFloat(kline.Close, 64)
+		open, high, low, close := kline.Open, kline.High, kline.Low, kline.Close
 
 		x := float64(padding) + float64(i)*candleWidth + candleWidth/2
 
@@ -65,13 +61,12 @@ func GenerateCandlestickSVG(klines []AsterDexKline, width, height int) string {
 	return svg.String()
 }
 
-func findPriceRange(klines []AsterDexKline) (float64, float64) {
+func findPriceRange(klines []Candle) (float64, float64) {
 	minPrice := math.MaxFloat64
 	maxPrice := -math.MaxFloat64
 
 	for _, kline := range klines {
-		high, _ := strconv.ParseFloat(kline.High, 64)
-		low, _ := strconv.ParseFloat(kline.Low, 64)
+		high, low := kline.High, kline.Low
 
 		if high > maxPrice {
 			maxPrice = high
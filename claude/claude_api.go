@@ -2,6 +2,7 @@ package claude
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -34,8 +35,10 @@ type ClaudeMessageRequest struct {
 	System        string         `json:"system"`
 	Messages      ClaudeMessages `json:"messages"`
 	MaxTokens     int            `json:"max_tokens"`
-	Temperature   float32        `json:"temperature,omitempty"`
+	Temperature   *float32       `json:"temperature,omitempty"`
 	StopSequences []string       `json:"stop_sequences,omitempty"`
+	Tools         []Tool         `json:"tools,omitempty"`
+	ToolChoice    *ToolChoice    `json:"tool_choice,omitempty"`
 	Thinking      *struct {
 		Type         string `json:"type"`
 		BudgetTokens int    `json:"budget_tokens,omitempty"`
@@ -49,9 +52,23 @@ type ClaudeMessage struct {
 	Content string `json:"content"`
 }
 type Content struct {
-	Type     string `json:"type"`
-	Text     string `json:"text,omitempty"`
-	ImageURL string `json:"image_url,omitempty"`
+	Type     string          `json:"type"`
+	Text     string          `json:"text,omitempty"`
+	ImageURL string          `json:"image_url,omitempty"`
+	ID       string          `json:"id,omitempty"`
+	Name     string          `json:"name,omitempty"`
+	Input    json.RawMessage `json:"input,omitempty"`
+}
+
+type Tool struct {
+	Name        string  `json:"name"`
+	Description string  `json:"description,omitempty"`
+	InputSchema *Schema `json:"input_schema"`
+}
+
+type ToolChoice struct {
+	Type string `json:"type"`
+	Name string `json:"name,omitempty"`
 }
 
 type ClaudeMessageResponse struct {
@@ -110,14 +127,71 @@ func (c *ClaudeApi) SendMessage(claudeMessages ClaudeMessages, systemMessage str
 		System:      systemMessage,
 		Messages:    claudeMessages,
 		MaxTokens:   min(c.maxTokens, MAX_TOKENS),
-		Temperature: c.temperature,
+		Temperature: &c.temperature,
 	}
 
 	log.Printf("🤖 [GRUTA_API] Sending request to API...")
 	return c.DoRequest(request)
 }
 
+// Complete implements LLM. A schema is requested through a tool the model
+// has to call, its input is the structured answer.
+func (c *ClaudeApi) Complete(ctx context.Context, request CompletionRequest) (*CompletionResponse, error) {
+	model := c.model
+	if request.Model != "" {
+		model = request.Model
+	}
+	temperature := c.temperature
+	if request.Temperature != nil {
+		temperature = *request.Temperature
+	}
+	maxTokens := c.maxTokens
+	if request.MaxTokens > 0 {
+		maxTokens = request.MaxTokens
+	}
+
+	messageRequest := ClaudeMessageRequest{
+		Model:       model,
+		System:      request.System,
+		Messages:    request.Messages,
+		MaxTokens:   min(maxTokens, MAX_TOKENS),
+		Temperature: &temperature,
+	}
+	if request.Schema != nil {
+		name := request.SchemaName
+		if name == "" {
+			name = "answer"
+		}
+		messageRequest.Tools = []Tool{{Name: name, Description: "Report the answer in this structure.", InputSchema: request.Schema}}
+		messageRequest.ToolChoice = &ToolChoice{Type: "tool", Name: name}
+	}
+
+	log.Printf("🤖 [GRUTA_API] %s request - Model: %s, Temperature: %.2f, Messages: %d", request.Purpose, model, temperature, len(request.Messages))
+	response, err := c.DoRequestContext(ctx, messageRequest)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &CompletionResponse{Model: response.Model, StopReason: response.StopReason, Usage: response.Usage, Attempts: 1}
+	if result.Model == "" {
+		result.Model = model
+	}
+	for _, content := range response.Content {
+		switch content.Type {
+		case "tool_use":
+			result.JSON = content.Input
+		case "text":
+			result.Text += content.Text
+		}
+	}
+	return result, nil
+}
+
 func (c *ClaudeApi) DoRequest(request ClaudeMessageRequest) (*ClaudeMessageResponse, error) {
+	return c.DoRequestContext(context.Background(), request)
+}
+
+func (c *ClaudeApi) DoRequestContext(ctx context.Context, request ClaudeMessageRequest) (*ClaudeMessageResponse, error) {
 	log.Printf("📤 [GRUTA_API] Marshaling request body...")
 	reqBody, err := json.Marshal(request)
 	if err != nil {
@@ -132,7 +206,7 @@ func (c *ClaudeApi) DoRequest(request ClaudeMessageRequest) (*ClaudeMessageRespo
 	}
 
 	log.Printf("🌐 [GRUTA_API] Creating HTTP request to %s", CLAUDE_API_URL)
-	httpReq, err := http.NewRequest("POST", CLAUDE_API_URL, bytes.NewBuffer(reqBody))
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", CLAUDE_API_URL, bytes.NewBuffer(reqBody))
 	if err != nil {
 		log.Printf("❌ [GRUTA_API] Error creating HTTP request: %v", err)
 		return nil, err
@@ -168,7 +242,9 @@ func (c *ClaudeApi) DoRequest(request ClaudeMessageRequest) (*ClaudeMessageRespo
 	if resp.StatusCode != 200 {
 		log.Printf("❌ [GRUTA_API] Non-200 status code: %d", resp.StatusCode)
 		log.Printf("❌ [GRUTA_API] Error response body: %s", string(body))
-		log.Printf("❌ [GRUTA_API] Error api key was: %s", c.apiKey[20:])
+		if len(c.apiKey) > 20 {
+			log.Printf("❌ [GRUTA_API] Error api key was: %s", c.apiKey[20:])
+		}
 
 		if resp.StatusCode == 529 {
 			log.Printf("⚠️ [GRUTA_API] API overloaded (529), returning special error")
@@ -203,6 +279,15 @@ func (c *ClaudeApi) DoRequest(request ClaudeMessageRequest) (*ClaudeMessageRespo
 
 	return &respData, nil
 }
+func (s *ClaudeApi) Transport() http.RoundTripper {
+	return s.client.Transport
+}
+
+// SetTransport replaces the HTTP transport, e.g. to record or replay API
+// sessions.
+func (s *ClaudeApi) SetTransport(transport http.RoundTripper) {
+	s.client.Transport = transport
+}
 func (s *ClaudeApi) SetMaxTokens(maxTokens int) {
 	s.maxTokens = maxTokens
 }
@@ -0,0 +1,112 @@
+package claude
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+)
+
+// RepairAttempts is how often CompleteJSON asks again after an answer that
+// does not match the schema.
+const RepairAttempts = 1
+
+var ErrInvalidOutput = errors.New("llm answer does not match the schema")
+
+// LLM is a chat model provider.
+type LLM interface {
+	Complete(ctx context.Context, request CompletionRequest) (*CompletionResponse, error)
+}
+
+// CompletionRequest is one call to an LLM. Empty Model, nil Temperature and
+// zero MaxTokens use the provider defaults. With a Schema the answer is
+// requested as structured output named SchemaName.
+type CompletionRequest struct {
+	Purpose     string
+	Model       string
+	Temperature *float32
+	MaxTokens   int
+	System      string
+	Messages    ClaudeMessages
+	Schema      *Schema
+	SchemaName  string
+}
+
+type CompletionResponse struct {
+	Text string
+	// JSON is the structured answer when the request had a Schema.
+	JSON       json.RawMessage
+	Model      string
+	StopReason string
+	Usage      Usage
+	// Attempts counts the calls including repairs, Usage sums all of them.
+	Attempts int
+}
+
+// Temperature returns a pointer for CompletionRequest.Temperature.
+func Temperature(value float32) *float32 {
+	return &value
+}
+
+// CompleteJSON asks for a structured answer, validates it against the
+// request schema and decodes it into T. An invalid answer is sent back with
+// the validation errors up to RepairAttempts times.
+func CompleteJSON[T any](ctx context.Context, llm LLM, request CompletionRequest) (T, *CompletionResponse, error) {
+	var result T
+	if request.Schema == nil {
+		return result, nil, fmt.Errorf("CompleteJSON needs a schema")
+	}
+
+	messages := request.Messages
+	usage := Usage{}
+	for attempt := 1; ; attempt++ {
+		request.Messages = messages
+		response, err := llm.Complete(ctx, request)
+		if response != nil {
+			usage.InputTokens += response.Usage.InputTokens
+			usage.OutputTokens += response.Usage.OutputTokens
+			response.Usage = usage
+			response.Attempts = attempt
+		}
+		if err != nil {
+			return result, response, err
+		}
+
+		raw := response.JSON
+		if len(raw) == 0 {
+			raw = ExtractJSON(response.Text)
+		}
+		err = request.Schema.Validate(raw)
+		if err == nil {
+			if err = json.Unmarshal(raw, &result); err == nil {
+				return result, response, nil
+			}
+		}
+		if attempt > RepairAttempts {
+			return result, response, fmt.Errorf("%w: %v", ErrInvalidOutput, err)
+		}
+
+		log.Printf("🔧 [LLM] %s answer invalid, asking for a repair: %v", request.Purpose, err)
+		answer := strings.TrimSpace(string(raw))
+		if answer == "" {
+			answer = "(empty answer)"
+		}
+		messages = append(messages[:len(messages):len(messages)],
+			ClaudeMessage{Role: ROLE_ASSISTANT, Content: answer},
+			ClaudeMessage{Role: ROLE_USER, Content: fmt.Sprintf("Your answer does not match the required schema: %v. Answer again with the complete corrected JSON.", err)},
+		)
+	}
+}
+
+// ExtractJSON cuts the outermost JSON object out of a text answer, dropping
+// code fences and prose around it.
+func ExtractJSON(text string) json.RawMessage {
+	start := strings.Index(text, "{")
+	end := strings.LastIndex(text, "}")
+	if start < 0 || end < start {
+		return json.RawMessage(strings.TrimSpace(text))
+	}
+	return json.RawMessage(text[start : end+1])
+}
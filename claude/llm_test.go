@@ -0,0 +1,148 @@
+package claude
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type verdict struct {
+	ShouldOpenOrder   bool    `json:"should_open_order"`
+	ConfidencePercent float64 `json:"confidence_percent"`
+	Justification     string  `json:"justification"`
+	Side              string  `json:"side"`
+}
+
+var verdictSchema = Object(
+	[]string{"should_open_order", "confidence_percent", "justification"},
+	map[string]*Schema{
+		"should_open_order":  Boolean("Whether to open the order"),
+		"confidence_percent": Number("Confidence").Between(0, 100),
+		"justification":      String("Reasoning"),
+		"side":               String("Order side", "LONG", "SHORT"),
+		"tags":               Array("Tags", Integer("")),
+	},
+)
+
+func verdictRequest() CompletionRequest {
+	return CompletionRequest{
+		Purpose:  "order_validation",
+		System:   "You validate trades.",
+		Messages: ClaudeMessages{{Role: ROLE_USER, Content: "Validate LONG GIGGLEUSDT"}},
+		Schema:   verdictSchema,
+	}
+}
+
+func TestSchemaValidate(t *testing.T) {
+	assert.NoError(t, verdictSchema.Validate([]byte(`{"should_open_order": true, "confidence_percent": 80, "justification": "ok", "side": "LONG", "tags": [1, 2]}`)))
+
+	err := verdictSchema.Validate([]byte(`{"should_open_order": "yes", "confidence_percent": 180, "side": "UP", "tags": [1.5]}`))
+	var validationErr *ValidationError
+	require.True(t, errors.As(err, &validationErr))
+	assert.Equal(t, []string{
+		`$: missing required field "justification"`,
+		`$.confidence_percent: 180 is above 100`,
+		`$.should_open_order: expected boolean`,
+		`$.side: "UP" is not one of LONG, SHORT`,
+		`$.tags[0]: expected integer, got 1.5`,
+	}, validationErr.Problems)
+
+	assert.ErrorContains(t, verdictSchema.Validate([]byte(`{"should_open_order": tru`)), "invalid JSON")
+}
+
+func TestCompleteJSON_RepairsInvalidAnswer(t *testing.T) {
+	mock := NewMockLLM(
+		`Sure! {"should_open_order": true, "confidence_percent": 180}`,
+		`{"should_open_order": true, "confidence_percent": 80, "justification": "signals aligned"}`,
+	)
+
+	result, response, err := CompleteJSON[verdict](context.Background(), mock, verdictRequest())
+	require.NoError(t, err)
+	assert.Equal(t, verdict{ShouldOpenOrder: true, ConfidencePercent: 80, Justification: "signals aligned"}, result)
+	assert.Equal(t, 2, response.Attempts)
+
+	requests := mock.Requests()
+	require.Len(t, requests, 2)
+	repair := requests[1].Messages
+	require.Len(t, repair, 3)
+	assert.Equal(t, `{"should_open_order": true, "confidence_percent": 180}`, repair[1].Content, "the invalid answer is sent back")
+	assert.Contains(t, repair[2].Content, "180 is above 100")
+	assert.Len(t, requests[0].Messages, 1, "the caller's messages are not modified")
+
+	first := requests[0]
+	firstUsage := Usage{InputTokens: (len(first.System) + len(first.Messages[0].Content) + 3) / 4}
+	assert.Greater(t, response.Usage.InputTokens, firstUsage.InputTokens, "usage sums both attempts")
+}
+
+func TestCompleteJSON_GivesUpAfterRepair(t *testing.T) {
+	mock := NewMockLLM(`{"should_open_order": 1}`, `not json at all`)
+
+	_, response, err := CompleteJSON[verdict](context.Background(), mock, verdictRequest())
+	assert.ErrorIs(t, err, ErrInvalidOutput)
+	assert.Equal(t, 1+RepairAttempts, response.Attempts)
+
+	_, _, err = CompleteJSON[verdict](context.Background(), mock, verdictRequest())
+	assert.ErrorContains(t, err, "no scripted answer")
+}
+
+func TestMockLLM_Deterministic(t *testing.T) {
+	mock := NewMockLLM()
+	mock.HandleFunc(func(request CompletionRequest) (string, error) {
+		return `{"should_open_order": false, "confidence_percent": 10, "justification": "` + request.Purpose + `"}`, nil
+	})
+
+	first, err := mock.Complete(context.Background(), verdictRequest())
+	require.NoError(t, err)
+	second, err := mock.Complete(context.Background(), verdictRequest())
+	require.NoError(t, err)
+	assert.Equal(t, first, second)
+	assert.Equal(t, MockModel, first.Model)
+	assert.JSONEq(t, `{"should_open_order": false, "confidence_percent": 10, "justification": "order_validation"}`, string(first.JSON))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = mock.Complete(ctx, verdictRequest())
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) { return f(req) }
+
+func TestClaudeApi_CompleteWithTool(t *testing.T) {
+	client, err := NewClaudeClient("sk-test", "", CLAUDE_45_MODEL)
+	require.NoError(t, err)
+
+	var sent ClaudeMessageRequest
+	client.SetTransport(roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		require.NoError(t, json.NewDecoder(req.Body).Decode(&sent))
+		body := `{"type": "message", "role": "assistant", "model": "claude-haiku-4-5", "stop_reason": "tool_use",
+			"content": [{"type": "tool_use", "id": "toolu_1", "name": "order_validation", "input": {"should_open_order": true, "confidence_percent": 75, "justification": "trend"}}],
+			"usage": {"input_tokens": 812, "output_tokens": 41}}`
+		return &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: io.NopCloser(strings.NewReader(body))}, nil
+	}))
+
+	request := verdictRequest()
+	request.SchemaName = "order_validation"
+	request.Model = "claude-haiku-4-5"
+	request.Temperature = Temperature(0)
+	result, response, err := CompleteJSON[verdict](context.Background(), client, request)
+	require.NoError(t, err)
+
+	assert.Equal(t, verdict{ShouldOpenOrder: true, ConfidencePercent: 75, Justification: "trend"}, result)
+	assert.Equal(t, "claude-haiku-4-5", sent.Model)
+	require.NotNil(t, sent.Temperature)
+	assert.Zero(t, *sent.Temperature, "a zero temperature is sent, not dropped")
+	require.Len(t, sent.Tools, 1)
+	assert.Equal(t, verdictSchema, sent.Tools[0].InputSchema)
+	assert.Equal(t, &ToolChoice{Type: "tool", Name: "order_validation"}, sent.ToolChoice)
+	assert.Equal(t, Usage{InputTokens: 812, OutputTokens: 41}, response.Usage)
+	assert.Equal(t, "claude-haiku-4-5", response.Model)
+}
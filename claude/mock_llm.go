@@ -0,0 +1,95 @@
+package claude
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+const MockModel = "mock-llm"
+
+// MockLLM is a deterministic LLM for tests. It answers with the queued
+// responses in order, then with the handler if one is set. Token usage is
+// estimated from the text length so ledgers see stable numbers.
+type MockLLM struct {
+	mu        sync.Mutex
+	responses []string
+	handler   func(CompletionRequest) (string, error)
+	requests  []CompletionRequest
+}
+
+func NewMockLLM(responses ...string) *MockLLM {
+	return &MockLLM{responses: responses}
+}
+
+// Queue adds answers. For a request with a schema the answer is the JSON
+// document, otherwise the text.
+func (m *MockLLM) Queue(responses ...string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.responses = append(m.responses, responses...)
+}
+
+// HandleFunc answers every request once the queue is empty.
+func (m *MockLLM) HandleFunc(handler func(CompletionRequest) (string, error)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.handler = handler
+}
+
+func (m *MockLLM) Requests() []CompletionRequest {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]CompletionRequest(nil), m.requests...)
+}
+
+func (m *MockLLM) Complete(ctx context.Context, request CompletionRequest) (*CompletionResponse, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	m.requests = append(m.requests, request)
+	var answer string
+	var err error
+	switch {
+	case len(m.responses) > 0:
+		answer = m.responses[0]
+		m.responses = m.responses[1:]
+	case m.handler != nil:
+		handler := m.handler
+		m.mu.Unlock()
+		answer, err = handler(request)
+		m.mu.Lock()
+	default:
+		err = fmt.Errorf("mock LLM: no scripted answer for %q", request.Purpose)
+	}
+	m.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	model := request.Model
+	if model == "" {
+		model = MockModel
+	}
+	input := len(request.System)
+	for _, message := range request.Messages {
+		input += len(message.Content)
+	}
+	response := &CompletionResponse{
+		Model:      model,
+		StopReason: "end_turn",
+		Usage:      Usage{InputTokens: (input + 3) / 4, OutputTokens: (len(answer) + 3) / 4},
+		Attempts:   1,
+	}
+	if request.Schema != nil && json.Valid([]byte(strings.TrimSpace(answer))) {
+		response.JSON = json.RawMessage(strings.TrimSpace(answer))
+		response.StopReason = "tool_use"
+	} else {
+		response.Text = answer
+	}
+	return response, nil
+}
@@ -0,0 +1,153 @@
+package claude
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Schema is the subset of JSON Schema the bot uses to describe structured
+// answers. It is sent as the tool input schema and checked locally.
+type Schema struct {
+	Type        string             `json:"type"`
+	Description string             `json:"description,omitempty"`
+	Properties  map[string]*Schema `json:"properties,omitempty"`
+	Required    []string           `json:"required,omitempty"`
+	Items       *Schema            `json:"items,omitempty"`
+	Enum        []string           `json:"enum,omitempty"`
+	Minimum     *float64           `json:"minimum,omitempty"`
+	Maximum     *float64           `json:"maximum,omitempty"`
+}
+
+func Object(required []string, properties map[string]*Schema) *Schema {
+	return &Schema{Type: "object", Properties: properties, Required: required}
+}
+
+func String(description string, enum ...string) *Schema {
+	return &Schema{Type: "string", Description: description, Enum: enum}
+}
+
+func Boolean(description string) *Schema {
+	return &Schema{Type: "boolean", Description: description}
+}
+
+func Integer(description string) *Schema {
+	return &Schema{Type: "integer", Description: description}
+}
+
+func Number(description string) *Schema {
+	return &Schema{Type: "number", Description: description}
+}
+
+func Array(description string, items *Schema) *Schema {
+	return &Schema{Type: "array", Description: description, Items: items}
+}
+
+// Between limits a number or integer schema to low..high.
+func (s *Schema) Between(low, high float64) *Schema {
+	s.Minimum = &low
+	s.Maximum = &high
+	return s
+}
+
+// ValidationError lists every place where a value breaks its schema.
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "schema validation failed: " + strings.Join(e.Problems, "; ")
+}
+
+// Validate checks a JSON document against the schema.
+func (s *Schema) Validate(data []byte) error {
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return &ValidationError{Problems: []string{fmt.Sprintf("invalid JSON: %v", err)}}
+	}
+	var problems []string
+	s.check("$", value, &problems)
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+	return nil
+}
+
+func (s *Schema) check(path string, value interface{}, problems *[]string) {
+	fail := func(format string, args ...interface{}) {
+		*problems = append(*problems, path+": "+fmt.Sprintf(format, args...))
+	}
+
+	switch s.Type {
+	case "object":
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			fail("expected object")
+			return
+		}
+		for _, name := range s.Required {
+			if _, ok := object[name]; !ok {
+				fail("missing required field %q", name)
+			}
+		}
+		names := make([]string, 0, len(s.Properties))
+		for name := range s.Properties {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if field, ok := object[name]; ok && field != nil {
+				s.Properties[name].check(path+"."+name, field, problems)
+			}
+		}
+	case "array":
+		items, ok := value.([]interface{})
+		if !ok {
+			fail("expected array")
+			return
+		}
+		if s.Items != nil {
+			for i, item := range items {
+				s.Items.check(fmt.Sprintf("%s[%d]", path, i), item, problems)
+			}
+		}
+	case "string":
+		text, ok := value.(string)
+		if !ok {
+			fail("expected string")
+			return
+		}
+		if len(s.Enum) > 0 && !contains(s.Enum, text) {
+			fail("%q is not one of %s", text, strings.Join(s.Enum, ", "))
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			fail("expected boolean")
+		}
+	case "integer", "number":
+		number, ok := value.(float64)
+		if !ok {
+			fail("expected %s", s.Type)
+			return
+		}
+		if s.Type == "integer" && number != float64(int64(number)) {
+			fail("expected integer, got %v", number)
+		}
+		if s.Minimum != nil && number < *s.Minimum {
+			fail("%v is below %v", number, *s.Minimum)
+		}
+		if s.Maximum != nil && number > *s.Maximum {
+			fail("%v is above %v", number, *s.Maximum)
+		}
+	}
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
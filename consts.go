@@ -10,6 +10,21 @@ const (
 	ENV_GRUFENDER_API_URL           = "GRUFENDER_API_URL"
 	ENV_CLAUDE_MIN_INTERVAL_MINUTES = "CLAUDE_MIN_INTERVAL_MINUTES"
 	ENV_API_EXTERNAL_SECRET         = "API_EXTERNAL_SECRET"
+	ENV_MAX_BTC_BETA_EXPOSURE       = "MAX_BTC_BETA_EXPOSURE"
+	ENV_BINANCE_KEY                 = "BINANCE_API_KEY"
+	ENV_BINANCE_SECRET              = "BINANCE_API_SECRET"
+	ENV_EXCHANGE_ENV                = "EXCHANGE_ENV"
+	ENV_EXCHANGE_BASE_URL           = "EXCHANGE_BASE_URL"
+	ENV_EXCHANGE_WS_URL             = "EXCHANGE_WS_URL"
+	ENV_EXCHANGE_RECV_WINDOW        = "EXCHANGE_RECV_WINDOW"
+	ENV_HTTP_RECORD_DIR             = "HTTP_RECORD_DIR"
+	ENV_HTTP_REPLAY_DIR             = "HTTP_REPLAY_DIR"
+	ENV_EXTERNAL_ANALYSIS_URL       = "EXTERNAL_ANALYSIS_URL"
+	ENV_EXTERNAL_ANALYSIS_MODE      = "EXTERNAL_ANALYSIS_MODE"
+	ENV_EXTERNAL_ANALYSIS_TIMEOUT   = "EXTERNAL_ANALYSIS_TIMEOUT_SECONDS"
+	ENV_CLAUDE_MODEL                = "CLAUDE_MODEL"
+	ENV_CLAUDE_TEMPERATURE          = "CLAUDE_TEMPERATURE"
+	ENV_LLM_DAILY_BUDGET            = "LLM_DAILY_BUDGET_USD"
 )
 
 const (
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"fmt"
+	"math"
+)
+
+type CorrelationCoupling string
+
+const (
+	CouplingHigh      CorrelationCoupling = "COUPLED"
+	CouplingPartial   CorrelationCoupling = "PARTIAL"
+	CouplingDecoupled CorrelationCoupling = "DECOUPLED"
+)
+
+const (
+	CorrelationWindow         = 72
+	CorrelationHighThreshold  = 0.6
+	CorrelationLowThreshold   = 0.3
+	CorrelationMinObservation = 24
+)
+
+type CorrelationAnalysis struct {
+	BTCCorrelation float64             `json:"btc_correlation"`
+	BTCBeta        float64             `json:"btc_beta"`
+	ETHCorrelation float64             `json:"eth_correlation,omitempty"`
+	ETHBeta        float64             `json:"eth_beta,omitempty"`
+	HasETH         bool                `json:"has_eth"`
+	Observations   int                 `json:"observations"`
+	Coupling       CorrelationCoupling `json:"coupling"`
+	Description    string              `json:"description"`
+}
+
+func CalculateCorrelation(coinKlines, btcKlines, ethKlines []Candle, window int) CorrelationAnalysis {
+	analysis := CorrelationAnalysis{
+		Coupling: CouplingPartial,
+	}
+
+	coinReturns, btcReturns := alignedLogReturns(coinKlines, btcKlines, window)
+	analysis.Observations = len(coinReturns)
+	if len(coinReturns) < CorrelationMinObservation {
+		analysis.Description = fmt.Sprintf("Not enough overlapping candles for correlation (%d, need %d)", len(coinReturns), CorrelationMinObservation)
+		return analysis
+	}
+
+	analysis.BTCCorrelation, analysis.BTCBeta = correlationAndBeta(coinReturns, btcReturns)
+
+	if len(ethKlines) > 0 {
+		coinEthReturns, ethReturns := alignedLogReturns(coinKlines, ethKlines, window)
+		if len(coinEthReturns) >= CorrelationMinObservation {
+			analysis.HasETH = true
+			analysis.ETHCorrelation, analysis.ETHBeta = correlationAndBeta(coinEthReturns, ethReturns)
+		}
+	}
+
+	switch {
+	case analysis.BTCCorrelation >= CorrelationHighThreshold:
+		analysis.Coupling = CouplingHigh
+	case math.Abs(analysis.BTCCorrelation) < CorrelationLowThreshold:
+		analysis.Coupling = CouplingDecoupled
+	default:
+		analysis.Coupling = CouplingPartial
+	}
+
+	analysis.Description = fmt.Sprintf("%s: BTC correlation %.2f, beta %.2f over %d candles",
+		analysis.Coupling, analysis.BTCCorrelation, analysis.BTCBeta, analysis.Observations)
+	if analysis.HasETH {
+		analysis.Description += fmt.Sprintf(", ETH correlation %.2f, beta %.2f", analysis.ETHCorrelation, analysis.ETHBeta)
+	}
+
+	return analysis
+}
+
+// alignedLogReturns matches candles by open time and returns the last
+// window log returns of both series.
+func alignedLogReturns(coinKlines, benchmarkKlines []Candle, window int) ([]float64, []float64) {
+	benchmarkCloses := make(map[int64]float64, len(benchmarkKlines))
+	for _, k := range benchmarkKlines {
+		if k.Close > 0 {
+			benchmarkCloses[k.OpenTime] = k.Close
+		}
+	}
+
+	var coinReturns, benchmarkReturns []float64
+	prevCoin, prevBenchmark := 0.0, 0.0
+	for _, k := range coinKlines {
+		coinClose := k.Close
+		benchmarkClose, ok := benchmarkCloses[k.OpenTime]
+		if coinClose <= 0 || !ok {
+			prevCoin, prevBenchmark = 0, 0
+			continue
+		}
+		if prevCoin > 0 && prevBenchmark > 0 {
+			coinReturns = append(coinReturns, math.Log(coinClose/prevCoin))
+			benchmarkReturns = append(benchmarkReturns, math.Log(benchmarkClose/prevBenchmark))
+		}
+		prevCoin, prevBenchmark = coinClose, benchmarkClose
+	}
+
+	if window > 0 && len(coinReturns) > window {
+		coinReturns = coinReturns[len(coinReturns)-window:]
+		benchmarkReturns = benchmarkReturns[len(benchmarkReturns)-window:]
+	}
+	return coinReturns, benchmarkReturns
+}
+
+func correlationAndBeta(returns, benchmark []float64) (float64, float64) {
+	n := float64(len(returns))
+	if n == 0 {
+		return 0, 0
+	}
+
+	var meanX, meanY float64
+	for i := range returns {
+		meanX += returns[i]
+		meanY += benchmark[i]
+	}
+	meanX /= n
+	meanY /= n
+
+	var covariance, varianceX, varianceY float64
+	for i := range returns {
+		dx := returns[i] - meanX
+		dy := benchmark[i] - meanY
+		covariance += dx * dy
+		varianceX += dx * dx
+		varianceY += dy * dy
+	}
+
+	if varianceX == 0 || varianceY == 0 {
+		return 0, 0
+	}
+	return covariance / math.Sqrt(varianceX*varianceY), covariance / varianceY
+}
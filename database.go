@@ -4,7 +4,9 @@ import (
 	"github.com/google/uuid"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 	"log"
+	"strings"
 	"time"
 )
 
@@ -32,6 +34,7 @@ type PositionSnapshot struct {
 	Amount           float64
 	UnrealizedPL     float64
 	MarkPrice        float64
+	LiquidationPrice float64
 	PositionOpenedAt time.Time
 	CreatedAt        time.Time `gorm:"index"`
 }
@@ -44,8 +47,16 @@ type TradingDecisionRecord struct {
 	CoinIchimoku        string
 	Activity            string
 	FudActivity         string
+	FudShare            string
+	ActivityZScore      float64
+	ActivityConfidence  float64
 	Sentiment           string
+	SentimentSource     string
 	FudAttack           string
+	Regime              string
+	Coupling            string
+	BTCCorrelation      float64
+	BTCBeta             float64
 	FinalDecision       string
 	DecisionExplanation string
 	CreatedAt           time.Time `gorm:"index"`
@@ -71,24 +82,33 @@ type PositionRecord struct {
 	Duration         int64
 	OpenReason       string
 	CloseReason      string
+	DeRiskedAt       *time.Time
+	DeRiskReason     string
+	ReducedQuantity  float64
+	MarginType       string
+	MarginAdded      float64
+	BTCCorrelation   float64
+	BTCBeta          float64
 	CreatedAt        time.Time `gorm:"index"`
 	UpdatedAt        time.Time
 }
 
 type FudAttackRecord struct {
-	ID              uint   `gorm:"primarykey"`
-	PositionUUID    string `gorm:"index"`
-	Symbol          string `gorm:"index;not null"`
-	HasAttack       bool   `gorm:"not null"`
-	Confidence      float64
-	MessageCount    int
-	FudType         string
-	Theme           string
-	StartedHoursAgo int
-	LastAttackTime  time.Time `gorm:"index"`
-	Justification   string
-	Participants    string
-	CreatedAt       time.Time `gorm:"index"`
+	ID                uint   `gorm:"primarykey"`
+	PositionUUID      string `gorm:"index"`
+	Symbol            string `gorm:"index;not null"`
+	HasAttack         bool   `gorm:"not null"`
+	Confidence        float64
+	RawConfidence     float64
+	CredibilityWeight float64
+	MessageCount      int
+	FudType           string
+	Theme             string
+	StartedHoursAgo   int
+	LastAttackTime    time.Time `gorm:"index"`
+	Justification     string
+	Participants      string
+	CreatedAt         time.Time `gorm:"index"`
 }
 
 type AIOrderValidationRecord struct {
@@ -119,16 +139,251 @@ type AiPositionCloseRecord struct {
 	CreatedAt         time.Time `gorm:"index"`
 }
 
+type MarketRegimeRecord struct {
+	ID                    uint   `gorm:"primarykey"`
+	Symbol                string `gorm:"index;not null"`
+	Regime                string `gorm:"index;not null"`
+	Confidence            float64
+	ATRPercent            float64
+	ATRRatio              float64
+	ADX                   float64
+	CloudThicknessPercent float64
+	BarsInCloud           int
+	VolumeRatio           float64
+	BTCSignal             string
+	Description           string
+	CreatedAt             time.Time `gorm:"index"`
+}
+
+type FudStateTransitionRecord struct {
+	ID                 uint   `gorm:"primarykey"`
+	Symbol             string `gorm:"index;not null"`
+	PositionUUID       string `gorm:"index"`
+	FromState          string `gorm:"not null"`
+	ToState            string `gorm:"index;not null"`
+	Strategy           string
+	Trigger            string `gorm:"not null"`
+	Details            string `gorm:"type:text"`
+	AttackConfidence   float64
+	AttackMessages     int
+	AttackParticipants int
+	CreatedAt          time.Time `gorm:"index"`
+}
+
+const (
+	FudOutcomeSourceLive     = "live"
+	FudOutcomeSourceBacktest = "backtest"
+)
+
+// FudModeOutcomeRecord is one handled FUD attack per strategy. Skipped rows
+// are attacks that expired before a position was opened.
+type FudModeOutcomeRecord struct {
+	ID              uint   `gorm:"primarykey"`
+	Symbol          string `gorm:"index;not null"`
+	Strategy        string `gorm:"index;not null"`
+	Source          string `gorm:"index;not null"`
+	PositionUUID    string `gorm:"index"`
+	Side            string
+	AttackStartedAt time.Time
+	EnteredAt       time.Time
+	ExitedAt        time.Time
+	EntryPrice      float64
+	ExitPrice       float64
+	ReturnPercent   float64
+	RealizedPL      float64
+	ExitReason      string
+	HoldMinutes     float64
+	Skipped         bool
+	CreatedAt       time.Time `gorm:"index"`
+}
+
+// ShadowPositionRecord is a virtual position of a shadow strategy, opened and
+// closed at the mark price without sending orders.
+type ShadowPositionRecord struct {
+	ID          uint      `gorm:"primarykey"`
+	UUID        string    `gorm:"uniqueIndex;not null"`
+	Symbol      string    `gorm:"index;not null"`
+	Strategy    string    `gorm:"index;not null"`
+	Side        string    `gorm:"not null"`
+	Quantity    float64   `gorm:"not null"`
+	EntryPrice  float64   `gorm:"not null"`
+	OpenedAt    time.Time `gorm:"index;not null"`
+	IsClosed    bool      `gorm:"index;default:false"`
+	ClosedAt    *time.Time
+	ClosePrice  float64
+	RealizedPL  float64
+	MaxPnL      float64 `gorm:"column:max_pnl"`
+	MinPnL      float64 `gorm:"column:min_pnl"`
+	OpenReason  string
+	CloseReason string
+	CreatedAt   time.Time `gorm:"index"`
+}
+
+type ShadowDecisionRecord struct {
+	ID                  uint   `gorm:"primarykey"`
+	PositionUUID        string `gorm:"index"`
+	Symbol              string `gorm:"index;not null"`
+	Strategy            string `gorm:"index;not null"`
+	BTCIchimoku         string
+	CoinIchimoku        string
+	Activity            string
+	FudActivity         string
+	FudShare            string
+	Sentiment           string
+	FudAttack           string
+	Regime              string
+	FinalDecision       string
+	DecisionExplanation string    `gorm:"type:text"`
+	CreatedAt           time.Time `gorm:"index"`
+}
+
+type ShadowSnapshotRecord struct {
+	ID           uint   `gorm:"primarykey"`
+	PositionUUID string `gorm:"index"`
+	Symbol       string `gorm:"index"`
+	Strategy     string `gorm:"index"`
+	Side         string
+	EntryPrice   float64
+	Amount       float64
+	UnrealizedPL float64
+	MarkPrice    float64
+	CreatedAt    time.Time `gorm:"index"`
+}
+
+type FudParticipantRecord struct {
+	ID               uint   `gorm:"primarykey"`
+	Username         string `gorm:"uniqueIndex;not null"`
+	Communities      string
+	CommunityCount   int
+	FirstSeen        time.Time `gorm:"index"`
+	LastSeen         time.Time `gorm:"index"`
+	AttackCount      int       `gorm:"index"`
+	TotalMessages    int
+	EvaluatedAttacks int
+	DropsAfter       int
+	AvgPriceMove24h  float64
+	Credibility      float64 `gorm:"default:0.5"`
+	UpdatedAt        time.Time
+}
+
+type FudParticipationRecord struct {
+	ID              uint      `gorm:"primarykey"`
+	Username        string    `gorm:"index;not null"`
+	Symbol          string    `gorm:"index;not null"`
+	CommunityID     string    `gorm:"index"`
+	AttackStartedAt time.Time `gorm:"index"`
+	LastAttackTime  time.Time
+	MessageCount    int
+	PriceAtAttack   float64
+	PriceMove24h    float64
+	Evaluated       bool `gorm:"index;default:false"`
+	CreatedAt       time.Time
+}
+
+const (
+	ActivityKindTotal = "activity"
+	ActivityKindFud   = "fud"
+)
+
+type ActivityRecord struct {
+	ID           uint      `gorm:"primarykey"`
+	Symbol       string    `gorm:"index;not null"`
+	CommunityID  string    `gorm:"uniqueIndex:idx_activity_point;not null"`
+	Kind         string    `gorm:"uniqueIndex:idx_activity_point;not null"`
+	Hour         time.Time `gorm:"uniqueIndex:idx_activity_point;not null"`
+	MessageCount int
+	UpdatedAt    time.Time
+}
+
+type SentimentRecord struct {
+	ID               uint   `gorm:"primarykey"`
+	Symbol           string `gorm:"index;not null"`
+	CommunityID      string `gorm:"index"`
+	OverallSentiment int
+	SentimentTrend   string
+	FudLevel         int
+	Confidence       float64
+	Recommendation   string    `gorm:"type:text"`
+	CreatedAt        time.Time `gorm:"index"`
+}
+
+// TweetRecord is an archived community tweet with the collector's labels.
+type TweetRecord struct {
+	ID          uint      `gorm:"primarykey"`
+	CommunityID string    `gorm:"uniqueIndex:idx_community_tweet;not null"`
+	TweetID     string    `gorm:"uniqueIndex:idx_community_tweet;not null"`
+	Text        string    `gorm:"type:text"`
+	Sentiment   int       `gorm:"index"`
+	IsFud       bool      `gorm:"index"`
+	TweetedAt   time.Time `gorm:"index"`
+	IngestedAt  time.Time
+}
+
+const (
+	AnalysisKindSentiment = "sentiment"
+	AnalysisKindFudAttack = "fud_attack"
+)
+
+// AnalysisShadowRecord compares the external sentiment or FUD-alert answer
+// with the tweet analysis of the same cycle for a pair in shadow mode.
+type AnalysisShadowRecord struct {
+	ID                 uint   `gorm:"primarykey"`
+	Symbol             string `gorm:"index;not null"`
+	CommunityID        string
+	Kind               string `gorm:"index;not null"`
+	ExternalSentiment  int
+	LocalSentiment     int
+	ExternalFudLevel   int
+	LocalFudLevel      int
+	ExternalHasAttack  bool
+	LocalHasAttack     bool
+	ExternalConfidence float64
+	LocalConfidence    float64
+	ExternalError      string    `gorm:"type:text"`
+	LocalError         string    `gorm:"type:text"`
+	CreatedAt          time.Time `gorm:"index"`
+}
+
+const (
+	LLMOutcomeOK            = "ok"
+	LLMOutcomeError         = "error"
+	LLMOutcomeInvalidOutput = "invalid_output"
+	LLMOutcomeOverBudget    = "over_budget"
+)
+
+// LLMCallRecord is one LLM request with its token usage and cost. Repair
+// attempts are separate calls.
+type LLMCallRecord struct {
+	ID           uint   `gorm:"primarykey"`
+	Purpose      string `gorm:"index;not null"`
+	Symbol       string `gorm:"index"`
+	PositionUUID string `gorm:"index"`
+	Model        string
+	InputTokens  int
+	OutputTokens int
+	CostUSD      float64
+	LatencyMs    int64
+	Outcome      string    `gorm:"index"`
+	Error        string    `gorm:"type:text"`
+	CreatedAt    time.Time `gorm:"index"`
+}
+
 var DB *gorm.DB
 
 func InitDatabase() error {
+	return OpenDatabase("trading_bot.db")
+}
+
+// OpenDatabase opens and migrates the sqlite database at dsn, tests pass an
+// in-memory DSN.
+func OpenDatabase(dsn string) error {
 	var err error
-	DB, err = gorm.Open(sqlite.Open("trading_bot.db"), &gorm.Config{})
+	DB, err = gorm.Open(sqlite.Open(dsn), &gorm.Config{})
 	if err != nil {
 		return err
 	}
 
-	return DB.AutoMigrate(&BalanceRecord{}, &PositionSnapshot{}, &TradingDecisionRecord{}, &PositionRecord{}, &FudAttackRecord{}, &AIOrderValidationRecord{}, &AiPositionCloseRecord{})
+	return DB.AutoMigrate(&BalanceRecord{}, &PositionSnapshot{}, &TradingDecisionRecord{}, &PositionRecord{}, &FudAttackRecord{}, &AIOrderValidationRecord{}, &AiPositionCloseRecord{}, &MarketRegimeRecord{}, &ActivityRecord{}, &SentimentRecord{}, &FudStateTransitionRecord{}, &FudParticipantRecord{}, &FudParticipationRecord{}, &FudModeOutcomeRecord{}, &ShadowPositionRecord{}, &ShadowDecisionRecord{}, &ShadowSnapshotRecord{}, &AnalysisShadowRecord{}, &TweetRecord{}, &LLMCallRecord{})
 }
 
 func SaveBalance(asset string, totalBalance float64, availableBalance float64) error {
@@ -195,6 +450,7 @@ func SavePositionSnapshot(position Position, markPrice float64, positionUUID str
 		Amount:           position.Amount,
 		UnrealizedPL:     position.UnrealizedPL,
 		MarkPrice:        markPrice,
+		LiquidationPrice: position.LiquidationPrice,
 		PositionOpenedAt: position.Timestamp,
 		CreatedAt:        time.Now(),
 	}
@@ -470,6 +726,24 @@ func BackfillMaxMinPnL() error {
 	return nil
 }
 
+// UpdatePositionDeRisk records a partial reduction of an open position.
+func UpdatePositionDeRisk(uuid string, reason string, quantity float64) error {
+	return DB.Model(&PositionRecord{}).
+		Where("uuid = ?", uuid).
+		Updates(map[string]interface{}{
+			"de_risked_at":     time.Now(),
+			"de_risk_reason":   reason,
+			"reduced_quantity": gorm.Expr("reduced_quantity + ?", quantity),
+		}).Error
+}
+
+// UpdatePositionMarginAdded adds an isolated margin top-up to the position.
+func UpdatePositionMarginAdded(uuid string, amount float64) error {
+	return DB.Model(&PositionRecord{}).
+		Where("uuid = ?", uuid).
+		Update("margin_added", gorm.Expr("margin_added + ?", amount)).Error
+}
+
 func GetPositionByUUID(uuid string) (PositionRecord, error) {
 	var position PositionRecord
 	err := DB.Where("uuid = ?", uuid).First(&position).Error
@@ -555,18 +829,20 @@ func SaveFudAttack(attack ClaudeFudAttackResponse, symbol string, positionUUID s
 	}
 
 	record := FudAttackRecord{
-		PositionUUID:    positionUUID,
-		Symbol:          symbol,
-		HasAttack:       attack.HasAttack,
-		Confidence:      attack.Confidence,
-		MessageCount:    attack.MessageCount,
-		FudType:         attack.FudType,
-		Theme:           attack.Theme,
-		StartedHoursAgo: attack.StartedHoursAgo,
-		LastAttackTime:  lastAttackTime,
-		Justification:   attack.Justification,
-		Participants:    participantsJSON,
-		CreatedAt:       time.Now(),
+		PositionUUID:      positionUUID,
+		Symbol:            symbol,
+		HasAttack:         attack.HasAttack,
+		Confidence:        attack.Confidence,
+		RawConfidence:     attack.RawConfidence,
+		CredibilityWeight: attack.CredibilityWeight,
+		MessageCount:      attack.MessageCount,
+		FudType:           attack.FudType,
+		Theme:             attack.Theme,
+		StartedHoursAgo:   attack.StartedHoursAgo,
+		LastAttackTime:    lastAttackTime,
+		Justification:     attack.Justification,
+		Participants:      participantsJSON,
+		CreatedAt:         time.Now(),
 	}
 	return DB.Create(&record).Error
 }
@@ -704,3 +980,554 @@ func CalculateCurrentBalance() (float64, error) {
 
 	return INITIAL_BALANCE + totalPnL, nil
 }
+
+func SaveMarketRegime(symbol string, regime RegimeAnalysis) error {
+	record := MarketRegimeRecord{
+		Symbol:                symbol,
+		Regime:                string(regime.Regime),
+		Confidence:            regime.Confidence,
+		ATRPercent:            regime.ATRPercent,
+		ATRRatio:              regime.ATRRatio,
+		ADX:                   regime.ADX,
+		CloudThicknessPercent: regime.CloudThicknessPercent,
+		BarsInCloud:           regime.BarsInCloud,
+		VolumeRatio:           regime.VolumeRatio,
+		BTCSignal:             string(regime.BTCSignal),
+		Description:           regime.Description,
+		CreatedAt:             time.Now(),
+	}
+	return DB.Create(&record).Error
+}
+
+func GetLatestMarketRegime(symbol string) (*MarketRegimeRecord, error) {
+	var record MarketRegimeRecord
+	err := DB.Where("symbol = ?", symbol).
+		Order("created_at DESC").
+		First(&record).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+func GetMarketRegimeHistory(symbol string, hoursBack int) ([]MarketRegimeRecord, error) {
+	var records []MarketRegimeRecord
+	startTime := time.Now().Add(-time.Duration(hoursBack) * time.Hour)
+	query := DB.Where("created_at >= ?", startTime)
+	if symbol != "" {
+		query = query.Where("symbol = ?", symbol)
+	}
+	err := query.Order("created_at ASC").Find(&records).Error
+	return records, err
+}
+
+// SaveActivityPoints upserts hourly message counts, the current hour is
+// re-fetched every cycle and keeps growing until it closes.
+func SaveActivityPoints(symbol string, communityID string, kind string, points []ActivityDataPoint) error {
+	if len(points) == 0 {
+		return nil
+	}
+
+	records := make([]ActivityRecord, 0, len(points))
+	for _, p := range points {
+		records = append(records, ActivityRecord{
+			Symbol:       symbol,
+			CommunityID:  communityID,
+			Kind:         kind,
+			Hour:         activityPointTime(p.Timestamp).Truncate(time.Hour),
+			MessageCount: p.MessageCount,
+			UpdatedAt:    time.Now(),
+		})
+	}
+
+	return DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "community_id"}, {Name: "kind"}, {Name: "hour"}},
+		DoUpdates: clause.AssignmentColumns([]string{"message_count", "updated_at"}),
+	}).CreateInBatches(&records, 200).Error
+}
+
+func GetActivityPoints(communityID string, kind string, from time.Time, to time.Time) ([]ActivityRecord, error) {
+	var records []ActivityRecord
+	err := DB.Where("community_id = ? AND kind = ? AND hour >= ? AND hour <= ?", communityID, kind, from, to).
+		Order("hour ASC").
+		Find(&records).Error
+	return records, err
+}
+
+// SaveTweets archives tweets of a community and skips the ones already
+// stored. It returns the number of new tweets.
+func SaveTweets(communityID string, tweets []CommunityTweet) (int, error) {
+	if len(tweets) == 0 {
+		return 0, nil
+	}
+
+	now := time.Now()
+	records := make([]TweetRecord, 0, len(tweets))
+	for _, tweet := range tweets {
+		records = append(records, TweetRecord{
+			CommunityID: communityID,
+			TweetID:     tweet.ID,
+			Text:        tweet.Text,
+			Sentiment:   tweet.Sentiment,
+			IsFud:       tweet.IsFud,
+			TweetedAt:   tweet.Date,
+			IngestedAt:  now,
+		})
+	}
+
+	result := DB.Clauses(clause.OnConflict{DoNothing: true}).CreateInBatches(&records, 200)
+	return int(result.RowsAffected), result.Error
+}
+
+// GetLatestTweetID is the highest archived tweet ID of a community, empty
+// when nothing is archived yet. IDs are compared as numbers.
+func GetLatestTweetID(communityID string) (string, error) {
+	var records []TweetRecord
+	err := DB.Where("community_id = ?", communityID).
+		Order("length(tweet_id) DESC, tweet_id DESC").
+		Limit(1).
+		Find(&records).Error
+	if err != nil || len(records) == 0 {
+		return "", err
+	}
+	return records[0].TweetID, nil
+}
+
+// TweetQuery filters the tweet archive. Zero values do not filter, Search
+// matches the text case-insensitively.
+type TweetQuery struct {
+	CommunityID string
+	From        time.Time
+	To          time.Time
+	Search      string
+	FudOnly     bool
+	Limit       int
+	Offset      int
+}
+
+// SearchTweets returns the matching tweets newest first and the total number
+// of matches.
+func SearchTweets(query TweetQuery) ([]TweetRecord, int64, error) {
+	db := DB.Model(&TweetRecord{})
+	if query.CommunityID != "" {
+		db = db.Where("community_id = ?", query.CommunityID)
+	}
+	if !query.From.IsZero() {
+		db = db.Where("tweeted_at >= ?", query.From)
+	}
+	if !query.To.IsZero() {
+		db = db.Where("tweeted_at <= ?", query.To)
+	}
+	if query.Search != "" {
+		db = db.Where("LOWER(text) LIKE ?", "%"+strings.ToLower(query.Search)+"%")
+	}
+	if query.FudOnly {
+		db = db.Where("is_fud = ?", true)
+	}
+
+	var total int64
+	if err := db.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var records []TweetRecord
+	if query.Limit > 0 {
+		db = db.Limit(query.Limit).Offset(query.Offset)
+	}
+	err := db.Order("tweeted_at DESC, length(tweet_id) DESC, tweet_id DESC").Find(&records).Error
+	return records, total, err
+}
+
+func GetTweetsInRange(communityID string, from time.Time, to time.Time) ([]TweetRecord, error) {
+	var records []TweetRecord
+	err := DB.Where("community_id = ? AND tweeted_at >= ? AND tweeted_at <= ?", communityID, from, to).
+		Order("tweeted_at ASC").
+		Find(&records).Error
+	return records, err
+}
+
+// CountTweetsSince counts the archived tweets of a community with an ID
+// above sinceID, all of them when sinceID is empty.
+func CountTweetsSince(communityID string, sinceID string) (int64, error) {
+	db := DB.Model(&TweetRecord{}).Where("community_id = ?", communityID)
+	if sinceID != "" {
+		db = db.Where("(length(tweet_id) > ? OR (length(tweet_id) = ? AND tweet_id > ?))", len(sinceID), len(sinceID), sinceID)
+	}
+	var count int64
+	err := db.Count(&count).Error
+	return count, err
+}
+
+func SaveSentiment(symbol string, communityID string, sentiment ClaudeSentimentResponse) error {
+	record := SentimentRecord{
+		Symbol:           symbol,
+		CommunityID:      communityID,
+		OverallSentiment: sentiment.OverallSentiment,
+		SentimentTrend:   sentiment.SentimentTrend,
+		FudLevel:         sentiment.FudLevel,
+		Confidence:       sentiment.Confidence,
+		Recommendation:   sentiment.Recommendation,
+		CreatedAt:        time.Now(),
+	}
+	return DB.Create(&record).Error
+}
+
+func SaveAnalysisShadow(record AnalysisShadowRecord) error {
+	if record.CreatedAt.IsZero() {
+		record.CreatedAt = time.Now()
+	}
+	return DB.Create(&record).Error
+}
+
+func GetAnalysisShadowRecords(symbol string, hoursBack int) ([]AnalysisShadowRecord, error) {
+	var records []AnalysisShadowRecord
+	startTime := time.Now().Add(-time.Duration(hoursBack) * time.Hour)
+	query := DB.Where("created_at >= ?", startTime)
+	if symbol != "" {
+		query = query.Where("symbol = ?", symbol)
+	}
+	err := query.Order("created_at DESC").Find(&records).Error
+	return records, err
+}
+
+func GetSentimentRecords(symbol string, from time.Time, to time.Time) ([]SentimentRecord, error) {
+	var records []SentimentRecord
+	err := DB.Where("symbol = ? AND created_at >= ? AND created_at <= ?", symbol, from, to).
+		Order("created_at ASC").
+		Find(&records).Error
+	return records, err
+}
+
+func GetFudAttacksInRange(symbol string, from time.Time, to time.Time) ([]FudAttackRecord, error) {
+	var attacks []FudAttackRecord
+	err := DB.Where("symbol = ? AND created_at >= ? AND created_at <= ?", symbol, from, to).
+		Order("created_at ASC").
+		Find(&attacks).Error
+	return attacks, err
+}
+
+func SaveFudStateTransition(record FudStateTransitionRecord) error {
+	return DB.Create(&record).Error
+}
+
+func GetLatestFudStateTransition(symbol string) (*FudStateTransitionRecord, error) {
+	var record FudStateTransitionRecord
+	err := DB.Where("symbol = ?", symbol).
+		Order("created_at DESC, id DESC").
+		First(&record).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+func GetFudStateTransitions(symbol string, hoursBack int) ([]FudStateTransitionRecord, error) {
+	var records []FudStateTransitionRecord
+	startTime := time.Now().Add(-time.Duration(hoursBack) * time.Hour)
+	query := DB.Where("created_at >= ?", startTime)
+	if symbol != "" {
+		query = query.Where("symbol = ?", symbol)
+	}
+	err := query.Order("created_at ASC, id ASC").Find(&records).Error
+	return records, err
+}
+
+func SaveFudModeOutcome(record FudModeOutcomeRecord) error {
+	return DB.Create(&record).Error
+}
+
+func GetFudModeOutcomes(symbol string, source string, hoursBack int) ([]FudModeOutcomeRecord, error) {
+	var records []FudModeOutcomeRecord
+	startTime := time.Now().Add(-time.Duration(hoursBack) * time.Hour)
+	query := DB.Where("created_at >= ?", startTime)
+	if symbol != "" {
+		query = query.Where("symbol = ?", symbol)
+	}
+	if source != "" {
+		query = query.Where("source = ?", source)
+	}
+	err := query.Order("created_at DESC").Find(&records).Error
+	return records, err
+}
+
+func ReplaceFudModeBacktestOutcomes(symbol string, outcomes []FudModeOutcomeRecord) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("symbol = ? AND source = ?", symbol, FudOutcomeSourceBacktest).Delete(&FudModeOutcomeRecord{}, &ShadowPositionRecord{}, &ShadowDecisionRecord{}, &ShadowSnapshotRecord{}).Error; err != nil {
+			return err
+		}
+		if len(outcomes) == 0 {
+			return nil
+		}
+		return tx.Create(&outcomes).Error
+	})
+}
+
+func SaveShadowPosition(record *ShadowPositionRecord) error {
+	return DB.Save(record).Error
+}
+
+func GetOpenShadowPosition(symbol string, strategy string) (*ShadowPositionRecord, error) {
+	var record ShadowPositionRecord
+	err := DB.Where("symbol = ? AND strategy = ? AND is_closed = ?", symbol, strategy, false).
+		Order("opened_at DESC").
+		First(&record).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+func GetShadowPositionByUUID(uuid string) (*ShadowPositionRecord, error) {
+	var record ShadowPositionRecord
+	if err := DB.Where("uuid = ?", uuid).First(&record).Error; err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+func GetShadowPositions(symbol string, hoursBack int) ([]ShadowPositionRecord, error) {
+	var records []ShadowPositionRecord
+	startTime := time.Now().Add(-time.Duration(hoursBack) * time.Hour)
+	query := DB.Where("(closed_at >= ? OR is_closed = ?)", startTime, false)
+	if symbol != "" {
+		query = query.Where("symbol = ?", symbol)
+	}
+	err := query.Order("opened_at ASC").Find(&records).Error
+	return records, err
+}
+
+func SaveShadowDecision(record *ShadowDecisionRecord) error {
+	return DB.Create(record).Error
+}
+
+func GetLatestShadowDecision(symbol string, strategy string) (*ShadowDecisionRecord, error) {
+	var record ShadowDecisionRecord
+	err := DB.Where("symbol = ? AND strategy = ?", symbol, strategy).
+		Order("created_at DESC, id DESC").
+		First(&record).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+func UpdateShadowDecisionPositionUUID(id uint, positionUUID string) error {
+	return DB.Model(&ShadowDecisionRecord{}).Where("id = ?", id).Update("position_uuid", positionUUID).Error
+}
+
+func SaveShadowSnapshot(record ShadowSnapshotRecord) error {
+	return DB.Create(&record).Error
+}
+
+func CountShadowSnapshots(positionUUID string) (int64, error) {
+	var count int64
+	err := DB.Model(&ShadowSnapshotRecord{}).Where("position_uuid = ?", positionUUID).Count(&count).Error
+	return count, err
+}
+
+// GetShadowSnapshotsByUUID returns the shadow snapshots as PositionSnapshot so
+// the exit rules run unchanged on virtual positions.
+func GetShadowSnapshotsByUUID(positionUUID string) ([]PositionSnapshot, error) {
+	var records []ShadowSnapshotRecord
+	if err := DB.Where("position_uuid = ?", positionUUID).Order("created_at ASC").Find(&records).Error; err != nil {
+		return nil, err
+	}
+	snapshots := make([]PositionSnapshot, 0, len(records))
+	for _, r := range records {
+		snapshots = append(snapshots, PositionSnapshot{
+			ID:           r.ID,
+			PositionUUID: r.PositionUUID,
+			Symbol:       r.Symbol,
+			Side:         r.Side,
+			EntryPrice:   r.EntryPrice,
+			Amount:       r.Amount,
+			UnrealizedPL: r.UnrealizedPL,
+			MarkPrice:    r.MarkPrice,
+			CreatedAt:    r.CreatedAt,
+		})
+	}
+	return snapshots, nil
+}
+
+func FindFudParticipation(username string, symbol string, startedAt time.Time, tolerance time.Duration) (*FudParticipationRecord, error) {
+	var record FudParticipationRecord
+	err := DB.Where("username = ? AND symbol = ? AND attack_started_at >= ? AND attack_started_at <= ?",
+		username, symbol, startedAt.Add(-tolerance), startedAt.Add(tolerance)).
+		Order("attack_started_at DESC").
+		First(&record).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+func SaveFudParticipation(record *FudParticipationRecord) error {
+	return DB.Save(record).Error
+}
+
+func GetPendingFudParticipations(symbol string, attackBefore time.Time, limit int) ([]FudParticipationRecord, error) {
+	var records []FudParticipationRecord
+	err := DB.Where("symbol = ? AND evaluated = ? AND last_attack_time <= ?", symbol, false, attackBefore).
+		Order("last_attack_time ASC").
+		Limit(limit).
+		Find(&records).Error
+	return records, err
+}
+
+func GetFudParticipations(hoursBack int) ([]FudParticipationRecord, error) {
+	var records []FudParticipationRecord
+	startTime := time.Now().Add(-time.Duration(hoursBack) * time.Hour)
+	err := DB.Where("last_attack_time >= ?", startTime).
+		Order("last_attack_time ASC").
+		Find(&records).Error
+	return records, err
+}
+
+// UpsertFudParticipant updates first/last seen and the community list, the
+// attack counter only grows for a new participation.
+func UpsertFudParticipant(username string, communityID string, seenAt time.Time, newAttack bool) error {
+	var participant FudParticipantRecord
+	err := DB.Where("username = ?", username).First(&participant).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return err
+	}
+	if err == gorm.ErrRecordNotFound {
+		participant = FudParticipantRecord{
+			Username:    username,
+			FirstSeen:   seenAt,
+			Credibility: ParticipantCredibility(0, 0),
+		}
+	}
+
+	if seenAt.Before(participant.FirstSeen) {
+		participant.FirstSeen = seenAt
+	}
+	if seenAt.After(participant.LastSeen) {
+		participant.LastSeen = seenAt
+	}
+	if newAttack {
+		participant.AttackCount++
+	}
+
+	communities := strings.Split(participant.Communities, ",")
+	if participant.Communities == "" {
+		communities = nil
+	}
+	found := false
+	for _, c := range communities {
+		if c == communityID {
+			found = true
+			break
+		}
+	}
+	if !found && communityID != "" {
+		communities = append(communities, communityID)
+	}
+	participant.Communities = strings.Join(communities, ",")
+	participant.CommunityCount = len(communities)
+
+	var totalMessages int64
+	DB.Model(&FudParticipationRecord{}).Where("username = ?", username).
+		Select("COALESCE(SUM(message_count), 0)").Scan(&totalMessages)
+	participant.TotalMessages = int(totalMessages)
+	participant.UpdatedAt = time.Now()
+
+	return DB.Save(&participant).Error
+}
+
+func RefreshFudParticipantStats(username string) error {
+	var participations []FudParticipationRecord
+	if err := DB.Where("username = ? AND evaluated = ?", username, true).Find(&participations).Error; err != nil {
+		return err
+	}
+
+	drops := 0
+	moveSum := 0.0
+	for _, p := range participations {
+		moveSum += p.PriceMove24h
+		if p.PriceMove24h <= ParticipantDropThreshold {
+			drops++
+		}
+	}
+	avgMove := 0.0
+	if len(participations) > 0 {
+		avgMove = moveSum / float64(len(participations))
+	}
+
+	return DB.Model(&FudParticipantRecord{}).Where("username = ?", username).Updates(map[string]interface{}{
+		"evaluated_attacks": len(participations),
+		"drops_after":       drops,
+		"avg_price_move24h": avgMove,
+		"credibility":       ParticipantCredibility(len(participations), drops),
+		"updated_at":        time.Now(),
+	}).Error
+}
+
+func GetFudParticipantsByUsernames(usernames []string) ([]FudParticipantRecord, error) {
+	var participants []FudParticipantRecord
+	if len(usernames) == 0 {
+		return participants, nil
+	}
+	err := DB.Where("username IN ?", usernames).Find(&participants).Error
+	return participants, err
+}
+
+func GetRepeatFudParticipants(minAttacks int, limit int) ([]FudParticipantRecord, error) {
+	var participants []FudParticipantRecord
+	err := DB.Where("attack_count >= ?", minAttacks).
+		Order("attack_count DESC, last_seen DESC").
+		Limit(limit).
+		Find(&participants).Error
+	return participants, err
+}
+
+func SaveLLMCall(record *LLMCallRecord) error {
+	return DB.Create(record).Error
+}
+
+func GetLLMCallsSince(since time.Time) ([]LLMCallRecord, error) {
+	var records []LLMCallRecord
+	err := DB.Where("created_at >= ?", since).Order("created_at ASC").Find(&records).Error
+	return records, err
+}
+
+func GetLLMCostSince(since time.Time) (float64, error) {
+	var cost float64
+	err := DB.Model(&LLMCallRecord{}).
+		Where("created_at >= ?", since).
+		Select("COALESCE(SUM(cost_usd), 0)").
+		Scan(&cost).Error
+	return cost, err
+}
+
+// AssignLLMCallsToPosition links the order validation calls made for a pair
+// before its position existed to the new position.
+func AssignLLMCallsToPosition(symbol string, positionUUID string, since time.Time) error {
+	return DB.Model(&LLMCallRecord{}).
+		Where("symbol = ? AND purpose = ? AND position_uuid = '' AND created_at >= ?", symbol, LLMPurposeOrderValidation, since).
+		Update("position_uuid", positionUUID).Error
+}
+
+func GetPositionsByUUIDs(uuids []string) ([]PositionRecord, error) {
+	var positions []PositionRecord
+	if len(uuids) == 0 {
+		return positions, nil
+	}
+	err := DB.Where("uuid IN ?", uuids).Find(&positions).Error
+	return positions, err
+}
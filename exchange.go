@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// Venues a TradingPair can trade on.
+const (
+	VenueAsterDex = "asterdex"
+	VenueBinance  = "binance"
+)
+
+// Candle is a venue neutral OHLCV bar, times are Unix milliseconds.
+type Candle struct {
+	OpenTime       int64
+	Open           float64
+	High           float64
+	Low            float64
+	Close          float64
+	Volume         float64
+	CloseTime      int64
+	QuoteVolume    float64
+	NumberOfTrades int
+	TakerBuyBase   float64
+	TakerBuyQuote  float64
+}
+
+// Exchange is a futures venue adapter. Positions, balances and candles are
+// returned as the venue neutral Position, AccountBalanceInfo and Candle.
+type Exchange interface {
+	Venue() string
+	OpenPosition(symbol string, side PositionSide, leverage int, quantity float64) (*Position, error)
+	ClosePosition(symbol string, side PositionSide) error
+	ReducePosition(symbol string, side PositionSide, quantity float64) error
+	GetPosition(symbol string) (*Position, error)
+	GetAllPositions() ([]*Position, error)
+	GetMarkPrice(symbol string) (float64, error)
+	GetBalance() (float64, error)
+	GetBalanceInfo() (AccountBalanceInfo, error)
+	GetAllBalances() ([]AccountBalanceInfo, error)
+	Klines(symbol string, interval string, startTime, endTime int64, limit int) ([]Candle, error)
+	SetMarginType(symbol string, marginType MarginType) error
+	AddIsolatedMargin(symbol string, side PositionSide, amount float64) error
+}
+
+// Exchanges holds one adapter per venue.
+type Exchanges map[string]Exchange
+
+func pairVenue(pair TradingPair) string {
+	if pair.Venue == "" {
+		return VenueAsterDex
+	}
+	return pair.Venue
+}
+
+// For returns the adapter of the pair's venue.
+func (e Exchanges) For(pair TradingPair) Exchange {
+	return e[pairVenue(pair)]
+}
+
+// NewExchange builds the adapter of a venue with credentials from the
+// environment.
+func NewExchange(venue, proxyDSN string) (Exchange, error) {
+	switch venue {
+	case VenueAsterDex, "":
+		return NewAsterDexExchangeWithProxy(os.Getenv(ENV_DEX_KEY), os.Getenv(ENV_DEX_SECRET), proxyDSN)
+	case VenueBinance:
+		return NewBinanceExchange(os.Getenv(ENV_BINANCE_KEY), os.Getenv(ENV_BINANCE_SECRET), proxyDSN)
+	}
+	return nil, fmt.Errorf("unknown venue %q", venue)
+}
+
+// NewExchanges builds the adapters of every venue the pairs use. AsterDex is
+// always included as the default venue.
+func NewExchanges(pairs []TradingPair, proxyDSN string) (Exchanges, error) {
+	exchanges := Exchanges{}
+	venues := []string{VenueAsterDex}
+	for _, pair := range pairs {
+		venues = append(venues, pairVenue(pair))
+	}
+	for _, venue := range venues {
+		if exchanges[venue] != nil {
+			continue
+		}
+		exchange, err := NewExchange(venue, proxyDSN)
+		if err != nil {
+			return nil, err
+		}
+		exchanges[venue] = exchange
+	}
+	return exchanges, nil
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+)
+
+// Exchange environments selected with EXCHANGE_ENV.
+const (
+	ExchangeEnvProd    = "prod"
+	ExchangeEnvTestnet = "testnet"
+	ExchangeEnvMock    = "mock"
+
+	DefaultRecvWindowMs = 5000
+)
+
+// ExchangeEndpoint is where a venue is reached in an environment.
+type ExchangeEndpoint struct {
+	BaseURL    string
+	WSURL      string
+	RecvWindow int
+}
+
+// exchangeEndpoints lists the known endpoints per venue and environment.
+// AsterDex publishes no public testnet, it needs EXCHANGE_BASE_URL.
+var exchangeEndpoints = map[string]map[string]ExchangeEndpoint{
+	VenueAsterDex: {
+		ExchangeEnvProd: {BaseURL: AsterDexBaseURL, WSURL: "wss://fstream.asterdex.com"},
+	},
+	VenueBinance: {
+		ExchangeEnvProd:    {BaseURL: BinanceFuturesBaseURL, WSURL: "wss://fstream.binance.com"},
+		ExchangeEnvTestnet: {BaseURL: "https://testnet.binancefuture.com", WSURL: "wss://fstream.binancefuture.com"},
+	},
+}
+
+func GetExchangeEnvironment() string {
+	env := os.Getenv(ENV_EXCHANGE_ENV)
+	if env == "" {
+		return ExchangeEnvProd
+	}
+	return env
+}
+
+// ResolveExchangeEndpoint returns the endpoint of a venue for EXCHANGE_ENV.
+// EXCHANGE_BASE_URL, EXCHANGE_WS_URL and EXCHANGE_RECV_WINDOW override it,
+// the mock environment starts the built-in mock server unless a base URL is
+// given.
+func ResolveExchangeEndpoint(venue string) (ExchangeEndpoint, error) {
+	env := GetExchangeEnvironment()
+	var endpoint ExchangeEndpoint
+
+	switch env {
+	case ExchangeEnvProd, ExchangeEnvTestnet:
+		endpoint = exchangeEndpoints[venue][env]
+	case ExchangeEnvMock:
+		if os.Getenv(ENV_EXCHANGE_BASE_URL) == "" {
+			baseURL, err := startLocalMockExchange()
+			if err != nil {
+				return endpoint, err
+			}
+			endpoint.BaseURL = baseURL
+		}
+	default:
+		return endpoint, fmt.Errorf("unknown exchange environment %q, use %s, %s or %s", env, ExchangeEnvProd, ExchangeEnvTestnet, ExchangeEnvMock)
+	}
+
+	if baseURL := os.Getenv(ENV_EXCHANGE_BASE_URL); baseURL != "" {
+		endpoint.BaseURL = baseURL
+	}
+	if wsURL := os.Getenv(ENV_EXCHANGE_WS_URL); wsURL != "" {
+		endpoint.WSURL = wsURL
+	}
+	endpoint.RecvWindow = getEnvAsInt(ENV_EXCHANGE_RECV_WINDOW, DefaultRecvWindowMs)
+
+	if endpoint.BaseURL == "" {
+		return endpoint, fmt.Errorf("no %s endpoint for %s, set %s", env, venue, ENV_EXCHANGE_BASE_URL)
+	}
+	return endpoint, nil
+}
+
+var (
+	localMockExchangeOnce sync.Once
+	localMockExchangeURL  string
+	localMockExchangeErr  error
+)
+
+// startLocalMockExchange runs one in-process mock server shared by all
+// venues of the mock environment.
+func startLocalMockExchange() (string, error) {
+	localMockExchangeOnce.Do(func() {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			localMockExchangeErr = fmt.Errorf("failed to start mock exchange: %w", err)
+			return
+		}
+		localMockExchangeURL = "http://" + listener.Addr().String()
+		log.Printf("Mock exchange listening on %s", localMockExchangeURL)
+		go func() {
+			if err := http.Serve(listener, NewMockFapiServer().Handler()); err != nil {
+				log.Printf("Mock exchange stopped: %v", err)
+			}
+		}()
+	})
+	return localMockExchangeURL, localMockExchangeErr
+}
+
+// runMockExchangeCommand serves the mock exchange until the process exits.
+func runMockExchangeCommand(addr string) error {
+	log.Printf("Mock exchange listening on %s (set %s=%s and %s=http://%s)", addr, ENV_EXCHANGE_ENV, ExchangeEnvMock, ENV_EXCHANGE_BASE_URL, addr)
+	return http.ListenAndServe(addr, NewMockFapiServer().Handler())
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+var (
+	ErrInsufficientMargin   = errors.New("insufficient margin")
+	ErrInvalidQuantity      = errors.New("invalid quantity")
+	ErrRateLimited          = errors.New("rate limited")
+	ErrIPBanned             = errors.New("ip banned")
+	ErrTimestampOutOfWindow = errors.New("timestamp outside recv window")
+	ErrUnknownOrder         = errors.New("unknown order")
+	ErrMarginTypeUnchanged  = errors.New("margin type unchanged")
+	ErrExchangeUnavailable  = errors.New("exchange unavailable")
+)
+
+// exchangeErrorCodes maps Binance style error codes to their typed errors.
+var exchangeErrorCodes = map[int]error{
+	-1003: ErrRateLimited,
+	-1021: ErrTimestampOutOfWindow,
+	-1111: ErrInvalidQuantity,
+	-2011: ErrUnknownOrder,
+	-2013: ErrUnknownOrder,
+	-2018: ErrInsufficientMargin,
+	-2019: ErrInsufficientMargin,
+	-4003: ErrInvalidQuantity,
+	-4005: ErrInvalidQuantity,
+	-4046: ErrMarginTypeUnchanged,
+}
+
+// ExchangeError is a non-200 response with its parsed {code,msg} body. It
+// matches the typed errors above with errors.Is.
+type ExchangeError struct {
+	StatusCode int
+	Code       int
+	Message    string
+	RetryAfter time.Duration
+	kind       error
+}
+
+func (e *ExchangeError) Error() string {
+	if e.Code != 0 {
+		return fmt.Sprintf("API error [%d] %d: %s", e.StatusCode, e.Code, e.Message)
+	}
+	return fmt.Sprintf("API error [%d]: %s", e.StatusCode, e.Message)
+}
+
+func (e *ExchangeError) Unwrap() error {
+	return e.kind
+}
+
+func parseExchangeError(statusCode int, header http.Header, body []byte) *ExchangeError {
+	exchangeErr := &ExchangeError{StatusCode: statusCode, Message: string(body)}
+
+	var payload struct {
+		Code int    `json:"code"`
+		Msg  string `json:"msg"`
+	}
+	if err := json.Unmarshal(body, &payload); err == nil && (payload.Code != 0 || payload.Msg != "") {
+		exchangeErr.Code = payload.Code
+		exchangeErr.Message = payload.Msg
+	}
+
+	if seconds, err := strconv.Atoi(header.Get("Retry-After")); err == nil && seconds > 0 {
+		exchangeErr.RetryAfter = time.Duration(seconds) * time.Second
+	}
+
+	switch {
+	case statusCode == http.StatusTeapot:
+		exchangeErr.kind = ErrIPBanned
+	case statusCode == http.StatusTooManyRequests:
+		exchangeErr.kind = ErrRateLimited
+	case exchangeErrorCodes[exchangeErr.Code] != nil:
+		exchangeErr.kind = exchangeErrorCodes[exchangeErr.Code]
+	case statusCode >= 500:
+		exchangeErr.kind = ErrExchangeUnavailable
+	}
+	return exchangeErr
+}
+
+// retryableError tells whether a failed call may be sent again. Rate limits
+// and timestamp errors are rejected before execution, so every call can be
+// retried. Server errors leave the outcome unknown and are only retried for
+// idempotent calls.
+func retryableError(err error, idempotent bool) bool {
+	if errors.Is(err, ErrRateLimited) || errors.Is(err, ErrTimestampOutOfWindow) {
+		return true
+	}
+	if !idempotent {
+		return false
+	}
+	var exchangeErr *ExchangeError
+	if errors.As(err, &exchangeErr) {
+		return errors.Is(err, ErrExchangeUnavailable)
+	}
+	return !errors.Is(err, ErrIPBanned)
+}
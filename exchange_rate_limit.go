@@ -0,0 +1,166 @@
+package main
+
+import (
+	"log"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+const (
+	// weightHeadroom keeps part of the limit free for orders.
+	weightHeadroom = 0.9
+
+	exchangeMaxAttempts    = 3
+	exchangeRetryBaseDelay = 500 * time.Millisecond
+	serverTimeSyncInterval = 30 * time.Minute
+)
+
+// fapiEndpointWeights holds the request weight of the heavier endpoints,
+// the rest weigh 1. Klines are weighed by limit in klinesWeight.
+var fapiEndpointWeights = map[string]int{
+	"/fapi/v2/positionRisk": 5,
+	"/fapi/v2/balance":      5,
+	"/fapi/v1/premiumIndex": 1,
+}
+
+// idempotentEndpoints are the signed POSTs that can be repeated safely.
+var idempotentEndpoints = map[string]bool{
+	"/fapi/v1/leverage":          true,
+	"/fapi/v1/marginType":        true,
+	"/fapi/v1/positionSide/dual": true,
+}
+
+func requestWeight(endpoint string, limit int) int {
+	if endpoint == "/fapi/v1/klines" {
+		return klinesWeight(limit)
+	}
+	if weight, ok := fapiEndpointWeights[endpoint]; ok {
+		return weight
+	}
+	return 1
+}
+
+func klinesWeight(limit int) int {
+	switch {
+	case limit <= 0:
+		// the exchange default is 500 candles
+		return 2
+	case limit < 100:
+		return 1
+	case limit < 500:
+		return 2
+	case limit <= 1000:
+		return 5
+	default:
+		return 10
+	}
+}
+
+// WeightLimiter tracks the used request weight of the current minute. The
+// exchange reports the real value in X-MBX-USED-WEIGHT-1M after every call,
+// a 429 or 418 blocks all calls until its Retry-After has passed.
+type WeightLimiter struct {
+	mu           sync.Mutex
+	limit        int
+	used         int
+	window       time.Time
+	blockedUntil time.Time
+}
+
+func NewWeightLimiter(limit int) *WeightLimiter {
+	return &WeightLimiter{limit: limit}
+}
+
+// Wait blocks until weight fits into the current minute.
+func (l *WeightLimiter) Wait(weight int) {
+	for {
+		delay := l.reserve(weight, time.Now())
+		if delay <= 0 {
+			return
+		}
+		log.Printf("Exchange rate limiter: waiting %s", delay.Round(time.Millisecond))
+		time.Sleep(delay)
+	}
+}
+
+func (l *WeightLimiter) reserve(weight int, now time.Time) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if now.Before(l.blockedUntil) {
+		return l.blockedUntil.Sub(now)
+	}
+	if window := now.Truncate(time.Minute); !window.Equal(l.window) {
+		l.window = window
+		l.used = 0
+	}
+	if l.used > 0 && float64(l.used+weight) > float64(l.limit)*weightHeadroom {
+		return l.window.Add(time.Minute).Sub(now)
+	}
+	l.used += weight
+	return 0
+}
+
+// Update takes the used weight reported by the exchange.
+func (l *WeightLimiter) Update(used int, now time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if window := now.Truncate(time.Minute); !window.Equal(l.window) {
+		l.window = window
+	}
+	l.used = used
+}
+
+// Block stops all calls until the given time.
+func (l *WeightLimiter) Block(until time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if until.After(l.blockedUntil) {
+		l.blockedUntil = until
+	}
+}
+
+// ServerClock keeps the offset between local and exchange time so signed
+// requests carry a timestamp inside the recv window.
+type ServerClock struct {
+	mu          sync.Mutex
+	offset      time.Duration
+	syncedAt    time.Time
+	attemptedAt time.Time
+}
+
+func (c *ServerClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return time.Now().Add(c.offset)
+}
+
+// ShouldSync tells whether the offset is due for a refresh. Failed syncs are
+// retried at most once a minute.
+func (c *ServerClock) ShouldSync() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	due := c.syncedAt.IsZero() || time.Since(c.syncedAt) > serverTimeSyncInterval
+	if !due || time.Since(c.attemptedAt) < time.Minute {
+		return false
+	}
+	c.attemptedAt = time.Now()
+	return true
+}
+
+// Set stores the offset from a server time read between sentAt and
+// receivedAt, assuming the server answered halfway.
+func (c *ServerClock) Set(serverTime, sentAt, receivedAt time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	local := sentAt.Add(receivedAt.Sub(sentAt) / 2)
+	c.offset = serverTime.Sub(local)
+	c.syncedAt = receivedAt
+}
+
+// retryDelay is an exponential backoff with full jitter.
+func retryDelay(attempt int) time.Duration {
+	backoff := exchangeRetryBaseDelay << attempt
+	return time.Duration(rand.Int63n(int64(backoff))) + exchangeRetryBaseDelay/2
+}
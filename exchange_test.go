@@ -0,0 +1,238 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fixtureRoute struct {
+	Status int
+	File   string
+	// Failures answers that many calls with 503 before serving the fixture.
+	Failures int
+}
+
+type fixtureRequest struct {
+	Method string
+	Path   string
+	Params url.Values
+	APIKey string
+}
+
+type fixtureServer struct {
+	*httptest.Server
+	mu       sync.Mutex
+	routes   map[string]*fixtureRoute
+	requests []fixtureRequest
+}
+
+// newFixtureServer serves recorded responses from testdata/fapi keyed by
+// "METHOD /path". /fapi/v1/time always answers with the current time.
+func newFixtureServer(t *testing.T, routes map[string]*fixtureRoute) *fixtureServer {
+	server := &fixtureServer{routes: routes}
+	server.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/fapi/v1/time" {
+			fmt.Fprintf(w, `{"serverTime":%d}`, time.Now().UnixMilli())
+			return
+		}
+
+		body, _ := io.ReadAll(r.Body)
+		params := r.URL.Query()
+		if form, err := url.ParseQuery(string(body)); err == nil {
+			for key, values := range form {
+				params[key] = values
+			}
+		}
+
+		server.mu.Lock()
+		server.requests = append(server.requests, fixtureRequest{Method: r.Method, Path: r.URL.Path, Params: params, APIKey: r.Header.Get("X-MBX-APIKEY")})
+		route := server.routes[r.Method+" "+r.URL.Path]
+		failing := route != nil && route.Failures > 0
+		if failing {
+			route.Failures--
+		}
+		server.mu.Unlock()
+
+		if route == nil {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprintf(w, `{"code":-5000,"msg":"no fixture for %s %s"}`, r.Method, r.URL.Path)
+			return
+		}
+		if failing {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		data, err := os.ReadFile(filepath.Join("testdata", "fapi", route.File))
+		if err != nil {
+			t.Errorf("fixture %s: %v", route.File, err)
+		}
+		w.Header().Set("X-MBX-USED-WEIGHT-1M", "7")
+		if route.Status != 0 {
+			w.WriteHeader(route.Status)
+		}
+		w.Write(data)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func (s *fixtureServer) requestsTo(method, path string) []fixtureRequest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var result []fixtureRequest
+	for _, request := range s.requests {
+		if request.Method == method && request.Path == path {
+			result = append(result, request)
+		}
+	}
+	return result
+}
+
+// fixtureAdapters returns every venue adapter pointed at the fixture server
+// with its own limiter and clock.
+func fixtureAdapters(t *testing.T, server *fixtureServer) map[string]*FapiExchange {
+	adapters := make(map[string]*FapiExchange)
+	for _, venue := range []string{VenueAsterDex, VenueBinance} {
+		adapter, err := newFapiExchange(venue, ExchangeEndpoint{BaseURL: server.URL, RecvWindow: DefaultRecvWindowMs},
+			"test-key", "test-secret", "", NewWeightLimiter(AsterDexWeightLimit), &ServerClock{})
+		require.NoError(t, err)
+		adapters[venue] = adapter
+	}
+	return adapters
+}
+
+func defaultFixtureRoutes() map[string]*fixtureRoute {
+	return map[string]*fixtureRoute{
+		"GET /fapi/v2/positionRisk":       {File: "positionRisk.json"},
+		"GET /fapi/v2/balance":            {File: "balance.json"},
+		"GET /fapi/v1/klines":             {File: "klines.json"},
+		"GET /fapi/v1/premiumIndex":       {File: "premiumIndex.json"},
+		"GET /fapi/v1/positionSide/dual":  {File: "positionSide_dual.json"},
+		"POST /fapi/v1/leverage":          {File: "ok.json"},
+		"POST /fapi/v1/marginType":        {Status: http.StatusBadRequest, File: "error_margin_type_unchanged.json"},
+		"POST /fapi/v1/order":             {File: "order.json"},
+		"POST /fapi/v1/positionMargin":    {File: "ok.json"},
+		"POST /fapi/v1/positionSide/dual": {File: "ok.json"},
+	}
+}
+
+func TestExchangeAdapters_ReadFixtures(t *testing.T) {
+	server := newFixtureServer(t, defaultFixtureRoutes())
+
+	for venue, exchange := range fixtureAdapters(t, server) {
+		t.Run(venue, func(t *testing.T) {
+			var adapter Exchange = exchange
+			assert.Equal(t, venue, adapter.Venue())
+
+			position, err := adapter.GetPosition("SOLUSDT")
+			require.NoError(t, err)
+			require.NotNil(t, position)
+			assert.Equal(t, PositionSideLong, position.Side)
+			assert.Equal(t, 2.5, position.Amount)
+			assert.Equal(t, 150.2, position.EntryPrice)
+			assert.Equal(t, 10, position.Leverage)
+			assert.InDelta(t, 136.81234567, position.LiquidationPrice, 1e-9)
+			assert.Equal(t, string(MarginTypeIsolated), position.MarginType)
+			assert.Equal(t, 42.05, position.IsolatedMargin)
+			assert.Equal(t, 381.0, position.Notional)
+
+			requests := server.requestsTo("GET", "/fapi/v2/positionRisk")
+			require.NotEmpty(t, requests)
+			last := requests[len(requests)-1]
+			assert.Equal(t, "test-key", last.APIKey)
+			assert.NotEmpty(t, last.Params.Get("signature"))
+			assert.NotEmpty(t, last.Params.Get("timestamp"))
+
+			balances, err := adapter.GetAllBalances()
+			require.NoError(t, err)
+			require.Len(t, balances, 2)
+			info, err := adapter.GetBalanceInfo()
+			require.NoError(t, err)
+			assert.Equal(t, 1100.25, info.AvailableBalance)
+
+			candles, err := adapter.Klines("SOLUSDT", "1h", 0, 0, 2)
+			require.NoError(t, err)
+			require.Len(t, candles, 2)
+			assert.Equal(t, Candle{
+				OpenTime: 1760003600000, Open: 150.9, High: 152.6, Low: 150.5, Close: 152.4, Volume: 980,
+				CloseTime: 1760007199999, QuoteVolume: 148700, NumberOfTrades: 701, TakerBuyBase: 510, TakerBuyQuote: 77400,
+			}, candles[1])
+
+			markPrice, err := adapter.GetMarkPrice("SOLUSDT")
+			require.NoError(t, err)
+			assert.Equal(t, 152.4, markPrice)
+		})
+	}
+}
+
+func TestExchangeAdapters_OrderFixtures(t *testing.T) {
+	server := newFixtureServer(t, defaultFixtureRoutes())
+
+	for venue, exchange := range fixtureAdapters(t, server) {
+		t.Run(venue, func(t *testing.T) {
+			before := len(server.requestsTo("POST", "/fapi/v1/order"))
+
+			position, err := exchange.OpenPosition("SOLUSDT", PositionSideLong, 10, 2.5)
+			require.NoError(t, err)
+			require.NotNil(t, position)
+
+			orders := server.requestsTo("POST", "/fapi/v1/order")
+			require.Len(t, orders, before+1)
+			order := orders[len(orders)-1].Params
+			assert.Equal(t, "BUY", order.Get("side"))
+			assert.Equal(t, "MARKET", order.Get("type"))
+			assert.Equal(t, "LONG", order.Get("positionSide"))
+			assert.Equal(t, "2.50", order.Get("quantity"))
+
+			assert.NoError(t, exchange.SetMarginType("SOLUSDT", MarginTypeIsolated), "-4046 means the margin type is already set")
+			assert.NoError(t, exchange.AddIsolatedMargin("SOLUSDT", PositionSideLong, 12.5))
+			topUps := server.requestsTo("POST", "/fapi/v1/positionMargin")
+			assert.Equal(t, "12.50", topUps[len(topUps)-1].Params.Get("amount"))
+		})
+	}
+}
+
+func TestExchangeAdapters_ErrorFixtures(t *testing.T) {
+	routes := defaultFixtureRoutes()
+	routes["POST /fapi/v1/order"] = &fixtureRoute{Status: http.StatusBadRequest, File: "error_insufficient_margin.json"}
+	server := newFixtureServer(t, routes)
+
+	for venue, exchange := range fixtureAdapters(t, server) {
+		t.Run(venue, func(t *testing.T) {
+			before := len(server.requestsTo("POST", "/fapi/v1/order"))
+
+			_, err := exchange.OpenPosition("SOLUSDT", PositionSideShort, 10, 2.5)
+			require.Error(t, err)
+			assert.True(t, errors.Is(err, ErrInsufficientMargin))
+			var exchangeErr *ExchangeError
+			require.True(t, errors.As(err, &exchangeErr))
+			assert.Equal(t, -2019, exchangeErr.Code)
+			assert.Len(t, server.requestsTo("POST", "/fapi/v1/order"), before+1, "orders are not retried")
+		})
+	}
+}
+
+func TestExchangeAdapters_RetryIdempotentCalls(t *testing.T) {
+	routes := defaultFixtureRoutes()
+	routes["GET /fapi/v1/premiumIndex"].Failures = 1
+	server := newFixtureServer(t, routes)
+
+	exchange := fixtureAdapters(t, server)[VenueBinance]
+	markPrice, err := exchange.GetMarkPrice("SOLUSDT")
+	require.NoError(t, err)
+	assert.Equal(t, 152.4, markPrice)
+	assert.Len(t, server.requestsTo("GET", "/fapi/v1/premiumIndex"), 2)
+}
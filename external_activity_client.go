@@ -28,10 +28,15 @@ type ExternalActivityClient struct {
 }
 
 func NewExternalActivityClient(baseURL string) ExternalActivityClient {
+	return NewExternalActivityClientWithTransport(baseURL, fixtureTransport("grufender_activity", http.DefaultTransport))
+}
+
+func NewExternalActivityClientWithTransport(baseURL string, transport http.RoundTripper) ExternalActivityClient {
 	return ExternalActivityClient{
 		baseURL: baseURL,
 		client: http.Client{
-			Timeout: 30 * time.Second,
+			Transport: transport,
+			Timeout:   30 * time.Second,
 		},
 	}
 }
@@ -45,13 +50,7 @@ func NewExternalActivityClientWithProxy(baseURL string, proxyDSN string) (Extern
 		}
 		transport.Proxy = http.ProxyURL(proxyURL)
 	}
-	return ExternalActivityClient{
-		baseURL: baseURL,
-		client: http.Client{
-			Transport: transport,
-			Timeout:   30 * time.Second,
-		},
-	}, nil
+	return NewExternalActivityClientWithTransport(baseURL, fixtureTransport("grufender_activity", transport)), nil
 }
 
 func (c ExternalActivityClient) GetCommunityActivity(communityID string, timestampFrom, timestampTo int64, period string) ([]ActivityDataPoint, error) {
@@ -105,88 +104,14 @@ func (c ExternalActivityClient) fetchActivity(endpoint string, timestampFrom, ti
 	return result.Data, nil
 }
 
-type ActivityTrend string
-
-const (
-	ActivityTrendSharpRise ActivityTrend = "SHARP_RISE"
-	ActivityTrendSharpDrop ActivityTrend = "SHARP_DROP"
-	ActivityTrendPlateau   ActivityTrend = "PLATEAU"
-)
-
-type ActivityAnalysis struct {
-	Trend              ActivityTrend
-	AverageCount       float64
-	RecentAverageCount float64
-	ChangePercent      float64
-}
-
-func AnalyzeActivityTrend(data []ActivityDataPoint) ActivityAnalysis {
-	if len(data) == 0 {
-		return ActivityAnalysis{
-			Trend: ActivityTrendPlateau,
-		}
-	}
-
-	if len(data) == 1 {
-		return ActivityAnalysis{
-			Trend:              ActivityTrendPlateau,
-			AverageCount:       float64(data[0].MessageCount),
-			RecentAverageCount: float64(data[0].MessageCount),
-			ChangePercent:      0,
-		}
-	}
-
-	splitPoint := len(data) / 2
-	if splitPoint == 0 {
-		splitPoint = 1
-	}
-
-	oldPeriod := data[:splitPoint]
-	recentPeriod := data[splitPoint:]
-
-	oldAvg := calculateAverage(oldPeriod)
-	recentAvg := calculateAverage(recentPeriod)
-
-	var changePercent float64
-	if oldAvg > 0 {
-		changePercent = ((recentAvg - oldAvg) / oldAvg) * 100
-	}
-
-	var trend ActivityTrend
-	if changePercent >= 50 {
-		trend = ActivityTrendSharpRise
-	} else if changePercent <= -50 {
-		trend = ActivityTrendSharpDrop
-	} else {
-		trend = ActivityTrendPlateau
-	}
-
-	return ActivityAnalysis{
-		Trend:              trend,
-		AverageCount:       oldAvg,
-		RecentAverageCount: recentAvg,
-		ChangePercent:      changePercent,
-	}
-}
-
-func AnalyzeFudActivityTrend(data []ActivityDataPoint) ActivityAnalysis {
-	return AnalyzeActivityTrend(data)
-}
-
-func calculateAverage(data []ActivityDataPoint) float64 {
-	if len(data) == 0 {
-		return 0
-	}
-
-	sum := 0
-	for _, point := range data {
-		sum += point.MessageCount
-	}
-
-	return float64(sum) / float64(len(data))
+func (c ExternalActivityClient) GetRecentTweets(communityID string, limit int) ([]CommunityTweet, error) {
+	return c.GetTweetsSince(communityID, "", limit)
 }
 
-func (c ExternalActivityClient) GetRecentTweets(communityID string, limit int) ([]CommunityTweet, error) {
+// GetTweetsSince returns up to limit of the newest tweets with an ID above
+// sinceID. The service may ignore since_id, so older tweets are dropped here
+// as well.
+func (c ExternalActivityClient) GetTweetsSince(communityID string, sinceID string, limit int) ([]CommunityTweet, error) {
 	endpoint := fmt.Sprintf("%s/api/external/community/%s/tweets", c.baseURL, communityID)
 	u, err := url.Parse(endpoint)
 	if err != nil {
@@ -197,6 +122,9 @@ func (c ExternalActivityClient) GetRecentTweets(communityID string, limit int) (
 	if limit > 0 {
 		query.Set("limit", strconv.Itoa(limit))
 	}
+	if sinceID != "" {
+		query.Set("since_id", sinceID)
+	}
 	u.RawQuery = query.Encode()
 
 	resp, err := c.client.Get(u.String())
@@ -219,5 +147,14 @@ func (c ExternalActivityClient) GetRecentTweets(communityID string, limit int) (
 		return nil, fmt.Errorf("%s: %s", result.Message, result.Error)
 	}
 
-	return result.Data, nil
+	if sinceID == "" {
+		return result.Data, nil
+	}
+	tweets := make([]CommunityTweet, 0, len(result.Data))
+	for _, tweet := range result.Data {
+		if compareTweetIDs(tweet.ID, sinceID) > 0 {
+			tweets = append(tweets, tweet)
+		}
+	}
+	return tweets, nil
 }
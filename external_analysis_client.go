@@ -0,0 +1,239 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+)
+
+const (
+	DefaultExternalAnalysisTimeout    = 120 * time.Second
+	DefaultExternalAnalysisAttempts   = 2
+	DefaultExternalAnalysisRetryDelay = 2500 * time.Millisecond
+
+	// ExternalAnalysisModeStub serves sentiment and FUD alerts from the
+	// built-in stub server instead of the Grufender service.
+	ExternalAnalysisModeStub = "stub"
+
+	externalAnalysisAPIKeyHeader = "X-API-Key"
+	externalSentimentLimit       = 50
+	externalFudAlertLimit        = 200
+)
+
+// ExternalAnalysisProvider answers the sentiment and FUD-attack questions of
+// a trading cycle for a community.
+type ExternalAnalysisProvider interface {
+	FetchSentiment(ctx context.Context, communityID string) (ClaudeSentimentResponse, error)
+	FetchFudAttack(ctx context.Context, communityID string) (ClaudeFudAttackResponse, error)
+}
+
+type ExternalAnalysisConfig struct {
+	BaseURL  string
+	APIKey   string
+	ProxyDSN string
+	Timeout  time.Duration
+	// Attempts is the number of tries for transport errors, 429 and 5xx
+	// answers, RetryDelay the wait between them.
+	Attempts   int
+	RetryDelay time.Duration
+}
+
+// ExternalAnalysisConfigFromEnv reads EXTERNAL_ANALYSIS_URL (default
+// GRUFENDER_API_URL), API_EXTERNAL_SECRET, PROXY_DSN and
+// EXTERNAL_ANALYSIS_TIMEOUT_SECONDS.
+func ExternalAnalysisConfigFromEnv() ExternalAnalysisConfig {
+	baseURL := os.Getenv(ENV_EXTERNAL_ANALYSIS_URL)
+	if baseURL == "" {
+		baseURL = os.Getenv(ENV_GRUFENDER_API_URL)
+	}
+	return ExternalAnalysisConfig{
+		BaseURL:  baseURL,
+		APIKey:   os.Getenv(ENV_API_EXTERNAL_SECRET),
+		ProxyDSN: os.Getenv(ENV_PROXY_DSN),
+		Timeout:  time.Duration(getEnvAsInt(ENV_EXTERNAL_ANALYSIS_TIMEOUT, int(DefaultExternalAnalysisTimeout/time.Second))) * time.Second,
+	}
+}
+
+type ExternalAnalysisClient struct {
+	config ExternalAnalysisConfig
+	client http.Client
+}
+
+func NewExternalAnalysisClient(config ExternalAnalysisConfig) (*ExternalAnalysisClient, error) {
+	transport := &http.Transport{}
+	if config.ProxyDSN != "" {
+		proxyURL, err := url.Parse(config.ProxyDSN)
+		if err != nil {
+			return nil, fmt.Errorf("new analysis client proxy dsn error: %s", err)
+		}
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+	return NewExternalAnalysisClientWithTransport(config, fixtureTransport("grufender_analysis", transport)), nil
+}
+
+func NewExternalAnalysisClientWithTransport(config ExternalAnalysisConfig, transport http.RoundTripper) *ExternalAnalysisClient {
+	if config.Timeout <= 0 {
+		config.Timeout = DefaultExternalAnalysisTimeout
+	}
+	if config.Attempts <= 0 {
+		config.Attempts = DefaultExternalAnalysisAttempts
+	}
+	if config.RetryDelay <= 0 {
+		config.RetryDelay = DefaultExternalAnalysisRetryDelay
+	}
+	return &ExternalAnalysisClient{
+		config: config,
+		client: http.Client{
+			Transport: transport,
+			Timeout:   config.Timeout,
+		},
+	}
+}
+
+// NewExternalAnalysisProviderFromEnv builds the provider of the trading loop.
+// EXTERNAL_ANALYSIS_MODE=stub points it at an in-process stub server for
+// paper trading and local runs.
+func NewExternalAnalysisProviderFromEnv() (ExternalAnalysisProvider, error) {
+	config := ExternalAnalysisConfigFromEnv()
+	if os.Getenv(ENV_EXTERNAL_ANALYSIS_MODE) == ExternalAnalysisModeStub {
+		baseURL, err := startLocalAnalysisStub(config.APIKey)
+		if err != nil {
+			return nil, err
+		}
+		config.BaseURL = baseURL
+		config.ProxyDSN = ""
+	}
+	if config.BaseURL == "" {
+		return nil, fmt.Errorf("no external analysis URL, set %s or %s", ENV_EXTERNAL_ANALYSIS_URL, ENV_GRUFENDER_API_URL)
+	}
+	return NewExternalAnalysisClient(config)
+}
+
+func (c *ExternalAnalysisClient) FetchSentiment(ctx context.Context, communityID string) (ClaudeSentimentResponse, error) {
+	status, body, err := c.get(ctx, fmt.Sprintf("/api/external/sentiment/%s", communityID), externalSentimentLimit)
+	if err != nil {
+		return ClaudeSentimentResponse{}, fmt.Errorf("failed to fetch sentiment analysis: %w", err)
+	}
+
+	if status != http.StatusOK {
+		return ClaudeSentimentResponse{
+			OverallSentiment: 5,
+			SentimentTrend:   "neutral",
+			Confidence:       0.0,
+			KeyThemes:        []string{},
+			Recommendation:   fmt.Sprintf("Sentiment API error (status %d): external service unavailable, raw: %s", status, string(body)),
+		}, fmt.Errorf("sentiment API error (status %d), raw: %s", status, string(body))
+	}
+
+	var sentimentResponse ClaudeSentimentResponse
+	if err := json.Unmarshal(body, &sentimentResponse); err != nil {
+		return ClaudeSentimentResponse{
+			OverallSentiment: 5,
+			SentimentTrend:   "neutral",
+			Confidence:       0.0,
+			KeyThemes:        []string{},
+			Recommendation:   fmt.Sprintf("Sentiment API parse error: %v", err),
+		}, fmt.Errorf("sentiment API parse error: %s, raw: %s", err, string(body))
+	}
+
+	return sentimentResponse, nil
+}
+
+func (c *ExternalAnalysisClient) FetchFudAttack(ctx context.Context, communityID string) (ClaudeFudAttackResponse, error) {
+	status, body, err := c.get(ctx, fmt.Sprintf("/api/external/fud-alert/%s", communityID), externalFudAlertLimit)
+	if err != nil {
+		return ClaudeFudAttackResponse{}, fmt.Errorf("failed to fetch FUD attack analysis: %w", err)
+	}
+
+	if status != http.StatusOK {
+		return ClaudeFudAttackResponse{
+			HasAttack:     false,
+			Confidence:    0.0,
+			MessageCount:  0,
+			Participants:  []FudAttackParticipant{},
+			Justification: fmt.Sprintf("FUD API error (status %d): external service unavailable, raw: %s", status, string(body)),
+		}, fmt.Errorf("FUD API error (status %d): external service unavailable, raw: %s", status, string(body))
+	}
+
+	var fudResponse ClaudeFudAttackResponse
+	if err := json.Unmarshal(body, &fudResponse); err != nil {
+		return ClaudeFudAttackResponse{
+			HasAttack:     false,
+			Confidence:    0.0,
+			MessageCount:  0,
+			Participants:  []FudAttackParticipant{},
+			Justification: fmt.Sprintf("FUD API parse error: %v", err),
+		}, fmt.Errorf("FUD API parse error: %v", err)
+	}
+
+	return fudResponse, nil
+}
+
+// get requests path with the API key header. Transport errors, 429 and 5xx
+// answers are retried until the attempts run out or ctx is done; the last
+// status and body are returned.
+func (c *ExternalAnalysisClient) get(ctx context.Context, path string, limit int) (int, []byte, error) {
+	if c.config.APIKey == "" {
+		return 0, nil, fmt.Errorf("%s not set", ENV_API_EXTERNAL_SECRET)
+	}
+
+	u, err := url.Parse(c.config.BaseURL + path)
+	if err != nil {
+		return 0, nil, err
+	}
+	query := u.Query()
+	query.Set("limit", strconv.Itoa(limit))
+	u.RawQuery = query.Encode()
+
+	var lastErr error
+	for attempt := 1; attempt <= c.config.Attempts; attempt++ {
+		if attempt > 1 {
+			select {
+			case <-ctx.Done():
+				return 0, nil, fmt.Errorf("%w after %d attempts, last error: %v", ctx.Err(), attempt-1, lastErr)
+			case <-time.After(c.config.RetryDelay):
+			}
+		}
+
+		status, body, err := c.do(ctx, u.String())
+		if err != nil {
+			if ctx.Err() != nil {
+				return 0, nil, err
+			}
+			lastErr = err
+			continue
+		}
+		if (status == http.StatusTooManyRequests || status >= 500) && attempt < c.config.Attempts {
+			lastErr = fmt.Errorf("status %d", status)
+			continue
+		}
+		return status, body, nil
+	}
+	return 0, nil, fmt.Errorf("%d attempts failed: %w", c.config.Attempts, lastErr)
+}
+
+func (c *ExternalAnalysisClient) do(ctx context.Context, rawURL string) (int, []byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return 0, nil, err
+	}
+	req.Header.Set(externalAnalysisAPIKeyHeader, c.config.APIKey)
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+	return resp.StatusCode, body, nil
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"sync"
+)
+
+// ExternalAnalysisStub serves the sentiment and FUD-alert endpoints of the
+// Grufender service from canned answers. Communities without one get a
+// neutral sentiment and no attack.
+type ExternalAnalysisStub struct {
+	apiKey string
+
+	mu         sync.Mutex
+	sentiments map[string]ClaudeSentimentResponse
+	fudAttacks map[string]ClaudeFudAttackResponse
+	requests   int
+}
+
+// NewExternalAnalysisStub answers requests carrying apiKey in the API key
+// header, an empty key accepts any request.
+func NewExternalAnalysisStub(apiKey string) *ExternalAnalysisStub {
+	return &ExternalAnalysisStub{
+		apiKey:     apiKey,
+		sentiments: make(map[string]ClaudeSentimentResponse),
+		fudAttacks: make(map[string]ClaudeFudAttackResponse),
+	}
+}
+
+func (s *ExternalAnalysisStub) SetSentiment(communityID string, sentiment ClaudeSentimentResponse) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sentiments[communityID] = sentiment
+}
+
+func (s *ExternalAnalysisStub) SetFudAttack(communityID string, attack ClaudeFudAttackResponse) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.fudAttacks[communityID] = attack
+}
+
+// Requests is the number of authorized requests served.
+func (s *ExternalAnalysisStub) Requests() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests
+}
+
+func (s *ExternalAnalysisStub) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/external/sentiment/{community}", s.authorized(s.handleSentiment))
+	mux.HandleFunc("GET /api/external/fud-alert/{community}", s.authorized(s.handleFudAlert))
+	return mux
+}
+
+func (s *ExternalAnalysisStub) authorized(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.apiKey != "" && r.Header.Get(externalAnalysisAPIKeyHeader) != s.apiKey {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprint(w, `{"status":"error","message":"invalid api key"}`)
+			return
+		}
+		s.mu.Lock()
+		s.requests++
+		s.mu.Unlock()
+		handler(w, r)
+	}
+}
+
+func (s *ExternalAnalysisStub) handleSentiment(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	sentiment, ok := s.sentiments[r.PathValue("community")]
+	s.mu.Unlock()
+	if !ok {
+		sentiment = ClaudeSentimentResponse{
+			OverallSentiment: 5,
+			SentimentTrend:   "stable",
+			Confidence:       0.5,
+			KeyThemes:        []string{},
+			Recommendation:   "stub sentiment",
+		}
+	}
+	mockJSON(w, sentiment)
+}
+
+func (s *ExternalAnalysisStub) handleFudAlert(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	attack, ok := s.fudAttacks[r.PathValue("community")]
+	s.mu.Unlock()
+	if !ok {
+		attack = ClaudeFudAttackResponse{
+			HasAttack:     false,
+			Confidence:    0.5,
+			Participants:  []FudAttackParticipant{},
+			Justification: "stub FUD alert",
+		}
+	}
+	mockJSON(w, attack)
+}
+
+// startLocalAnalysisStub runs a stub on a free local port for
+// EXTERNAL_ANALYSIS_MODE=stub and returns its base URL.
+func startLocalAnalysisStub(apiKey string) (string, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return "", fmt.Errorf("failed to start analysis stub: %w", err)
+	}
+	baseURL := "http://" + listener.Addr().String()
+	log.Printf("External analysis stub listening on %s", baseURL)
+	go func() {
+		if err := http.Serve(listener, NewExternalAnalysisStub(apiKey).Handler()); err != nil {
+			log.Printf("External analysis stub stopped: %v", err)
+		}
+	}()
+	return baseURL, nil
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/grutapig/fudtradebot/claude"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	fixtureCommunityOK        = "1969807538154811438"
+	fixtureCommunityError     = "1786006467847368871"
+	fixtureCommunityMalformed = "1938175945476555178"
+)
+
+// replayCassette loads testdata/cassettes/<name>.json.
+func replayCassette(t *testing.T, name string) *ReplayTransport {
+	replay, err := LoadReplayTransport(filepath.Join("testdata", "cassettes", name+".json"))
+	require.NoError(t, err)
+	return replay
+}
+
+func TestExternalActivityClient_Cassette(t *testing.T) {
+	client := NewExternalActivityClientWithTransport("https://grutapig.com/grufender", replayCassette(t, "grufender_activity"))
+
+	activity, err := client.GetCommunityActivity(fixtureCommunityOK, 1760000000, 1760007200, "1h")
+	require.NoError(t, err)
+	require.Len(t, activity, 3)
+	assert.Equal(t, ActivityDataPoint{Timestamp: 1760003600, MessageCount: 57}, activity[1])
+
+	_, err = client.GetCommunityFudActivity(fixtureCommunityOK, 1760000000, 1760007200, "1h")
+	assert.EqualError(t, err, "community not tracked: fud activity unavailable")
+
+	tweets, err := client.GetRecentTweets(fixtureCommunityOK, 2)
+	require.NoError(t, err)
+	require.Len(t, tweets, 2)
+	assert.True(t, tweets[1].IsFud)
+	assert.Equal(t, 2, tweets[1].Sentiment)
+	assert.Equal(t, time.Date(2025, 10, 9, 10, 15, 0, 0, time.UTC), tweets[0].Date)
+
+	_, err = client.GetRecentTweets(fixtureCommunityError, 2)
+	assert.Error(t, err, "an HTML gateway page is not a tweets response")
+}
+
+// capturingTransport records every request before passing it on.
+type capturingTransport struct {
+	next     http.RoundTripper
+	requests []*http.Request
+}
+
+func (t *capturingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.requests = append(t.requests, req)
+	return t.next.RoundTrip(req)
+}
+
+func TestExternalAnalysisClient_Cassette(t *testing.T) {
+	transport := &capturingTransport{next: replayCassette(t, "grufender_analysis")}
+	client := NewExternalAnalysisClientWithTransport(ExternalAnalysisConfig{
+		BaseURL:    "https://grutapig.com/grufender",
+		APIKey:     "live-secret",
+		RetryDelay: time.Millisecond,
+	}, transport)
+	ctx := context.Background()
+
+	t.Run("sentiment", func(t *testing.T) {
+		sentiment, err := client.FetchSentiment(ctx, fixtureCommunityOK)
+		require.NoError(t, err)
+		assert.Equal(t, 7, sentiment.OverallSentiment)
+		assert.Equal(t, 3, sentiment.FudLevel)
+		assert.Equal(t, []string{"bridge launch", "listing rumours"}, sentiment.KeyThemes)
+
+		sentiment, err = client.FetchSentiment(ctx, fixtureCommunityError)
+		assert.ErrorContains(t, err, "status 503")
+		assert.Equal(t, 5, sentiment.OverallSentiment, "errors fall back to a neutral reading")
+
+		_, err = client.FetchSentiment(ctx, fixtureCommunityMalformed)
+		assert.ErrorContains(t, err, "parse error")
+	})
+
+	t.Run("fud alert", func(t *testing.T) {
+		attack, err := client.FetchFudAttack(ctx, fixtureCommunityOK)
+		require.NoError(t, err)
+		assert.True(t, attack.HasAttack)
+		assert.Equal(t, 31, attack.MessageCount)
+		require.Len(t, attack.Participants, 2)
+		assert.Equal(t, "bear_whale", attack.Participants[0].Username)
+		require.NotNil(t, attack.LastAttackTime)
+
+		attack, err = client.FetchFudAttack(ctx, fixtureCommunityError)
+		assert.ErrorContains(t, err, "status 500")
+		assert.False(t, attack.HasAttack)
+
+		_, err = client.FetchFudAttack(ctx, fixtureCommunityMalformed)
+		assert.ErrorContains(t, err, "parse error")
+	})
+
+	require.NotEmpty(t, transport.requests)
+	for _, req := range transport.requests {
+		assert.False(t, req.URL.Query().Has("apikey"), "%s sends the key in the query", req.URL)
+		assert.Equal(t, "live-secret", req.Header.Get("X-API-Key"), req.URL.String())
+	}
+}
+
+func TestExternalAnalysisClient_Stub(t *testing.T) {
+	stub := NewExternalAnalysisStub("stub-key")
+	attackTime := time.Now().Add(-time.Hour).UTC().Truncate(time.Second)
+	stub.SetFudAttack(fixtureCommunityOK, ClaudeFudAttackResponse{HasAttack: true, Confidence: 0.9, LastAttackTime: &attackTime})
+	server := httptest.NewServer(stub.Handler())
+	defer server.Close()
+
+	client := NewExternalAnalysisClientWithTransport(ExternalAnalysisConfig{BaseURL: server.URL, APIKey: "stub-key"}, nil)
+	var provider ExternalAnalysisProvider = client
+
+	attack, err := provider.FetchFudAttack(context.Background(), fixtureCommunityOK)
+	require.NoError(t, err)
+	assert.True(t, attack.HasAttack)
+	assert.True(t, attackTime.Equal(*attack.LastAttackTime))
+
+	sentiment, err := provider.FetchSentiment(context.Background(), fixtureCommunityError)
+	require.NoError(t, err)
+	assert.Equal(t, 5, sentiment.OverallSentiment, "unscripted communities read neutral")
+	assert.Equal(t, 2, stub.Requests())
+
+	wrongKey := NewExternalAnalysisClientWithTransport(ExternalAnalysisConfig{BaseURL: server.URL, APIKey: "other"}, nil)
+	_, err = wrongKey.FetchSentiment(context.Background(), fixtureCommunityOK)
+	assert.ErrorContains(t, err, "status 401", "the key travels in a header, not the query")
+	assert.Equal(t, 2, stub.Requests())
+}
+
+func TestExternalAnalysisClient_RetriesRespectContext(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	client := NewExternalAnalysisClientWithTransport(ExternalAnalysisConfig{
+		BaseURL:    server.URL,
+		APIKey:     "key",
+		Attempts:   3,
+		RetryDelay: time.Millisecond,
+	}, nil)
+	_, err := client.FetchSentiment(context.Background(), fixtureCommunityOK)
+	assert.ErrorContains(t, err, "status 502")
+	assert.Equal(t, int32(3), calls.Load())
+
+	calls.Store(0)
+	slow := NewExternalAnalysisClientWithTransport(ExternalAnalysisConfig{
+		BaseURL:    server.URL,
+		APIKey:     "key",
+		Attempts:   3,
+		RetryDelay: time.Hour,
+	}, nil)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	started := time.Now()
+	_, err = slow.FetchFudAttack(ctx, fixtureCommunityOK)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(started), time.Second)
+	assert.Equal(t, int32(1), calls.Load())
+
+	_, err = NewExternalAnalysisClientWithTransport(ExternalAnalysisConfig{BaseURL: server.URL}, nil).FetchSentiment(context.Background(), fixtureCommunityOK)
+	assert.ErrorContains(t, err, ENV_API_EXTERNAL_SECRET+" not set")
+}
+
+func TestClaudeClient_Cassette(t *testing.T) {
+	client, err := claude.NewClaudeClient("sk-test", "", claude.CLAUDE_45_MODEL)
+	require.NoError(t, err)
+	client.SetTransport(replayCassette(t, "claude"))
+	messages := claude.ClaudeMessages{
+		{Role: claude.ROLE_USER, Content: "Validate LONG GIGGLEUSDT"},
+		{Role: claude.ROLE_ASSISTANT, Content: "{"},
+	}
+
+	response, err := client.SendMessage(messages, "You validate trades.")
+	require.NoError(t, err)
+	require.Len(t, response.Content, 1)
+	assert.True(t, strings.HasPrefix(response.Content[0].Text, `"should_open_order": true`))
+	assert.Equal(t, 812, response.Usage.InputTokens)
+
+	_, err = client.SendMessage(messages, "You validate trades.")
+	assert.ErrorContains(t, err, "invalid_request_error")
+
+	_, err = client.SendMessage(messages, "You validate trades.")
+	assert.EqualError(t, err, "gruta_overloaded_529")
+
+	_, err = client.SendMessage(messages, "You validate trades.")
+	assert.ErrorContains(t, err, "unmarshall err")
+}
+
+func TestFapiExchange_Cassette(t *testing.T) {
+	exchange, err := newFapiExchange(VenueAsterDex, ExchangeEndpoint{BaseURL: AsterDexBaseURL, RecvWindow: DefaultRecvWindowMs},
+		"live-key", "live-secret", "", NewWeightLimiter(AsterDexWeightLimit), nil)
+	require.NoError(t, err)
+	exchange.SetTransport(replayCassette(t, "fapi_asterdex"))
+
+	position, err := exchange.GetPosition("SOLUSDT")
+	require.NoError(t, err)
+	require.NotNil(t, position)
+	assert.Equal(t, PositionSideShort, position.Side)
+	assert.Equal(t, -1.2, position.Amount)
+	assert.Equal(t, 169.88, position.LiquidationPrice)
+	assert.Equal(t, string(MarginTypeCrossed), position.MarginType)
+
+	markPrice, err := exchange.GetMarkPrice("SOLUSDT")
+	require.NoError(t, err)
+	assert.Equal(t, 152.4, markPrice)
+
+	_, err = exchange.GetMarkPrice("TOSHIUSDT")
+	assert.ErrorContains(t, err, "failed to parse price value")
+
+	_, err = exchange.OpenPosition("SOLUSDT", PositionSideShort, 10, 250)
+	assert.True(t, errors.Is(err, ErrInsufficientMargin))
+}
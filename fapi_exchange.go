@@ -0,0 +1,686 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FapiExchange is the REST client of a Binance compatible USDⓈ-M futures
+// API. The venue adapters only differ in base URL, credentials and their
+// rate limiter.
+type FapiExchange struct {
+	venue      string
+	baseURL    string
+	wsURL      string
+	recvWindow int
+	apiKey     string
+	secretKey  string
+	client     *http.Client
+	limiter    *WeightLimiter
+	clock      *ServerClock
+}
+
+func newFapiExchange(venue string, endpoint ExchangeEndpoint, apiKey, secretKey, proxyDSN string, limiter *WeightLimiter, clock *ServerClock) (*FapiExchange, error) {
+	transport := &http.Transport{}
+	if proxyDSN != "" {
+		proxyURL, err := url.Parse(proxyDSN)
+		if err != nil {
+			return nil, fmt.Errorf("new %s exchange proxy dsn error: %s", venue, err)
+		}
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+	return &FapiExchange{
+		venue:      venue,
+		baseURL:    endpoint.BaseURL,
+		wsURL:      endpoint.WSURL,
+		recvWindow: endpoint.RecvWindow,
+		apiKey:     apiKey,
+		secretKey:  secretKey,
+		client: &http.Client{
+			Transport: fixtureTransport("fapi_"+venue, transport),
+			Timeout:   10 * time.Second,
+		},
+		limiter: limiter,
+		clock:   clock,
+	}, nil
+}
+
+// SetTransport replaces the HTTP transport, e.g. with a ReplayTransport.
+func (e *FapiExchange) SetTransport(transport http.RoundTripper) {
+	e.client.Transport = transport
+}
+
+func (e *FapiExchange) Venue() string {
+	return e.venue
+}
+
+// WebSocketURL is the stream endpoint of the configured environment.
+func (e *FapiExchange) WebSocketURL() string {
+	return e.wsURL
+}
+
+// Binance style API response structures
+type fapiPosition struct {
+	Symbol           string `json:"symbol"`
+	PositionSide     string `json:"positionSide"`
+	PositionAmt      string `json:"positionAmt"`
+	EntryPrice       string `json:"entryPrice"`
+	UnrealizedProfit string `json:"unRealizedProfit"`
+	Leverage         string `json:"leverage"`
+	MarkPrice        string `json:"markPrice"`
+	LiquidationPrice string `json:"liquidationPrice"`
+	MarginType       string `json:"marginType"`
+	IsolatedMargin   string `json:"isolatedMargin"`
+	Notional         string `json:"notional"`
+}
+
+// normalizeMarginType maps the lower case "isolated"/"cross" of positionRisk
+// to the values /fapi/v1/marginType takes.
+func normalizeMarginType(marginType string) string {
+	switch strings.ToUpper(marginType) {
+	case "ISOLATED":
+		return string(MarginTypeIsolated)
+	case "CROSS", "CROSSED":
+		return string(MarginTypeCrossed)
+	}
+	return strings.ToUpper(marginType)
+}
+
+func (p fapiPosition) toPosition(amount float64) *Position {
+	entryPrice, _ := strconv.ParseFloat(p.EntryPrice, 64)
+	unrealizedPL, _ := strconv.ParseFloat(p.UnrealizedProfit, 64)
+	leverage, _ := strconv.Atoi(p.Leverage)
+	liquidationPrice, _ := strconv.ParseFloat(p.LiquidationPrice, 64)
+	isolatedMargin, _ := strconv.ParseFloat(p.IsolatedMargin, 64)
+	notional, _ := strconv.ParseFloat(p.Notional, 64)
+
+	return &Position{
+		Symbol:           p.Symbol,
+		Side:             PositionSide(p.PositionSide),
+		Leverage:         leverage,
+		EntryPrice:       entryPrice,
+		Amount:           amount,
+		UnrealizedPL:     unrealizedPL,
+		LiquidationPrice: liquidationPrice,
+		MarginType:       normalizeMarginType(p.MarginType),
+		IsolatedMargin:   isolatedMargin,
+		Notional:         notional,
+		Timestamp:        time.Now(),
+	}
+}
+
+type fapiBalance struct {
+	AccountAlias       string `json:"accountAlias"`
+	Asset              string `json:"asset"`
+	Balance            string `json:"balance"`
+	CrossWalletBalance string `json:"crossWalletBalance"`
+	CrossUnPnl         string `json:"crossUnPnl"`
+	AvailableBalance   string `json:"availableBalance"`
+	MaxWithdrawAmount  string `json:"maxWithdrawAmount"`
+	MarginAvailable    bool   `json:"marginAvailable"`
+	UpdateTime         int64  `json:"updateTime"`
+}
+
+type fapiMarkPrice struct {
+	Symbol    string `json:"symbol"`
+	MarkPrice string `json:"markPrice"`
+	Time      int64  `json:"time"`
+}
+
+type fapiOrderResponse struct {
+	OrderID    int64  `json:"orderId"`
+	Symbol     string `json:"symbol"`
+	Status     string `json:"status"`
+	Side       string `json:"side"`
+	Type       string `json:"type"`
+	OrigQty    string `json:"origQty"`
+	Price      string `json:"price"`
+	AvgPrice   string `json:"avgPrice"`
+	UpdateTime int64  `json:"updateTime"`
+}
+
+// generateSignature creates HMAC SHA256 signature for signed endpoints
+func (e *FapiExchange) generateSignature(params string) string {
+	mac := hmac.New(sha256.New, []byte(e.secretKey))
+	mac.Write([]byte(params))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// doRequest performs HTTP request with authentication. Idempotent calls are
+// retried with jittered backoff, rate limited and timestamp rejections are
+// retried for every call.
+func (e *FapiExchange) doRequest(method, endpoint, params string, signed bool) ([]byte, error) {
+	idempotent := method == "GET" || idempotentEndpoints[endpoint]
+	limit, _ := strconv.Atoi(queryValue(params, "limit"))
+	weight := requestWeight(endpoint, limit)
+
+	var lastErr error
+	for attempt := 0; attempt < exchangeMaxAttempts; attempt++ {
+		if attempt > 0 {
+			delay := retryDelay(attempt - 1)
+			log.Printf("Exchange %s %s failed (%v), retry %d in %s", method, endpoint, lastErr, attempt, delay.Round(time.Millisecond))
+			time.Sleep(delay)
+		}
+
+		body, err := e.sendRequest(method, endpoint, params, signed, weight)
+		if err == nil {
+			return body, nil
+		}
+		lastErr = err
+		if errors.Is(err, ErrTimestampOutOfWindow) {
+			if err := e.SyncServerTime(); err != nil {
+				log.Printf("Exchange server time sync failed: %v", err)
+			}
+		}
+		if !retryableError(err, idempotent) {
+			return nil, err
+		}
+	}
+	return nil, lastErr
+}
+
+func (e *FapiExchange) sendRequest(method, endpoint, params string, signed bool, weight int) ([]byte, error) {
+	requestURL := e.baseURL + endpoint
+
+	if e.limiter != nil {
+		e.limiter.Wait(weight)
+	}
+
+	if signed {
+		if e.clock != nil && e.clock.ShouldSync() {
+			if err := e.SyncServerTime(); err != nil {
+				log.Printf("Exchange server time sync failed: %v", err)
+			}
+		}
+		timestamp := strconv.FormatInt(e.now().UnixMilli(), 10)
+		if params != "" {
+			params += "&timestamp=" + timestamp
+		} else {
+			params = "timestamp=" + timestamp
+		}
+		if e.recvWindow > 0 {
+			params += "&recvWindow=" + strconv.Itoa(e.recvWindow)
+		}
+		signature := e.generateSignature(params)
+		params += "&signature=" + signature
+	}
+
+	if method == "GET" && params != "" {
+		requestURL += "?" + params
+	}
+
+	var req *http.Request
+	var err error
+
+	if method == "POST" || method == "PUT" || method == "DELETE" {
+		if params != "" {
+			req, err = http.NewRequest(method, requestURL, bytes.NewBufferString(params))
+		} else {
+			req, err = http.NewRequest(method, requestURL, nil)
+		}
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	} else {
+		req, err = http.NewRequest(method, requestURL, nil)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if signed {
+		req.Header.Set("X-MBX-APIKEY", e.apiKey)
+	}
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if e.limiter != nil {
+		if used, err := strconv.Atoi(resp.Header.Get("X-MBX-USED-WEIGHT-1M")); err == nil {
+			e.limiter.Update(used, time.Now())
+		}
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		exchangeErr := parseExchangeError(resp.StatusCode, resp.Header, body)
+		if e.limiter != nil && (errors.Is(exchangeErr, ErrRateLimited) || errors.Is(exchangeErr, ErrIPBanned)) {
+			retryAfter := exchangeErr.RetryAfter
+			if retryAfter <= 0 {
+				retryAfter = time.Minute
+			}
+			e.limiter.Block(time.Now().Add(retryAfter))
+		}
+		return nil, exchangeErr
+	}
+
+	return body, nil
+}
+
+func (e *FapiExchange) now() time.Time {
+	if e.clock == nil {
+		return time.Now()
+	}
+	return e.clock.Now()
+}
+
+// SyncServerTime reads the exchange time and stores the clock offset used
+// for signed request timestamps.
+func (e *FapiExchange) SyncServerTime() error {
+	if e.clock == nil {
+		return nil
+	}
+	sentAt := time.Now()
+	body, err := e.sendRequest("GET", "/fapi/v1/time", "", false, 1)
+	if err != nil {
+		return err
+	}
+	receivedAt := time.Now()
+
+	var result struct {
+		ServerTime int64 `json:"serverTime"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return fmt.Errorf("failed to parse server time: %w", err)
+	}
+	e.clock.Set(time.UnixMilli(result.ServerTime), sentAt, receivedAt)
+	return nil
+}
+
+func queryValue(params, key string) string {
+	values, err := url.ParseQuery(params)
+	if err != nil {
+		return ""
+	}
+	return values.Get(key)
+}
+
+// GetPositionMode gets current position mode (Hedge or One-way)
+func (e *FapiExchange) GetPositionMode() (bool, error) {
+	body, err := e.doRequest("GET", "/fapi/v1/positionSide/dual", "", true)
+	if err != nil {
+		return false, err
+	}
+
+	var result struct {
+		DualSidePosition bool `json:"dualSidePosition"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return false, err
+	}
+
+	return result.DualSidePosition, nil
+}
+
+// SetPositionMode sets position mode (Hedge or One-way)
+func (e *FapiExchange) SetPositionMode(hedgeMode bool) error {
+	params := fmt.Sprintf("dualSidePosition=%t", hedgeMode)
+	_, err := e.doRequest("POST", "/fapi/v1/positionSide/dual", params, true)
+	return err
+}
+
+// SetLeverage sets leverage for a symbol
+func (e *FapiExchange) SetLeverage(symbol string, leverage int) error {
+	params := fmt.Sprintf("symbol=%s&leverage=%d", symbol, leverage)
+	_, err := e.doRequest("POST", "/fapi/v1/leverage", params, true)
+	return err
+}
+
+// SetMarginType sets ISOLATED or CROSSED margin for a symbol. The exchange
+// answers -4046 when the symbol already uses that margin type.
+func (e *FapiExchange) SetMarginType(symbol string, marginType MarginType) error {
+	params := fmt.Sprintf("symbol=%s&marginType=%s", symbol, marginType)
+	_, err := e.doRequest("POST", "/fapi/v1/marginType", params, true)
+	if errors.Is(err, ErrMarginTypeUnchanged) {
+		return nil
+	}
+	return err
+}
+
+// AddIsolatedMargin moves margin from the wallet into an isolated position.
+func (e *FapiExchange) AddIsolatedMargin(symbol string, side PositionSide, amount float64) error {
+	params := fmt.Sprintf("symbol=%s&positionSide=%s&amount=%.2f&type=1", symbol, side, amount)
+	_, err := e.doRequest("POST", "/fapi/v1/positionMargin", params, true)
+	return err
+}
+
+func (e *FapiExchange) OpenPosition(symbol string, side PositionSide, leverage int, quantity float64) (*Position, error) {
+	// Check and set position mode if needed
+	isHedgeMode, err := e.GetPositionMode()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get position mode: %w", err)
+	}
+
+	// If not in Hedge Mode and we're using LONG/SHORT, enable it
+	if !isHedgeMode && (side == PositionSideLong || side == PositionSideShort) {
+		if err := e.SetPositionMode(true); err != nil {
+			return nil, fmt.Errorf("failed to enable hedge mode: %w", err)
+		}
+	}
+
+	// Set leverage
+	if err := e.SetLeverage(symbol, leverage); err != nil {
+		return nil, fmt.Errorf("failed to set leverage: %w", err)
+	}
+
+	// Determine order side based on position side
+	var orderSide string
+	if side == PositionSideLong {
+		orderSide = "BUY"
+	} else {
+		orderSide = "SELL"
+	}
+
+	// Place market order with positionSide parameter
+	params := fmt.Sprintf("symbol=%s&side=%s&type=MARKET&quantity=%.2f&positionSide=%s",
+		symbol, orderSide, quantity, side)
+
+	body, err := e.doRequest("POST", "/fapi/v1/order", params, true)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open position: %w", err)
+	}
+
+	var orderResp fapiOrderResponse
+	if err := json.Unmarshal(body, &orderResp); err != nil {
+		return nil, fmt.Errorf("failed to parse order response: %w", err)
+	}
+
+	// Get the position info
+	position, err := e.GetPosition(symbol)
+	if err != nil {
+		return nil, fmt.Errorf("position opened but failed to fetch details: %w", err)
+	}
+
+	return position, nil
+}
+
+func (e *FapiExchange) ClosePosition(symbol string, side PositionSide) error {
+	// Get current position to know the amount
+	position, err := e.GetPosition(symbol)
+	if err != nil {
+		return fmt.Errorf("failed to get position: %w", err)
+	}
+
+	if position == nil || position.Amount == 0 {
+		return fmt.Errorf("no open position found for %s", symbol)
+	}
+
+	// Determine order side (opposite of position side)
+	var orderSide string
+	if side == PositionSideLong {
+		orderSide = "SELL"
+	} else {
+		orderSide = "BUY"
+	}
+
+	// Close position with market order
+	params := fmt.Sprintf("symbol=%s&side=%s&type=MARKET&positionSide=%s&quantity=%.8f",
+		symbol, orderSide, side, math.Abs(position.Amount))
+	fmt.Println(params)
+	_, err = e.doRequest("POST", "/fapi/v1/order", params, true)
+	if err != nil {
+		return fmt.Errorf("failed to close position: %w", err)
+	}
+
+	return nil
+}
+
+// ReducePosition closes part of a position with a market order.
+func (e *FapiExchange) ReducePosition(symbol string, side PositionSide, quantity float64) error {
+	var orderSide string
+	if side == PositionSideLong {
+		orderSide = "SELL"
+	} else {
+		orderSide = "BUY"
+	}
+
+	params := fmt.Sprintf("symbol=%s&side=%s&type=MARKET&positionSide=%s&quantity=%s",
+		symbol, orderSide, side, strconv.FormatFloat(quantity, 'f', -1, 64))
+	if _, err := e.doRequest("POST", "/fapi/v1/order", params, true); err != nil {
+		return fmt.Errorf("failed to reduce position: %w", err)
+	}
+	return nil
+}
+
+// GetPosition retrieves position information for a specific symbol
+func (e *FapiExchange) GetPosition(symbol string) (*Position, error) {
+	params := fmt.Sprintf("symbol=%s", symbol)
+	body, err := e.doRequest("GET", "/fapi/v2/positionRisk", params, true)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get position: %w", err)
+	}
+
+	var positions []fapiPosition
+	if err := json.Unmarshal(body, &positions); err != nil {
+		return nil, fmt.Errorf("failed to parse positions: %w", err)
+	}
+
+	// Find the position with non-zero amount
+	for _, pos := range positions {
+		amount, _ := strconv.ParseFloat(pos.PositionAmt, 64)
+		if amount != 0 {
+			return pos.toPosition(amount), nil
+		}
+	}
+
+	return nil, nil // No open position
+}
+
+// GetAllPositions retrieves all open positions
+func (e *FapiExchange) GetAllPositions() ([]*Position, error) {
+	body, err := e.doRequest("GET", "/fapi/v2/positionRisk", "", true)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get positions: %w", err)
+	}
+
+	var adePositions []fapiPosition
+	if err := json.Unmarshal(body, &adePositions); err != nil {
+		return nil, fmt.Errorf("failed to parse positions: %w", err)
+	}
+
+	var positions []*Position
+	for _, pos := range adePositions {
+		amount, _ := strconv.ParseFloat(pos.PositionAmt, 64)
+		if amount != 0 {
+			positions = append(positions, pos.toPosition(amount))
+		}
+	}
+
+	return positions, nil
+}
+
+func (e *FapiExchange) GetMarkPrice(symbol string) (float64, error) {
+	params := fmt.Sprintf("symbol=%s", symbol)
+	body, err := e.doRequest("GET", "/fapi/v1/premiumIndex", params, false)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get mark price: %w", err)
+	}
+
+	var markPrice fapiMarkPrice
+	if err := json.Unmarshal(body, &markPrice); err != nil {
+		return 0, fmt.Errorf("failed to parse mark price: %w", err)
+	}
+
+	price, err := strconv.ParseFloat(markPrice.MarkPrice, 64)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse price value: %w", err)
+	}
+
+	return price, nil
+}
+
+type AccountBalanceInfo struct {
+	AccountAlias       string
+	Asset              string
+	Balance            float64
+	CrossWalletBalance float64
+	CrossUnPnl         float64
+	AvailableBalance   float64
+	MaxWithdrawAmount  float64
+	MarginAvailable    bool
+	UpdateTime         int64
+}
+
+func (e *FapiExchange) GetBalance() (float64, error) {
+	infos, err := e.GetAllBalances()
+	if err != nil {
+		return 0, err
+	}
+	for _, info := range infos {
+		if info.Asset == "USDT" {
+			return info.Balance, nil
+		}
+	}
+	return 0, fmt.Errorf("USDT balance not found")
+}
+
+func (e *FapiExchange) GetBalanceInfo() (AccountBalanceInfo, error) {
+	infos, err := e.GetAllBalances()
+	if err != nil {
+		return AccountBalanceInfo{}, err
+	}
+	for _, info := range infos {
+		if info.Asset == "USDT" {
+			return info, nil
+		}
+	}
+	return AccountBalanceInfo{}, fmt.Errorf("USDT balance not found")
+}
+
+func (e *FapiExchange) GetAllBalances() ([]AccountBalanceInfo, error) {
+	body, err := e.doRequest("GET", "/fapi/v2/balance", "", true)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get balance: %w", err)
+	}
+	var balances []fapiBalance
+	if err := json.Unmarshal(body, &balances); err != nil {
+		return nil, fmt.Errorf("failed to parse balance: %w", err)
+	}
+
+	var result []AccountBalanceInfo
+	for _, bal := range balances {
+		balance, err := strconv.ParseFloat(bal.Balance, 64)
+		if err != nil {
+			continue
+		}
+
+		crossWalletBalance, _ := strconv.ParseFloat(bal.CrossWalletBalance, 64)
+		crossUnPnl, _ := strconv.ParseFloat(bal.CrossUnPnl, 64)
+		availableBalance, _ := strconv.ParseFloat(bal.AvailableBalance, 64)
+		maxWithdrawAmount, _ := strconv.ParseFloat(bal.MaxWithdrawAmount, 64)
+
+		result = append(result, AccountBalanceInfo{
+			AccountAlias:       bal.AccountAlias,
+			Asset:              bal.Asset,
+			Balance:            balance,
+			CrossWalletBalance: crossWalletBalance,
+			CrossUnPnl:         crossUnPnl,
+			AvailableBalance:   availableBalance,
+			MaxWithdrawAmount:  maxWithdrawAmount,
+			MarginAvailable:    bal.MarginAvailable,
+			UpdateTime:         bal.UpdateTime,
+		})
+	}
+
+	return result, nil
+}
+
+func (e *FapiExchange) Klines(pair string, interval string, startTime, endTime int64, limit int) ([]Candle, error) {
+	params := fmt.Sprintf("symbol=%s&interval=%s", pair, interval)
+
+	if startTime > 0 {
+		params += fmt.Sprintf("&startTime=%d", startTime)
+	}
+	if endTime > 0 {
+		params += fmt.Sprintf("&endTime=%d", endTime)
+	}
+	if limit > 0 {
+		params += fmt.Sprintf("&limit=%d", limit)
+	}
+
+	body, err := e.doRequest("GET", "/fapi/v1/klines", params, false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get klines: %w", err)
+	}
+
+	var rawKlines [][]interface{}
+	if err := json.Unmarshal(body, &rawKlines); err != nil {
+		return nil, fmt.Errorf("failed to parse klines: %w", err)
+	}
+
+	klines := make([]Candle, len(rawKlines))
+	for i, raw := range rawKlines {
+		candle, err := parseFapiKline(raw)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse kline %d: %w", i, err)
+		}
+		klines[i] = candle
+	}
+
+	return klines, nil
+}
+
+// parseFapiKline reads the [openTime, open, high, low, close, volume,
+// closeTime, quoteVolume, trades, takerBuyBase, takerBuyQuote, ...] array.
+func parseFapiKline(raw []interface{}) (Candle, error) {
+	if len(raw) < 11 {
+		return Candle{}, fmt.Errorf("expected 11 fields, got %d", len(raw))
+	}
+	var candle Candle
+	var err error
+	number := func(i int) float64 {
+		value, ok := raw[i].(float64)
+		if !ok && err == nil {
+			err = fmt.Errorf("field %d is not a number", i)
+		}
+		return value
+	}
+	decimal := func(i int) float64 {
+		text, ok := raw[i].(string)
+		if !ok {
+			if err == nil {
+				err = fmt.Errorf("field %d is not a string", i)
+			}
+			return 0
+		}
+		value, parseErr := strconv.ParseFloat(text, 64)
+		if parseErr != nil && err == nil {
+			err = fmt.Errorf("field %d: %w", i, parseErr)
+		}
+		return value
+	}
+
+	candle.OpenTime = int64(number(0))
+	candle.Open = decimal(1)
+	candle.High = decimal(2)
+	candle.Low = decimal(3)
+	candle.Close = decimal(4)
+	candle.Volume = decimal(5)
+	candle.CloseTime = int64(number(6))
+	candle.QuoteVolume = decimal(7)
+	candle.NumberOfTrades = int(number(8))
+	candle.TakerBuyBase = decimal(9)
+	candle.TakerBuyQuote = decimal(10)
+	return candle, err
+}
@@ -1,112 +1,400 @@
 package main
 
 import (
+	"fmt"
 	"log"
 	"time"
 )
 
+const fudMaxTransitionsPerCycle = 5
+
+// FudCycleInput carries the analyses the FUD state machine looks at on every
+// cycle.
+type FudCycleInput struct {
+	Attack       ClaudeFudAttackResponse
+	CoinIchimoku IchimokuAnalysis
+	FudActivity  ActivityAnalysis
+	Sentiment    ClaudeSentimentResponse
+}
+
+// processFudAttackTradingCycle advances the FUD attack state machine. It
+// returns true while the machine owns the pair, so the normal decision flow
+// is skipped for the cycle.
 func processFudAttackTradingCycle(
-	exchange AsterDexExchange,
+	exchange Exchange,
 	pair TradingPair,
 	state *TradingState,
-	lastFudAttack ClaudeFudAttackResponse,
-	coinIchimoku IchimokuAnalysis,
+	input FudCycleInput,
 ) (bool, error) {
+	if state.FudState == "" {
+		state.FudState = FudStateIdle
+	}
 
-	if !state.FudAttackMode {
-		return false, nil
+	config := GetFudModeConfig(pair)
+	lastFudAttack := input.Attack
+	// Follow-up attacks while the machine is active extend the quiet period.
+	if lastFudAttack.HasAttack && lastFudAttack.LastAttackTime != nil && lastFudAttack.LastAttackTime.After(state.FudLastAttackTime) && state.FudState != FudStateIdle {
+		state.FudLastAttackTime = *lastFudAttack.LastAttackTime
+		state.FudHandledAttackTime = *lastFudAttack.LastAttackTime
 	}
 
-	log.Printf("[%s] === FUD ATTACK MODE ACTIVE ===", pair.Symbol)
+	for i := 0; i < fudMaxTransitionsPerCycle; i++ {
+		before := state.FudState
+		if err := stepFudStateMachine(exchange, pair, state, config, input); err != nil {
+			return true, err
+		}
+		if state.FudState == before {
+			break
+		}
+	}
 
-	if lastFudAttack.LastAttackTime == nil {
-		log.Printf("[%s] FUD attack has no timestamp, skipping FUD mode", pair.Symbol)
+	if state.FudState == FudStateIdle {
 		return false, nil
 	}
 
-	timeSinceAttack := time.Since(*lastFudAttack.LastAttackTime)
-
-	if timeSinceAttack > 12*time.Hour {
-		log.Printf("[%s] More than 12 hours since FUD attack, checking exit conditions...", pair.Symbol)
-
-		coinSignal := convertIchimokuToSignal(coinIchimoku)
-		if coinSignal == SignalLong || coinSignal == SignalEmpty {
-			log.Printf("[%s] Exit FUD mode: coin signal is %s and 12+ hours passed", pair.Symbol, coinSignal)
-
-			if state.CurrentPosition == PositionSideShort {
-				if err := exchange.ClosePosition(pair.Symbol, PositionSideShort); err != nil {
-					log.Printf("[%s] Failed to close SHORT: %v", pair.Symbol, err)
-					return true, err
-				}
-
-				if state.PositionUUID != "" {
-					markPrice, _ := exchange.GetMarkPrice(pair.Symbol)
-					closedPosition, _ := exchange.GetPosition(pair.Symbol)
-					realizedPL := 0.0
-					if closedPosition != nil {
-						realizedPL = closedPosition.UnrealizedPL
-					}
-					if err := UpdatePositionClose(state.PositionUUID, markPrice, realizedPL, "fud_mode_exit"); err != nil {
-						log.Printf("[%s] Failed to update position close: %v", pair.Symbol, err)
-					}
-				}
-
-				state.CurrentPosition = PositionSideBoth
-				state.PositionUUID = ""
-				state.OpenReason = ""
+	log.Printf("[%s] === FUD ATTACK MODE (%s): %s (since %s) ===", pair.Symbol, state.FudStrategy, state.FudState, state.FudStateSince.Format("15:04:05"))
+	return true, nil
+}
+
+func stepFudStateMachine(
+	exchange Exchange,
+	pair TradingPair,
+	state *TradingState,
+	config FudModeConfig,
+	input FudCycleInput,
+) error {
+	now := time.Now()
+	lastFudAttack := input.Attack
+	coinSignal := convertIchimokuToSignal(input.CoinIchimoku)
+
+	switch state.FudState {
+	case FudStateIdle:
+		qualified, reason := qualifyFudAttack(lastFudAttack, config, now)
+		if !qualified {
+			if lastFudAttack.HasAttack {
+				log.Printf("[%s] FUD attack does not arm FUD mode: %s", pair.Symbol, reason)
 			}
+			return nil
+		}
+		if !lastFudAttack.LastAttackTime.After(state.FudHandledAttackTime) {
+			return nil
+		}
+		state.FudAttackStartTime = *lastFudAttack.LastAttackTime
+		state.FudLastAttackTime = *lastFudAttack.LastAttackTime
+		state.FudHandledAttackTime = *lastFudAttack.LastAttackTime
+		state.FudStrategy = config.Strategy
+		transitionFudState(pair, state, FudStateArmed, FudTriggerAttackDetected, reason, lastFudAttack)
 
-			state.FudAttackMode = false
-			state.FudAttackShortStarted = false
-			state.FudAttackStartTime = time.Time{}
-			log.Printf("[%s] === FUD ATTACK MODE DEACTIVATED ===", pair.Symbol)
-			return true, nil
+	case FudStateArmed:
+		if state.FudStrategy == FudStrategyContrarian {
+			state.FudPeakZScore = input.FudActivity.ZScore
+			transitionFudState(pair, state, FudStateAwaitingPeak, FudTriggerAwaitPeak,
+				fmt.Sprintf("waiting for the attack to fade (FUD z-score %.2f)", input.FudActivity.ZScore), lastFudAttack)
+			return nil
 		}
-	}
+		if now.Sub(state.FudLastAttackTime) > config.MaxAttackAge {
+			recordFudSkippedOutcome(pair, state, FudTriggerAttackExpired)
+			transitionFudState(pair, state, FudStateIdle, FudTriggerAttackExpired,
+				fmt.Sprintf("no forced SHORT within %.0f min of the attack", config.MaxAttackAge.Minutes()), lastFudAttack)
+			return nil
+		}
+		if state.CurrentPosition == PositionSideShort {
+			transitionFudState(pair, state, FudStateShortOpen, FudTriggerShortAdopted,
+				fmt.Sprintf("keeping existing SHORT %s", state.PositionUUID), lastFudAttack)
+			return nil
+		}
+		if err := openFudPosition(exchange, pair, state, PositionSideShort, "fud_attack_forced"); err != nil {
+			return err
+		}
+		transitionFudState(pair, state, FudStateShortOpen, FudTriggerShortOpened,
+			fmt.Sprintf("forced SHORT %s", state.PositionUUID), lastFudAttack)
 
-	if !state.FudAttackShortStarted {
-		coinSignal := convertIchimokuToSignal(coinIchimoku)
-		if coinSignal == SignalShort {
-			log.Printf("[%s] Coin Ichimoku SHORT detected while in FUD mode - transitioning to real SHORT", pair.Symbol)
-			state.FudAttackShortStarted = true
+	case FudStateShortOpen, FudStateRealShort:
+		if state.CurrentPosition != PositionSideShort {
+			transitionFudState(pair, state, FudStateIdle, FudTriggerPositionExternal,
+				"SHORT position is no longer open", lastFudAttack)
+			return nil
+		}
+
+		holding := now.Sub(state.OpenedAt)
+		if config.MaxHoldTime > 0 && !state.OpenedAt.IsZero() && holding > config.MaxHoldTime {
+			state.FudExitReason = "fud_mode_max_hold"
+			transitionFudState(pair, state, FudStateExiting, FudTriggerMaxHoldTime,
+				fmt.Sprintf("held %s, max %s", holding.Round(time.Minute), config.MaxHoldTime), lastFudAttack)
+			return nil
 		}
-		return true, nil
-	}
 
-	coinSignal := convertIchimokuToSignal(coinIchimoku)
-	if coinSignal == SignalLong {
-		log.Printf("[%s] Exit FUD mode: coin signal switched to LONG after real SHORT started", pair.Symbol)
+		sinceAttack := now.Sub(state.FudLastAttackTime)
+		if sinceAttack > config.QuietPeriod && coinSignal != SignalShort {
+			state.FudExitReason = "fud_mode_exit"
+			transitionFudState(pair, state, FudStateExiting, FudTriggerAttackQuiet,
+				fmt.Sprintf("%s since last attack, coin signal %s", sinceAttack.Round(time.Minute), coinSignal), lastFudAttack)
+			return nil
+		}
 
-		if state.CurrentPosition == PositionSideShort {
-			if err := exchange.ClosePosition(pair.Symbol, PositionSideShort); err != nil {
-				log.Printf("[%s] Failed to close SHORT: %v", pair.Symbol, err)
-				return true, err
+		if state.FudState == FudStateShortOpen && coinSignal == SignalShort {
+			transitionFudState(pair, state, FudStateRealShort, FudTriggerCoinShort,
+				"coin Ichimoku confirms SHORT", lastFudAttack)
+			return nil
+		}
+
+		if state.FudState == FudStateRealShort && coinSignal == SignalLong {
+			state.FudExitReason = "fud_mode_long_signal"
+			transitionFudState(pair, state, FudStateExiting, FudTriggerCoinLong,
+				"coin Ichimoku switched to LONG after real SHORT", lastFudAttack)
+			return nil
+		}
+
+		log.Printf("[%s] Holding in FUD attack mode (%s), coin signal: %s", pair.Symbol, state.FudState, coinSignal)
+
+	case FudStateAwaitingPeak:
+		if input.FudActivity.ZScore > state.FudPeakZScore {
+			state.FudPeakZScore = input.FudActivity.ZScore
+		}
+
+		waiting := now.Sub(state.FudStateSince)
+		if config.MaxPeakWait > 0 && waiting > config.MaxPeakWait {
+			recordFudSkippedOutcome(pair, state, FudTriggerPeakWaitExpired)
+			transitionFudState(pair, state, FudStateIdle, FudTriggerPeakWaitExpired,
+				fmt.Sprintf("no entry after %s", waiting.Round(time.Minute)), lastFudAttack)
+			return nil
+		}
+
+		peaked, details := fudAttackPeaked(state, config, input, now)
+		if !peaked {
+			log.Printf("[%s] Waiting for FUD attack to peak: %s", pair.Symbol, details)
+			return nil
+		}
+
+		if err := openFudPosition(exchange, pair, state, PositionSideLong, "fud_contrarian_long"); err != nil {
+			return err
+		}
+		transitionFudState(pair, state, FudStateLongOpen, FudTriggerPeakPassed,
+			fmt.Sprintf("contrarian LONG %s: %s", state.PositionUUID, details), lastFudAttack)
+
+	case FudStateLongOpen:
+		if state.CurrentPosition != PositionSideLong {
+			transitionFudState(pair, state, FudStateIdle, FudTriggerPositionExternal,
+				"LONG position is no longer open", lastFudAttack)
+			return nil
+		}
+
+		if lastFudAttack.HasAttack && state.FudLastAttackTime.After(state.OpenedAt) {
+			state.FudExitReason = "fud_contrarian_attack_resumed"
+			transitionFudState(pair, state, FudStateExiting, FudTriggerAttackResumed,
+				fmt.Sprintf("new attack at %s", state.FudLastAttackTime.Format("15:04")), lastFudAttack)
+			return nil
+		}
+
+		holding := now.Sub(state.OpenedAt)
+		if config.MaxHoldTime > 0 && !state.OpenedAt.IsZero() && holding > config.MaxHoldTime {
+			state.FudExitReason = "fud_contrarian_max_hold"
+			transitionFudState(pair, state, FudStateExiting, FudTriggerMaxHoldTime,
+				fmt.Sprintf("held %s, max %s", holding.Round(time.Minute), config.MaxHoldTime), lastFudAttack)
+			return nil
+		}
+
+		pnlPercent, err := fudPositionPnLPercent(exchange, pair)
+		if err != nil {
+			log.Printf("[%s] Failed to get contrarian LONG P/L: %v", pair.Symbol, err)
+		} else {
+			if config.StopLossPercent > 0 && pnlPercent <= -config.StopLossPercent {
+				state.FudExitReason = "fud_contrarian_stop_loss"
+				transitionFudState(pair, state, FudStateExiting, FudTriggerStopLoss,
+					fmt.Sprintf("P/L %.2f%% below -%.2f%%", pnlPercent, config.StopLossPercent), lastFudAttack)
+				return nil
+			}
+			if config.TakeProfitPercent > 0 && pnlPercent >= config.TakeProfitPercent {
+				state.FudExitReason = "fud_contrarian_take_profit"
+				transitionFudState(pair, state, FudStateExiting, FudTriggerTakeProfit,
+					fmt.Sprintf("P/L %.2f%% above %.2f%%", pnlPercent, config.TakeProfitPercent), lastFudAttack)
+				return nil
 			}
+		}
 
-			if state.PositionUUID != "" {
-				markPrice, _ := exchange.GetMarkPrice(pair.Symbol)
-				closedPosition, _ := exchange.GetPosition(pair.Symbol)
-				realizedPL := 0.0
-				if closedPosition != nil {
-					realizedPL = closedPosition.UnrealizedPL
-				}
-				if err := UpdatePositionClose(state.PositionUUID, markPrice, realizedPL, "fud_mode_long_signal"); err != nil {
-					log.Printf("[%s] Failed to update position close: %v", pair.Symbol, err)
-				}
+		if coinSignal == SignalShort {
+			state.FudExitReason = "fud_contrarian_short_signal"
+			transitionFudState(pair, state, FudStateExiting, FudTriggerCoinShort,
+				"coin Ichimoku switched to SHORT", lastFudAttack)
+			return nil
+		}
+
+		log.Printf("[%s] Holding contrarian LONG (P/L %.2f%%), coin signal: %s", pair.Symbol, pnlPercent, coinSignal)
+
+	case FudStateExiting:
+		reason := state.FudExitReason
+		if reason == "" {
+			reason = "fud_mode_exit"
+		}
+		side := PositionSideShort
+		if state.FudStrategy == FudStrategyContrarian {
+			side = PositionSideLong
+		}
+		if state.CurrentPosition == side {
+			positionUUID := state.PositionUUID
+			exitPrice, realizedPL, err := closeStatePosition(exchange, pair, state, side, reason)
+			if err != nil {
+				return err
 			}
+			recordFudOutcome(pair, state, positionUUID, side, exitPrice, realizedPL, reason)
+		}
+		transitionFudState(pair, state, FudStateIdle, FudTriggerPositionClosed, reason, lastFudAttack)
+	}
+
+	return nil
+}
+
+// fudAttackPeaked decides whether a contrarian entry is allowed: the attack
+// went quiet, FUD activity fell well below its peak, sentiment is no longer
+// declining and the coin Ichimoku stopped pointing down.
+func fudAttackPeaked(state *TradingState, config FudModeConfig, input FudCycleInput, now time.Time) (bool, string) {
+	sinceAttack := now.Sub(state.FudLastAttackTime)
+	fudFalling := state.FudPeakZScore > 0 && input.FudActivity.Trend != ActivityTrendSharpRise &&
+		input.FudActivity.ZScore <= state.FudPeakZScore*config.PeakDecayRatio
+	sentimentStable := input.Sentiment.SentimentTrend != "declining"
+	coinSignal := convertIchimokuToSignal(input.CoinIchimoku)
+	ichimokuStable := coinSignal != SignalShort
+
+	details := fmt.Sprintf("%s since last attack (need %s), FUD z-score %.2f vs peak %.2f, sentiment %s, coin %s",
+		sinceAttack.Round(time.Minute), config.PeakQuietPeriod, input.FudActivity.ZScore, state.FudPeakZScore,
+		input.Sentiment.SentimentTrend, coinSignal)
 
-			state.CurrentPosition = PositionSideBoth
-			state.PositionUUID = ""
-			state.OpenReason = ""
+	return sinceAttack >= config.PeakQuietPeriod && fudFalling && sentimentStable && ichimokuStable, details
+}
+
+func fudPositionPnLPercent(exchange Exchange, pair TradingPair) (float64, error) {
+	position, err := exchange.GetPosition(pair.Symbol)
+	if err != nil {
+		return 0, err
+	}
+	if position == nil || position.EntryPrice == 0 {
+		return 0, fmt.Errorf("no open position")
+	}
+	markPrice, err := exchange.GetMarkPrice(pair.Symbol)
+	if err != nil {
+		return 0, err
+	}
+
+	change := (markPrice - position.EntryPrice) / position.EntryPrice * 100
+	if position.Side == PositionSideShort {
+		change = -change
+	}
+	return change, nil
+}
+
+func openFudPosition(exchange Exchange, pair TradingPair, state *TradingState, side PositionSide, reason string) error {
+	log.Printf("[%s] 🚨 FUD ATTACK MODE: Opening %s position (%s)", pair.Symbol, side, reason)
+
+	if state.CurrentPosition != PositionSideBoth {
+		log.Printf("[%s] Closing existing %s position", pair.Symbol, state.CurrentPosition)
+		if _, _, err := closeStatePosition(exchange, pair, state, state.CurrentPosition, "fud_mode_switch"); err != nil {
+			return err
 		}
+	}
 
-		state.FudAttackMode = false
-		state.FudAttackShortStarted = false
-		state.FudAttackStartTime = time.Time{}
-		log.Printf("[%s] === FUD ATTACK MODE DEACTIVATED ===", pair.Symbol)
-		return true, nil
+	if markPrice, err := exchange.GetMarkPrice(pair.Symbol); err != nil {
+		log.Printf("[%s] Failed to get mark price for margin check: %v", pair.Symbol, err)
+	} else if margin, err := CheckAvailableMargin(exchange, pair, markPrice); err != nil {
+		log.Printf("[%s] Failed to check available margin: %v", pair.Symbol, err)
+	} else if !margin.Allowed {
+		log.Printf("[%s] ❌ Margin check failed - not opening FUD position: %s", pair.Symbol, margin.Reason)
+		return fmt.Errorf("%w: %s", ErrInsufficientMargin, margin.Reason)
 	}
 
-	log.Printf("[%s] Holding in FUD attack mode, coin signal: %s", pair.Symbol, coinSignal)
-	return true, nil
+	if err := applyPairMarginType(exchange, pair); err != nil {
+		log.Printf("[%s] ❌ Not opening position: %v", pair.Symbol, err)
+		return err
+	}
+
+	position, err := exchange.OpenPosition(pair.Symbol, side, pair.Leverage, pair.Quantity)
+	if err != nil {
+		log.Printf("[%s] Failed to open %s: %v", pair.Symbol, side, err)
+		return err
+	}
+
+	state.CurrentPosition = side
+	state.OpenedAt = time.Now()
+	state.OpenReason = reason
+	state.PositionUUID = GeneratePositionUUID()
+
+	log.Printf("[%s] FUD %s position opened: entry %.6f, amount %.6f, UUID: %s",
+		pair.Symbol, side, position.EntryPrice, position.Amount, state.PositionUUID)
+
+	positionRecord := PositionRecord{
+		UUID:           state.PositionUUID,
+		Symbol:         pair.Symbol,
+		Side:           string(side),
+		Leverage:       pair.Leverage,
+		Quantity:       pair.Quantity,
+		EntryPrice:     position.EntryPrice,
+		MarginType:     effectiveMarginType(position, pair),
+		OpenedAt:       state.OpenedAt,
+		OpenReason:     reason,
+		MaxPnL:         position.UnrealizedPL,
+		MinPnL:         position.UnrealizedPL,
+		BTCCorrelation: state.LastCorrelation.BTCCorrelation,
+		BTCBeta:        state.LastCorrelation.BTCBeta,
+		CreatedAt:      time.Now(),
+	}
+	if err := SavePositionOpen(positionRecord); err != nil {
+		log.Printf("[%s] Failed to save position to database: %v", pair.Symbol, err)
+	}
+	return nil
+}
+
+func recordFudOutcome(pair TradingPair, state *TradingState, positionUUID string, side PositionSide, exitPrice float64, realizedPL float64, reason string) {
+	outcome := FudModeOutcomeRecord{
+		Symbol:          pair.Symbol,
+		Strategy:        string(state.FudStrategy),
+		Source:          FudOutcomeSourceLive,
+		PositionUUID:    positionUUID,
+		Side:            string(side),
+		AttackStartedAt: state.FudAttackStartTime,
+		ExitedAt:        time.Now(),
+		ExitPrice:       exitPrice,
+		RealizedPL:      realizedPL,
+		ExitReason:      reason,
+		CreatedAt:       time.Now(),
+	}
+	if position, err := GetPositionByUUID(positionUUID); err == nil {
+		outcome.EnteredAt = position.OpenedAt
+		outcome.EntryPrice = position.EntryPrice
+	}
+	outcome.ReturnPercent = fudOutcomeReturnPercent(side, outcome.EntryPrice, exitPrice)
+	if !outcome.EnteredAt.IsZero() {
+		outcome.HoldMinutes = outcome.ExitedAt.Sub(outcome.EnteredAt).Minutes()
+	}
+
+	if err := SaveFudModeOutcome(outcome); err != nil {
+		log.Printf("[%s] Failed to save FUD mode outcome: %v", pair.Symbol, err)
+	}
+}
+
+// recordFudSkippedOutcome keeps attacks that never led to a position, so both
+// strategies are compared over the same set of attacks.
+func recordFudSkippedOutcome(pair TradingPair, state *TradingState, reason string) {
+	outcome := FudModeOutcomeRecord{
+		Symbol:          pair.Symbol,
+		Strategy:        string(state.FudStrategy),
+		Source:          FudOutcomeSourceLive,
+		AttackStartedAt: state.FudAttackStartTime,
+		ExitedAt:        time.Now(),
+		ExitReason:      reason,
+		Skipped:         true,
+		CreatedAt:       time.Now(),
+	}
+	if err := SaveFudModeOutcome(outcome); err != nil {
+		log.Printf("[%s] Failed to save FUD mode outcome: %v", pair.Symbol, err)
+	}
+}
+
+func fudOutcomeReturnPercent(side PositionSide, entryPrice, exitPrice float64) float64 {
+	if entryPrice <= 0 || exitPrice <= 0 {
+		return 0
+	}
+	change := (exitPrice - entryPrice) / entryPrice * 100
+	if side == PositionSideShort {
+		change = -change
+	}
+	return change
 }
@@ -0,0 +1,360 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"time"
+)
+
+// FudModeBacktestResult compares both FUD strategies over the same stored
+// attacks of one pair.
+type FudModeBacktestResult struct {
+	Symbol   string                 `json:"symbol"`
+	From     time.Time              `json:"from"`
+	To       time.Time              `json:"to"`
+	Attacks  int                    `json:"attacks"`
+	Outcomes []FudModeOutcomeRecord `json:"outcomes"`
+	Error    string                 `json:"error,omitempty"`
+}
+
+type fudBacktestEpisode struct {
+	startIdx   int
+	startedAt  time.Time
+	attackIdxs []int
+}
+
+// BacktestFudStrategies replays the stored FUD attacks of a pair over hourly
+// candles for both the forced SHORT and the contrarian LONG strategy. Coin
+// Ichimoku is not replayed, exits use the quiet period, max hold and for the
+// contrarian strategy its stop loss and take profit. Results are stored as
+// backtest outcomes, replacing earlier backtest runs of the pair.
+func BacktestFudStrategies(exchange Exchange, pair TradingPair, days int) FudModeBacktestResult {
+	if days <= 0 {
+		days = ResearchDefaultDays
+	}
+	if days > ResearchMaxDays {
+		days = ResearchMaxDays
+	}
+
+	to := time.Now().Truncate(time.Hour)
+	from := to.Add(-time.Duration(days) * 24 * time.Hour)
+	hours := int(to.Sub(from).Hours()) + 1
+	result := FudModeBacktestResult{Symbol: pair.Symbol, From: from, To: to}
+
+	closes, err := fetchHourlyCloses(exchange, pair.Symbol, from, to, hours)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	fuds, err := hourlyActivitySeries(pair.CommunityID, ActivityKindFud, from, to, hours)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	sentiments, err := GetSentimentRecords(pair.Symbol, from.Add(-ResearchSentimentMaxAge), to)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	trends := hourlySentimentTrends(sentiments, from, hours)
+
+	config := GetFudModeConfig(pair)
+	episodes, attacks, err := loadFudBacktestEpisodes(pair, config, from, to)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	result.Attacks = attacks
+
+	for _, episode := range episodes {
+		result.Outcomes = append(result.Outcomes,
+			simulateFudShort(pair, config, episode, from, closes),
+			simulateFudContrarian(pair, config, episode, from, closes, fuds, trends))
+	}
+
+	if err := ReplaceFudModeBacktestOutcomes(pair.Symbol, result.Outcomes); err != nil {
+		log.Printf("[%s] Failed to save FUD backtest outcomes: %v", pair.Symbol, err)
+	}
+	return result
+}
+
+// hourlySentimentTrends holds every stored sentiment trend for
+// ResearchSentimentMaxAge hours after it was recorded.
+func hourlySentimentTrends(sentiments []SentimentRecord, from time.Time, hours int) []string {
+	trends := make([]string, hours)
+	for _, s := range sentiments {
+		idx := int(s.CreatedAt.Sub(from) / time.Hour)
+		if idx < 0 {
+			idx = 0
+		}
+		for i := idx; i < hours && i < idx+int(ResearchSentimentMaxAge.Hours()); i++ {
+			trends[i] = s.SentimentTrend
+		}
+	}
+	return trends
+}
+
+func loadFudBacktestEpisodes(pair TradingPair, config FudModeConfig, from, to time.Time) ([]fudBacktestEpisode, int, error) {
+	records, err := GetFudAttacksInRange(pair.Symbol, from, to)
+	if err != nil {
+		return nil, 0, err
+	}
+	episodes, attacks := groupFudBacktestEpisodes(records, config, from)
+	return episodes, attacks, nil
+}
+
+// groupFudBacktestEpisodes groups qualifying attacks into episodes, a new
+// episode starts once the previous one was quiet for longer than the quiet
+// period.
+func groupFudBacktestEpisodes(records []FudAttackRecord, config FudModeConfig, from time.Time) ([]fudBacktestEpisode, int) {
+	var episodes []fudBacktestEpisode
+	var lastAttack time.Time
+	attacks := 0
+	for _, record := range records {
+		if !record.HasAttack || record.LastAttackTime.IsZero() {
+			continue
+		}
+		attack := fudAttackFromRecord(record)
+		if qualified, _ := qualifyFudAttack(attack, config, record.CreatedAt); !qualified {
+			continue
+		}
+		idx := int(record.LastAttackTime.Sub(from) / time.Hour)
+		if idx < 0 {
+			continue
+		}
+		attacks++
+
+		if len(episodes) == 0 || record.LastAttackTime.Sub(lastAttack) > config.QuietPeriod {
+			episodes = append(episodes, fudBacktestEpisode{startIdx: idx, startedAt: record.LastAttackTime})
+		}
+		current := &episodes[len(episodes)-1]
+		if len(current.attackIdxs) == 0 || current.attackIdxs[len(current.attackIdxs)-1] != idx {
+			current.attackIdxs = append(current.attackIdxs, idx)
+		}
+		if record.LastAttackTime.After(lastAttack) {
+			lastAttack = record.LastAttackTime
+		}
+	}
+	return episodes, attacks
+}
+
+func fudAttackFromRecord(record FudAttackRecord) ClaudeFudAttackResponse {
+	lastAttackTime := record.LastAttackTime
+	attack := ClaudeFudAttackResponse{
+		HasAttack:       record.HasAttack,
+		Confidence:      record.Confidence,
+		MessageCount:    record.MessageCount,
+		FudType:         record.FudType,
+		Theme:           record.Theme,
+		StartedHoursAgo: record.StartedHoursAgo,
+		LastAttackTime:  &lastAttackTime,
+	}
+	if record.Participants != "" {
+		json.Unmarshal([]byte(record.Participants), &attack.Participants)
+	}
+	return attack
+}
+
+func simulateFudShort(pair TradingPair, config FudModeConfig, episode fudBacktestEpisode, from time.Time, closes []float64) FudModeOutcomeRecord {
+	outcome := newFudBacktestOutcome(pair, FudStrategyShort, PositionSideShort, episode)
+	entryIdx := firstPricedHour(closes, episode.startIdx)
+	if entryIdx < 0 || entryIdx-episode.startIdx > int(math.Ceil(config.MaxAttackAge.Hours())) {
+		return skipFudBacktestOutcome(outcome, FudTriggerAttackExpired)
+	}
+
+	lastAttackIdx := episode.startIdx
+	for i := entryIdx + 1; i < len(closes); i++ {
+		if attackAt(episode, i) {
+			lastAttackIdx = i
+		}
+		if math.IsNaN(closes[i]) {
+			continue
+		}
+		if config.MaxHoldTime > 0 && hoursToDuration(i-entryIdx) > config.MaxHoldTime {
+			return closeFudBacktestOutcome(outcome, from, closes, entryIdx, i, "fud_mode_max_hold")
+		}
+		if hoursToDuration(i-lastAttackIdx) > config.QuietPeriod {
+			return closeFudBacktestOutcome(outcome, from, closes, entryIdx, i, "fud_mode_exit")
+		}
+	}
+	return closeFudBacktestOutcome(outcome, from, closes, entryIdx, lastPricedHour(closes), "backtest_end")
+}
+
+func simulateFudContrarian(pair TradingPair, config FudModeConfig, episode fudBacktestEpisode, from time.Time, closes, fuds []float64, trends []string) FudModeOutcomeRecord {
+	outcome := newFudBacktestOutcome(pair, FudStrategyContrarian, PositionSideLong, episode)
+
+	peak := 0.0
+	lastAttackIdx := episode.startIdx
+	entryIdx := -1
+	for i := episode.startIdx; i < len(closes); i++ {
+		if attackAt(episode, i) {
+			lastAttackIdx = i
+		}
+		if !math.IsNaN(fuds[i]) && fuds[i] > peak {
+			peak = fuds[i]
+		}
+		if config.MaxPeakWait > 0 && hoursToDuration(i-episode.startIdx) > config.MaxPeakWait {
+			return skipFudBacktestOutcome(outcome, FudTriggerPeakWaitExpired)
+		}
+		if math.IsNaN(closes[i]) || math.IsNaN(fuds[i]) || peak == 0 {
+			continue
+		}
+		if hoursToDuration(i-lastAttackIdx) >= config.PeakQuietPeriod && fuds[i] <= peak*config.PeakDecayRatio && trends[i] != "declining" {
+			entryIdx = i
+			break
+		}
+	}
+	if entryIdx < 0 {
+		return skipFudBacktestOutcome(outcome, FudTriggerPeakWaitExpired)
+	}
+
+	entryPrice := closes[entryIdx]
+	for i := entryIdx + 1; i < len(closes); i++ {
+		if attackAt(episode, i) {
+			return closeFudBacktestOutcome(outcome, from, closes, entryIdx, i, "fud_contrarian_attack_resumed")
+		}
+		if math.IsNaN(closes[i]) {
+			continue
+		}
+		change := (closes[i] - entryPrice) / entryPrice * 100
+		if config.StopLossPercent > 0 && change <= -config.StopLossPercent {
+			return closeFudBacktestOutcome(outcome, from, closes, entryIdx, i, "fud_contrarian_stop_loss")
+		}
+		if config.TakeProfitPercent > 0 && change >= config.TakeProfitPercent {
+			return closeFudBacktestOutcome(outcome, from, closes, entryIdx, i, "fud_contrarian_take_profit")
+		}
+		if config.MaxHoldTime > 0 && hoursToDuration(i-entryIdx) > config.MaxHoldTime {
+			return closeFudBacktestOutcome(outcome, from, closes, entryIdx, i, "fud_contrarian_max_hold")
+		}
+	}
+	return closeFudBacktestOutcome(outcome, from, closes, entryIdx, lastPricedHour(closes), "backtest_end")
+}
+
+func newFudBacktestOutcome(pair TradingPair, strategy FudStrategy, side PositionSide, episode fudBacktestEpisode) FudModeOutcomeRecord {
+	return FudModeOutcomeRecord{
+		Symbol:          pair.Symbol,
+		Strategy:        string(strategy),
+		Source:          FudOutcomeSourceBacktest,
+		Side:            string(side),
+		AttackStartedAt: episode.startedAt,
+		CreatedAt:       time.Now(),
+	}
+}
+
+func skipFudBacktestOutcome(outcome FudModeOutcomeRecord, reason string) FudModeOutcomeRecord {
+	outcome.Skipped = true
+	outcome.ExitReason = reason
+	return outcome
+}
+
+func closeFudBacktestOutcome(outcome FudModeOutcomeRecord, from time.Time, closes []float64, entryIdx, exitIdx int, reason string) FudModeOutcomeRecord {
+	for exitIdx > entryIdx && math.IsNaN(closes[exitIdx]) {
+		exitIdx--
+	}
+	if exitIdx <= entryIdx {
+		return skipFudBacktestOutcome(outcome, reason)
+	}
+	outcome.EnteredAt = from.Add(hoursToDuration(entryIdx))
+	outcome.ExitedAt = from.Add(hoursToDuration(exitIdx))
+	outcome.EntryPrice = closes[entryIdx]
+	outcome.ExitPrice = closes[exitIdx]
+	outcome.ReturnPercent = fudOutcomeReturnPercent(PositionSide(outcome.Side), outcome.EntryPrice, outcome.ExitPrice)
+	outcome.HoldMinutes = outcome.ExitedAt.Sub(outcome.EnteredAt).Minutes()
+	outcome.ExitReason = reason
+	return outcome
+}
+
+func attackAt(episode fudBacktestEpisode, idx int) bool {
+	for _, attackIdx := range episode.attackIdxs {
+		if attackIdx == idx && idx != episode.startIdx {
+			return true
+		}
+	}
+	return false
+}
+
+func firstPricedHour(closes []float64, from int) int {
+	for i := from; i < len(closes); i++ {
+		if !math.IsNaN(closes[i]) && closes[i] > 0 {
+			return i
+		}
+	}
+	return -1
+}
+
+func lastPricedHour(closes []float64) int {
+	for i := len(closes) - 1; i >= 0; i-- {
+		if !math.IsNaN(closes[i]) && closes[i] > 0 {
+			return i
+		}
+	}
+	return -1
+}
+
+func hoursToDuration(hours int) time.Duration {
+	return time.Duration(hours) * time.Hour
+}
+
+// runFudBacktestCommand is the -fud-backtest entry point, it prints the
+// per-strategy summary of every pair.
+func runFudBacktestCommand(exchanges Exchanges, days int) {
+	for _, pair := range TradingPairs {
+		result := BacktestFudStrategies(exchanges.For(pair), pair, days)
+		if result.Error != "" {
+			log.Printf("[%s] FUD backtest failed: %s", pair.Symbol, result.Error)
+			continue
+		}
+		log.Printf("[%s] FUD backtest over %d qualifying attacks", pair.Symbol, result.Attacks)
+		for _, summary := range SummarizeFudOutcomes(result.Outcomes) {
+			fmt.Printf("%s\t%s\ttrades=%d\tskipped=%d\twin_rate=%.1f%%\tavg_return=%.2f%%\ttotal_return=%.2f%%\n",
+				pair.Symbol, summary.Strategy, summary.Trades, summary.Skipped, summary.WinRate*100, summary.AvgReturn, summary.TotalReturn)
+		}
+	}
+}
+
+type FudOutcomeSummary struct {
+	Strategy    string  `json:"strategy"`
+	Source      string  `json:"source"`
+	Trades      int     `json:"trades"`
+	Skipped     int     `json:"skipped"`
+	Wins        int     `json:"wins"`
+	WinRate     float64 `json:"win_rate"`
+	AvgReturn   float64 `json:"avg_return"`
+	TotalReturn float64 `json:"total_return"`
+	RealizedPL  float64 `json:"realized_pl"`
+}
+
+// SummarizeFudOutcomes aggregates outcomes per strategy and source.
+func SummarizeFudOutcomes(outcomes []FudModeOutcomeRecord) []FudOutcomeSummary {
+	var summaries []FudOutcomeSummary
+	index := make(map[string]int)
+	for _, o := range outcomes {
+		key := fmt.Sprintf("%s/%s", o.Source, o.Strategy)
+		i, ok := index[key]
+		if !ok {
+			i = len(summaries)
+			index[key] = i
+			summaries = append(summaries, FudOutcomeSummary{Strategy: o.Strategy, Source: o.Source})
+		}
+		s := &summaries[i]
+		if o.Skipped {
+			s.Skipped++
+			continue
+		}
+		s.Trades++
+		s.TotalReturn += o.ReturnPercent
+		s.RealizedPL += o.RealizedPL
+		if o.ReturnPercent > 0 {
+			s.Wins++
+		}
+	}
+	for i := range summaries {
+		if summaries[i].Trades > 0 {
+			summaries[i].WinRate = float64(summaries[i].Wins) / float64(summaries[i].Trades)
+			summaries[i].AvgReturn = summaries[i].TotalReturn / float64(summaries[i].Trades)
+		}
+	}
+	return summaries
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var fudBacktestFrom = time.Date(2025, 10, 1, 0, 0, 0, 0, time.UTC)
+
+// fudAttackAt is a stored attack analysis made createdAfter the attack.
+func fudAttackAt(hour int, createdAfter time.Duration) FudAttackRecord {
+	attackTime := fudBacktestFrom.Add(hoursToDuration(hour))
+	return FudAttackRecord{Symbol: "GIGGLEUSDT", HasAttack: true, Confidence: 0.8, LastAttackTime: attackTime, CreatedAt: attackTime.Add(createdAfter)}
+}
+
+// flatCloses returns n hourly closes at price with the given overrides.
+func flatCloses(n int, price float64, overrides map[int]float64) []float64 {
+	closes := make([]float64, n)
+	for i := range closes {
+		closes[i] = price
+		if value, ok := overrides[i]; ok {
+			closes[i] = value
+		}
+	}
+	return closes
+}
+
+func TestGroupFudBacktestEpisodes(t *testing.T) {
+	noAttack := fudAttackAt(8, time.Minute)
+	noAttack.HasAttack = false
+	records := []FudAttackRecord{
+		fudAttackAt(-2, time.Minute),
+		fudAttackAt(2, 30*time.Minute),
+		fudAttackAt(5, 10*time.Minute),
+		fudAttackAt(5, 40*time.Minute),
+		fudAttackAt(6, 3*time.Hour),
+		noAttack,
+		fudAttackAt(17, time.Minute),
+		fudAttackAt(30, time.Minute),
+	}
+
+	episodes, attacks := groupFudBacktestEpisodes(records, DefaultFudModeConfig(), fudBacktestFrom)
+
+	assert.Equal(t, 5, attacks, "attacks before the range, without attack or analysed too late are dropped")
+	assert.Equal(t, []fudBacktestEpisode{
+		{startIdx: 2, startedAt: fudBacktestFrom.Add(2 * time.Hour), attackIdxs: []int{2, 5, 17}},
+		{startIdx: 30, startedAt: fudBacktestFrom.Add(30 * time.Hour), attackIdxs: []int{30}},
+	}, episodes, "12h after the last attack still belongs to the episode, 13h starts a new one")
+
+	strict := DefaultFudModeConfig()
+	strict.MaxAttackAge = 20 * time.Minute
+	episodes, attacks = groupFudBacktestEpisodes(records, strict, fudBacktestFrom)
+	assert.Equal(t, 3, attacks)
+	assert.Equal(t, []int{5, 17}, episodes[0].attackIdxs, "the late analysis of hour 2 no longer qualifies")
+}
+
+func TestSimulateFudShort(t *testing.T) {
+	pair := scenarioPair()
+	episode := fudBacktestEpisode{startIdx: 2, startedAt: fudBacktestFrom.Add(2 * time.Hour), attackIdxs: []int{2, 5}}
+	maxHold := DefaultFudModeConfig()
+	maxHold.MaxHoldTime = 6 * time.Hour
+	nan := math.NaN()
+
+	tests := []struct {
+		name     string
+		config   FudModeConfig
+		closes   []float64
+		skipped  bool
+		reason   string
+		entryIdx int
+		exitIdx  int
+		ret      float64
+	}{
+		{"quiet period after the last attack", DefaultFudModeConfig(), flatCloses(48, 100, map[int]float64{18: 90}), false, "fud_mode_exit", 2, 18, 10},
+		{"max hold", maxHold, flatCloses(48, 100, map[int]float64{9: 105}), false, "fud_mode_max_hold", 2, 9, -5},
+		{"exit on the last priced hour", DefaultFudModeConfig(), flatCloses(14, 100, map[int]float64{12: 95, 13: nan}), false, "backtest_end", 2, 12, 5},
+		{"entry waits for a priced hour within the max age", DefaultFudModeConfig(), flatCloses(48, 100, map[int]float64{2: nan}), false, "fud_mode_exit", 3, 18, 0},
+		{"attack expired before a priced hour", DefaultFudModeConfig(), flatCloses(48, 100, map[int]float64{2: nan, 3: nan}), true, FudTriggerAttackExpired, 0, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			outcome := simulateFudShort(pair, tt.config, episode, fudBacktestFrom, tt.closes)
+			assert.Equal(t, tt.skipped, outcome.Skipped)
+			assert.Equal(t, tt.reason, outcome.ExitReason)
+			assert.Equal(t, string(PositionSideShort), outcome.Side)
+			if tt.skipped {
+				return
+			}
+			assert.Equal(t, fudBacktestFrom.Add(hoursToDuration(tt.entryIdx)), outcome.EnteredAt)
+			assert.Equal(t, fudBacktestFrom.Add(hoursToDuration(tt.exitIdx)), outcome.ExitedAt)
+			assert.InDelta(t, tt.ret, outcome.ReturnPercent, 1e-9)
+		})
+	}
+}
+
+func TestSimulateFudContrarian(t *testing.T) {
+	pair := scenarioPair()
+	episode := fudBacktestEpisode{startIdx: 2, startedAt: fudBacktestFrom.Add(2 * time.Hour), attackIdxs: []int{2, 3}}
+	resumed := episode
+	resumed.attackIdxs = []int{2, 3, 7}
+	fuds := flatCloses(48, 8, map[int]float64{0: 0, 1: 0, 2: 10, 3: 20, 4: 15})
+	noDecay := flatCloses(48, 20, map[int]float64{0: 0, 1: 0})
+	trends := make([]string, 48)
+	declining := make([]string, 48)
+	declining[5] = "declining"
+	maxHold := DefaultFudModeConfig()
+	maxHold.MaxHoldTime = 4 * time.Hour
+
+	tests := []struct {
+		name     string
+		config   FudModeConfig
+		episode  fudBacktestEpisode
+		closes   []float64
+		fuds     []float64
+		trends   []string
+		skipped  bool
+		reason   string
+		entryIdx int
+		exitIdx  int
+		ret      float64
+	}{
+		{"stop loss", DefaultFudModeConfig(), episode, flatCloses(48, 100, map[int]float64{8: 96.5}), fuds, trends, false, "fud_contrarian_stop_loss", 5, 8, -3.5},
+		{"take profit", DefaultFudModeConfig(), episode, flatCloses(48, 100, map[int]float64{8: 109}), fuds, trends, false, "fud_contrarian_take_profit", 5, 8, 9},
+		{"max hold", maxHold, episode, flatCloses(48, 100, nil), fuds, trends, false, "fud_contrarian_max_hold", 5, 10, 0},
+		{"attack resumed", DefaultFudModeConfig(), resumed, flatCloses(48, 100, map[int]float64{7: 98}), fuds, trends, false, "fud_contrarian_attack_resumed", 5, 7, -2},
+		{"declining sentiment delays the entry", DefaultFudModeConfig(), episode, flatCloses(48, 100, map[int]float64{9: 110}), fuds, declining, false, "fud_contrarian_take_profit", 6, 9, 10},
+		{"peak never decays", DefaultFudModeConfig(), episode, flatCloses(48, 100, nil), noDecay, trends, true, FudTriggerPeakWaitExpired, 0, 0, 0},
+		{"ends with the data", DefaultFudModeConfig(), episode, flatCloses(12, 100, map[int]float64{11: 102}), fuds, trends, false, "backtest_end", 5, 11, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			outcome := simulateFudContrarian(pair, tt.config, tt.episode, fudBacktestFrom, tt.closes, tt.fuds, tt.trends)
+			assert.Equal(t, tt.skipped, outcome.Skipped)
+			assert.Equal(t, tt.reason, outcome.ExitReason)
+			assert.Equal(t, string(PositionSideLong), outcome.Side)
+			if tt.skipped {
+				return
+			}
+			assert.Equal(t, fudBacktestFrom.Add(hoursToDuration(tt.entryIdx)), outcome.EnteredAt)
+			assert.Equal(t, fudBacktestFrom.Add(hoursToDuration(tt.exitIdx)), outcome.ExitedAt)
+			assert.InDelta(t, tt.ret, outcome.ReturnPercent, 1e-9)
+		})
+	}
+}
@@ -0,0 +1,314 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	ParticipantEvaluationHorizon = 24 * time.Hour
+	ParticipantEpisodeTolerance  = 2 * time.Hour
+	ParticipantDropThreshold     = -0.02
+	ParticipantNeutralCredit     = 0.5
+	ParticipantMinWeight         = 0.5
+	ParticipantMaxWeight         = 1.5
+	ParticipantEvaluationBatch   = 50
+	ParticipantGroupMinCoAttacks = 2
+)
+
+// ParticipantCredibility is a Laplace-smoothed share of evaluated attacks that
+// were followed by a price drop. Unknown accounts sit at the neutral 0.5.
+func ParticipantCredibility(evaluated, drops int) float64 {
+	return float64(drops+1) / float64(evaluated+2)
+}
+
+// ApplyParticipantCredibility scales the attack confidence by the message
+// weighted credibility of its participants. A neutral crowd keeps the
+// confidence unchanged, known accurate FUDders raise it up to 1.5x and
+// accounts whose attacks never moved price lower it down to 0.5x.
+func ApplyParticipantCredibility(attack *ClaudeFudAttackResponse) {
+	attack.RawConfidence = attack.Confidence
+	attack.CredibilityWeight = 1
+	if !attack.HasAttack || len(attack.Participants) == 0 {
+		return
+	}
+
+	usernames := make([]string, 0, len(attack.Participants))
+	for _, p := range attack.Participants {
+		usernames = append(usernames, normalizeUsername(p.Username))
+	}
+	known, err := GetFudParticipantsByUsernames(usernames)
+	if err != nil {
+		log.Printf("Failed to load FUD participants: %v", err)
+		return
+	}
+	credibility := make(map[string]float64, len(known))
+	for _, p := range known {
+		credibility[p.Username] = p.Credibility
+	}
+
+	var weighted, totalMessages float64
+	for _, p := range attack.Participants {
+		messages := math.Max(float64(p.MessageCount), 1)
+		score, ok := credibility[normalizeUsername(p.Username)]
+		if !ok {
+			score = ParticipantNeutralCredit
+		}
+		weighted += score * messages
+		totalMessages += messages
+	}
+
+	averageCredibility := weighted / totalMessages
+	attack.CredibilityWeight = math.Max(ParticipantMinWeight, math.Min(ParticipantMaxWeight, 2*averageCredibility))
+	attack.Confidence = math.Min(1, attack.RawConfidence*attack.CredibilityWeight)
+}
+
+// RegisterFudParticipants records every participant of an attack. Repeated
+// analyses of the same attack update the existing participation instead of
+// counting a new one.
+func RegisterFudParticipants(attack ClaudeFudAttackResponse, pair TradingPair, price float64) error {
+	if !attack.HasAttack || len(attack.Participants) == 0 {
+		return nil
+	}
+
+	now := time.Now()
+	attackTime := now
+	if attack.LastAttackTime != nil {
+		attackTime = *attack.LastAttackTime
+	}
+	startedAt := now.Add(-time.Duration(attack.StartedHoursAgo) * time.Hour).Truncate(time.Hour)
+
+	for _, p := range attack.Participants {
+		username := normalizeUsername(p.Username)
+		if username == "" {
+			continue
+		}
+
+		participation, err := FindFudParticipation(username, pair.Symbol, startedAt, ParticipantEpisodeTolerance)
+		if err != nil {
+			return err
+		}
+		isNew := participation == nil
+		if isNew {
+			participation = &FudParticipationRecord{
+				Username:        username,
+				Symbol:          pair.Symbol,
+				CommunityID:     pair.CommunityID,
+				AttackStartedAt: startedAt,
+				PriceAtAttack:   price,
+				CreatedAt:       now,
+			}
+		}
+		participation.LastAttackTime = attackTime
+		if p.MessageCount > participation.MessageCount {
+			participation.MessageCount = p.MessageCount
+		}
+		if participation.PriceAtAttack == 0 {
+			participation.PriceAtAttack = price
+		}
+		if err := SaveFudParticipation(participation); err != nil {
+			return err
+		}
+
+		if err := UpsertFudParticipant(username, pair.CommunityID, attackTime, isNew); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// EvaluateFudParticipations fills in the price move that followed each
+// participation once the evaluation horizon has passed, then refreshes the
+// credibility of the affected accounts.
+func EvaluateFudParticipations(exchange Exchange, symbol string) error {
+	pending, err := GetPendingFudParticipations(symbol, time.Now().Add(-ParticipantEvaluationHorizon), ParticipantEvaluationBatch)
+	if err != nil {
+		return err
+	}
+	if len(pending) == 0 {
+		return nil
+	}
+
+	// Participants of one attack share the attack time and entry price, so the
+	// move is looked up once per pair of the values priceMoveAfter reads.
+	type moveKey struct {
+		attackTime int64
+		price      float64
+	}
+	moves := make(map[moveKey]float64)
+	updated := make(map[string]bool)
+	for i := range pending {
+		p := &pending[i]
+		key := moveKey{p.LastAttackTime.Unix(), p.PriceAtAttack}
+		move, ok := moves[key]
+		if !ok {
+			move, err = priceMoveAfter(exchange, symbol, p.LastAttackTime, p.PriceAtAttack, ParticipantEvaluationHorizon)
+			if err != nil {
+				log.Printf("[%s] Failed to evaluate FUD participation %d: %v", symbol, p.ID, err)
+				continue
+			}
+			moves[key] = move
+		}
+
+		p.PriceMove24h = move
+		p.Evaluated = true
+		if err := SaveFudParticipation(p); err != nil {
+			return err
+		}
+		updated[p.Username] = true
+	}
+
+	for username := range updated {
+		if err := RefreshFudParticipantStats(username); err != nil {
+			return err
+		}
+	}
+	log.Printf("[%s] Evaluated %d FUD participations, refreshed %d participants", symbol, len(pending), len(updated))
+	return nil
+}
+
+func priceMoveAfter(exchange Exchange, symbol string, from time.Time, entryPrice float64, horizon time.Duration) (float64, error) {
+	target := from.Add(horizon)
+	klines, err := exchange.Klines(symbol, "1h", target.Add(-time.Hour).UnixMilli(), target.UnixMilli(), 1)
+	if err != nil {
+		return 0, err
+	}
+	if len(klines) == 0 {
+		return 0, fmt.Errorf("no candle at %s", target.Format(time.RFC3339))
+	}
+	closePrice := klines[0].Close
+
+	if entryPrice <= 0 {
+		startKlines, err := exchange.Klines(symbol, "1h", from.Add(-time.Hour).UnixMilli(), from.UnixMilli(), 1)
+		if err != nil || len(startKlines) == 0 {
+			return 0, fmt.Errorf("no entry price for %s", from.Format(time.RFC3339))
+		}
+		entryPrice = startKlines[0].Close
+		if entryPrice <= 0 {
+			return 0, fmt.Errorf("invalid entry price for %s", from.Format(time.RFC3339))
+		}
+	}
+	return (closePrice - entryPrice) / entryPrice, nil
+}
+
+type FudGroup struct {
+	Members     []string  `json:"members"`
+	Communities []string  `json:"communities"`
+	CoAttacks   int       `json:"co_attacks"`
+	LastSeen    time.Time `json:"last_seen"`
+}
+
+// FindCoordinatedFudGroups links accounts that joined the same attacks at
+// least ParticipantGroupMinCoAttacks times and keeps the connected groups
+// that appear in more than one community.
+func FindCoordinatedFudGroups(participations []FudParticipationRecord) []FudGroup {
+	type episodeKey struct {
+		symbol    string
+		startedAt int64
+	}
+	episodes := make(map[episodeKey][]FudParticipationRecord)
+	for _, p := range participations {
+		key := episodeKey{p.Symbol, p.AttackStartedAt.Unix()}
+		episodes[key] = append(episodes[key], p)
+	}
+
+	type pairKey struct{ a, b string }
+	coAttacks := make(map[pairKey]int)
+	for _, members := range episodes {
+		for i := 0; i < len(members); i++ {
+			for j := i + 1; j < len(members); j++ {
+				a, b := members[i].Username, members[j].Username
+				if a == b {
+					continue
+				}
+				if a > b {
+					a, b = b, a
+				}
+				coAttacks[pairKey{a, b}]++
+			}
+		}
+	}
+
+	parent := make(map[string]string)
+	var find func(string) string
+	find = func(x string) string {
+		if parent[x] != x {
+			parent[x] = find(parent[x])
+		}
+		return parent[x]
+	}
+	for pair, count := range coAttacks {
+		if count < ParticipantGroupMinCoAttacks {
+			continue
+		}
+		for _, u := range []string{pair.a, pair.b} {
+			if _, ok := parent[u]; !ok {
+				parent[u] = u
+			}
+		}
+		parent[find(pair.a)] = find(pair.b)
+	}
+
+	groupMembers := make(map[string]map[string]bool)
+	for u := range parent {
+		root := find(u)
+		if groupMembers[root] == nil {
+			groupMembers[root] = make(map[string]bool)
+		}
+		groupMembers[root][u] = true
+	}
+
+	var groups []FudGroup
+	for _, members := range groupMembers {
+		group := FudGroup{}
+		communities := make(map[string]bool)
+		for _, p := range participations {
+			if !members[p.Username] {
+				continue
+			}
+			communities[p.CommunityID] = true
+			if p.LastAttackTime.After(group.LastSeen) {
+				group.LastSeen = p.LastAttackTime
+			}
+		}
+		for _, episode := range episodes {
+			count := 0
+			for _, p := range episode {
+				if members[p.Username] {
+					count++
+				}
+			}
+			if count >= 2 {
+				group.CoAttacks++
+			}
+		}
+		if len(communities) < 2 {
+			continue
+		}
+		for u := range members {
+			group.Members = append(group.Members, u)
+		}
+		for c := range communities {
+			group.Communities = append(group.Communities, c)
+		}
+		sort.Strings(group.Members)
+		sort.Strings(group.Communities)
+		groups = append(groups, group)
+	}
+
+	sort.Slice(groups, func(i, j int) bool {
+		if groups[i].CoAttacks != groups[j].CoAttacks {
+			return groups[i].CoAttacks > groups[j].CoAttacks
+		}
+		return len(groups[i].Members) > len(groups[j].Members)
+	})
+	return groups
+}
+
+func normalizeUsername(username string) string {
+	return strings.ToLower(strings.TrimPrefix(strings.TrimSpace(username), "@"))
+}
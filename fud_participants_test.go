@@ -0,0 +1,128 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParticipantCredibility(t *testing.T) {
+	assert.Equal(t, 0.5, ParticipantCredibility(0, 0), "unknown accounts are neutral")
+	assert.InDelta(t, 2.0/3, ParticipantCredibility(1, 1), 1e-9)
+	assert.InDelta(t, 1.0/12, ParticipantCredibility(10, 0), 1e-9)
+	assert.InDelta(t, 11.0/12, ParticipantCredibility(10, 10), 1e-9)
+}
+
+func TestApplyParticipantCredibility(t *testing.T) {
+	openTestDatabase(t, "fud_credibility")
+	for username, credibility := range map[string]float64{"alice": 0.7, "bob": 0.1, "carol": 0.9} {
+		require.NoError(t, DB.Create(&FudParticipantRecord{Username: username, Credibility: credibility}).Error)
+	}
+	participants := func(list ...FudAttackParticipant) ClaudeFudAttackResponse {
+		return ClaudeFudAttackResponse{HasAttack: true, Confidence: 0.5, Participants: list}
+	}
+
+	tests := []struct {
+		name       string
+		attack     ClaudeFudAttackResponse
+		weight     float64
+		confidence float64
+	}{
+		{"unknown crowd keeps the confidence", participants(FudAttackParticipant{"newcomer", 4}), 1, 0.5},
+		{"accurate account raises it", participants(FudAttackParticipant{"@Alice", 1}), 1.4, 0.7},
+		{"weight is capped", participants(FudAttackParticipant{"carol", 1}), ParticipantMaxWeight, 0.75},
+		{"weight has a floor", participants(FudAttackParticipant{"bob", 1}), ParticipantMinWeight, 0.25},
+		{"weighted by messages", participants(FudAttackParticipant{"alice", 3}, FudAttackParticipant{"bob", 1}), 1.1, 0.55},
+		{"zero messages count once", participants(FudAttackParticipant{"alice", 0}, FudAttackParticipant{"newcomer", 0}), 1.2, 0.6},
+		{"confidence stays at most 1", ClaudeFudAttackResponse{HasAttack: true, Confidence: 0.8, Participants: []FudAttackParticipant{{"alice", 1}}}, 1.4, 1},
+		{"no attack", ClaudeFudAttackResponse{Confidence: 0.5, Participants: []FudAttackParticipant{{"carol", 1}}}, 1, 0.5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			attack := tt.attack
+			ApplyParticipantCredibility(&attack)
+			assert.Equal(t, tt.attack.Confidence, attack.RawConfidence)
+			assert.InDelta(t, tt.weight, attack.CredibilityWeight, 1e-9)
+			assert.InDelta(t, tt.confidence, attack.Confidence, 1e-9)
+		})
+	}
+}
+
+func TestEvaluateFudParticipations(t *testing.T) {
+	openTestDatabase(t, "fud_evaluation")
+	exchange := newScenarioExchange()
+	exchange.klines["GIGGLEUSDT/1h"] = []Candle{{Close: 90}}
+
+	startedAt := time.Now().Add(-48 * time.Hour).Truncate(time.Hour)
+	participations := []FudParticipationRecord{
+		{Username: "alice", LastAttackTime: startedAt.Add(time.Hour), PriceAtAttack: 100},
+		{Username: "bob", LastAttackTime: startedAt.Add(time.Hour), PriceAtAttack: 80},
+		{Username: "carol", LastAttackTime: startedAt.Add(time.Hour), PriceAtAttack: 100},
+		{Username: "dave", LastAttackTime: time.Now().Add(-time.Hour), PriceAtAttack: 100},
+	}
+	for i := range participations {
+		p := &participations[i]
+		p.Symbol = "GIGGLEUSDT"
+		p.AttackStartedAt = startedAt
+		require.NoError(t, SaveFudParticipation(p))
+		require.NoError(t, UpsertFudParticipant(p.Username, "community", p.LastAttackTime, true))
+	}
+
+	require.NoError(t, EvaluateFudParticipations(exchange, "GIGGLEUSDT"))
+
+	var evaluated []FudParticipationRecord
+	require.NoError(t, DB.Order("username").Find(&evaluated).Error)
+	require.Len(t, evaluated, 4)
+	assert.InDelta(t, -0.1, evaluated[0].PriceMove24h, 1e-9)
+	assert.InDelta(t, 0.125, evaluated[1].PriceMove24h, 1e-9, "same attack start, different entry price")
+	assert.InDelta(t, -0.1, evaluated[2].PriceMove24h, 1e-9)
+	assert.True(t, evaluated[2].Evaluated)
+	assert.False(t, evaluated[3].Evaluated, "the horizon has not passed yet")
+
+	known, err := GetFudParticipantsByUsernames([]string{"alice", "bob", "dave"})
+	require.NoError(t, err)
+	credibility := make(map[string]float64)
+	for _, p := range known {
+		credibility[p.Username] = p.Credibility
+	}
+	assert.InDelta(t, ParticipantCredibility(1, 1), credibility["alice"], 1e-9)
+	assert.InDelta(t, ParticipantCredibility(1, 0), credibility["bob"], 1e-9)
+	assert.InDelta(t, ParticipantCredibility(0, 0), credibility["dave"], 1e-9)
+}
+
+func TestFindCoordinatedFudGroups(t *testing.T) {
+	base := time.Date(2025, 10, 1, 0, 0, 0, 0, time.UTC)
+	attack := func(symbol, community string, day int, usernames ...string) []FudParticipationRecord {
+		var records []FudParticipationRecord
+		for _, u := range usernames {
+			records = append(records, FudParticipationRecord{
+				Username:        u,
+				Symbol:          symbol,
+				CommunityID:     community,
+				AttackStartedAt: base.Add(time.Duration(day) * 24 * time.Hour),
+				LastAttackTime:  base.Add(time.Duration(day)*24*time.Hour + time.Hour),
+			})
+		}
+		return records
+	}
+	var participations []FudParticipationRecord
+	participations = append(participations, attack("GIGGLEUSDT", "giggle", 1, "alice", "bob", "erin")...)
+	participations = append(participations, attack("PEPEUSDT", "pepe", 2, "alice", "bob")...)
+	participations = append(participations, attack("PEPEUSDT", "pepe", 3, "bob", "frank")...)
+	participations = append(participations, attack("WIFUSDT", "wif", 4, "frank", "bob")...)
+	participations = append(participations, attack("GIGGLEUSDT", "giggle", 5, "carol", "dave")...)
+	participations = append(participations, attack("GIGGLEUSDT", "giggle", 6, "carol", "dave")...)
+
+	groups := FindCoordinatedFudGroups(participations)
+
+	require.Len(t, groups, 1, "carol and dave only attack one community")
+	group := groups[0]
+	assert.Equal(t, []string{"alice", "bob", "frank"}, group.Members, "linked through bob, erin joined once")
+	assert.Equal(t, []string{"giggle", "pepe", "wif"}, group.Communities)
+	assert.Equal(t, 4, group.CoAttacks)
+	assert.Equal(t, base.Add(4*24*time.Hour+time.Hour), group.LastSeen)
+
+	assert.Empty(t, FindCoordinatedFudGroups(attack("GIGGLEUSDT", "giggle", 1, "alice", "bob")))
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"time"
+)
+
+type FudState string
+
+const (
+	FudStateIdle      FudState = "idle"
+	FudStateArmed     FudState = "armed"
+	FudStateShortOpen FudState = "short_open"
+	FudStateRealShort FudState = "real_short"
+	FudStateExiting   FudState = "exiting"
+
+	FudStateAwaitingPeak FudState = "awaiting_peak"
+	FudStateLongOpen     FudState = "long_open"
+)
+
+// FudStrategy selects how a pair responds to a qualified FUD attack: the
+// forced SHORT, or the contrarian LONG once the attack has peaked.
+type FudStrategy string
+
+const (
+	FudStrategyShort      FudStrategy = "short"
+	FudStrategyContrarian FudStrategy = "contrarian"
+)
+
+const (
+	FudTriggerAttackDetected   = "attack_detected"
+	FudTriggerAttackExpired    = "attack_expired"
+	FudTriggerShortOpened      = "forced_short_opened"
+	FudTriggerShortAdopted     = "existing_short_adopted"
+	FudTriggerCoinShort        = "coin_ichimoku_short"
+	FudTriggerCoinLong         = "coin_ichimoku_long"
+	FudTriggerAttackQuiet      = "attack_quiet"
+	FudTriggerMaxHoldTime      = "max_hold_time"
+	FudTriggerPositionClosed   = "position_closed"
+	FudTriggerPositionExternal = "position_closed_externally"
+	FudTriggerRestored         = "restored"
+	FudTriggerAwaitPeak        = "awaiting_peak"
+	FudTriggerPeakPassed       = "attack_peaked"
+	FudTriggerPeakWaitExpired  = "peak_wait_expired"
+	FudTriggerStopLoss         = "stop_loss"
+	FudTriggerTakeProfit       = "take_profit"
+	FudTriggerAttackResumed    = "attack_resumed"
+)
+
+// FudModeConfig holds the per-pair thresholds of the FUD attack state machine.
+// An attack arms the machine only when it passes every minimum and is not
+// older than MaxAttackAge. The Peak* and percent fields only apply to the
+// contrarian strategy.
+type FudModeConfig struct {
+	Strategy        FudStrategy
+	MinConfidence   float64
+	MinParticipants int
+	MinMessages     int
+	MaxAttackAge    time.Duration
+	QuietPeriod     time.Duration
+	MaxHoldTime     time.Duration
+
+	PeakQuietPeriod   time.Duration
+	PeakDecayRatio    float64
+	MaxPeakWait       time.Duration
+	StopLossPercent   float64
+	TakeProfitPercent float64
+}
+
+func DefaultFudModeConfig() FudModeConfig {
+	return FudModeConfig{
+		Strategy:          FudStrategyShort,
+		MinConfidence:     0,
+		MinParticipants:   0,
+		MinMessages:       0,
+		MaxAttackAge:      1 * time.Hour,
+		QuietPeriod:       12 * time.Hour,
+		MaxHoldTime:       0,
+		PeakQuietPeriod:   2 * time.Hour,
+		PeakDecayRatio:    0.5,
+		MaxPeakWait:       24 * time.Hour,
+		StopLossPercent:   3,
+		TakeProfitPercent: 8,
+	}
+}
+
+func GetFudModeConfig(pair TradingPair) FudModeConfig {
+	if pair.FudMode != nil {
+		config := *pair.FudMode
+		if config.Strategy == "" {
+			config.Strategy = FudStrategyShort
+		}
+		return config
+	}
+	return DefaultFudModeConfig()
+}
+
+// qualifyFudAttack checks a FUD attack analysis against the pair thresholds and
+// returns a human readable reason either way.
+func qualifyFudAttack(attack ClaudeFudAttackResponse, config FudModeConfig, now time.Time) (bool, string) {
+	if !attack.HasAttack {
+		return false, "no attack"
+	}
+	if attack.LastAttackTime == nil {
+		return false, "attack has no timestamp"
+	}
+	age := now.Sub(*attack.LastAttackTime)
+	if age > config.MaxAttackAge {
+		return false, fmt.Sprintf("attack is %.0f min old (max %.0f min)", age.Minutes(), config.MaxAttackAge.Minutes())
+	}
+	if attack.Confidence < config.MinConfidence {
+		return false, fmt.Sprintf("confidence %.0f%% below %.0f%%", attack.Confidence*100, config.MinConfidence*100)
+	}
+	if !attack.ParticipantsUnknown && len(attack.Participants) < config.MinParticipants {
+		return false, fmt.Sprintf("%d participants below %d", len(attack.Participants), config.MinParticipants)
+	}
+	if attack.MessageCount < config.MinMessages {
+		return false, fmt.Sprintf("%d messages below %d", attack.MessageCount, config.MinMessages)
+	}
+	participants := fmt.Sprintf("%d participants", len(attack.Participants))
+	if attack.ParticipantsUnknown {
+		participants = "participants unknown"
+		if config.MinParticipants > 0 {
+			participants += fmt.Sprintf(" (min %d skipped)", config.MinParticipants)
+		}
+	}
+	return true, fmt.Sprintf("confidence %.0f%%, %s, %d messages, %.0f min ago",
+		attack.Confidence*100, participants, attack.MessageCount, age.Minutes())
+}
+
+func transitionFudState(pair TradingPair, state *TradingState, to FudState, trigger string, details string, attack ClaudeFudAttackResponse) {
+	from := state.FudState
+	if from == "" {
+		from = FudStateIdle
+	}
+
+	log.Printf("[%s] 🔁 FUD state: %s -> %s (%s) %s", pair.Symbol, from, to, trigger, details)
+
+	now := time.Now()
+	record := FudStateTransitionRecord{
+		Symbol:             pair.Symbol,
+		PositionUUID:       state.PositionUUID,
+		FromState:          string(from),
+		ToState:            string(to),
+		Strategy:           string(state.FudStrategy),
+		Trigger:            trigger,
+		Details:            details,
+		AttackConfidence:   attack.Confidence,
+		AttackMessages:     attack.MessageCount,
+		AttackParticipants: len(attack.Participants),
+		CreatedAt:          now,
+	}
+	if err := SaveFudStateTransition(record); err != nil {
+		log.Printf("[%s] Failed to save FUD state transition: %v", pair.Symbol, err)
+	}
+
+	state.FudState = to
+	state.FudStateSince = now
+	if to == FudStateIdle {
+		state.FudAttackStartTime = time.Time{}
+		state.FudLastAttackTime = time.Time{}
+		state.FudExitReason = ""
+		state.FudStrategy = ""
+		state.FudPeakZScore = 0
+	}
+}
+
+// restoreFudState resumes a FUD mode that was holding a position when the bot
+// stopped. Earlier states are dropped, the next attack analysis re-arms them.
+func restoreFudState(pair TradingPair, state *TradingState) {
+	state.FudState = FudStateIdle
+
+	last, err := GetLatestFudStateTransition(pair.Symbol)
+	if err != nil {
+		log.Printf("[%s] Failed to load last FUD state: %v", pair.Symbol, err)
+		return
+	}
+	if last == nil || FudState(last.ToState) == FudStateIdle {
+		return
+	}
+
+	strategy := FudStrategy(last.Strategy)
+	if strategy == "" {
+		strategy = FudStrategyShort
+	}
+	expectedSide := PositionSideShort
+	if strategy == FudStrategyContrarian {
+		expectedSide = PositionSideLong
+	}
+
+	switch FudState(last.ToState) {
+	case FudStateShortOpen, FudStateRealShort, FudStateLongOpen, FudStateExiting, FudStateAwaitingPeak:
+		if state.CurrentPosition == expectedSide || FudState(last.ToState) == FudStateAwaitingPeak {
+			state.FudState = FudState(last.ToState)
+			state.FudStrategy = strategy
+			state.FudStateSince = last.CreatedAt
+			state.FudAttackStartTime = last.CreatedAt
+			state.FudLastAttackTime = last.CreatedAt
+			if attack, err := GetLatestFudAttack(pair.Symbol); err == nil && attack != nil && attack.HasAttack && !attack.LastAttackTime.IsZero() {
+				state.FudLastAttackTime = attack.LastAttackTime
+			}
+			state.FudHandledAttackTime = state.FudLastAttackTime
+			log.Printf("[%s] ✓ Restored FUD state %s (since %s)", pair.Symbol, state.FudState, last.CreatedAt.Format("2006-01-02 15:04:05"))
+			return
+		}
+	}
+
+	transitionFudState(pair, state, FudStateIdle, FudTriggerRestored,
+		fmt.Sprintf("last state %s does not match exchange position %s", last.ToState, state.CurrentPosition), ClaudeFudAttackResponse{})
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQualifyFudAttack(t *testing.T) {
+	now := time.Date(2025, 10, 9, 12, 0, 0, 0, time.UTC)
+	lastAttack := now.Add(-10 * time.Minute)
+	config := DefaultFudModeConfig()
+	config.MinConfidence = 0.5
+	config.MinParticipants = 2
+	config.MinMessages = 10
+
+	external := ClaudeFudAttackResponse{
+		HasAttack:      true,
+		Confidence:     0.8,
+		MessageCount:   40,
+		Participants:   []FudAttackParticipant{{Username: "bear_whale"}, {Username: "exitliq"}},
+		LastAttackTime: &lastAttack,
+	}
+	local := external
+	local.Participants = []FudAttackParticipant{}
+	local.ParticipantsUnknown = true
+	old := now.Add(-2 * time.Hour)
+
+	tests := []struct {
+		name   string
+		attack func(ClaudeFudAttackResponse) ClaudeFudAttackResponse
+		base   ClaudeFudAttackResponse
+		ok     bool
+		reason string
+	}{
+		{"external attack passes", nil, external, true, "2 participants"},
+		{"too few external participants", func(a ClaudeFudAttackResponse) ClaudeFudAttackResponse { a.Participants = a.Participants[:1]; return a }, external, false, "1 participants below 2"},
+		{"local attack skips the participant minimum", nil, local, true, "participants unknown (min 2 skipped)"},
+		{"local attack still needs messages", func(a ClaudeFudAttackResponse) ClaudeFudAttackResponse { a.MessageCount = 5; return a }, local, false, "5 messages below 10"},
+		{"low confidence", func(a ClaudeFudAttackResponse) ClaudeFudAttackResponse { a.Confidence = 0.3; return a }, external, false, "confidence 30% below 50%"},
+		{"old attack", func(a ClaudeFudAttackResponse) ClaudeFudAttackResponse { a.LastAttackTime = &old; return a }, external, false, "attack is 120 min old"},
+		{"no attack", func(a ClaudeFudAttackResponse) ClaudeFudAttackResponse { a.HasAttack = false; return a }, external, false, "no attack"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			attack := tt.base
+			if tt.attack != nil {
+				attack = tt.attack(attack)
+			}
+			ok, reason := qualifyFudAttack(attack, config, now)
+			assert.Equal(t, tt.ok, ok, reason)
+			assert.Contains(t, reason, tt.reason)
+		})
+	}
+}
+
+func TestTransitionFudState_RecordsExitingStrategy(t *testing.T) {
+	openTestDatabase(t, "fud_state_transitions")
+	pair := scenarioPair()
+	state := &TradingState{FudState: FudStateLongOpen, FudStrategy: FudStrategyContrarian, FudPeakZScore: 3.2}
+
+	transitionFudState(pair, state, FudStateIdle, FudTriggerMaxHoldTime, "held 80h", ClaudeFudAttackResponse{})
+
+	last, err := GetLatestFudStateTransition(pair.Symbol)
+	require.NoError(t, err)
+	require.NotNil(t, last)
+	assert.Equal(t, string(FudStrategyContrarian), last.Strategy, "the transition to idle keeps the mode that exited")
+	assert.Equal(t, string(FudStateLongOpen), last.FromState)
+	assert.Equal(t, FudStateIdle, state.FudState)
+	assert.Empty(t, state.FudStrategy)
+	assert.Zero(t, state.FudPeakZScore)
+	assert.Zero(t, DefaultFudModeConfig().MaxHoldTime, "no hold limit unless the pair sets one")
+}
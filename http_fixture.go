@@ -0,0 +1,319 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+const fixtureRedacted = "REDACTED"
+
+// fixtureSecretParams are query and form parameters whose value is replaced
+// when recording, fixtureVolatileParams change on every call and are left
+// out of the recording and of request matching.
+var (
+	fixtureSecretParams   = map[string]bool{"apikey": true, "api_key": true, "key": true, "token": true}
+	fixtureVolatileParams = map[string]bool{"timestamp": true, "signature": true, "recvWindow": true}
+	fixtureKeptHeaders    = []string{"Content-Type", "Retry-After", "X-MBX-USED-WEIGHT-1M"}
+)
+
+// HTTPInteraction is one recorded request and its response, or the
+// transport error when the request never got one.
+type HTTPInteraction struct {
+	Method   string               `json:"method"`
+	URL      string               `json:"url"`
+	Body     string               `json:"body,omitempty"`
+	Response *HTTPFixtureResponse `json:"response,omitempty"`
+	Error    string               `json:"error,omitempty"`
+}
+
+type HTTPFixtureResponse struct {
+	Status  int               `json:"status"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    string            `json:"body"`
+}
+
+// HTTPCassette is the file format of a recorded session.
+type HTTPCassette struct {
+	Interactions []HTTPInteraction `json:"interactions"`
+}
+
+func LoadHTTPCassette(path string) (*HTTPCassette, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cassette: %w", err)
+	}
+	var cassette HTTPCassette
+	if err := json.Unmarshal(data, &cassette); err != nil {
+		return nil, fmt.Errorf("failed to parse cassette %s: %w", path, err)
+	}
+	return &cassette, nil
+}
+
+// RecordingTransport passes requests to the next transport and writes every
+// interaction to a cassette file with credentials scrubbed: auth headers are
+// not recorded, secret parameters and the given secret values are replaced
+// with REDACTED.
+type RecordingTransport struct {
+	path     string
+	next     http.RoundTripper
+	secrets  []string
+	mu       sync.Mutex
+	cassette HTTPCassette
+}
+
+func NewRecordingTransport(path string, next http.RoundTripper, secrets ...string) *RecordingTransport {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	var nonEmpty []string
+	for _, secret := range secrets {
+		if secret != "" {
+			nonEmpty = append(nonEmpty, secret)
+		}
+	}
+	return &RecordingTransport{path: path, next: next, secrets: nonEmpty}
+}
+
+func (t *RecordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := readFixtureBody(req)
+	if err != nil {
+		return nil, err
+	}
+
+	interaction := HTTPInteraction{
+		Method: req.Method,
+		URL:    t.scrub(scrubFixtureURL(req.URL)),
+		Body:   t.scrub(scrubFixtureBody(req.Header.Get("Content-Type"), body)),
+	}
+
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		interaction.Error = t.scrub(err.Error())
+		t.append(interaction)
+		return nil, err
+	}
+
+	respBody, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(respBody))
+
+	headers := make(map[string]string)
+	for _, name := range fixtureKeptHeaders {
+		if value := resp.Header.Get(name); value != "" {
+			headers[name] = value
+		}
+	}
+	interaction.Response = &HTTPFixtureResponse{Status: resp.StatusCode, Headers: headers, Body: t.scrub(string(respBody))}
+	t.append(interaction)
+	return resp, nil
+}
+
+func (t *RecordingTransport) scrub(value string) string {
+	for _, secret := range t.secrets {
+		value = strings.ReplaceAll(value, secret, fixtureRedacted)
+		value = strings.ReplaceAll(value, url.QueryEscape(secret), fixtureRedacted)
+	}
+	return value
+}
+
+func (t *RecordingTransport) append(interaction HTTPInteraction) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.cassette.Interactions = append(t.cassette.Interactions, interaction)
+
+	data, err := json.MarshalIndent(t.cassette, "", "  ")
+	if err == nil {
+		err = os.WriteFile(t.path, data, 0644)
+	}
+	if err != nil {
+		log.Printf("Failed to write HTTP recording %s: %v", t.path, err)
+	}
+}
+
+// ReplayTransport answers requests from a cassette without network access.
+// Requests are matched on method, path, query and form body without the
+// volatile and secret parameters; the host is ignored. Interactions with the
+// same request are served in recorded order and the last one repeats.
+type ReplayTransport struct {
+	mu     sync.Mutex
+	byKey  map[string][]HTTPInteraction
+	served map[string]int
+}
+
+func NewReplayTransport(cassette *HTTPCassette) *ReplayTransport {
+	t := &ReplayTransport{byKey: make(map[string][]HTTPInteraction), served: make(map[string]int)}
+	for _, interaction := range cassette.Interactions {
+		u, err := url.Parse(interaction.URL)
+		if err != nil {
+			continue
+		}
+		key := fixtureRequestKey(interaction.Method, u, interaction.Body)
+		t.byKey[key] = append(t.byKey[key], interaction)
+	}
+	return t
+}
+
+func LoadReplayTransport(path string) (*ReplayTransport, error) {
+	cassette, err := LoadHTTPCassette(path)
+	if err != nil {
+		return nil, err
+	}
+	return NewReplayTransport(cassette), nil
+}
+
+func (t *ReplayTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := readFixtureBody(req)
+	if err != nil {
+		return nil, err
+	}
+	key := fixtureRequestKey(req.Method, req.URL, scrubFixtureBody(req.Header.Get("Content-Type"), body))
+
+	t.mu.Lock()
+	interactions := t.byKey[key]
+	if len(interactions) == 0 {
+		t.mu.Unlock()
+		return nil, fmt.Errorf("no recorded response for %s", key)
+	}
+	index := min(t.served[key], len(interactions)-1)
+	t.served[key]++
+	t.mu.Unlock()
+
+	interaction := interactions[index]
+	if interaction.Error != "" || interaction.Response == nil {
+		return nil, errors.New(interaction.Error)
+	}
+
+	header := make(http.Header)
+	for name, value := range interaction.Response.Headers {
+		header.Set(name, value)
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", interaction.Response.Status, http.StatusText(interaction.Response.Status)),
+		StatusCode:    interaction.Response.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(strings.NewReader(interaction.Response.Body)),
+		ContentLength: int64(len(interaction.Response.Body)),
+		Request:       req,
+	}, nil
+}
+
+// readFixtureBody reads the request body and puts it back for the next
+// transport.
+func readFixtureBody(req *http.Request) (string, error) {
+	if req.Body == nil {
+		return "", nil
+	}
+	body, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return "", err
+	}
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	return string(body), nil
+}
+
+func scrubFixtureURL(u *url.URL) string {
+	scrubbed := *u
+	scrubbed.User = nil
+	scrubbed.RawQuery = scrubFixtureValues(u.Query())
+	return scrubbed.String()
+}
+
+func scrubFixtureBody(contentType, body string) string {
+	if !strings.HasPrefix(contentType, "application/x-www-form-urlencoded") {
+		return body
+	}
+	values, err := url.ParseQuery(body)
+	if err != nil {
+		return body
+	}
+	return scrubFixtureValues(values)
+}
+
+func scrubFixtureValues(values url.Values) string {
+	for name := range values {
+		if fixtureVolatileParams[name] {
+			values.Del(name)
+		} else if fixtureSecretParams[strings.ToLower(name)] {
+			values.Set(name, fixtureRedacted)
+		}
+	}
+	return values.Encode()
+}
+
+func fixtureRequestKey(method string, u *url.URL, body string) string {
+	key := method + " " + u.Path
+	if query := fixtureMatchValues(u.Query()); query != "" {
+		key += "?" + query
+	}
+	// Form bodies (signed exchange calls) are part of the key, JSON bodies
+	// such as LLM prompts are not.
+	if body != "" && !strings.HasPrefix(strings.TrimSpace(body), "{") {
+		if values, err := url.ParseQuery(body); err == nil {
+			if form := fixtureMatchValues(values); form != "" {
+				key += " " + form
+			}
+		}
+	}
+	return key
+}
+
+func fixtureMatchValues(values url.Values) string {
+	for name := range values {
+		if fixtureVolatileParams[name] || fixtureSecretParams[strings.ToLower(name)] {
+			values.Del(name)
+		}
+	}
+	return values.Encode()
+}
+
+// fixtureTransport wraps the transport of an external client named name
+// according to HTTP_RECORD_DIR (record the session to <dir>/<name>.json) or
+// HTTP_REPLAY_DIR (serve it from there). Without either it returns next.
+func fixtureTransport(name string, next http.RoundTripper) http.RoundTripper {
+	if dir := os.Getenv(ENV_HTTP_REPLAY_DIR); dir != "" {
+		replay, err := LoadReplayTransport(filepath.Join(dir, name+".json"))
+		if err != nil {
+			log.Printf("HTTP replay for %s unavailable, using the network: %v", name, err)
+			return next
+		}
+		log.Printf("Replaying %s HTTP traffic from %s", name, dir)
+		return replay
+	}
+	if dir := os.Getenv(ENV_HTTP_RECORD_DIR); dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			log.Printf("HTTP recording for %s disabled: %v", name, err)
+			return next
+		}
+		log.Printf("Recording %s HTTP traffic to %s", name, dir)
+		return NewRecordingTransport(filepath.Join(dir, name+".json"), next, fixtureSecrets()...)
+	}
+	return next
+}
+
+// fixtureSecrets are the configured credentials scrubbed from recordings.
+func fixtureSecrets() []string {
+	var secrets []string
+	for _, name := range []string{ENV_DEX_KEY, ENV_DEX_SECRET, ENV_BINANCE_KEY, ENV_BINANCE_SECRET, ENV_CLAUDE_API_KEY, ENV_API_EXTERNAL_SECRET, ENV_PROXY_DSN} {
+		if value := os.Getenv(name); value != "" {
+			secrets = append(secrets, value)
+		}
+	}
+	return secrets
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func fixtureGet(t *testing.T, client *http.Client, rawURL string) (int, string) {
+	req, err := http.NewRequest("GET", rawURL, nil)
+	require.NoError(t, err)
+	req.Header.Set("X-API-Key", "sk-secret-123")
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return resp.StatusCode, string(body)
+}
+
+func TestRecordingTransport_ScrubsSecretsAndReplays(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+		fmt.Fprintf(w, `{"path":%q,"echo":%q,"symbol":%q}`, r.URL.Path, r.Form.Get("apikey"), r.Form.Get("symbol"))
+	}))
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "session.json")
+	recorder := &http.Client{Transport: NewRecordingTransport(path, nil, "sk-secret-123")}
+
+	status, body := fixtureGet(t, recorder, server.URL+"/sentiment?apikey=sk-secret-123&limit=50")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Contains(t, body, "sk-secret-123", "the caller still sees the live response")
+	status, _ = fixtureGet(t, recorder, server.URL+"/missing")
+	assert.Equal(t, http.StatusNotFound, status)
+
+	form := url.Values{"symbol": {"SOLUSDT"}, "timestamp": {"1760000000000"}, "signature": {"abc"}}
+	resp, err := recorder.PostForm(server.URL+"/order", form)
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	recorded := string(data)
+	assert.NotContains(t, recorded, "sk-secret-123")
+	assert.NotContains(t, recorded, "X-API-Key")
+	assert.NotContains(t, recorded, "signature")
+	assert.NotContains(t, recorded, "1760000000000")
+	assert.Contains(t, recorded, "apikey=REDACTED")
+
+	replay, err := LoadReplayTransport(path)
+	require.NoError(t, err)
+	replayer := &http.Client{Transport: replay}
+
+	status, body = fixtureGet(t, replayer, "https://elsewhere.example/sentiment?limit=50&apikey=another-key")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, `{"path":"/sentiment","echo":"REDACTED","symbol":""}`, strings.TrimSpace(body))
+	status, _ = fixtureGet(t, replayer, "https://elsewhere.example/missing")
+	assert.Equal(t, http.StatusNotFound, status)
+
+	form.Set("timestamp", "1760000099999")
+	form.Set("signature", "def")
+	resp, err = replayer.PostForm("https://elsewhere.example/order", form)
+	require.NoError(t, err, "volatile parameters do not take part in matching")
+	replayed, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.Contains(t, string(replayed), `"symbol":"SOLUSDT"`)
+
+	form.Set("symbol", "ETHUSDT")
+	_, err = replayer.PostForm("https://elsewhere.example/order", form)
+	assert.ErrorContains(t, err, "no recorded response")
+}
+
+func TestReplayTransport_OrderAndTransportErrors(t *testing.T) {
+	replayer := &http.Client{Transport: NewReplayTransport(&HTTPCassette{Interactions: []HTTPInteraction{
+		{Method: "GET", URL: "https://api.example/poll", Response: &HTTPFixtureResponse{Status: 503, Body: "busy"}},
+		{Method: "GET", URL: "https://api.example/poll", Response: &HTTPFixtureResponse{Status: 200, Body: "ready"}},
+		{Method: "GET", URL: "https://api.example/down", Error: "dial tcp: connection refused"},
+	}})}
+
+	status, body := fixtureGet(t, replayer, "https://api.example/poll")
+	assert.Equal(t, 503, status)
+	assert.Equal(t, "busy", body)
+	for i := 0; i < 2; i++ {
+		status, body = fixtureGet(t, replayer, "https://api.example/poll")
+		assert.Equal(t, 200, status)
+		assert.Equal(t, "ready", body, "the last interaction repeats")
+	}
+
+	_, err := replayer.Get("https://api.example/down")
+	assert.ErrorContains(t, err, "connection refused")
+}
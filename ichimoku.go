@@ -2,7 +2,6 @@ package main
 
 import (
 	"math"
-	"strconv"
 )
 
 type IchimokuSignal string
@@ -49,7 +48,7 @@ type IchimokuResult struct {
 	Analysis IchimokuAnalysis
 }
 
-func CalculateIchimoku(klines []AsterDexKline) IchimokuResult {
+func CalculateIchimoku(klines []Candle) IchimokuResult {
 	if len(klines) < 52 {
 		return IchimokuResult{
 			Analysis: IchimokuAnalysis{
@@ -64,9 +63,9 @@ func CalculateIchimoku(klines []AsterDexKline) IchimokuResult {
 	closes := make([]float64, len(klines))
 
 	for i, k := range klines {
-		highs[i], _ = strconv.ParseFloat(k.High, 64)
-		lows[i], _ = strconv.ParseFloat(k.Low, 64)
-		closes[i], _ = strconv.ParseFloat(k.Close, 64)
+		highs[i] = k.High
+		lows[i] = k.Low
+		closes[i] = k.Close
 	}
 
 	tenkan := calculateTenkan(highs, lows)
@@ -226,7 +225,7 @@ func analyzeIchimoku(closes, tenkan, kijun, senkouA, senkouB []float64) Ichimoku
 	return analysis
 }
 
-func convertToLines(klines []AsterDexKline, values []float64) []IchimokuLine {
+func convertToLines(klines []Candle, values []float64) []IchimokuLine {
 	lines := make([]IchimokuLine, len(klines))
 	for i := range klines {
 		lines[i] = IchimokuLine{
@@ -237,7 +236,7 @@ func convertToLines(klines []AsterDexKline, values []float64) []IchimokuLine {
 	return lines
 }
 
-func convertToLinesShifted(klines []AsterDexKline, values []float64, shift int) []IchimokuLine {
+func convertToLinesShifted(klines []Candle, values []float64, shift int) []IchimokuLine {
 	lines := make([]IchimokuLine, len(klines))
 	for i := range klines {
 		shiftedIndex := i + shift
@@ -251,13 +250,12 @@ func convertToLinesShifted(klines []AsterDexKline, values []float64, shift int)
 	return lines
 }
 
-func convertPriceToLines(klines []AsterDexKline) []IchimokuLine {
+func convertPriceToLines(klines []Candle) []IchimokuLine {
 	lines := make([]IchimokuLine, len(klines))
 	for i, k := range klines {
-		close, _ := strconv.ParseFloat(k.Close, 64)
 		lines[i] = IchimokuLine{
 			Timestamp: k.OpenTime,
-			Value:     close,
+			Value:     k.Close,
 		}
 	}
 	return lines
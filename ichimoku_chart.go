@@ -3,11 +3,10 @@ package main
 import (
 	"fmt"
 	"math"
-	"strconv"
 	"strings"
 )
 
-func GenerateIchimokuSVG(klines []AsterDexKline, ichimoku IchimokuData, width, height int) string {
+func GenerateIchimokuSVG(klines []Candle, ichimoku IchimokuData, width, height int) string {
 	if len(klines) == 0 {
 		return ""
 	}
@@ -35,10 +34,7 @@ func GenerateIchimokuSVG(klines []AsterDexKline, ichimoku IchimokuData, width, h
 	drawLine(&svg, ichimoku.Tenkan, minPrice, priceRange, padding, chartWidth, chartHeight, candleWidth, "#06b6d4", 2)
 
 	for i, kline := range klines {
-		open, _ := strconv.ParseFloat(kline.Open, 64)
-		high, _ := strconv.ParseFloat(kline.High, 64)
-		low, _ := strconv.ParseFloat(kline.Low, 64)
-		close, _ := strconv.ParseFloat(kline.Close, 64)
+		open, high, low, close := kline.Open, kline.High, kline.Low, kline.Close
 
 		x := float64(padding) + float64(i)*candleWidth + candleWidth/2
 
@@ -168,13 +164,12 @@ func drawLine(svg *strings.Builder, line []IchimokuLine, minPrice, priceRange fl
 	}
 }
 
-func findPriceRangeWithIchimoku(klines []AsterDexKline, ichimoku IchimokuData) (float64, float64) {
+func findPriceRangeWithIchimoku(klines []Candle, ichimoku IchimokuData) (float64, float64) {
 	minPrice := math.MaxFloat64
 	maxPrice := -math.MaxFloat64
 
 	for _, kline := range klines {
-		high, _ := strconv.ParseFloat(kline.High, 64)
-		low, _ := strconv.ParseFloat(kline.Low, 64)
+		high, low := kline.High, kline.Low
 
 		if high > maxPrice {
 			maxPrice = high
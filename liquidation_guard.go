@@ -0,0 +1,200 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"time"
+)
+
+const (
+	CloseReasonLiquidationGuard  = "liquidation_guard_close"
+	DeRiskReasonLiquidationGuard = "liquidation_guard_reduce"
+)
+
+type LiquidationAction string
+
+const (
+	LiquidationActionNone   LiquidationAction = "none"
+	LiquidationActionWarn   LiquidationAction = "warn"
+	LiquidationActionTopUp  LiquidationAction = "top_up"
+	LiquidationActionReduce LiquidationAction = "reduce"
+	LiquidationActionClose  LiquidationAction = "close"
+)
+
+// LiquidationGuardConfig sets the distance from mark price to liquidation
+// price, in percent of the mark price, at which a position is warned about,
+// reduced once by ReduceFraction or closed. A zero threshold disables that
+// step. Isolated positions get up to MaxTopUps top-ups of TopUpAmount USDT
+// below TopUpDistancePercent before they are reduced. MarginBufferPercent is
+// the extra margin an order needs on top of its initial margin before it is
+// opened.
+type LiquidationGuardConfig struct {
+	WarnDistancePercent   float64
+	TopUpDistancePercent  float64
+	TopUpAmount           float64
+	MaxTopUps             int
+	ReduceDistancePercent float64
+	CloseDistancePercent  float64
+	ReduceFraction        float64
+	MarginBufferPercent   float64
+}
+
+func DefaultLiquidationGuardConfig() LiquidationGuardConfig {
+	return LiquidationGuardConfig{
+		WarnDistancePercent:   30,
+		ReduceDistancePercent: 15,
+		CloseDistancePercent:  7.5,
+		ReduceFraction:        0.5,
+		MarginBufferPercent:   20,
+	}
+}
+
+func GetLiquidationGuardConfig(pair TradingPair) LiquidationGuardConfig {
+	if pair.Liquidation == nil {
+		return DefaultLiquidationGuardConfig()
+	}
+	return *pair.Liquidation
+}
+
+// LiquidationDistancePercent is how far the mark price has to move against the
+// position to reach its liquidation price. It returns false when the exchange
+// reports no liquidation price.
+func LiquidationDistancePercent(position Position, markPrice float64) (float64, bool) {
+	if position.LiquidationPrice <= 0 || markPrice <= 0 {
+		return 0, false
+	}
+	distance := (markPrice - position.LiquidationPrice) / markPrice * 100
+	if position.Side == PositionSideShort || (position.Side == PositionSideBoth && position.Amount < 0) {
+		distance = -distance
+	}
+	return distance, true
+}
+
+type LiquidationRisk struct {
+	Action          LiquidationAction
+	DistancePercent float64
+	Reason          string
+}
+
+// EvaluateLiquidationRisk picks the strongest action whose threshold the
+// distance to liquidation has fallen below. topUps is the number of isolated
+// margin top-ups so far, reduced tells that the position was already reduced,
+// a second reduce step is escalated to a warning.
+func EvaluateLiquidationRisk(config LiquidationGuardConfig, position Position, markPrice float64, topUps int, reduced bool) LiquidationRisk {
+	distance, ok := LiquidationDistancePercent(position, markPrice)
+	if !ok {
+		return LiquidationRisk{Action: LiquidationActionNone, Reason: "no liquidation price"}
+	}
+	risk := LiquidationRisk{Action: LiquidationActionNone, DistancePercent: distance}
+	describe := func(limit float64) string {
+		return fmt.Sprintf("liquidation %.6f is %.2f%% from mark %.6f (limit %.2f%%, %s margin)",
+			position.LiquidationPrice, distance, markPrice, limit, position.MarginType)
+	}
+
+	switch {
+	case config.CloseDistancePercent > 0 && distance <= config.CloseDistancePercent:
+		risk.Action = LiquidationActionClose
+		risk.Reason = describe(config.CloseDistancePercent)
+	case position.MarginType == string(MarginTypeIsolated) && config.TopUpDistancePercent > 0 && distance <= config.TopUpDistancePercent &&
+		config.TopUpAmount > 0 && topUps < config.MaxTopUps:
+		risk.Action = LiquidationActionTopUp
+		risk.Reason = describe(config.TopUpDistancePercent)
+	case config.ReduceDistancePercent > 0 && distance <= config.ReduceDistancePercent && !reduced && config.ReduceFraction > 0 && config.ReduceFraction < 1:
+		risk.Action = LiquidationActionReduce
+		risk.Reason = describe(config.ReduceDistancePercent)
+	case config.ReduceDistancePercent > 0 && distance <= config.ReduceDistancePercent:
+		risk.Action = LiquidationActionWarn
+		risk.Reason = describe(config.ReduceDistancePercent)
+	case config.WarnDistancePercent > 0 && distance <= config.WarnDistancePercent:
+		risk.Action = LiquidationActionWarn
+		risk.Reason = describe(config.WarnDistancePercent)
+	default:
+		risk.Reason = describe(config.WarnDistancePercent)
+	}
+	return risk
+}
+
+// applyLiquidationGuard checks the live position against its liquidation
+// price. It returns true when the position was reduced or closed, the cycle
+// then skips the position update as the snapshot no longer matches.
+func applyLiquidationGuard(ctx *StrategyContext, position Position, markPrice float64) bool {
+	pair := ctx.Pair
+	state := ctx.State
+	if state.CurrentPosition == PositionSideBoth {
+		return false
+	}
+
+	config := GetLiquidationGuardConfig(pair)
+	reduced := !state.LiquidationReducedAt.IsZero() && !state.LiquidationReducedAt.Before(state.OpenedAt)
+	risk := EvaluateLiquidationRisk(config, position, markPrice, state.LiquidationTopUps, reduced)
+	switch risk.Action {
+	case LiquidationActionWarn:
+		log.Printf("[%s] ⚠️ Liquidation warning: %s", pair.Symbol, risk.Reason)
+	case LiquidationActionTopUp:
+		log.Printf("[%s] 🛡️ Liquidation guard adding %.2f USDT isolated margin: %s", pair.Symbol, config.TopUpAmount, risk.Reason)
+		if err := ctx.Exchange.AddIsolatedMargin(pair.Symbol, state.CurrentPosition, config.TopUpAmount); err != nil {
+			log.Printf("[%s] Failed to add isolated margin: %v", pair.Symbol, err)
+			return false
+		}
+		state.LiquidationTopUps++
+		if err := UpdatePositionMarginAdded(state.PositionUUID, config.TopUpAmount); err != nil {
+			log.Printf("[%s] Failed to record margin top-up: %v", pair.Symbol, err)
+		}
+		return true
+	case LiquidationActionReduce:
+		quantity := reduceQuantity(pair, position.Amount, config.ReduceFraction)
+		if quantity <= 0 {
+			log.Printf("[%s] ⚠️ Liquidation warning, position too small to reduce: %s", pair.Symbol, risk.Reason)
+			return false
+		}
+		log.Printf("[%s] 🛡️ Liquidation guard reducing %g: %s", pair.Symbol, quantity, risk.Reason)
+		if err := ctx.Exchange.ReducePosition(pair.Symbol, state.CurrentPosition, quantity); err != nil {
+			log.Printf("[%s] Failed to reduce position: %v", pair.Symbol, err)
+			return false
+		}
+		state.LiquidationReducedAt = time.Now()
+		if err := UpdatePositionDeRisk(state.PositionUUID, DeRiskReasonLiquidationGuard, quantity); err != nil {
+			log.Printf("[%s] Failed to record position de-risk: %v", pair.Symbol, err)
+		}
+		return true
+	case LiquidationActionClose:
+		log.Printf("[%s] 🛡️ Liquidation guard closing position: %s", pair.Symbol, risk.Reason)
+		if _, _, err := closeStatePosition(ctx.Exchange, pair, state, state.CurrentPosition, CloseReasonLiquidationGuard); err != nil {
+			log.Printf("[%s] Failed to close on liquidation guard: %v", pair.Symbol, err)
+			return false
+		}
+		return true
+	}
+	return false
+}
+
+type MarginCheck struct {
+	Allowed   bool
+	Required  float64
+	Available float64
+	Reason    string
+}
+
+// CheckAvailableMargin verifies that the available USDT balance covers the
+// initial margin of a new order plus the pair's margin buffer.
+func CheckAvailableMargin(exchange Exchange, pair TradingPair, markPrice float64) (MarginCheck, error) {
+	leverage := pair.Leverage
+	if leverage <= 0 {
+		leverage = 1
+	}
+	buffer := GetLiquidationGuardConfig(pair).MarginBufferPercent
+	check := MarginCheck{Required: markPrice * pair.Quantity / float64(leverage) * (1 + buffer/100)}
+
+	balance, err := exchange.GetBalanceInfo()
+	if err != nil {
+		return check, err
+	}
+	check.Available = balance.AvailableBalance
+	check.Allowed = check.Available >= check.Required
+	if check.Allowed {
+		check.Reason = fmt.Sprintf("available margin %.2f USDT covers %.2f USDT (incl. %.0f%% buffer)", check.Available, check.Required, buffer)
+	} else {
+		check.Reason = fmt.Sprintf("available margin %.2f USDT below required %.2f USDT (incl. %.0f%% buffer)", check.Available, check.Required, buffer)
+	}
+	return check, nil
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLiquidationDistancePercent(t *testing.T) {
+	tests := []struct {
+		name     string
+		position Position
+		mark     float64
+		distance float64
+		ok       bool
+	}{
+		{"long above liquidation", Position{Side: PositionSideLong, Amount: 10, LiquidationPrice: 80}, 100, 20, true},
+		{"short below liquidation", Position{Side: PositionSideShort, Amount: -10, LiquidationPrice: 120}, 100, 20, true},
+		{"one-way short by amount", Position{Side: PositionSideBoth, Amount: -10, LiquidationPrice: 110}, 100, 10, true},
+		{"one-way long by amount", Position{Side: PositionSideBoth, Amount: 10, LiquidationPrice: 90}, 100, 10, true},
+		{"long past liquidation", Position{Side: PositionSideLong, Amount: 10, LiquidationPrice: 105}, 100, -5, true},
+		{"no liquidation price", Position{Side: PositionSideLong, Amount: 10}, 100, 0, false},
+		{"no mark price", Position{Side: PositionSideShort, Amount: -10, LiquidationPrice: 120}, 0, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			distance, ok := LiquidationDistancePercent(tt.position, tt.mark)
+			assert.Equal(t, tt.ok, ok)
+			assert.InDelta(t, tt.distance, distance, 1e-9)
+		})
+	}
+}
+
+func TestEvaluateLiquidationRisk(t *testing.T) {
+	isolated := LiquidationGuardConfig{
+		WarnDistancePercent:   30,
+		TopUpDistancePercent:  20,
+		TopUpAmount:           25,
+		MaxTopUps:             2,
+		ReduceDistancePercent: 15,
+		CloseDistancePercent:  7.5,
+		ReduceFraction:        0.5,
+	}
+	long := func(liquidation float64, marginType MarginType) Position {
+		return Position{Side: PositionSideLong, Amount: 10, LiquidationPrice: liquidation, MarginType: string(marginType)}
+	}
+	short := func(liquidation float64) Position {
+		return Position{Side: PositionSideShort, Amount: -10, LiquidationPrice: liquidation, MarginType: string(MarginTypeCrossed)}
+	}
+
+	tests := []struct {
+		name     string
+		config   LiquidationGuardConfig
+		position Position
+		topUps   int
+		reduced  bool
+		action   LiquidationAction
+	}{
+		{"far away", DefaultLiquidationGuardConfig(), long(50, MarginTypeCrossed), 0, false, LiquidationActionNone},
+		{"warn", DefaultLiquidationGuardConfig(), long(75, MarginTypeCrossed), 0, false, LiquidationActionWarn},
+		{"reduce", DefaultLiquidationGuardConfig(), long(88, MarginTypeCrossed), 0, false, LiquidationActionReduce},
+		{"reduced once, then warn", DefaultLiquidationGuardConfig(), long(88, MarginTypeCrossed), 0, true, LiquidationActionWarn},
+		{"close", DefaultLiquidationGuardConfig(), long(95, MarginTypeCrossed), 0, true, LiquidationActionClose},
+		{"short close above mark", DefaultLiquidationGuardConfig(), short(105), 0, false, LiquidationActionClose},
+		{"short reduce", DefaultLiquidationGuardConfig(), short(112), 0, false, LiquidationActionReduce},
+		{"short liquidation below mark reads as passed", DefaultLiquidationGuardConfig(), short(50), 0, false, LiquidationActionClose},
+		{"no top-up by default", DefaultLiquidationGuardConfig(), long(82, MarginTypeIsolated), 0, false, LiquidationActionWarn},
+		{"isolated top-up before reduce", isolated, long(88, MarginTypeIsolated), 0, false, LiquidationActionTopUp},
+		{"top-ups used up", isolated, long(88, MarginTypeIsolated), 2, false, LiquidationActionReduce},
+		{"crossed margin is not topped up", isolated, long(88, MarginTypeCrossed), 0, false, LiquidationActionReduce},
+		{"close before top-up", isolated, long(95, MarginTypeIsolated), 0, false, LiquidationActionClose},
+		{"disabled steps", LiquidationGuardConfig{}, long(99, MarginTypeCrossed), 0, false, LiquidationActionNone},
+		{"no liquidation price", DefaultLiquidationGuardConfig(), long(0, MarginTypeCrossed), 0, false, LiquidationActionNone},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			risk := EvaluateLiquidationRisk(tt.config, tt.position, 100, tt.topUps, tt.reduced)
+			assert.Equal(t, tt.action, risk.Action, risk.Reason)
+		})
+	}
+}
+
+func TestCheckAvailableMargin(t *testing.T) {
+	exchange := newScenarioExchange()
+	exchange.balance = 119
+	pair := TradingPair{Symbol: "GIGGLEUSDT", Leverage: 5, Quantity: 5}
+
+	check, err := CheckAvailableMargin(exchange, pair, 100)
+	require.NoError(t, err)
+	assert.InDelta(t, 120, check.Required, 1e-9, "100 USDT initial margin plus the 20% buffer")
+	assert.False(t, check.Allowed, check.Reason)
+
+	exchange.balance = 120
+	check, err = CheckAvailableMargin(exchange, pair, 100)
+	require.NoError(t, err)
+	assert.True(t, check.Allowed, check.Reason)
+}
+
+func TestApplyLiquidationGuard_ReduceRoundsToTheLotStep(t *testing.T) {
+	openTestDatabase(t, "liquidation_reduce")
+	pair := scenarioPair()
+
+	reduce := func(amount float64) (*scenarioExchange, *TradingState, bool) {
+		exchange := newScenarioExchange()
+		exchange.SetPrice(pair.Symbol, 100, time.Now())
+		_, err := exchange.SimulatedExchange.OpenPosition(pair.Symbol, PositionSideLong, 1, amount)
+		require.NoError(t, err)
+		state := &TradingState{CurrentPosition: PositionSideLong, OpenedAt: time.Now().Add(-time.Hour)}
+		ctx := &StrategyContext{Pair: pair, State: state, Exchange: exchange}
+		position := Position{Symbol: pair.Symbol, Side: PositionSideLong, Amount: amount, LiquidationPrice: 88, MarginType: string(MarginTypeCrossed)}
+		return exchange, state, applyLiquidationGuard(ctx, position, 100)
+	}
+
+	exchange, state, acted := reduce(7)
+	assert.True(t, acted)
+	require.Len(t, exchange.orders, 1)
+	assert.Equal(t, "reduce", exchange.orders[0].Action)
+	assert.Equal(t, 3.0, exchange.orders[0].Quantity, "half of 7 rounded down to the whole units of Quantity 10")
+	assert.False(t, state.LiquidationReducedAt.IsZero())
+
+	exchange, state, acted = reduce(1)
+	assert.False(t, acted, "half a unit rounds to nothing")
+	assert.Empty(t, exchange.orders)
+	assert.True(t, state.LiquidationReducedAt.IsZero())
+}
@@ -0,0 +1,314 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/grutapig/fudtradebot/claude"
+)
+
+var ErrLLMBudgetExceeded = errors.New("daily LLM budget exceeded")
+
+// llmModelPrices are USD per million input and output tokens, matched by
+// model prefix. Unknown models are priced like Sonnet.
+var llmModelPrices = []struct {
+	prefix string
+	input  float64
+	output float64
+}{
+	{"claude-opus-4-5", 5, 25},
+	{"claude-opus-4", 15, 75},
+	{"claude-sonnet-4", 3, 15},
+	{"claude-3-7-sonnet", 3, 15},
+	{"claude-haiku-4-5", 1, 5},
+	{"claude-3-5-haiku", 0.8, 4},
+	{claude.MockModel, 0, 0},
+}
+
+const (
+	llmDefaultInputPrice  = 3.0
+	llmDefaultOutputPrice = 15.0
+)
+
+func LLMCallCost(model string, usage claude.Usage) float64 {
+	input, output := llmDefaultInputPrice, llmDefaultOutputPrice
+	for _, price := range llmModelPrices {
+		if strings.HasPrefix(model, price.prefix) {
+			input, output = price.input, price.output
+			break
+		}
+	}
+	return (float64(usage.InputTokens)*input + float64(usage.OutputTokens)*output) / 1e6
+}
+
+type llmCallInfoKey struct{}
+
+type llmCallInfo struct {
+	symbol       string
+	positionUUID string
+}
+
+// withLLMCallInfo tags the LLM calls made with ctx with the pair and
+// position they are made for.
+func withLLMCallInfo(ctx context.Context, symbol string, positionUUID string) context.Context {
+	return context.WithValue(ctx, llmCallInfoKey{}, llmCallInfo{symbol: symbol, positionUUID: positionUUID})
+}
+
+func llmCallInfoFrom(ctx context.Context) llmCallInfo {
+	info, _ := ctx.Value(llmCallInfoKey{}).(llmCallInfo)
+	return info
+}
+
+// LLMLedger stores every call of the wrapped LLM with its tokens, cost,
+// latency and outcome. Once the calls of the current UTC day cost
+// dailyBudget USD it refuses further calls with ErrLLMBudgetExceeded.
+type LLMLedger struct {
+	llm         claude.LLM
+	dailyBudget float64
+	now         func() time.Time
+}
+
+func NewLLMLedger(llm claude.LLM, dailyBudget float64) *LLMLedger {
+	return &LLMLedger{llm: llm, dailyBudget: dailyBudget, now: time.Now}
+}
+
+func (l *LLMLedger) Complete(ctx context.Context, request claude.CompletionRequest) (*claude.CompletionResponse, error) {
+	info := llmCallInfoFrom(ctx)
+	record := &LLMCallRecord{
+		Purpose:      request.Purpose,
+		Symbol:       info.symbol,
+		PositionUUID: info.positionUUID,
+		Model:        request.Model,
+		CreatedAt:    l.now(),
+	}
+
+	if l.BudgetExceeded() {
+		record.Outcome = LLMOutcomeOverBudget
+		l.save(record)
+		return nil, ErrLLMBudgetExceeded
+	}
+
+	started := time.Now()
+	response, err := l.llm.Complete(ctx, request)
+	record.LatencyMs = time.Since(started).Milliseconds()
+	if err != nil {
+		record.Outcome = LLMOutcomeError
+		record.Error = err.Error()
+	} else {
+		record.Model = response.Model
+		record.InputTokens = response.Usage.InputTokens
+		record.OutputTokens = response.Usage.OutputTokens
+		record.CostUSD = LLMCallCost(response.Model, response.Usage)
+		record.Outcome = LLMOutcomeOK
+		if request.Schema != nil {
+			raw := response.JSON
+			if len(raw) == 0 {
+				raw = claude.ExtractJSON(response.Text)
+			}
+			if err := request.Schema.Validate(raw); err != nil {
+				record.Outcome = LLMOutcomeInvalidOutput
+				record.Error = err.Error()
+			}
+		}
+	}
+	l.save(record)
+	return response, err
+}
+
+func (l *LLMLedger) save(record *LLMCallRecord) {
+	log.Printf("[%s] LLM %s call: %s, %d/%d tokens, $%.4f, %dms, %s", record.Symbol, record.Purpose, record.Model,
+		record.InputTokens, record.OutputTokens, record.CostUSD, record.LatencyMs, record.Outcome)
+	if err := SaveLLMCall(record); err != nil {
+		log.Printf("[%s] Failed to save LLM call: %v", record.Symbol, err)
+	}
+}
+
+// SpentToday is the cost of the calls since midnight UTC.
+func (l *LLMLedger) SpentToday() (float64, error) {
+	return GetLLMCostSince(l.now().UTC().Truncate(24 * time.Hour))
+}
+
+func (l *LLMLedger) DailyBudget() float64 {
+	return l.dailyBudget
+}
+
+// BudgetExceeded reports whether today's calls used up the daily budget.
+// Without a budget, or when the ledger cannot be read, calls go through.
+func (l *LLMLedger) BudgetExceeded() bool {
+	if l.dailyBudget <= 0 {
+		return false
+	}
+	spent, err := l.SpentToday()
+	if err != nil {
+		log.Printf("Failed to read LLM spending: %v", err)
+		return false
+	}
+	return spent >= l.dailyBudget
+}
+
+// llmAvailable returns the LLM for this cycle's AI checks, nil when there is
+// none or its ledger is over the daily budget.
+func llmAvailable(llm claude.LLM, symbol string) claude.LLM {
+	if ledger, ok := llm.(*LLMLedger); ok && ledger.BudgetExceeded() {
+		log.Printf("[%s] Daily LLM budget of $%.2f used up, skipping AI checks", symbol, ledger.DailyBudget())
+		return nil
+	}
+	return llm
+}
+
+type LLMPurposeCost struct {
+	Purpose      string  `json:"purpose"`
+	Calls        int     `json:"calls"`
+	Failed       int     `json:"failed"`
+	CostUSD      float64 `json:"cost_usd"`
+	AvgLatencyMs float64 `json:"avg_latency_ms"`
+	latencyTotal int64
+}
+
+type LLMDayCost struct {
+	Day     string  `json:"day"`
+	Calls   int     `json:"calls"`
+	CostUSD float64 `json:"cost_usd"`
+}
+
+// LLMTradeCost sets the LLM cost of a position against its P/L, realized
+// for closed positions and current for open ones.
+type LLMTradeCost struct {
+	PositionUUID string  `json:"position_uuid"`
+	Symbol       string  `json:"symbol"`
+	Side         string  `json:"side"`
+	IsClosed     bool    `json:"is_closed"`
+	Calls        int     `json:"calls"`
+	CostUSD      float64 `json:"cost_usd"`
+	PnL          float64 `json:"pnl"`
+	NetPnL       float64 `json:"net_pnl"`
+}
+
+// LLMPairCost is the LLM cost of a pair, including calls not tied to a
+// position, against the P/L of its trades that used the LLM.
+type LLMPairCost struct {
+	Symbol   string  `json:"symbol"`
+	Calls    int     `json:"calls"`
+	CostUSD  float64 `json:"cost_usd"`
+	Trades   int     `json:"trades"`
+	TradePnL float64 `json:"trade_pnl"`
+	NetPnL   float64 `json:"net_pnl"`
+}
+
+type LLMCostSummary struct {
+	Calls        int              `json:"calls"`
+	InputTokens  int              `json:"input_tokens"`
+	OutputTokens int              `json:"output_tokens"`
+	CostUSD      float64          `json:"cost_usd"`
+	ByPurpose    []LLMPurposeCost `json:"by_purpose"`
+	ByDay        []LLMDayCost     `json:"by_day"`
+	ByPair       []LLMPairCost    `json:"by_pair"`
+	Trades       []LLMTradeCost   `json:"trades"`
+}
+
+func SummarizeLLMCosts(calls []LLMCallRecord, positions []PositionRecord) LLMCostSummary {
+	summary := LLMCostSummary{
+		ByPurpose: []LLMPurposeCost{},
+		ByDay:     []LLMDayCost{},
+		ByPair:    []LLMPairCost{},
+		Trades:    []LLMTradeCost{},
+	}
+	purposes := make(map[string]*LLMPurposeCost)
+	days := make(map[string]*LLMDayCost)
+	pairs := make(map[string]*LLMPairCost)
+	trades := make(map[string]*LLMTradeCost)
+
+	for _, call := range calls {
+		summary.Calls++
+		summary.InputTokens += call.InputTokens
+		summary.OutputTokens += call.OutputTokens
+		summary.CostUSD += call.CostUSD
+
+		purpose, ok := purposes[call.Purpose]
+		if !ok {
+			purpose = &LLMPurposeCost{Purpose: call.Purpose}
+			purposes[call.Purpose] = purpose
+		}
+		purpose.Calls++
+		purpose.CostUSD += call.CostUSD
+		purpose.latencyTotal += call.LatencyMs
+		if call.Outcome != LLMOutcomeOK {
+			purpose.Failed++
+		}
+
+		dayKey := call.CreatedAt.UTC().Format("2006-01-02")
+		day, ok := days[dayKey]
+		if !ok {
+			day = &LLMDayCost{Day: dayKey}
+			days[dayKey] = day
+		}
+		day.Calls++
+		day.CostUSD += call.CostUSD
+
+		pair, ok := pairs[call.Symbol]
+		if !ok {
+			pair = &LLMPairCost{Symbol: call.Symbol}
+			pairs[call.Symbol] = pair
+		}
+		pair.Calls++
+		pair.CostUSD += call.CostUSD
+
+		if call.PositionUUID != "" {
+			trade, ok := trades[call.PositionUUID]
+			if !ok {
+				trade = &LLMTradeCost{PositionUUID: call.PositionUUID, Symbol: call.Symbol}
+				trades[call.PositionUUID] = trade
+			}
+			trade.Calls++
+			trade.CostUSD += call.CostUSD
+		}
+	}
+
+	for _, position := range positions {
+		trade, ok := trades[position.UUID]
+		if !ok {
+			continue
+		}
+		trade.Side = position.Side
+		trade.IsClosed = position.IsClosed
+		trade.PnL = position.CurrentPnL
+		if position.IsClosed {
+			trade.PnL = position.RealizedPL
+		}
+	}
+
+	for _, trade := range trades {
+		trade.NetPnL = trade.PnL - trade.CostUSD
+		summary.Trades = append(summary.Trades, *trade)
+		if pair, ok := pairs[trade.Symbol]; ok {
+			pair.Trades++
+			pair.TradePnL += trade.PnL
+		}
+	}
+	for _, purpose := range purposes {
+		purpose.AvgLatencyMs = float64(purpose.latencyTotal) / float64(purpose.Calls)
+		summary.ByPurpose = append(summary.ByPurpose, *purpose)
+	}
+	for _, day := range days {
+		summary.ByDay = append(summary.ByDay, *day)
+	}
+	for _, pair := range pairs {
+		pair.NetPnL = pair.TradePnL - pair.CostUSD
+		summary.ByPair = append(summary.ByPair, *pair)
+	}
+
+	sort.Slice(summary.ByPurpose, func(i, j int) bool { return summary.ByPurpose[i].Purpose < summary.ByPurpose[j].Purpose })
+	sort.Slice(summary.ByDay, func(i, j int) bool { return summary.ByDay[i].Day < summary.ByDay[j].Day })
+	sort.Slice(summary.ByPair, func(i, j int) bool { return summary.ByPair[i].Symbol < summary.ByPair[j].Symbol })
+	sort.Slice(summary.Trades, func(i, j int) bool {
+		if summary.Trades[i].CostUSD != summary.Trades[j].CostUSD {
+			return summary.Trades[i].CostUSD > summary.Trades[j].CostUSD
+		}
+		return summary.Trades[i].PositionUUID < summary.Trades[j].PositionUUID
+	})
+	return summary
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/grutapig/fudtradebot/claude"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func llmCalls(t *testing.T) []LLMCallRecord {
+	var records []LLMCallRecord
+	require.NoError(t, DB.Order("id").Find(&records).Error)
+	return records
+}
+
+func TestLLMCallCost(t *testing.T) {
+	usage := claude.Usage{InputTokens: 1000000, OutputTokens: 100000}
+	assert.InDelta(t, 4.5, LLMCallCost(claude.CLAUDE_45_MODEL, usage), 1e-9)
+	assert.InDelta(t, 1.5, LLMCallCost("claude-haiku-4-5-20251001", usage), 1e-9)
+	assert.InDelta(t, 22.5, LLMCallCost("claude-opus-4-1", usage), 1e-9)
+	assert.InDelta(t, 4.5, LLMCallCost("some-new-model", usage), 1e-9, "unknown models are priced like Sonnet")
+	assert.Zero(t, LLMCallCost(claude.MockModel, usage))
+}
+
+func TestLLMLedger_RecordsCallsAndBudget(t *testing.T) {
+	openTestDatabase(t, "llm_ledger_calls")
+	mock := claude.NewMockLLM(
+		`{"should_open_order": true, "confidence_percent": 80, "justification": "aligned"}`,
+		`{"should_open_order": "maybe"}`,
+	)
+	ledger := NewLLMLedger(mock, 0.01)
+	request := newLLMRequest(LLMPurposeOrderValidation, "You validate trades.", "Validate LONG GIGGLEUSDT", orderValidationSchema)
+	request.Model = claude.CLAUDE_45_MODEL
+	ctx := withLLMCallInfo(context.Background(), "GIGGLEUSDT", "pos-1")
+
+	_, err := ledger.Complete(ctx, request)
+	require.NoError(t, err)
+	_, err = ledger.Complete(ctx, request)
+	require.NoError(t, err)
+	mock.HandleFunc(func(claude.CompletionRequest) (string, error) { return "", errors.New("gruta_overloaded_529") })
+	_, err = ledger.Complete(context.Background(), request)
+	assert.EqualError(t, err, "gruta_overloaded_529")
+
+	records := llmCalls(t)
+	require.Len(t, records, 3)
+	assert.Equal(t, LLMOutcomeOK, records[0].Outcome)
+	assert.Equal(t, LLMPurposeOrderValidation, records[0].Purpose)
+	assert.Equal(t, "GIGGLEUSDT", records[0].Symbol)
+	assert.Equal(t, "pos-1", records[0].PositionUUID)
+	assert.Equal(t, claude.CLAUDE_45_MODEL, records[0].Model)
+	assert.Positive(t, records[0].InputTokens)
+	assert.InDelta(t, LLMCallCost(claude.CLAUDE_45_MODEL, claude.Usage{InputTokens: records[0].InputTokens, OutputTokens: records[0].OutputTokens}), records[0].CostUSD, 1e-12)
+	assert.Equal(t, LLMOutcomeInvalidOutput, records[1].Outcome)
+	assert.Contains(t, records[1].Error, "expected boolean")
+	assert.Equal(t, LLMOutcomeError, records[2].Outcome)
+	assert.Empty(t, records[2].Symbol)
+
+	spent, err := ledger.SpentToday()
+	require.NoError(t, err)
+	assert.InDelta(t, records[0].CostUSD+records[1].CostUSD, spent, 1e-12)
+	assert.False(t, ledger.BudgetExceeded())
+	assert.Equal(t, ledger, llmAvailable(ledger, "GIGGLEUSDT"))
+
+	require.NoError(t, SaveLLMCall(&LLMCallRecord{Purpose: LLMPurposeCloseAnalysis, CostUSD: 0.02, Outcome: LLMOutcomeOK, CreatedAt: time.Now()}))
+	assert.True(t, ledger.BudgetExceeded())
+	assert.Nil(t, llmAvailable(ledger, "GIGGLEUSDT"), "AI checks are skipped over budget")
+	_, err = ledger.Complete(ctx, request)
+	assert.ErrorIs(t, err, ErrLLMBudgetExceeded)
+	assert.Len(t, mock.Requests(), 3, "over budget the provider is not called")
+	records = llmCalls(t)
+	assert.Equal(t, LLMOutcomeOverBudget, records[len(records)-1].Outcome)
+
+	ledger.now = func() time.Time { return time.Now().Add(24 * time.Hour) }
+	assert.False(t, ledger.BudgetExceeded(), "the budget resets at midnight UTC")
+	assert.Nil(t, llmAvailable(nil, "GIGGLEUSDT"))
+}
+
+func TestSummarizeLLMCosts(t *testing.T) {
+	day := time.Date(2025, 10, 9, 12, 0, 0, 0, time.UTC)
+	calls := []LLMCallRecord{
+		{Purpose: LLMPurposeOrderValidation, Symbol: "GIGGLEUSDT", PositionUUID: "pos-1", CostUSD: 0.01, InputTokens: 900, OutputTokens: 60, LatencyMs: 2000, Outcome: LLMOutcomeOK, CreatedAt: day},
+		{Purpose: LLMPurposeCloseAnalysis, Symbol: "GIGGLEUSDT", PositionUUID: "pos-1", CostUSD: 0.03, LatencyMs: 4000, Outcome: LLMOutcomeInvalidOutput, CreatedAt: day.Add(time.Hour)},
+		{Purpose: LLMPurposeCloseAnalysis, Symbol: "GIGGLEUSDT", PositionUUID: "pos-2", CostUSD: 0.02, LatencyMs: 2000, Outcome: LLMOutcomeOK, CreatedAt: day.Add(24 * time.Hour)},
+		{Purpose: LLMPurposeSentiment, Symbol: "GIGGLEUSDT", CostUSD: 0.04, LatencyMs: 6000, Outcome: LLMOutcomeOK, CreatedAt: day.Add(25 * time.Hour)},
+	}
+	positions := []PositionRecord{
+		{UUID: "pos-1", Symbol: "GIGGLEUSDT", Side: "LONG", IsClosed: true, RealizedPL: 1.5, CurrentPnL: 1.2},
+		{UUID: "pos-2", Symbol: "GIGGLEUSDT", Side: "SHORT", CurrentPnL: -0.5},
+	}
+
+	summary := SummarizeLLMCosts(calls, positions)
+	assert.Equal(t, 4, summary.Calls)
+	assert.InDelta(t, 0.10, summary.CostUSD, 1e-9)
+	assert.Equal(t, 900, summary.InputTokens)
+
+	require.Len(t, summary.ByPurpose, 3)
+	assert.Equal(t, LLMPurposeCloseAnalysis, summary.ByPurpose[0].Purpose)
+	assert.Equal(t, 2, summary.ByPurpose[0].Calls)
+	assert.Equal(t, 1, summary.ByPurpose[0].Failed)
+	assert.Equal(t, 3000.0, summary.ByPurpose[0].AvgLatencyMs)
+
+	require.Len(t, summary.ByDay, 2)
+	assert.Equal(t, LLMDayCost{Day: "2025-10-09", Calls: 2, CostUSD: 0.04}, summary.ByDay[0])
+
+	require.Len(t, summary.Trades, 2)
+	assert.Equal(t, "pos-1", summary.Trades[0].PositionUUID, "the most expensive trade first")
+	assert.True(t, summary.Trades[0].IsClosed)
+	assert.Equal(t, 1.5, summary.Trades[0].PnL, "closed trades use the realized P/L")
+	assert.InDelta(t, 1.46, summary.Trades[0].NetPnL, 1e-9)
+	assert.Equal(t, -0.5, summary.Trades[1].PnL)
+
+	require.Len(t, summary.ByPair, 1)
+	assert.Equal(t, 2, summary.ByPair[0].Trades)
+	assert.InDelta(t, 1.0, summary.ByPair[0].TradePnL, 1e-9)
+	assert.InDelta(t, 0.9, summary.ByPair[0].NetPnL, 1e-9, "sentiment calls count against the pair")
+}
+
+func TestScenario_LLMLedgerLinksValidationToPosition(t *testing.T) {
+	s := newScenario(t, scenarioPair()).withClaude()
+	s.claude = NewLLMLedger(s.claude, 0)
+	s.setTrend("BTCUSDT", 90000, 110000)
+	s.setTrend(s.pair.Symbol, 80, 120)
+	s.services.queueVerdicts(scenarioApprove)
+
+	s.cycle()
+
+	require.Len(t, s.exchange.orders, 1)
+	records := llmCalls(t)
+	require.Len(t, records, 1)
+	assert.Equal(t, LLMPurposeOrderValidation, records[0].Purpose)
+	assert.Equal(t, s.state.PositionUUID, records[0].PositionUUID, "the validation is linked to the position it opened")
+	assert.InDelta(t, (900*3+60*15)/1e6, records[0].CostUSD, 1e-12)
+}
+
+func TestScenario_LLMBudgetSkipsAIChecks(t *testing.T) {
+	s := newScenario(t, scenarioPair()).withClaude()
+	s.claude = NewLLMLedger(s.claude, 0.001)
+	require.NoError(t, SaveLLMCall(&LLMCallRecord{Purpose: LLMPurposeSentiment, Symbol: s.pair.Symbol, CostUSD: 0.002, Outcome: LLMOutcomeOK, CreatedAt: time.Now()}))
+	s.setTrend("BTCUSDT", 90000, 110000)
+	s.setTrend(s.pair.Symbol, 80, 120)
+	s.services.queueVerdicts(scenarioReject)
+
+	s.cycle()
+
+	assert.Zero(t, s.services.calls(), "no LLM call over budget")
+	require.Len(t, s.exchange.orders, 1, "the order opens without AI validation")
+	assert.Empty(t, s.validations())
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/grutapig/fudtradebot/claude"
+)
+
+// What an LLM call is for. The purpose selects the model and temperature
+// overrides and is logged with every call.
+const (
+	LLMPurposeOrderValidation = "order_validation"
+	LLMPurposeCloseAnalysis   = "close_analysis"
+	LLMPurposeSentiment       = "sentiment"
+)
+
+// newLLMRequest builds a structured request for a purpose. CLAUDE_MODEL_<PURPOSE>
+// and CLAUDE_TEMPERATURE_<PURPOSE> override the client defaults per purpose.
+func newLLMRequest(purpose string, systemPrompt string, userMessage string, schema *claude.Schema) claude.CompletionRequest {
+	request := claude.CompletionRequest{
+		Purpose:    purpose,
+		Model:      os.Getenv(ENV_CLAUDE_MODEL + "_" + strings.ToUpper(purpose)),
+		System:     systemPrompt,
+		Messages:   claude.ClaudeMessages{{Role: claude.ROLE_USER, Content: userMessage}},
+		Schema:     schema,
+		SchemaName: purpose,
+	}
+	if value := os.Getenv(ENV_CLAUDE_TEMPERATURE + "_" + strings.ToUpper(purpose)); value != "" {
+		if temperature, err := strconv.ParseFloat(value, 32); err == nil {
+			request.Temperature = claude.Temperature(float32(temperature))
+		}
+	}
+	return request
+}
+
+var orderValidationSchema = claude.Object(
+	[]string{"should_open_order", "confidence_percent", "justification"},
+	map[string]*claude.Schema{
+		"should_open_order":  claude.Boolean("Whether to open the order"),
+		"confidence_percent": claude.Number("Confidence in the answer").Between(0, 100),
+		"justification":      claude.String("Short reasoning"),
+	},
+)
+
+var positionCloseSchema = claude.Object(
+	[]string{"should_close", "confidence_percent", "justification"},
+	map[string]*claude.Schema{
+		"should_close":       claude.Boolean("Whether to close the position now"),
+		"confidence_percent": claude.Number("Confidence in the answer").Between(0, 100),
+		"justification":      claude.String("Short reasoning"),
+		"expected_pnl":       claude.Number("Expected P/L percent if the position stays open"),
+		"risk_assessment":    claude.String("Main risks of keeping the position"),
+	},
+)
+
+// Scores are clamped after parsing, so the schema does not bound them.
+var tweetAnalysisSchema = claude.Object(
+	[]string{"sentiment", "fud_attack"},
+	map[string]*claude.Schema{
+		"sentiment": claude.Object(
+			[]string{"overall_sentiment", "sentiment_trend", "fud_level", "confidence"},
+			map[string]*claude.Schema{
+				"overall_sentiment": claude.Integer("0-10, 5 is neutral"),
+				"sentiment_trend":   claude.String("Direction from older to newer tweets", "improving", "stable", "declining"),
+				"fud_level":         claude.Integer("0-10"),
+				"confidence":        claude.Number("0.0-1.0"),
+				"key_themes":        claude.Array("Main themes", claude.String("")),
+				"recommendation":    claude.String("One or two sentences for a trader"),
+			},
+		),
+		"fud_attack": claude.Object(
+			[]string{"has_attack", "confidence"},
+			map[string]*claude.Schema{
+				"has_attack":           claude.Boolean("Whether a coordinated FUD attack is under way"),
+				"confidence":           claude.Number("0.0-1.0"),
+				"message_count":        claude.Integer("FUD tweets belonging to the attack"),
+				"fud_type":             claude.String("Kind of attack, empty without one"),
+				"theme":                claude.String("What the attack claims"),
+				"started_hours_ago":    claude.Integer("Hours since the first tweet of the attack"),
+				"last_attack_tweet_id": claude.String("ID of the newest tweet of the attack"),
+				"justification":        claude.String("Why this is or is not a coordinated attack"),
+			},
+		),
+	},
+)
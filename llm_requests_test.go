@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/grutapig/fudtradebot/claude"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateOrderWithAI_PurposeSettingsAndRepair(t *testing.T) {
+	t.Setenv(ENV_CLAUDE_MODEL+"_ORDER_VALIDATION", "claude-haiku-4-5")
+	t.Setenv(ENV_CLAUDE_TEMPERATURE+"_ORDER_VALIDATION", "0.2")
+	llm := claude.NewMockLLM(
+		`{"should_open_order": "yes", "confidence_percent": 80, "justification": "aligned"}`,
+		`{"should_open_order": true, "confidence_percent": 80, "justification": "aligned"}`,
+	)
+
+	result, err := ValidateOrderWithAI(context.Background(), llm, TradingDecisionResult{Signal: SignalLong}, IchimokuAnalysis{}, IchimokuAnalysis{},
+		ActivityAnalysis{}, ActivityAnalysis{}, FudShareAnalysis{}, ClaudeSentimentResponse{}, RegimeAnalysis{}, CorrelationAnalysis{})
+	require.NoError(t, err)
+	assert.Equal(t, ClaudeOrderValidationResponse{ShouldOpenOrder: true, ConfidencePercent: 80, Justification: "aligned"}, result)
+
+	requests := llm.Requests()
+	require.Len(t, requests, 2, "the invalid answer is repaired once")
+	assert.Equal(t, LLMPurposeOrderValidation, requests[0].Purpose)
+	assert.Equal(t, "claude-haiku-4-5", requests[0].Model)
+	require.NotNil(t, requests[0].Temperature)
+	assert.InDelta(t, 0.2, *requests[0].Temperature, 1e-6)
+	assert.Equal(t, orderValidationSchema, requests[0].Schema)
+
+	llm.Queue(`{"should_open_order": true}`, `{"confidence_percent": 20}`)
+	_, err = ValidateOrderWithAI(context.Background(), llm, TradingDecisionResult{Signal: SignalLong}, IchimokuAnalysis{}, IchimokuAnalysis{},
+		ActivityAnalysis{}, ActivityAnalysis{}, FudShareAnalysis{}, ClaudeSentimentResponse{}, RegimeAnalysis{}, CorrelationAnalysis{})
+	assert.ErrorIs(t, err, claude.ErrInvalidOutput)
+}
+
+func TestNewLLMRequest_Defaults(t *testing.T) {
+	request := newLLMRequest(LLMPurposeCloseAnalysis, "system", "user", positionCloseSchema)
+	assert.Empty(t, request.Model, "the client default model is used")
+	assert.Nil(t, request.Temperature)
+	assert.Equal(t, LLMPurposeCloseAnalysis, request.SchemaName)
+	require.Len(t, request.Messages, 1)
+	assert.Equal(t, claude.ROLE_USER, request.Messages[0].Role)
+}
@@ -32,8 +32,26 @@ var TradingPairs = []TradingPair{
 
 func main() {
 	webOnly := flag.Bool("web-only", false, "Start only web server without trading")
+	research := flag.Bool("research", false, "Run the sentiment-price research report and exit")
+	researchDays := flag.Int("research-days", ResearchDefaultDays, "Days of history for the research report")
+	researchFormat := flag.String("research-format", "json", "Research report format: json or csv")
+	researchOutput := flag.String("research-output", "", "Research report file (default stdout)")
+	fudBacktest := flag.Bool("fud-backtest", false, "Replay stored FUD attacks for both FUD strategies and exit")
+	optimize := flag.Bool("optimize", false, "Run the walk-forward parameter optimizer and exit")
+	optimizeTarget := flag.String("optimize-target", StrategySentimentIchimoku, "Optimizer target: a strategy name, fud_short or fud_contrarian")
+	optimizeSymbol := flag.String("optimize-symbol", "", "Optimize only this pair (default all pairs)")
+	optimizeDays := flag.Int("optimize-days", OptimizeDefaultDays, "Days of history for the optimizer")
+	optimizeFolds := flag.Int("optimize-folds", OptimizeDefaultFolds, "Walk-forward folds")
+	optimizeSamples := flag.Int("optimize-samples", 0, "Evaluate a random subset of the parameter grid (default the full grid)")
+	optimizeTop := flag.Int("optimize-top", OptimizeDefaultTop, "Ranked parameter sets to print per pair")
+	optimizeOutput := flag.String("optimize-output", "", "Write the optimizer reports as JSON to this file")
+	mockExchange := flag.String("mock-exchange", "", "Serve the built-in mock exchange on this address (e.g. :8091) and exit when it stops")
 	flag.Parse()
 
+	if *mockExchange != "" {
+		log.Fatalf("Mock exchange failed: %v", runMockExchangeCommand(*mockExchange))
+	}
+
 	log.Println("Starting trading bot...")
 	godotenv.Load()
 
@@ -49,6 +67,36 @@ func main() {
 	}
 	log.Println("Database initialized successfully")
 
+	if *research {
+		if err := runResearchCommand(*researchDays, *researchFormat, *researchOutput); err != nil {
+			log.Fatalf("Research failed: %v", err)
+		}
+		return
+	}
+
+	if *fudBacktest {
+		exchanges, err := NewExchanges(TradingPairs, os.Getenv(ENV_PROXY_DSN))
+		if err != nil {
+			log.Fatalf("FUD backtest failed: %v", err)
+		}
+		runFudBacktestCommand(exchanges, *researchDays)
+		return
+	}
+
+	if *optimize {
+		options := OptimizeOptions{
+			Target:  *optimizeTarget,
+			Days:    *optimizeDays,
+			Folds:   *optimizeFolds,
+			Samples: *optimizeSamples,
+			Top:     *optimizeTop,
+		}
+		if err := runOptimizeCommand(*optimizeSymbol, options, *optimizeOutput); err != nil {
+			log.Fatalf("Optimizer failed: %v", err)
+		}
+		return
+	}
+
 	go StartWebServer()
 
 	apiKey := os.Getenv(ENV_DEX_KEY)
@@ -70,50 +118,81 @@ func main() {
 		log.Println("Warning: CLAUDE_API_KEY not set, sentiment analysis will be disabled")
 	}
 
-	var exchange AsterDexExchange
 	var activityClient ExternalActivityClient
-	var err error
+	analysis, err := NewExternalAnalysisProviderFromEnv()
+	if err != nil {
+		log.Fatalf("Failed to create external analysis client: %v", err)
+	}
+
+	exchanges, err := NewExchanges(TradingPairs, proxyDSN)
+	if err != nil {
+		log.Fatalf("Failed to create exchanges: %v", err)
+	}
 
 	if proxyDSN != "" {
 		log.Printf("Initializing clients with proxy")
 
-		exchange, err = NewAsterDexExchangeWithProxy(apiKey, secretKey, proxyDSN)
-		if err != nil {
-			log.Fatalf("Failed to create exchange with proxy: %v", err)
-		}
-
 		activityClient, err = NewExternalActivityClientWithProxy(grufenderApiURL, proxyDSN)
 		if err != nil {
 			log.Fatalf("Failed to create activity client with proxy: %v", err)
 		}
 	} else {
-		exchange = NewAsterDexExchange(apiKey, secretKey)
 		activityClient = NewExternalActivityClient(grufenderApiURL)
 	}
 
-	var claudeClient *claude.ClaudeApi
+	var claudeClient claude.LLM
 	if claudeAPIKey != "" {
-		claudeClient, err = claude.NewClaudeClient(claudeAPIKey, proxyDSN, claude.CLAUDE_45_MODEL)
+		claudeModel := os.Getenv(ENV_CLAUDE_MODEL)
+		if claudeModel == "" {
+			claudeModel = claude.CLAUDE_45_MODEL
+		}
+		client, err := claude.NewClaudeClient(claudeAPIKey, proxyDSN, claudeModel)
 		if err != nil {
 			log.Fatalf("Failed to create Claude client: %v", err)
 		}
-		claudeClient.SetMaxTokens(4000)
+		client.SetMaxTokens(4000)
+		client.SetTransport(fixtureTransport("claude", client.Transport()))
+		claudeClient = NewLLMLedger(client, getEnvAsFloat(ENV_LLM_DAILY_BUDGET, 0))
 	}
 
-	go runBalanceCollector(exchange)
+	var tweetAnalyzer *TweetAnalyzer
+	for _, pair := range TradingPairs {
+		if pair.TweetAnalysis != TweetAnalysisOff && claudeClient != nil && tweetAnalyzer == nil {
+			tweetAnalyzer, err = NewTweetAnalyzer(NewTweetArchive(activityClient), claudeClient, DefaultTweetAnalysisConfig())
+			if err != nil {
+				log.Fatalf("Failed to create tweet analyzer: %v", err)
+			}
+		}
+	}
+
+	SetResearchExchanges(exchanges)
+
+	go runBalanceCollector(exchanges[VenueAsterDex])
+	go runTweetIngestion(activityClient, TradingPairs)
 
 	if *webOnly {
 		log.Println("Running in WEB-ONLY mode - trading disabled")
 		select {}
 	}
 
+	for _, pair := range TradingPairs {
+		if _, err := NewStrategy(pair.Strategy); err != nil {
+			log.Fatalf("[%s] Invalid strategy config: %v", pair.Symbol, err)
+		}
+		for _, shadow := range pair.Shadows {
+			if _, err := NewStrategy(shadow); err != nil {
+				log.Fatalf("[%s] Invalid shadow strategy config: %v", pair.Symbol, err)
+			}
+		}
+	}
+
 	var wg sync.WaitGroup
 
 	for _, pair := range TradingPairs {
 		wg.Add(1)
 		go func(pair TradingPair) {
 			defer wg.Done()
-			runTradingLoop(exchange, activityClient, claudeClient, pair, claudeMinIntervalMinutes)
+			runTradingLoop(exchanges.For(pair), activityClient, NewPairAnalysisProvider(pair, analysis, tweetAnalyzer), claudeClient, pair, claudeMinIntervalMinutes)
 		}(pair)
 	}
 
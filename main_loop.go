@@ -1,14 +1,16 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"github.com/grutapig/fudtradebot/claude"
 	"log"
+	"math"
 	"time"
 )
 
-func runBalanceCollector(exchange AsterDexExchange) {
+func runBalanceCollector(exchange Exchange) {
 	ticker := time.NewTicker(1 * time.Minute)
 	defer ticker.Stop()
 
@@ -27,14 +29,42 @@ func runBalanceCollector(exchange AsterDexExchange) {
 	}
 }
 
-func runTradingLoop(exchange AsterDexExchange, activityClient ExternalActivityClient, claudeClient *claude.ClaudeApi, pair TradingPair, claudeMinIntervalMinutes int) {
-	state := TradingState{
-		CurrentPosition: PositionSideBoth,
+func runTradingLoop(exchange Exchange, activityClient ExternalActivityClient, analysis ExternalAnalysisProvider, claudeClient claude.LLM, pair TradingPair, claudeMinIntervalMinutes int) {
+	log.Printf("[%s] Starting trading loop for community %s", pair.Symbol, pair.CommunityID)
+
+	state := restoreTradingState(exchange, pair)
+
+	strategy, err := NewStrategy(pair.Strategy)
+	if err != nil {
+		log.Printf("[%s] Trading loop stopped: %v", pair.Symbol, err)
+		return
 	}
+	log.Printf("[%s] Using strategy %s", pair.Symbol, strategy.Name())
 
-	UpdateTradingState(pair.Symbol, &state)
+	shadows, err := NewShadowRunners(pair)
+	if err != nil {
+		log.Printf("[%s] Shadow strategies disabled: %v", pair.Symbol, err)
+	}
+	for _, shadow := range shadows {
+		log.Printf("[%s] Shadowing strategy %s (%s)", pair.Symbol, shadow.Label, shadow.Strategy.Name())
+	}
 
-	log.Printf("[%s] Starting trading loop for community %s", pair.Symbol, pair.CommunityID)
+	for {
+		if err := processTradingCycle(exchange, activityClient, analysis, claudeClient, pair, strategy, shadows, state, claudeMinIntervalMinutes); err != nil {
+			log.Printf("[%s] Error in trading cycle: %v", pair.Symbol, err)
+		}
+		time.Sleep(time.Second * 60)
+	}
+}
+
+// restoreTradingState builds the state of a pair on startup from the open
+// position on the exchange and its database record, and closes database
+// positions the exchange no longer has.
+func restoreTradingState(exchange Exchange, pair TradingPair) *TradingState {
+	state := &TradingState{
+		CurrentPosition: PositionSideBoth,
+	}
+	UpdateTradingState(pair.Symbol, state)
 
 	log.Printf("[%s] Restoring position state from exchange...", pair.Symbol)
 	position, err := exchange.GetPosition(pair.Symbol)
@@ -67,6 +97,14 @@ func runTradingLoop(exchange AsterDexExchange, activityClient ExternalActivityCl
 		dbPosition, err := GetOpenPositionBySymbolAndSide(pair.Symbol, string(position.Side))
 		if err == nil {
 			state.PositionUUID = dbPosition.UUID
+			if dbPosition.DeRiskedAt != nil && dbPosition.DeRiskReason == DeRiskReasonLiquidationGuard {
+				state.LiquidationReducedAt = *dbPosition.DeRiskedAt
+			} else if dbPosition.DeRiskedAt != nil {
+				state.TimeExitDeRiskedAt = *dbPosition.DeRiskedAt
+			}
+			if topUp := GetLiquidationGuardConfig(pair).TopUpAmount; topUp > 0 {
+				state.LiquidationTopUps = int(math.Round(dbPosition.MarginAdded / topUp))
+			}
 			log.Printf("[%s] ✓ Imported position UUID from database: %s", pair.Symbol, state.PositionUUID)
 		} else {
 			state.PositionUUID = GeneratePositionUUID()
@@ -79,6 +117,7 @@ func runTradingLoop(exchange AsterDexExchange, activityClient ExternalActivityCl
 				Leverage:   pair.Leverage,
 				Quantity:   pair.Quantity,
 				EntryPrice: position.EntryPrice,
+				MarginType: effectiveMarginType(position, pair),
 				OpenedAt:   position.Timestamp,
 				OpenReason: "restored_from_exchange",
 				MaxPnL:     position.UnrealizedPL,
@@ -95,23 +134,27 @@ func runTradingLoop(exchange AsterDexExchange, activityClient ExternalActivityCl
 		log.Printf("[%s] ✓ No existing position found, starting fresh", pair.Symbol)
 	}
 
-	for {
-		if err := processTradingCycle(exchange, activityClient, claudeClient, pair, &state, claudeMinIntervalMinutes); err != nil {
-			log.Printf("[%s] Error in trading cycle: %v", pair.Symbol, err)
-		}
-		time.Sleep(time.Second * 60)
-	}
+	restoreFudState(pair, state)
+	return state
 }
 
-func processTradingCycle(exchange AsterDexExchange, activityClient ExternalActivityClient, claudeClient *claude.ClaudeApi, pair TradingPair, state *TradingState, claudeMinIntervalMinutes int) error {
-	log.Printf("\n========== [%s] Starting analysis cycle ==========", pair.Symbol)
+func processTradingCycle(exchange Exchange, activityClient ExternalActivityClient, analysis ExternalAnalysisProvider, claudeClient claude.LLM, pair TradingPair, strategy Strategy, shadows []*ShadowRunner, state *TradingState, claudeMinIntervalMinutes int) error {
+	log.Printf("\n========== [%s] Starting analysis cycle (%s) ==========", pair.Symbol, strategy.Name())
 	if state.CurrentPosition != PositionSideBoth {
 		log.Printf("[%s] Current position: %v (opened %v ago)", pair.Symbol, state.CurrentPosition, time.Since(state.OpenedAt).Round(time.Minute))
 	} else {
 		log.Printf("[%s] Current position: no active position", pair.Symbol)
 	}
 
-	var lastFudAttack ClaudeFudAttackResponse
+	ctx := &StrategyContext{
+		Now:            time.Now(),
+		Pair:           pair,
+		State:          state,
+		Exchange:       exchange,
+		ActivityClient: activityClient,
+		Analysis:       analysis,
+		Claude:         llmAvailable(claudeClient, pair.Symbol),
+	}
 
 	currentPosition, err := exchange.GetPosition(pair.Symbol)
 	if err != nil {
@@ -130,33 +173,65 @@ func processTradingCycle(exchange AsterDexExchange, activityClient ExternalActiv
 				pair.Symbol, currentPosition.UnrealizedPL, markPrice)
 		}
 
-		snapshotCount, err := CountPositionSnapshots(state.PositionUUID)
-		if err != nil {
-			log.Printf("[%s] Failed to count position snapshots: %v", pair.Symbol, err)
-		} else if snapshotCount > 0 && snapshotCount%10 == 0 {
-			if _, err := performAICloseAnalysis(claudeClient, exchange, activityClient, pair, state); err != nil {
-				log.Printf("[%s] Failed to perform AI close analysis: %v", pair.Symbol, err)
+		if applyLiquidationGuard(ctx, *currentPosition, markPrice) {
+			log.Printf("[%s] Liquidation guard acted, skipping position update", pair.Symbol)
+		} else {
+			snapshotCount, err := CountPositionSnapshots(state.PositionUUID)
+			if err != nil {
+				log.Printf("[%s] Failed to count position snapshots: %v", pair.Symbol, err)
+			} else {
+				intents, err := strategy.OnPositionUpdate(ctx, PositionUpdate{Position: *currentPosition, MarkPrice: markPrice, SnapshotCount: snapshotCount})
+				if err != nil {
+					log.Printf("[%s] Strategy %s failed on position update: %v", pair.Symbol, strategy.Name(), err)
+				} else if err := executeIntents(ctx, intents); err != nil {
+					log.Printf("[%s] Failed to execute position update intents: %v", pair.Symbol, err)
+				}
 			}
+
+			applyTimeExits(ctx, *currentPosition)
 		}
 	}
 
-	now := time.Now()
-	timestampTo := now.UnixMilli()
-	timestampFrom := now.Add(-7 * 24 * time.Hour).UnixMilli()
+	if err := collectCycleData(ctx); err != nil {
+		return err
+	}
 
-	log.Printf("[%s] Collecting market data...", pair.Symbol)
-	activityData, err := activityClient.GetCommunityActivity(pair.CommunityID, timestampFrom, timestampTo, "hour")
+	intents, err := strategy.OnCycle(ctx)
 	if err != nil {
-		log.Printf("[%s] Failed to get community activity: %v", pair.Symbol, err)
-		return err
+		err = fmt.Errorf("strategy %s: %w", strategy.Name(), err)
+	} else {
+		err = executeIntents(ctx, intents)
+	}
+
+	runShadowStrategies(ctx, shadows)
+	return err
+}
+
+// collectCycleData fetches market and community data and runs every analysis
+// a strategy may use. Side effects that do not depend on the strategy, such as
+// storing activity, sentiment and FUD attacks, happen here as well.
+func collectCycleData(ctx *StrategyContext) error {
+	pair := ctx.Pair
+	state := ctx.State
+	exchange := ctx.Exchange
+	activityClient := ctx.ActivityClient
+
+	position, err := exchange.GetPosition(pair.Symbol)
+	if err != nil {
+		log.Printf("[%s] Failed to refresh position: %v", pair.Symbol, err)
 	}
+	ctx.Position = position
+
+	now := ctx.Now
 
-	fudActivityData, err := activityClient.GetCommunityFudActivity(pair.CommunityID, timestampFrom, timestampTo, "hour")
+	log.Printf("[%s] Collecting market data...", pair.Symbol)
+	activityData, fudActivityData, err := fetchActivityHistory(activityClient, pair, state, now)
 	if err != nil {
-		log.Printf("[%s] Failed to get FUD activity: %v", pair.Symbol, err)
 		return err
 	}
 
+	recordActivityPoints(pair, state, activityData, fudActivityData)
+
 	btcKlines, err := exchange.Klines("BTCUSDT", KLINES_BTC_INTERVAL, 0, 0, 200)
 	if err != nil {
 		log.Printf("[%s] Failed to get BTC price data: %v", pair.Symbol, err)
@@ -167,6 +242,17 @@ func processTradingCycle(exchange AsterDexExchange, activityClient ExternalActiv
 		log.Printf("[%s] Failed to get coin price data: %v", pair.Symbol, err)
 		return err
 	}
+	btcHourlyKlines, err := exchange.Klines("BTCUSDT", KLINES_INTERVAL, 0, 0, CorrelationWindow+1)
+	if err != nil {
+		log.Printf("[%s] Failed to get BTC %s price data for correlation: %v", pair.Symbol, KLINES_INTERVAL, err)
+	}
+	var ethHourlyKlines []Candle
+	if pair.CorrelateETH {
+		ethHourlyKlines, err = exchange.Klines("ETHUSDT", KLINES_INTERVAL, 0, 0, CorrelationWindow+1)
+		if err != nil {
+			log.Printf("[%s] Failed to get ETH %s price data for correlation: %v", pair.Symbol, KLINES_INTERVAL, err)
+		}
+	}
 	log.Printf("[%s] Market data collected successfully", pair.Symbol)
 
 	log.Printf("\n[%s] ===== ANALYSIS RESULTS =====", pair.Symbol)
@@ -177,28 +263,53 @@ func processTradingCycle(exchange AsterDexExchange, activityClient ExternalActiv
 	coinIchimoku := CalculateIchimoku(coinKlines)
 	log.Printf("[%s] Coin Ichimoku: %s", pair.Symbol, coinIchimoku.Analysis.Signal)
 
+	regime := DetectMarketRegime(coinKlines, btcIchimoku.Analysis)
+	regimeRule := GetRegimeRule(pair, regime.Regime)
+	log.Printf("[%s] Market regime: %s (confidence %.0f%%) - %s", pair.Symbol, regime.Regime, regime.Confidence*100, regime.Description)
+	recordMarketRegime(pair, state, regime)
+
+	correlation := CalculateCorrelation(coinKlines, btcHourlyKlines, ethHourlyKlines, CorrelationWindow)
+	state.LastCorrelation = correlation
+	log.Printf("[%s] Correlation: %s", pair.Symbol, correlation.Description)
+
 	activityAnalysis := AnalyzeActivityTrend(activityData)
-	log.Printf("[%s] Community activity trend: %v", pair.Symbol, activityAnalysis.Trend)
+	log.Printf("[%s] Community activity trend: %v (confidence %.0f%%) - %s", pair.Symbol, activityAnalysis.Trend, activityAnalysis.Confidence*100, activityAnalysis.Description)
 
 	fudActivityAnalysis := AnalyzeFudActivityTrend(fudActivityData)
-	log.Printf("[%s] FUD activity trend: %v", pair.Symbol, fudActivityAnalysis.Trend)
+	log.Printf("[%s] FUD activity trend: %v (confidence %.0f%%) - %s", pair.Symbol, fudActivityAnalysis.Trend, fudActivityAnalysis.Confidence*100, fudActivityAnalysis.Description)
+
+	fudShare := AnalyzeFudShare(activityData, fudActivityData)
+	log.Printf("[%s] %s", pair.Symbol, fudShare.Description)
 
 	sentiment := ClaudeSentimentResponse{}
+	sentimentSource := ""
 	if time.Since(state.LastSentimentFetchTime) < 30*time.Minute && state.LastSentimentAnalysis.Confidence != 0 {
 		sentiment = state.LastSentimentAnalysis
+		sentimentSource = SentimentSourceCache
 		log.Printf("[%s] Using cached sentiment (last fetch: %v ago)", pair.Symbol, time.Since(state.LastSentimentFetchTime).Round(time.Second))
 	} else {
-		sentimentAnalysis, err := FetchExternalSentimentAnalysis(pair.CommunityID)
+		sentimentAnalysis, err := ctx.Analysis.FetchSentiment(withLLMCallInfo(context.Background(), pair.Symbol, state.PositionUUID), pair.CommunityID)
 		if err != nil {
 			log.Printf("[%s] Claude analysis failed: %v", pair.Symbol, err)
-			if state.LastSentimentAnalysis.Confidence != 0 {
+			tweets, tweetsErr := NewTweetArchive(activityClient).GetRecentTweets(pair.CommunityID, DefaultTweetAnalysisConfig().TweetLimit)
+			if offline := ScoreTweetsOffline(tweets); tweetsErr == nil && offline.Confidence != 0 {
+				sentiment = offline
+				sentimentSource = SentimentSourceLexicon
+				log.Printf("[%s] Offline lexicon sentiment: %d/10, Trend: %s, FUD level %d/10 (%d tweets)", pair.Symbol, offline.OverallSentiment, offline.SentimentTrend, offline.FudLevel, len(tweets))
+			} else if state.LastSentimentAnalysis.Confidence != 0 {
 				sentiment = state.LastSentimentAnalysis
+				sentimentSource = SentimentSourceCache
 			}
 		} else {
 			log.Printf("[%s] Sentiment: %d/10, Trend: %s", pair.Symbol, sentimentAnalysis.OverallSentiment, sentimentAnalysis.SentimentTrend)
 			sentiment = sentimentAnalysis
+			sentimentSource = SentimentSourceAnalysis
 			state.LastSentimentAnalysis = sentimentAnalysis
 			state.LastSentimentFetchTime = time.Now()
+			noteNewTweets(pair, "sentiment analysis", &state.LastAnalyzedTweetID)
+			if err := SaveSentiment(pair.Symbol, pair.CommunityID, sentimentAnalysis); err != nil {
+				log.Printf("[%s] Failed to save sentiment: %v", pair.Symbol, err)
+			}
 		}
 	}
 
@@ -207,31 +318,50 @@ func processTradingCycle(exchange AsterDexExchange, activityClient ExternalActiv
 		fudAttack = state.LastFudAttack
 		log.Printf("[%s] Using cached FUD attack (last fetch: %v ago)", pair.Symbol, time.Since(state.LastFudAttackFetchTime).Round(time.Second))
 	} else {
-		fudAttackResp, err := FetchExternalFudAttackAnalysis(pair.CommunityID)
+		fudAttackResp, err := ctx.Analysis.FetchFudAttack(withLLMCallInfo(context.Background(), pair.Symbol, state.PositionUUID), pair.CommunityID)
 		if err != nil {
 			log.Printf("[%s] FUD attack analysis failed: %v", pair.Symbol, err)
 			if state.LastFudAttack.Confidence != 0 {
 				fudAttack = state.LastFudAttack
 			}
 		} else {
+			ApplyParticipantCredibility(&fudAttackResp)
 			fudAttack = fudAttackResp
 			state.LastFudAttack = fudAttackResp
 			state.LastFudAttackFetchTime = time.Now()
+			noteNewTweets(pair, "FUD check", &state.LastFudCheckTweetID)
 
 			if err := SaveFudAttack(fudAttack, pair.Symbol, state.PositionUUID); err != nil {
 				log.Printf("[%s] Failed to save FUD attack to database: %v", pair.Symbol, err)
 			} else {
 				log.Printf("[%s] FUD attack analysis saved to database", pair.Symbol)
 			}
+
+			if fudAttack.HasAttack {
+				markPrice, err := exchange.GetMarkPrice(pair.Symbol)
+				if err != nil {
+					log.Printf("[%s] Failed to get mark price for FUD participants: %v", pair.Symbol, err)
+				}
+				if err := RegisterFudParticipants(fudAttack, pair, markPrice); err != nil {
+					log.Printf("[%s] Failed to register FUD participants: %v", pair.Symbol, err)
+				}
+			}
+		}
+	}
+
+	if time.Since(state.LastParticipantEval) > time.Hour {
+		if err := EvaluateFudParticipations(exchange, pair.Symbol); err != nil {
+			log.Printf("[%s] Failed to evaluate FUD participations: %v", pair.Symbol, err)
 		}
+		state.LastParticipantEval = time.Now()
 	}
 
 	if fudAttack.Confidence != 0 {
-		lastFudAttack = fudAttack
+		ctx.FudAttack = fudAttack
 		log.Printf("\n[%s] ===== FUD ATTACK ANALYSIS =====", pair.Symbol)
 		if fudAttack.HasAttack {
 			log.Printf("[%s] ⚠️  COORDINATED FUD ATTACK DETECTED!", pair.Symbol)
-			log.Printf("[%s]   Confidence: %.0f%%", pair.Symbol, fudAttack.Confidence*100)
+			log.Printf("[%s]   Confidence: %.0f%% (raw %.0f%%, participant credibility weight %.2f)", pair.Symbol, fudAttack.Confidence*100, fudAttack.RawConfidence*100, fudAttack.CredibilityWeight)
 			log.Printf("[%s]   Messages: %d", pair.Symbol, fudAttack.MessageCount)
 			log.Printf("[%s]   FUD Type: %s", pair.Symbol, fudAttack.FudType)
 			log.Printf("[%s]   Theme: %s", pair.Symbol, fudAttack.Theme)
@@ -244,16 +374,6 @@ func processTradingCycle(exchange AsterDexExchange, activityClient ExternalActiv
 				log.Printf("[%s]     - %s (%d messages)", pair.Symbol, p.Username, p.MessageCount)
 			}
 			log.Printf("[%s]   Justification: %s", pair.Symbol, fudAttack.Justification)
-
-			if fudAttack.LastAttackTime != nil {
-				timeSinceAttack := time.Since(*fudAttack.LastAttackTime)
-				if timeSinceAttack <= 1*time.Hour && !state.FudAttackMode {
-					log.Printf("[%s] 🚨 ACTIVATING FUD ATTACK TRADING MODE (attack is fresh: %.0f min ago)", pair.Symbol, timeSinceAttack.Minutes())
-					state.FudAttackMode = true
-					state.FudAttackStartTime = *fudAttack.LastAttackTime
-					state.FudAttackShortStarted = false
-				}
-			}
 		} else {
 			log.Printf("[%s] ✓ No coordinated FUD attack detected", pair.Symbol)
 			log.Printf("[%s]   Confidence: %.0f%%", pair.Symbol, fudAttack.Confidence*100)
@@ -262,408 +382,29 @@ func processTradingCycle(exchange AsterDexExchange, activityClient ExternalActiv
 	}
 	state.LastFudCheckTime = now
 
-	handledByFudMode, err := processFudAttackTradingCycle(exchange, pair, state, lastFudAttack, coinIchimoku.Analysis)
-	if err != nil {
-		log.Printf("[%s] Error in FUD attack trading cycle: %v", pair.Symbol, err)
-	}
-	if handledByFudMode {
-		log.Printf("[%s] Cycle handled by FUD attack mode", pair.Symbol)
-		return nil
-	}
-
-	if state.FudAttackMode {
-		log.Printf("[%s] 🚨 FUD ATTACK MODE: Opening forced SHORT position", pair.Symbol)
-
-		if state.CurrentPosition != PositionSideShort {
-			if state.CurrentPosition != PositionSideBoth {
-				log.Printf("[%s] Closing existing %s position", pair.Symbol, state.CurrentPosition)
-				if err := exchange.ClosePosition(pair.Symbol, state.CurrentPosition); err != nil {
-					log.Printf("[%s] Failed to close position: %v", pair.Symbol, err)
-					return err
-				}
-
-				if state.PositionUUID != "" {
-					markPrice, _ := exchange.GetMarkPrice(pair.Symbol)
-					closedPosition, _ := exchange.GetPosition(pair.Symbol)
-					realizedPL := 0.0
-					if closedPosition != nil {
-						realizedPL = closedPosition.UnrealizedPL
-					}
-					if err := UpdatePositionClose(state.PositionUUID, markPrice, realizedPL, "fud_mode_switch"); err != nil {
-						log.Printf("[%s] Failed to update position close: %v", pair.Symbol, err)
-					}
-				}
-
-				state.CurrentPosition = PositionSideBoth
-				state.PositionUUID = ""
-				state.OpenReason = ""
-			}
-
-			log.Printf("[%s] Opening forced SHORT position due to FUD attack", pair.Symbol)
-			position, err := exchange.OpenPosition(pair.Symbol, PositionSideShort, pair.Leverage, pair.Quantity)
-			if err != nil {
-				log.Printf("[%s] Failed to open SHORT: %v", pair.Symbol, err)
-				return err
-			}
-
-			state.CurrentPosition = PositionSideShort
-			state.OpenedAt = time.Now()
-			state.OpenReason = "fud_attack_forced"
-			state.PositionUUID = GeneratePositionUUID()
-
-			log.Printf("[%s] FUD SHORT position opened: entry %.6f, amount %.6f, UUID: %s",
-				pair.Symbol, position.EntryPrice, position.Amount, state.PositionUUID)
-
-			positionRecord := PositionRecord{
-				UUID:       state.PositionUUID,
-				Symbol:     pair.Symbol,
-				Side:       string(PositionSideShort),
-				Leverage:   pair.Leverage,
-				Quantity:   pair.Quantity,
-				EntryPrice: position.EntryPrice,
-				OpenedAt:   state.OpenedAt,
-				OpenReason: "fud_attack_forced",
-				MaxPnL:     position.UnrealizedPL,
-				MinPnL:     position.UnrealizedPL,
-				CreatedAt:  time.Now(),
-			}
-			if err := SavePositionOpen(positionRecord); err != nil {
-				log.Printf("[%s] Failed to save position to database: %v", pair.Symbol, err)
-			}
-		}
-
-		return nil
-	}
-
-	decision := MakeTradingDecision(btcIchimoku.Analysis, coinIchimoku.Analysis, activityAnalysis, fudActivityAnalysis, sentiment)
-	log.Printf("\n[%s] ===== DECISION: %s (reason: %s) =====", pair.Symbol, decision.Signal, decision.Reason)
-	log.Printf("[%s] Explanation: %s", pair.Symbol, decision.Explanation)
-
-	fudAttackInfo := "no"
-	if lastFudAttack.HasAttack {
-		fudAttackInfo = "yes"
-	}
-
-	decisionRecord := TradingDecisionRecord{
-		PositionUUID:        state.PositionUUID,
-		Symbol:              pair.Symbol,
-		BTCIchimoku:         decision.BTCIchimokuSignal,
-		CoinIchimoku:        decision.CoinIchimokuSignal,
-		Activity:            decision.ActivitySignal,
-		FudActivity:         decision.FudActivitySignal,
-		Sentiment:           decision.SentimentSignal,
-		FudAttack:           fudAttackInfo,
-		FinalDecision:       string(decision.Signal),
-		DecisionExplanation: decision.Explanation,
-		CreatedAt:           time.Now(),
-	}
-
-	shouldSave := false
-	lastDecision, err := GetLatestTradingDecision(pair.Symbol)
-	if err != nil {
-		log.Printf("[%s] Failed to get last decision: %v", pair.Symbol, err)
-		shouldSave = true
-	} else if lastDecision == nil {
-		shouldSave = true
-	} else if lastDecision.BTCIchimoku != decisionRecord.BTCIchimoku ||
-		lastDecision.CoinIchimoku != decisionRecord.CoinIchimoku ||
-		lastDecision.Activity != decisionRecord.Activity ||
-		lastDecision.FudActivity != decisionRecord.FudActivity ||
-		lastDecision.Sentiment != decisionRecord.Sentiment ||
-		lastDecision.FudAttack != decisionRecord.FudAttack ||
-		lastDecision.FinalDecision != decisionRecord.FinalDecision {
-		shouldSave = true
-	}
-
-	var savedDecision *TradingDecisionRecord
-	var decisionChanged bool
-	if shouldSave {
-		decisionChanged = true
-		if err := SaveTradingDecision(decisionRecord); err != nil {
-			log.Printf("[%s] Failed to save trading decision: %v", pair.Symbol, err)
-		} else {
-			log.Printf("[%s] Trading decision saved to database", pair.Symbol)
-			lastSaved, _ := GetLatestTradingDecision(pair.Symbol)
-			savedDecision = lastSaved
-		}
-	}
-
-	//close position
-	if state.CurrentPosition != PositionSideBoth {
-		currentPosition, _ := exchange.GetPosition(pair.Symbol)
-		currentPnL := 0.0
-		if currentPosition != nil {
-			currentPnL = currentPosition.UnrealizedPL
-		}
-
-		snapshots, _ := GetPositionSnapshotsByUUID(state.PositionUUID)
-		maSignal := CalculateMovingAveragePnLSignal(snapshots, currentPnL)
-
-		log.Printf("[%s] MA Signal: ShouldClose=%v, Current PnL=$%.2f, MA=$%.2f, Threshold=$%.2f",
-			pair.Symbol, maSignal.ShouldClose, maSignal.CurrentPnL, maSignal.MovingAverage, maSignal.Threshold)
-		log.Printf("[%s] MA Reason: %s", pair.Symbol, maSignal.TriggerReason)
-
-		if maSignal.ShouldClose {
-			log.Printf("[%s] 🚨 MOVING AVERAGE EXIT SIGNAL - Force closing position!", pair.Symbol)
-
-			var closedPosition *Position
-			if state.CurrentPosition == PositionSideLong {
-				if err := exchange.ClosePosition(pair.Symbol, PositionSideLong); err != nil {
-					log.Printf("[%s] Failed to close LONG: %v", pair.Symbol, err)
-					return err
-				}
-				closedPosition, _ = exchange.GetPosition(pair.Symbol)
-			} else if state.CurrentPosition == PositionSideShort {
-				if err := exchange.ClosePosition(pair.Symbol, PositionSideShort); err != nil {
-					log.Printf("[%s] Failed to close SHORT: %v", pair.Symbol, err)
-					return err
-				}
-				closedPosition, _ = exchange.GetPosition(pair.Symbol)
-			}
-
-			if state.PositionUUID != "" {
-				markPrice, _ := exchange.GetMarkPrice(pair.Symbol)
-				realizedPL := 0.0
-				if closedPosition != nil {
-					realizedPL = closedPosition.UnrealizedPL
-				}
-				if err := UpdatePositionClose(state.PositionUUID, markPrice, realizedPL, "moving_average_exit"); err != nil {
-					log.Printf("[%s] Failed to update position close: %v", pair.Symbol, err)
-				} else {
-					log.Printf("[%s] Position close recorded in database (MA exit)", pair.Symbol)
-				}
-			}
-
-			state.CurrentPosition = PositionSideBoth
-			state.PositionUUID = ""
-			state.OpenReason = ""
-			log.Printf("[%s] Position closed by Moving Average exit signal", pair.Symbol)
-			return nil
-		}
-
-		shouldClose := ShouldClosePosition(state.CurrentPosition, coinIchimoku)
-		if shouldClose && savedDecision != nil {
-			shouldClose, err := performAICloseAnalysis(claudeClient, exchange, activityClient, pair, state)
-			if err != nil {
-				log.Printf("[%s] Failed to perform AI close analysis: %v", pair.Symbol, err)
-			} else {
-				if !shouldClose {
-					log.Println("we should not close this position because of AI decision")
-					return nil
-				}
-			}
-			log.Printf("[%s] Ichimoku signals to close %s position", pair.Symbol, state.CurrentPosition)
-
-			var closedPosition *Position
-			if state.CurrentPosition == PositionSideLong {
-				if err := exchange.ClosePosition(pair.Symbol, PositionSideLong); err != nil {
-					log.Printf("[%s] Failed to close LONG: %v", pair.Symbol, err)
-					return err
-				}
-				closedPosition, _ = exchange.GetPosition(pair.Symbol)
-			} else if state.CurrentPosition == PositionSideShort {
-				if err := exchange.ClosePosition(pair.Symbol, PositionSideShort); err != nil {
-					log.Printf("[%s] Failed to close SHORT: %v", pair.Symbol, err)
-					return err
-				}
-				closedPosition, _ = exchange.GetPosition(pair.Symbol)
-			}
-
-			if state.PositionUUID != "" {
-				markPrice, _ := exchange.GetMarkPrice(pair.Symbol)
-				realizedPL := 0.0
-				if closedPosition != nil {
-					realizedPL = closedPosition.UnrealizedPL
-				}
-				if err := UpdatePositionClose(state.PositionUUID, markPrice, realizedPL, "ichimoku_exit"); err != nil {
-					log.Printf("[%s] Failed to update position close: %v", pair.Symbol, err)
-				} else {
-					log.Printf("[%s] Position close recorded in database", pair.Symbol)
-				}
-			}
-
-			state.CurrentPosition = PositionSideBoth
-			state.PositionUUID = ""
-			state.OpenReason = ""
-			log.Printf("[%s] Position closed by Ichimoku exit signal", pair.Symbol)
-		} else {
-			log.Printf("[%s] Position held - Ichimoku conditions not met for exit", pair.Symbol)
-		}
-		return nil
-	}
-
-	if decision.Signal == SignalEmpty {
-		log.Printf("[%s] No signal - no action", pair.Symbol)
-		return nil
-	}
-
-	desiredPosition := PositionSideBoth
-	if decision.Signal == SignalLong {
-		desiredPosition = PositionSideLong
-	} else if decision.Signal == SignalShort {
-		desiredPosition = PositionSideShort
-	}
-
-	if state.CurrentPosition == desiredPosition {
-		log.Printf("[%s] Position already matches signal - holding", pair.Symbol)
-		return nil
-	}
-
-	if !shouldSave {
-		log.Printf("[%s] Signal from cache - skipping order processing", pair.Symbol)
-		return nil
-	}
-
-	var validationRecord *AIOrderValidationRecord
-	if claudeClient != nil {
-		decisionKey := fmt.Sprintf("%s:%s", pair.Symbol, decision.Signal)
-		timeSinceLastRejection := time.Since(state.LastAIRejectionTime)
-		if !decisionChanged {
-			log.Printf("[%s] ⏳ AI rejected this signal %.0f min ago - blocking for %.0f more minutes",
-				pair.Symbol,
-				timeSinceLastRejection.Minutes(),
-				(10*time.Minute - timeSinceLastRejection).Minutes())
-			return nil
-		}
-
-		log.Printf("[%s] Validating order decision with AI...", pair.Symbol)
-		aiValidation, err := ValidateOrderWithAI(*claudeClient, decision, btcIchimoku.Analysis, coinIchimoku.Analysis, activityAnalysis, fudActivityAnalysis, sentiment)
-		if err != nil {
-			log.Printf("[%s] AI validation failed: %v", pair.Symbol, err)
-			log.Printf("[%s] Proceeding without AI validation", pair.Symbol)
-		} else {
-			log.Printf("[%s] AI Validation Result:", pair.Symbol)
-			log.Printf("[%s]   Should Open: %v", pair.Symbol, aiValidation.ShouldOpenOrder)
-			log.Printf("[%s]   Confidence: %.1f%%", pair.Symbol, aiValidation.ConfidencePercent)
-			log.Printf("[%s]   Justification: %s", pair.Symbol, aiValidation.Justification)
-
-			requestDataJSON, _ := json.Marshal(map[string]interface{}{
-				"decision":      decision,
-				"btc_ichimoku":  btcIchimoku.Analysis,
-				"coin_ichimoku": coinIchimoku.Analysis,
-				"activity":      activityAnalysis,
-				"fud_activity":  fudActivityAnalysis,
-				"sentiment":     sentiment,
-			})
-			responseDataJSON, _ := json.Marshal(aiValidation)
-
-			validationRecord = &AIOrderValidationRecord{
-				PositionUUID:      "",
-				DecisionRecordID:  0,
-				Symbol:            pair.Symbol,
-				RequestData:       string(requestDataJSON),
-				ResponseData:      string(responseDataJSON),
-				ShouldOpenOrder:   aiValidation.ShouldOpenOrder,
-				ConfidencePercent: aiValidation.ConfidencePercent,
-				Justification:     aiValidation.Justification,
-				CreatedAt:         time.Now(),
-			}
-
-			if savedDecision != nil && savedDecision.ID > 0 {
-				validationRecord.DecisionRecordID = savedDecision.ID
-			}
-
-			if !aiValidation.ShouldOpenOrder {
-				log.Printf("[%s] ❌ AI rejected the order - not opening position", pair.Symbol)
-				log.Printf("[%s] 🔒 Blocking this signal for 10 minutes", pair.Symbol)
-				state.LastAIRejectionTime = time.Now()
-				state.LastRejectedDecision = decisionKey
-				if err := SaveAIOrderValidation(validationRecord); err != nil {
-					log.Printf("[%s] Failed to save AI validation: %v", pair.Symbol, err)
-				} else {
-					log.Printf("[%s] AI validation saved to database", pair.Symbol)
-				}
-				return nil
-			}
-
-			log.Printf("[%s] ✅ AI approved the order - proceeding", pair.Symbol)
-		}
+	ctx.BTCIchimoku = btcIchimoku
+	ctx.CoinIchimoku = coinIchimoku
+	ctx.Regime = regime
+	ctx.RegimeRule = regimeRule
+	ctx.Correlation = correlation
+	ctx.Activity = activityAnalysis
+	ctx.FudActivity = fudActivityAnalysis
+	ctx.FudShare = fudShare
+	ctx.Sentiment = sentiment
+	ctx.SentimentSource = sentimentSource
+	if markPrice, err := exchange.GetMarkPrice(pair.Symbol); err == nil {
+		ctx.MarkPrice = markPrice
 	}
-
-	log.Printf("[%s] Opening %s position", pair.Symbol, desiredPosition)
-	position, err := exchange.OpenPosition(pair.Symbol, desiredPosition, pair.Leverage, pair.Quantity)
-	if err != nil {
-		log.Printf("[%s] Failed to open %s: %v", pair.Symbol, desiredPosition, err)
-		return err
-	}
-
-	state.CurrentPosition = desiredPosition
-	state.OpenedAt = time.Now()
-	state.OpenReason = decision.Reason
-	state.PositionUUID = GeneratePositionUUID()
-	if validationRecord != nil {
-		validationRecord.PositionUUID = state.PositionUUID
-		DB.Save(validationRecord)
-	}
-	if savedDecision != nil && savedDecision.ID > 0 {
-		if err := UpdateDecisionPositionUUIDByID(savedDecision.ID, state.PositionUUID); err != nil {
-			log.Printf("[%s] Failed to update decision ID %d with position UUID: %v", pair.Symbol, savedDecision.ID, err)
-		} else {
-			log.Printf("[%s] Decision ID %d updated with position UUID: %s", pair.Symbol, savedDecision.ID, state.PositionUUID)
-		}
-	} else {
-		if err := SaveTradingDecision(TradingDecisionRecord{
-			PositionUUID:        state.PositionUUID,
-			Symbol:              pair.Symbol,
-			BTCIchimoku:         decision.BTCIchimokuSignal,
-			CoinIchimoku:        decision.CoinIchimokuSignal,
-			Activity:            decision.ActivitySignal,
-			FudActivity:         decision.FudActivitySignal,
-			Sentiment:           decision.SentimentSignal,
-			FudAttack:           fudAttackInfo,
-			FinalDecision:       string(decision.Signal),
-			DecisionExplanation: decision.Explanation,
-			CreatedAt:           time.Now(),
-		}); err != nil {
-			log.Printf("[%s] Failed to save opening decision: %v", pair.Symbol, err)
-		} else {
-			log.Printf("[%s] New decision saved with position UUID: %s", pair.Symbol, state.PositionUUID)
-		}
-	}
-
-	log.Printf("[%s] Position opened: %s (entry: %.6f, amount: %.6f, reason: %s, UUID: %s)", pair.Symbol, desiredPosition, position.EntryPrice, position.Amount, decision.Reason, state.PositionUUID)
-
-	positionRecord := PositionRecord{
-		UUID:       state.PositionUUID,
-		Symbol:     pair.Symbol,
-		Side:       string(desiredPosition),
-		Leverage:   pair.Leverage,
-		Quantity:   pair.Quantity,
-		EntryPrice: position.EntryPrice,
-		OpenedAt:   state.OpenedAt,
-		OpenReason: decision.Reason,
-		MaxPnL:     position.UnrealizedPL,
-		MinPnL:     position.UnrealizedPL,
-		CreatedAt:  time.Now(),
-	}
-	if err := SavePositionOpen(positionRecord); err != nil {
-		log.Printf("[%s] Failed to save position to database: %v", pair.Symbol, err)
-	} else {
-		log.Printf("[%s] Position record saved to database", pair.Symbol)
-	}
-
-	if claudeClient != nil {
-		if err := DB.Model(&AIOrderValidationRecord{}).
-			Where("symbol = ? AND position_uuid = ''", pair.Symbol).
-			Order("created_at DESC").
-			Limit(1).
-			Update("position_uuid", state.PositionUUID).Error; err != nil {
-			log.Printf("[%s] Failed to link AI validation to position: %v", pair.Symbol, err)
-		} else {
-			log.Printf("[%s] AI validation linked to position UUID: %s", pair.Symbol, state.PositionUUID)
-		}
-	}
-
 	return nil
 }
 
-func performAICloseAnalysis(claudeClient *claude.ClaudeApi, exchange AsterDexExchange, activityClient ExternalActivityClient, pair TradingPair, state *TradingState) (bool, error) {
+// performAICloseAnalysis asks the AI whether the open position should be
+// closed and stores the analysis. Closing is left to the caller.
+func performAICloseAnalysis(claudeClient claude.LLM, exchange Exchange, activityClient ExternalActivityClient, pair TradingPair, state *TradingState) (bool, error) {
 	if claudeClient == nil {
 		return false, nil
 	}
 
-	log.Printf("[%s] 🤖 AI Close Analysis: Triggered at snapshot milestone", pair.Symbol)
-
 	positionRecord, err := GetPositionByUUID(state.PositionUUID)
 	if err != nil {
 		return false, fmt.Errorf("failed to get position record: %w", err)
@@ -674,7 +415,7 @@ func performAICloseAnalysis(claudeClient *claude.ClaudeApi, exchange AsterDexExc
 		return false, fmt.Errorf("failed to get snapshots: %w", err)
 	}
 
-	recentTweets, err := activityClient.GetRecentTweets(pair.CommunityID, 50)
+	recentTweets, err := NewTweetArchive(activityClient).GetRecentTweets(pair.CommunityID, 50)
 	if err != nil {
 		log.Printf("[%s] Failed to fetch tweets for close analysis: %v", pair.Symbol, err)
 		recentTweets = []CommunityTweet{}
@@ -692,17 +433,24 @@ func performAICloseAnalysis(claudeClient *claude.ClaudeApi, exchange AsterDexExc
 	btcIchimoku := CalculateIchimoku(btcKlines)
 	coinIchimoku := CalculateIchimoku(coinKlines)
 	shouldCloseByIchimoku := ShouldClosePositionDetailed(state.CurrentPosition, coinIchimoku)
+	regime := DetectMarketRegime(coinKlines, btcIchimoku.Analysis)
+	regimeRule := GetRegimeRule(pair, regime.Regime)
 
 	log.Printf("[%s] AI Close Analysis: Analyzing %d snapshots, %d tweets", pair.Symbol, len(snapshots), len(recentTweets))
 
-	currentPosition, _ := exchange.GetPosition(pair.Symbol)
-	currentPnL := 0.0
-	if currentPosition != nil {
-		currentPnL = currentPosition.UnrealizedPL
+	maConfig := GetMAExitConfig(pair).WithParams(pair.Strategy.Params)
+	if maConfig.Ratio <= 0 {
+		maConfig.Ratio = regimeRule.MAExitRatio
+	}
+	maInput := MAExitInput{Snapshots: snapshots, ATR: regime.ATR}
+	if currentPosition, _ := exchange.GetPosition(pair.Symbol); currentPosition != nil {
+		maInput.CurrentPnL = currentPosition.UnrealizedPL
+		maInput.EntryPrice = currentPosition.EntryPrice
+		maInput.Amount = currentPosition.Amount
 	}
-	maSignal := CalculateMovingAveragePnLSignal(snapshots, currentPnL)
+	maSignal := EvaluateMAExit(maConfig, maInput)
 
-	closeResponse, err := AnalyzePositionClose(*claudeClient, positionRecord, snapshots, recentTweets, btcIchimoku.Analysis, coinIchimoku.Analysis, shouldCloseByIchimoku, maSignal)
+	closeResponse, err := AnalyzePositionClose(withLLMCallInfo(context.Background(), pair.Symbol, state.PositionUUID), claudeClient, positionRecord, snapshots, recentTweets, btcIchimoku.Analysis, coinIchimoku.Analysis, shouldCloseByIchimoku, maSignal, regime)
 	if err != nil {
 		return false, fmt.Errorf("AI close analysis failed: %w", err)
 	}
@@ -720,6 +468,7 @@ func performAICloseAnalysis(claudeClient *claude.ClaudeApi, exchange AsterDexExc
 		Tweets       []CommunityTweet   `json:"recent_tweets"`
 		BTCIchimoku  IchimokuAnalysis   `json:"btc_ichimoku"`
 		CoinIchimoku IchimokuAnalysis   `json:"coin_ichimoku"`
+		MarketRegime RegimeAnalysis     `json:"market_regime"`
 	}
 
 	requestData := CloseAnalysisRequest{
@@ -728,6 +477,7 @@ func performAICloseAnalysis(claudeClient *claude.ClaudeApi, exchange AsterDexExc
 		Tweets:       recentTweets,
 		BTCIchimoku:  btcIchimoku.Analysis,
 		CoinIchimoku: coinIchimoku.Analysis,
+		MarketRegime: regime,
 	}
 
 	requestJSON, _ := json.Marshal(requestData)
@@ -752,35 +502,102 @@ func performAICloseAnalysis(claudeClient *claude.ClaudeApi, exchange AsterDexExc
 	} else {
 		log.Printf("[%s] AI close analysis saved to database", pair.Symbol)
 	}
-	var shouldClose bool
 	if closeResponse.ShouldClose {
-		log.Printf("[%s] 🚨 AI recommends closing position - executing close", pair.Symbol)
+		log.Printf("[%s] 🚨 AI recommends closing position", pair.Symbol)
+	} else {
+		log.Printf("[%s] AI recommends holding position", pair.Symbol)
+	}
+	return closeResponse.ShouldClose, nil
+}
 
-		if err := exchange.ClosePosition(pair.Symbol, state.CurrentPosition); err != nil {
-			return false, fmt.Errorf("failed to close position: %w", err)
-		}
+func recordMarketRegime(pair TradingPair, state *TradingState, regime RegimeAnalysis) {
+	previous := state.LastRegime
+	state.LastRegime = regime
 
-		markPrice, _ := exchange.GetMarkPrice(pair.Symbol)
-		closedPosition, _ := exchange.GetPosition(pair.Symbol)
-		realizedPL := 0.0
-		if closedPosition != nil {
-			realizedPL = closedPosition.UnrealizedPL
-		}
+	lastRecord, err := GetLatestMarketRegime(pair.Symbol)
+	if err != nil {
+		log.Printf("[%s] Failed to get last market regime: %v", pair.Symbol, err)
+		return
+	}
+	if lastRecord != nil && lastRecord.Regime == string(regime.Regime) && time.Since(lastRecord.CreatedAt) < time.Hour {
+		return
+	}
 
-		if err := UpdatePositionClose(state.PositionUUID, markPrice, realizedPL, "ai_close_recommendation"); err != nil {
-			log.Printf("[%s] Failed to update position close: %v", pair.Symbol, err)
-		} else {
-			log.Printf("[%s] Position closed by AI recommendation", pair.Symbol)
+	if previous.Regime != "" && previous.Regime != regime.Regime {
+		log.Printf("[%s] 🔀 Market regime changed: %s -> %s", pair.Symbol, previous.Regime, regime.Regime)
+	}
+	if err := SaveMarketRegime(pair.Symbol, regime); err != nil {
+		log.Printf("[%s] Failed to save market regime: %v", pair.Symbol, err)
+	}
+}
+
+// recordActivityPoints stores the hourly series for research. After the first
+// full save only the last couple of hours are upserted.
+// fetchActivityHistory keeps the hourly activity and FUD activity of the last
+// ActivityBaselineDays in the state. After the first full download only the
+// hours since the newest cached one are fetched, the newest hour again as it
+// is still counting. The full window is fetched again once a day to fill gaps.
+func fetchActivityHistory(client ExternalActivityClient, pair TradingPair, state *TradingState, now time.Time) ([]ActivityDataPoint, []ActivityDataPoint, error) {
+	windowStart := now.Add(-ActivityBaselineDays * 24 * time.Hour)
+	full := len(state.ActivityHistory) == 0 || now.Sub(state.ActivityFullFetchAt) >= ActivityFullRefreshInterval
+	from := windowStart
+	if !full {
+		from = latestActivityHour(state.ActivityHistory)
+		if latest := latestActivityHour(state.FudActivityHistory); !latest.IsZero() && latest.Before(from) {
+			from = latest
 		}
+		if from.Before(windowStart) {
+			from = windowStart
+		}
+	}
 
-		state.CurrentPosition = PositionSideBoth
-		state.PositionUUID = ""
-		state.OpenReason = ""
-		shouldClose = true
+	activityData, err := client.GetCommunityActivity(pair.CommunityID, from.UnixMilli(), now.UnixMilli(), "hour")
+	if err != nil {
+		log.Printf("[%s] Failed to get community activity: %v", pair.Symbol, err)
+		return nil, nil, err
+	}
+	fudActivityData, err := client.GetCommunityFudActivity(pair.CommunityID, from.UnixMilli(), now.UnixMilli(), "hour")
+	if err != nil {
+		log.Printf("[%s] Failed to get FUD activity: %v", pair.Symbol, err)
+		return nil, nil, err
+	}
+
+	if full {
+		state.ActivityHistory = mergeActivityPoints(nil, activityData, windowStart)
+		state.FudActivityHistory = mergeActivityPoints(nil, fudActivityData, windowStart)
+		state.ActivityFullFetchAt = now
 	} else {
-		log.Printf("[%s] AI recommends holding position", pair.Symbol)
-		shouldClose = false
+		state.ActivityHistory = mergeActivityPoints(state.ActivityHistory, activityData, windowStart)
+		state.FudActivityHistory = mergeActivityPoints(state.FudActivityHistory, fudActivityData, windowStart)
 	}
+	return state.ActivityHistory, state.FudActivityHistory, nil
+}
 
-	return shouldClose, nil
+func recordActivityPoints(pair TradingPair, state *TradingState, activityData, fudActivityData []ActivityDataPoint) {
+	since := state.LastActivitySavedHour.Add(-time.Hour)
+	latest := state.LastActivitySavedHour
+	filter := func(points []ActivityDataPoint) []ActivityDataPoint {
+		var fresh []ActivityDataPoint
+		for _, p := range points {
+			hour := activityPointTime(p.Timestamp).Truncate(time.Hour)
+			if hour.Before(since) {
+				continue
+			}
+			if hour.After(latest) {
+				latest = hour
+			}
+			fresh = append(fresh, p)
+		}
+		return fresh
+	}
+
+	if err := SaveActivityPoints(pair.Symbol, pair.CommunityID, ActivityKindTotal, filter(activityData)); err != nil {
+		log.Printf("[%s] Failed to save activity points: %v", pair.Symbol, err)
+		return
+	}
+	if err := SaveActivityPoints(pair.Symbol, pair.CommunityID, ActivityKindFud, filter(fudActivityData)); err != nil {
+		log.Printf("[%s] Failed to save FUD activity points: %v", pair.Symbol, err)
+		return
+	}
+	state.LastActivitySavedHour = latest
 }
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"fmt"
+	"log"
+)
+
+// applyPairMarginType sets the pair's margin type on the exchange before an
+// order. Pairs without a margin type keep whatever the account uses.
+func applyPairMarginType(exchange Exchange, pair TradingPair) error {
+	if pair.MarginType == "" {
+		return nil
+	}
+	if pair.MarginType != MarginTypeIsolated && pair.MarginType != MarginTypeCrossed {
+		return fmt.Errorf("unknown margin type %q", pair.MarginType)
+	}
+	if err := exchange.SetMarginType(pair.Symbol, pair.MarginType); err != nil {
+		return fmt.Errorf("failed to set %s margin: %w", pair.MarginType, err)
+	}
+	log.Printf("[%s] Margin type: %s", pair.Symbol, pair.MarginType)
+	return nil
+}
+
+// effectiveMarginType is the margin type the exchange reports for the
+// position, falling back to the pair config.
+func effectiveMarginType(position *Position, pair TradingPair) string {
+	if position != nil && position.MarginType != "" {
+		return position.MarginType
+	}
+	return string(pair.MarginType)
+}
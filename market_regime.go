@@ -0,0 +1,327 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+type MarketRegime string
+
+const (
+	MarketRegimeTrendingUp   MarketRegime = "TRENDING_UP"
+	MarketRegimeTrendingDown MarketRegime = "TRENDING_DOWN"
+	MarketRegimeRanging      MarketRegime = "RANGING"
+	MarketRegimeVolatile     MarketRegime = "VOLATILE"
+	MarketRegimeIlliquid     MarketRegime = "ILLIQUID"
+	MarketRegimeUnknown      MarketRegime = "UNKNOWN"
+)
+
+const (
+	RegimeATRPeriod           = 14
+	RegimeADXPeriod           = 14
+	RegimeTrendADX            = 25.0
+	RegimeRangeADX            = 20.0
+	RegimeVolatileATRRatio    = 1.8
+	RegimeIlliquidVolumeRatio = 0.3
+	RegimeMinCloudThickness   = 0.5
+	RegimeMaxBarsInCloud      = 6
+)
+
+type RegimeAnalysis struct {
+	Regime                MarketRegime   `json:"regime"`
+	Confidence            float64        `json:"confidence"`
+	ATR                   float64        `json:"atr"`
+	ATRPercent            float64        `json:"atr_percent"`
+	ATRRatio              float64        `json:"atr_ratio"`
+	ADX                   float64        `json:"adx"`
+	PlusDI                float64        `json:"plus_di"`
+	MinusDI               float64        `json:"minus_di"`
+	CloudThicknessPercent float64        `json:"cloud_thickness_percent"`
+	BarsInCloud           int            `json:"bars_in_cloud"`
+	VolumeRatio           float64        `json:"volume_ratio"`
+	BTCSignal             IchimokuSignal `json:"btc_signal"`
+	Description           string         `json:"description"`
+}
+
+// RegimeRule controls how the bot behaves while a pair is in a given regime.
+// MAExitRatio replaces the default 0.7 moving average exit threshold.
+type RegimeRule struct {
+	AllowEntries bool
+	AllowLong    bool
+	AllowShort   bool
+	MAExitRatio  float64
+}
+
+// DefaultRegimeRule keeps the behaviour of the bot before regimes existed:
+// entries on both sides and the 0.7 MA exit in every regime.
+func DefaultRegimeRule() RegimeRule {
+	return RegimeRule{AllowEntries: true, AllowLong: true, AllowShort: true, MAExitRatio: 0.7}
+}
+
+// StrictRegimeRules are opt-in rules for TradingPair.RegimeRules: no entries
+// while ranging or illiquid, only trend-side entries while trending and a
+// tighter MA exit in volatile and illiquid markets.
+func StrictRegimeRules() map[MarketRegime]RegimeRule {
+	return map[MarketRegime]RegimeRule{
+		MarketRegimeTrendingUp:   {AllowEntries: true, AllowLong: true, AllowShort: false, MAExitRatio: 0.7},
+		MarketRegimeTrendingDown: {AllowEntries: true, AllowLong: false, AllowShort: true, MAExitRatio: 0.7},
+		MarketRegimeRanging:      {AllowEntries: false, AllowLong: true, AllowShort: true, MAExitRatio: 0.7},
+		MarketRegimeVolatile:     {AllowEntries: true, AllowLong: true, AllowShort: true, MAExitRatio: 0.85},
+		MarketRegimeIlliquid:     {AllowEntries: false, AllowLong: true, AllowShort: true, MAExitRatio: 0.8},
+	}
+}
+
+// GetRegimeRule returns the pair's rule for the regime, or DefaultRegimeRule
+// when the pair sets none.
+func GetRegimeRule(pair TradingPair, regime MarketRegime) RegimeRule {
+	if rule, ok := pair.RegimeRules[regime]; ok {
+		return rule
+	}
+	return DefaultRegimeRule()
+}
+
+func DetectMarketRegime(klines []Candle, btcIchimoku IchimokuAnalysis) RegimeAnalysis {
+	analysis := RegimeAnalysis{
+		Regime:    MarketRegimeUnknown,
+		BTCSignal: btcIchimoku.Signal,
+	}
+
+	if len(klines) < 52+RegimeADXPeriod {
+		analysis.Description = "Not enough data for regime detection"
+		return analysis
+	}
+
+	highs := make([]float64, len(klines))
+	lows := make([]float64, len(klines))
+	closes := make([]float64, len(klines))
+	quoteVolumes := make([]float64, len(klines))
+
+	for i, k := range klines {
+		highs[i] = k.High
+		lows[i] = k.Low
+		closes[i] = k.Close
+		quoteVolumes[i] = k.QuoteVolume
+	}
+
+	n := len(closes)
+	price := closes[n-1]
+
+	atr := calculateATR(highs, lows, closes, RegimeATRPeriod)
+	analysis.ATR = atr[n-1]
+	if price > 0 {
+		analysis.ATRPercent = analysis.ATR / price * 100
+	}
+	analysis.ATRRatio = calculateATRRatio(atr, closes, 100)
+
+	adx, plusDI, minusDI := calculateADX(highs, lows, closes, RegimeADXPeriod)
+	analysis.ADX = adx[n-1]
+	analysis.PlusDI = plusDI[n-1]
+	analysis.MinusDI = minusDI[n-1]
+
+	tenkan := calculateTenkan(highs, lows)
+	kijun := calculateKijun(highs, lows)
+	senkouA := calculateSenkouA(tenkan, kijun)
+	senkouB := calculateSenkouB(highs, lows)
+
+	cloudTop := math.Max(senkouA[n-1], senkouB[n-1])
+	cloudBottom := math.Min(senkouA[n-1], senkouB[n-1])
+	if price > 0 {
+		analysis.CloudThicknessPercent = (cloudTop - cloudBottom) / price * 100
+	}
+	analysis.BarsInCloud = countBarsInCloud(closes, senkouA, senkouB)
+	analysis.VolumeRatio = calculateVolumeRatio(quoteVolumes, 24, 168)
+
+	priceAboveCloud := price > cloudTop
+	priceBelowCloud := price < cloudBottom
+	btcSignal := convertIchimokuToSignal(btcIchimoku)
+
+	switch {
+	case analysis.VolumeRatio > 0 && analysis.VolumeRatio < RegimeIlliquidVolumeRatio:
+		analysis.Regime = MarketRegimeIlliquid
+		analysis.Confidence = 1 - analysis.VolumeRatio/RegimeIlliquidVolumeRatio
+		analysis.Description = fmt.Sprintf("ILLIQUID: recent volume is %.0f%% of the weekly median", analysis.VolumeRatio*100)
+	case analysis.ATRRatio >= RegimeVolatileATRRatio:
+		analysis.Regime = MarketRegimeVolatile
+		analysis.Confidence = math.Min(1, analysis.ATRRatio/(RegimeVolatileATRRatio*1.5))
+		analysis.Description = fmt.Sprintf("VOLATILE: ATR is %.1fx its average (%.2f%% of price)", analysis.ATRRatio, analysis.ATRPercent)
+	case analysis.ADX >= RegimeTrendADX && priceAboveCloud && analysis.PlusDI > analysis.MinusDI &&
+		analysis.CloudThicknessPercent >= RegimeMinCloudThickness:
+		analysis.Regime = MarketRegimeTrendingUp
+		analysis.Confidence = trendConfidence(analysis.ADX, btcSignal, SignalLong)
+		analysis.Description = fmt.Sprintf("TRENDING_UP: ADX %.1f, price above cloud, +DI %.1f > -DI %.1f", analysis.ADX, analysis.PlusDI, analysis.MinusDI)
+	case analysis.ADX >= RegimeTrendADX && priceBelowCloud && analysis.MinusDI > analysis.PlusDI &&
+		analysis.CloudThicknessPercent >= RegimeMinCloudThickness:
+		analysis.Regime = MarketRegimeTrendingDown
+		analysis.Confidence = trendConfidence(analysis.ADX, btcSignal, SignalShort)
+		analysis.Description = fmt.Sprintf("TRENDING_DOWN: ADX %.1f, price below cloud, -DI %.1f > +DI %.1f", analysis.ADX, analysis.MinusDI, analysis.PlusDI)
+	default:
+		analysis.Regime = MarketRegimeRanging
+		confidence := 0.5
+		if analysis.ADX < RegimeRangeADX {
+			confidence += 0.25
+		}
+		if analysis.BarsInCloud >= RegimeMaxBarsInCloud {
+			confidence += 0.25
+		}
+		analysis.Confidence = confidence
+		analysis.Description = fmt.Sprintf("RANGING: ADX %.1f, %d bars in cloud, cloud thickness %.2f%%", analysis.ADX, analysis.BarsInCloud, analysis.CloudThicknessPercent)
+	}
+
+	return analysis
+}
+
+func trendConfidence(adx float64, btcSignal Signal, direction Signal) float64 {
+	confidence := math.Min(1, adx/50)
+	if btcSignal == direction {
+		confidence = math.Min(1, confidence+0.2)
+	} else if btcSignal != SignalEmpty {
+		confidence = math.Max(0, confidence-0.2)
+	}
+	return confidence
+}
+
+func calculateATR(highs, lows, closes []float64, period int) []float64 {
+	result := make([]float64, len(closes))
+	if len(closes) <= period {
+		return result
+	}
+
+	trueRanges := make([]float64, len(closes))
+	for i := 1; i < len(closes); i++ {
+		trueRanges[i] = math.Max(highs[i]-lows[i], math.Max(math.Abs(highs[i]-closes[i-1]), math.Abs(lows[i]-closes[i-1])))
+	}
+
+	var sum float64
+	for i := 1; i <= period; i++ {
+		sum += trueRanges[i]
+	}
+	result[period] = sum / float64(period)
+	for i := period + 1; i < len(closes); i++ {
+		result[i] = (result[i-1]*float64(period-1) + trueRanges[i]) / float64(period)
+	}
+	return result
+}
+
+func calculateATRRatio(atr, closes []float64, lookback int) float64 {
+	n := len(atr)
+	if n == 0 || closes[n-1] == 0 {
+		return 0
+	}
+	start := n - lookback
+	if start < 0 {
+		start = 0
+	}
+
+	var sum float64
+	var count int
+	for i := start; i < n; i++ {
+		if atr[i] > 0 && closes[i] > 0 {
+			sum += atr[i] / closes[i]
+			count++
+		}
+	}
+	if count == 0 || sum == 0 {
+		return 0
+	}
+	return (atr[n-1] / closes[n-1]) / (sum / float64(count))
+}
+
+func calculateADX(highs, lows, closes []float64, period int) ([]float64, []float64, []float64) {
+	n := len(closes)
+	adx := make([]float64, n)
+	plusDI := make([]float64, n)
+	minusDI := make([]float64, n)
+	if n <= period*2 {
+		return adx, plusDI, minusDI
+	}
+
+	var smoothedTR, smoothedPlusDM, smoothedMinusDM float64
+	dx := make([]float64, n)
+
+	for i := 1; i < n; i++ {
+		upMove := highs[i] - highs[i-1]
+		downMove := lows[i-1] - lows[i]
+		plusDM, minusDM := 0.0, 0.0
+		if upMove > downMove && upMove > 0 {
+			plusDM = upMove
+		}
+		if downMove > upMove && downMove > 0 {
+			minusDM = downMove
+		}
+		tr := math.Max(highs[i]-lows[i], math.Max(math.Abs(highs[i]-closes[i-1]), math.Abs(lows[i]-closes[i-1])))
+
+		if i <= period {
+			smoothedTR += tr
+			smoothedPlusDM += plusDM
+			smoothedMinusDM += minusDM
+			if i < period {
+				continue
+			}
+		} else {
+			smoothedTR = smoothedTR - smoothedTR/float64(period) + tr
+			smoothedPlusDM = smoothedPlusDM - smoothedPlusDM/float64(period) + plusDM
+			smoothedMinusDM = smoothedMinusDM - smoothedMinusDM/float64(period) + minusDM
+		}
+
+		if smoothedTR > 0 {
+			plusDI[i] = smoothedPlusDM / smoothedTR * 100
+			minusDI[i] = smoothedMinusDM / smoothedTR * 100
+		}
+		if sum := plusDI[i] + minusDI[i]; sum > 0 {
+			dx[i] = math.Abs(plusDI[i]-minusDI[i]) / sum * 100
+		}
+	}
+
+	var dxSum float64
+	for i := period; i < period*2; i++ {
+		dxSum += dx[i]
+	}
+	adx[period*2-1] = dxSum / float64(period)
+	for i := period * 2; i < n; i++ {
+		adx[i] = (adx[i-1]*float64(period-1) + dx[i]) / float64(period)
+	}
+
+	return adx, plusDI, minusDI
+}
+
+func countBarsInCloud(closes, senkouA, senkouB []float64) int {
+	count := 0
+	for i := len(closes) - 1; i >= 0; i-- {
+		if senkouA[i] == 0 || senkouB[i] == 0 {
+			break
+		}
+		top := math.Max(senkouA[i], senkouB[i])
+		bottom := math.Min(senkouA[i], senkouB[i])
+		if closes[i] < bottom || closes[i] > top {
+			break
+		}
+		count++
+	}
+	return count
+}
+
+func calculateVolumeRatio(volumes []float64, recentBars, baselineBars int) float64 {
+	n := len(volumes)
+	if n < recentBars*2 {
+		return 0
+	}
+
+	var recentSum float64
+	for _, v := range volumes[n-recentBars:] {
+		recentSum += v
+	}
+	recentAvg := recentSum / float64(recentBars)
+
+	start := n - baselineBars
+	if start < 0 {
+		start = 0
+	}
+	baseline := append([]float64(nil), volumes[start:]...)
+	sort.Float64s(baseline)
+	median := baseline[len(baseline)/2]
+	if median == 0 {
+		return 0
+	}
+	return recentAvg / median
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCalculateATR(t *testing.T) {
+	highs := []float64{11, 11, 11, 11, 11, 14}
+	lows := []float64{9, 9, 9, 9, 9, 10}
+	closes := []float64{10, 10, 10, 10, 10, 13}
+
+	atr := calculateATR(highs, lows, closes, 3)
+	assert.Zero(t, atr[2], "no value before the first full period")
+	assert.InDelta(t, 2, atr[3], 1e-9)
+	assert.InDelta(t, 2, atr[4], 1e-9)
+	assert.InDelta(t, (2*2+4)/3.0, atr[5], 1e-9, "Wilder smoothing of the new true range")
+
+	assert.InDelta(t, 1, calculateATRRatio(atr[:5], closes[:5], 100), 1e-9)
+}
+
+func TestCalculateADX(t *testing.T) {
+	n := 40
+	highs := make([]float64, n)
+	lows := make([]float64, n)
+	closes := make([]float64, n)
+	for i := range closes {
+		closes[i] = 100 + float64(i)
+		highs[i] = closes[i] + 1
+		lows[i] = closes[i] - 1
+	}
+
+	adx, plusDI, minusDI := calculateADX(highs, lows, closes, 14)
+	assert.Zero(t, adx[26], "no ADX before two periods")
+	assert.InDelta(t, 100, adx[n-1], 1e-9, "a one-way move is a full trend")
+	assert.InDelta(t, 50, plusDI[n-1], 1e-9, "+DM of 1 against a true range of 2")
+	assert.Zero(t, minusDI[n-1])
+
+	for i := range closes {
+		closes[i] = 100 - float64(i)
+		highs[i] = closes[i] + 1
+		lows[i] = closes[i] - 1
+	}
+	adx, plusDI, minusDI = calculateADX(highs, lows, closes, 14)
+	assert.InDelta(t, 100, adx[n-1], 1e-9)
+	assert.Zero(t, plusDI[n-1])
+	assert.InDelta(t, 50, minusDI[n-1], 1e-9)
+
+	adx, _, _ = calculateADX(highs[:28], lows[:28], closes[:28], 14)
+	assert.Equal(t, make([]float64, 28), adx, "too short for ADX")
+}
+
+// choppyKlines oscillates around price so the close keeps falling back into
+// the cloud.
+func choppyKlines(n int, price float64) []Candle {
+	klines := trendKlines(time.Hour, n, price, price)
+	for i := range klines {
+		closePrice := price * (1 + 0.01*math.Sin(float64(i)))
+		klines[i].Open = klines[i].Close
+		klines[i].Close = closePrice
+		klines[i].High = math.Max(klines[i].Open, closePrice) * 1.002
+		klines[i].Low = math.Min(klines[i].Open, closePrice) * 0.998
+	}
+	return klines
+}
+
+func TestDetectMarketRegime(t *testing.T) {
+	volatile := trendKlines(time.Hour, 200, 80, 120)
+	for i := len(volatile) - 5; i < len(volatile); i++ {
+		volatile[i].High = volatile[i].Close * 1.15
+		volatile[i].Low = volatile[i].Close * 0.85
+	}
+	illiquid := trendKlines(time.Hour, 200, 80, 120)
+	for i := len(illiquid) - 24; i < len(illiquid); i++ {
+		illiquid[i].QuoteVolume /= 10
+	}
+
+	tests := []struct {
+		name   string
+		klines []Candle
+		regime MarketRegime
+	}{
+		{"not enough candles", trendKlines(time.Hour, 60, 80, 120), MarketRegimeUnknown},
+		{"steady rise", trendKlines(time.Hour, 200, 80, 120), MarketRegimeTrendingUp},
+		{"steady fall", trendKlines(time.Hour, 200, 120, 80), MarketRegimeTrendingDown},
+		{"chop", choppyKlines(200, 100), MarketRegimeRanging},
+		{"range expansion", volatile, MarketRegimeVolatile},
+		{"volume dried up", illiquid, MarketRegimeIlliquid},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			analysis := DetectMarketRegime(tt.klines, IchimokuAnalysis{})
+			assert.Equal(t, tt.regime, analysis.Regime, analysis.Description)
+		})
+	}
+}
+
+func TestGetRegimeRule(t *testing.T) {
+	pair := TradingPair{Symbol: "GIGGLEUSDT"}
+	for _, regime := range []MarketRegime{MarketRegimeTrendingUp, MarketRegimeTrendingDown, MarketRegimeRanging, MarketRegimeVolatile, MarketRegimeIlliquid, MarketRegimeUnknown} {
+		assert.Equal(t, DefaultRegimeRule(), GetRegimeRule(pair, regime), "pairs without rules trade every regime like before")
+	}
+
+	pair.RegimeRules = StrictRegimeRules()
+	ranging := GetRegimeRule(pair, MarketRegimeRanging)
+	assert.False(t, ranging.AllowEntries)
+	trendingUp := GetRegimeRule(pair, MarketRegimeTrendingUp)
+	assert.True(t, trendingUp.AllowLong)
+	assert.False(t, trendingUp.AllowShort)
+	require.Equal(t, DefaultRegimeRule(), GetRegimeRule(pair, MarketRegimeUnknown), "too little data never blocks entries")
+}
@@ -0,0 +1,487 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	MockExchangeWalletBalance = 10000.0
+	// mockMaintenanceMargin is the maintenance margin rate used for the mock
+	// liquidation price.
+	mockMaintenanceMargin  = 0.005
+	mockKlinesDefaultLimit = 500
+	mockKlinesMaxLimit     = 1500
+)
+
+// MockFapiServer is a local stand-in for the /fapi endpoints the bot uses:
+// server time, position mode, leverage, margin type, isolated margin, market
+// orders, position risk, balance, mark price and klines. Prices follow a
+// deterministic wave per symbol unless set with SetPrice, fills happen at the
+// mark price on a SimulatedExchange with a USDT wallet. Signed endpoints only
+// check that an API key and signature are present.
+type MockFapiServer struct {
+	mu          sync.Mutex
+	exchange    *SimulatedExchange
+	wallet      float64
+	booked      int
+	dualSide    bool
+	leverage    map[string]int
+	marginTypes map[string]MarginType
+	topUps      map[string]float64
+	prices      map[string]float64
+	nextOrderID int64
+}
+
+func NewMockFapiServer() *MockFapiServer {
+	return &MockFapiServer{
+		exchange:    NewSimulatedExchange(),
+		wallet:      MockExchangeWalletBalance,
+		leverage:    make(map[string]int),
+		marginTypes: make(map[string]MarginType),
+		topUps:      make(map[string]float64),
+		prices:      make(map[string]float64),
+		nextOrderID: 1,
+	}
+}
+
+// SetPrice pins the mark price of a symbol, 0 returns it to the wave.
+func (m *MockFapiServer) SetPrice(symbol string, price float64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if price <= 0 {
+		delete(m.prices, symbol)
+		return
+	}
+	m.prices[symbol] = price
+}
+
+func (m *MockFapiServer) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /fapi/v1/time", m.handleTime)
+	mux.HandleFunc("GET /fapi/v1/klines", m.handleKlines)
+	mux.HandleFunc("GET /fapi/v1/premiumIndex", m.handlePremiumIndex)
+	mux.HandleFunc("GET /fapi/v1/positionSide/dual", m.signed(m.handleGetPositionMode))
+	mux.HandleFunc("POST /fapi/v1/positionSide/dual", m.signed(m.handleSetPositionMode))
+	mux.HandleFunc("POST /fapi/v1/leverage", m.signed(m.handleLeverage))
+	mux.HandleFunc("POST /fapi/v1/marginType", m.signed(m.handleMarginType))
+	mux.HandleFunc("POST /fapi/v1/positionMargin", m.signed(m.handlePositionMargin))
+	mux.HandleFunc("POST /fapi/v1/order", m.signed(m.handleOrder))
+	mux.HandleFunc("GET /fapi/v2/positionRisk", m.signed(m.handlePositionRisk))
+	mux.HandleFunc("GET /fapi/v2/balance", m.signed(m.handleBalance))
+	return mux
+}
+
+func (m *MockFapiServer) signed(handler func(http.ResponseWriter, url.Values)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			mockError(w, http.StatusBadRequest, -1102, err.Error())
+			return
+		}
+		if r.Header.Get("X-MBX-APIKEY") == "" {
+			mockError(w, http.StatusUnauthorized, -2015, "Invalid API-key, IP, or permissions for action.")
+			return
+		}
+		if r.Form.Get("signature") == "" || r.Form.Get("timestamp") == "" {
+			mockError(w, http.StatusBadRequest, -1102, "Mandatory parameter 'signature' or 'timestamp' was not sent.")
+			return
+		}
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		m.syncPrices(r.Form.Get("symbol"))
+		handler(w, r.Form)
+	}
+}
+
+func (m *MockFapiServer) handleTime(w http.ResponseWriter, r *http.Request) {
+	mockJSON(w, map[string]int64{"serverTime": time.Now().UnixMilli()})
+}
+
+func (m *MockFapiServer) handleGetPositionMode(w http.ResponseWriter, params url.Values) {
+	mockJSON(w, map[string]bool{"dualSidePosition": m.dualSide})
+}
+
+func (m *MockFapiServer) handleSetPositionMode(w http.ResponseWriter, params url.Values) {
+	m.dualSide = params.Get("dualSidePosition") == "true"
+	mockOK(w)
+}
+
+func (m *MockFapiServer) handleLeverage(w http.ResponseWriter, params url.Values) {
+	symbol := params.Get("symbol")
+	leverage, err := strconv.Atoi(params.Get("leverage"))
+	if symbol == "" || err != nil || leverage < 1 || leverage > 125 {
+		mockError(w, http.StatusBadRequest, -4028, "Leverage is not valid.")
+		return
+	}
+	m.leverage[symbol] = leverage
+	mockJSON(w, map[string]interface{}{"symbol": symbol, "leverage": leverage, "maxNotionalValue": "1000000"})
+}
+
+func (m *MockFapiServer) handleMarginType(w http.ResponseWriter, params url.Values) {
+	symbol := params.Get("symbol")
+	marginType := MarginType(strings.ToUpper(params.Get("marginType")))
+	if marginType != MarginTypeIsolated && marginType != MarginTypeCrossed {
+		mockError(w, http.StatusBadRequest, -1116, "Invalid marginType.")
+		return
+	}
+	if m.marginType(symbol) == marginType {
+		mockError(w, http.StatusBadRequest, -4046, "No need to change margin type.")
+		return
+	}
+	if position, _ := m.exchange.GetPosition(symbol); position != nil {
+		mockError(w, http.StatusBadRequest, -4048, "Margin type cannot be changed if there exists position.")
+		return
+	}
+	m.marginTypes[symbol] = marginType
+	mockOK(w)
+}
+
+func (m *MockFapiServer) handlePositionMargin(w http.ResponseWriter, params url.Values) {
+	symbol := params.Get("symbol")
+	amount, err := strconv.ParseFloat(params.Get("amount"), 64)
+	position, _ := m.exchange.GetPosition(symbol)
+	switch {
+	case err != nil || amount <= 0:
+		mockError(w, http.StatusBadRequest, -1102, "Invalid amount.")
+	case position == nil || m.marginType(symbol) != MarginTypeIsolated:
+		mockError(w, http.StatusBadRequest, -4047, "Margin can only be added to an isolated position.")
+	case params.Get("type") != "1":
+		mockError(w, http.StatusBadRequest, -1102, "The mock only supports adding margin (type=1).")
+	case amount > m.available():
+		mockError(w, http.StatusBadRequest, -2019, "Margin is insufficient.")
+	default:
+		m.topUps[symbol] += amount
+		mockJSON(w, map[string]interface{}{"amount": amount, "code": 200, "msg": "Successfully modify position margin.", "type": 1})
+	}
+}
+
+func (m *MockFapiServer) handleOrder(w http.ResponseWriter, params url.Values) {
+	symbol := params.Get("symbol")
+	side := params.Get("side")
+	positionSide := PositionSide(params.Get("positionSide"))
+	quantity, err := strconv.ParseFloat(params.Get("quantity"), 64)
+	if symbol == "" || (side != "BUY" && side != "SELL") {
+		mockError(w, http.StatusBadRequest, -1102, "Mandatory parameter 'symbol' or 'side' was not sent.")
+		return
+	}
+	if params.Get("type") != "MARKET" {
+		mockError(w, http.StatusBadRequest, -1116, "The mock only supports MARKET orders.")
+		return
+	}
+	if err != nil || quantity <= 0 {
+		mockError(w, http.StatusBadRequest, -4003, "Quantity less than or equal to zero.")
+		return
+	}
+	if positionSide == "" {
+		positionSide = PositionSideBoth
+	}
+	if positionSide != PositionSideBoth && !m.dualSide {
+		mockError(w, http.StatusBadRequest, -4061, "Order's position side does not match user's setting.")
+		return
+	}
+	if positionSide == PositionSideBoth {
+		mockError(w, http.StatusBadRequest, -4061, "The mock only supports hedge mode orders with LONG or SHORT.")
+		return
+	}
+
+	opening := (side == "BUY") == (positionSide == PositionSideLong)
+	position, _ := m.exchange.GetPosition(symbol)
+	switch {
+	case opening && position != nil:
+		mockError(w, http.StatusBadRequest, -4164, "The mock supports one position per symbol.")
+		return
+	case opening:
+		leverage := m.leverageOf(symbol)
+		price, _ := m.exchange.GetMarkPrice(symbol)
+		if price*quantity/float64(leverage) > m.available() {
+			mockError(w, http.StatusBadRequest, -2019, "Margin is insufficient.")
+			return
+		}
+		if _, err := m.exchange.OpenPosition(symbol, positionSide, leverage, quantity); err != nil {
+			mockError(w, http.StatusBadRequest, -1000, err.Error())
+			return
+		}
+	case position == nil || position.Side != positionSide:
+		mockError(w, http.StatusBadRequest, -2022, "ReduceOnly Order is rejected.")
+		return
+	default:
+		if err := m.exchange.ReducePosition(symbol, positionSide, quantity); err != nil {
+			mockError(w, http.StatusBadRequest, -1000, err.Error())
+			return
+		}
+		if remaining, _ := m.exchange.GetPosition(symbol); remaining == nil {
+			delete(m.topUps, symbol)
+		}
+		m.bookTrades()
+	}
+
+	price, _ := m.exchange.GetMarkPrice(symbol)
+	orderID := m.nextOrderID
+	m.nextOrderID++
+	mockJSON(w, fapiOrderResponse{
+		OrderID:    orderID,
+		Symbol:     symbol,
+		Status:     "FILLED",
+		Side:       side,
+		Type:       "MARKET",
+		OrigQty:    params.Get("quantity"),
+		Price:      "0",
+		AvgPrice:   strconv.FormatFloat(price, 'f', -1, 64),
+		UpdateTime: time.Now().UnixMilli(),
+	})
+}
+
+func (m *MockFapiServer) handlePositionRisk(w http.ResponseWriter, params url.Values) {
+	symbols := []string{params.Get("symbol")}
+	if symbols[0] == "" {
+		symbols = symbols[:0]
+		for symbol := range m.exchange.positions {
+			symbols = append(symbols, symbol)
+		}
+	}
+
+	var result []fapiPosition
+	for _, symbol := range symbols {
+		position, _ := m.exchange.GetPosition(symbol)
+		markPrice, _ := m.exchange.GetMarkPrice(symbol)
+		for _, side := range []PositionSide{PositionSideLong, PositionSideShort} {
+			entry := fapiPosition{
+				Symbol:           symbol,
+				PositionSide:     string(side),
+				PositionAmt:      "0",
+				EntryPrice:       "0",
+				UnrealizedProfit: "0",
+				Leverage:         strconv.Itoa(m.leverageOf(symbol)),
+				MarkPrice:        formatMockFloat(markPrice),
+				LiquidationPrice: "0",
+				MarginType:       "cross",
+				IsolatedMargin:   "0",
+				Notional:         "0",
+			}
+			if position != nil && position.Side == side {
+				amount := position.Amount
+				if side == PositionSideShort {
+					amount = -amount
+				}
+				notional := markPrice * amount
+				entry.PositionAmt = formatMockFloat(amount)
+				entry.EntryPrice = formatMockFloat(position.EntryPrice)
+				entry.UnrealizedProfit = formatMockFloat(position.UnrealizedPL)
+				entry.Notional = formatMockFloat(notional)
+				entry.LiquidationPrice = formatMockFloat(m.liquidationPrice(symbol, position))
+			}
+			if m.marginType(symbol) == MarginTypeIsolated {
+				entry.MarginType = "isolated"
+				if position != nil && position.Side == side {
+					entry.IsolatedMargin = formatMockFloat(m.isolatedMargin(symbol, position) + position.UnrealizedPL)
+				}
+			}
+			result = append(result, entry)
+		}
+	}
+	mockJSON(w, result)
+}
+
+func (m *MockFapiServer) handleBalance(w http.ResponseWriter, params url.Values) {
+	unrealized := 0.0
+	for symbol := range m.exchange.positions {
+		position, _ := m.exchange.GetPosition(symbol)
+		unrealized += position.UnrealizedPL
+	}
+	available := formatMockFloat(m.available())
+	mockJSON(w, []fapiBalance{{
+		AccountAlias:       "mock",
+		Asset:              "USDT",
+		Balance:            formatMockFloat(m.wallet),
+		CrossWalletBalance: formatMockFloat(m.wallet),
+		CrossUnPnl:         formatMockFloat(unrealized),
+		AvailableBalance:   available,
+		MaxWithdrawAmount:  available,
+		MarginAvailable:    true,
+		UpdateTime:         time.Now().UnixMilli(),
+	}})
+}
+
+func (m *MockFapiServer) handlePremiumIndex(w http.ResponseWriter, r *http.Request) {
+	symbol := r.URL.Query().Get("symbol")
+	if symbol == "" {
+		mockError(w, http.StatusBadRequest, -1102, "Mandatory parameter 'symbol' was not sent.")
+		return
+	}
+	m.mu.Lock()
+	price := m.markPrice(symbol, time.Now())
+	m.mu.Unlock()
+	mockJSON(w, map[string]interface{}{
+		"symbol":          symbol,
+		"markPrice":       formatMockFloat(price),
+		"indexPrice":      formatMockFloat(price),
+		"lastFundingRate": "0.00010000",
+		"nextFundingTime": time.Now().Truncate(8 * time.Hour).Add(8 * time.Hour).UnixMilli(),
+		"time":            time.Now().UnixMilli(),
+	})
+}
+
+// handleKlines serves candles of the price wave, the last one still open.
+func (m *MockFapiServer) handleKlines(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	symbol := query.Get("symbol")
+	interval := klineIntervalDuration(query.Get("interval"))
+	limit, _ := strconv.Atoi(query.Get("limit"))
+	if limit <= 0 {
+		limit = mockKlinesDefaultLimit
+	}
+	if limit > mockKlinesMaxLimit {
+		limit = mockKlinesMaxLimit
+	}
+	startMs, _ := strconv.ParseInt(query.Get("startTime"), 10, 64)
+	endMs, _ := strconv.ParseInt(query.Get("endTime"), 10, 64)
+
+	now := time.Now()
+	end := now
+	if endMs > 0 && time.UnixMilli(endMs).Before(now) {
+		end = time.UnixMilli(endMs)
+	}
+	last := end.Truncate(interval)
+	first := last.Add(-time.Duration(limit-1) * interval)
+	if startMs > 0 {
+		first = time.UnixMilli(startMs).Truncate(interval)
+		if time.UnixMilli(startMs).After(first) {
+			first = first.Add(interval)
+		}
+		if limitEnd := first.Add(time.Duration(limit-1) * interval); limitEnd.Before(last) {
+			last = limitEnd
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	result := [][]interface{}{}
+	for open := first; !open.After(last); open = open.Add(interval) {
+		closeTime := open.Add(interval)
+		if closeTime.After(now) {
+			closeTime = now
+		}
+		prices := []float64{m.markPrice(symbol, open), m.markPrice(symbol, open.Add(interval/2)), m.markPrice(symbol, closeTime)}
+		openPrice, closePrice := prices[0], prices[2]
+		high, low := math.Max(math.Max(prices[0], prices[1]), prices[2]), math.Min(math.Min(prices[0], prices[1]), prices[2])
+		volume := 1000 + 500*math.Abs(math.Sin(float64(open.Unix())/3600))
+		result = append(result, []interface{}{
+			open.UnixMilli(), formatMockFloat(openPrice), formatMockFloat(high * 1.001), formatMockFloat(low * 0.999), formatMockFloat(closePrice),
+			formatMockFloat(volume), open.Add(interval).UnixMilli() - 1, formatMockFloat(volume * closePrice), int(volume / 10),
+			formatMockFloat(volume / 2), formatMockFloat(volume / 2 * closePrice), "0",
+		})
+	}
+	mockJSON(w, result)
+}
+
+// syncPrices marks the requested symbol and every symbol the exchange knows
+// to the current price.
+func (m *MockFapiServer) syncPrices(symbol string) {
+	now := time.Now()
+	if symbol != "" {
+		m.exchange.SetPrice(symbol, m.markPrice(symbol, now), now)
+	}
+	for known := range m.exchange.prices {
+		m.exchange.SetPrice(known, m.markPrice(known, now), now)
+	}
+}
+
+func (m *MockFapiServer) markPrice(symbol string, at time.Time) float64 {
+	if price, ok := m.prices[symbol]; ok {
+		return price
+	}
+	return m.wavePrice(symbol, at)
+}
+
+// wavePrice is a slow 4 hour wave of ±2% around a base price derived from
+// the symbol, so every run sees the same market.
+func (m *MockFapiServer) wavePrice(symbol string, at time.Time) float64 {
+	hash := fnv.New32a()
+	hash.Write([]byte(symbol))
+	seed := hash.Sum32()
+	base := map[string]float64{"BTCUSDT": 100000, "ETHUSDT": 4000}[symbol]
+	if base == 0 {
+		base = math.Pow(10, float64(seed%5)-2) * (1 + float64(seed%97)/10)
+	}
+	phase := float64(seed%360) * math.Pi / 180
+	minutes := float64(at.Unix()) / 60
+	return base * (1 + 0.02*math.Sin(2*math.Pi*minutes/240+phase))
+}
+
+func (m *MockFapiServer) leverageOf(symbol string) int {
+	if leverage, ok := m.leverage[symbol]; ok {
+		return leverage
+	}
+	return 20
+}
+
+func (m *MockFapiServer) marginType(symbol string) MarginType {
+	if marginType, ok := m.marginTypes[symbol]; ok {
+		return marginType
+	}
+	return MarginTypeCrossed
+}
+
+func (m *MockFapiServer) isolatedMargin(symbol string, position *Position) float64 {
+	return position.EntryPrice*position.Amount/float64(position.Leverage) + m.topUps[symbol]
+}
+
+// liquidationPrice is where the position margin (isolated) or the free
+// wallet (cross) is used up down to the maintenance margin.
+func (m *MockFapiServer) liquidationPrice(symbol string, position *Position) float64 {
+	margin := m.isolatedMargin(symbol, position)
+	if m.marginType(symbol) == MarginTypeCrossed {
+		margin = m.available() + position.EntryPrice*position.Amount/float64(position.Leverage)
+	}
+	buffer := (margin - position.EntryPrice*position.Amount*mockMaintenanceMargin) / position.Amount
+	if position.Side == PositionSideShort {
+		return position.EntryPrice + buffer
+	}
+	return math.Max(0, position.EntryPrice-buffer)
+}
+
+// available is the wallet plus unrealized P/L minus the initial margin of the
+// open positions and isolated top-ups.
+func (m *MockFapiServer) available() float64 {
+	available := m.wallet
+	for symbol := range m.exchange.positions {
+		position, _ := m.exchange.GetPosition(symbol)
+		available += position.UnrealizedPL - position.EntryPrice*position.Amount/float64(position.Leverage) - m.topUps[symbol]
+	}
+	return available
+}
+
+// bookTrades moves the P/L of new trades into the wallet.
+func (m *MockFapiServer) bookTrades() {
+	for _, trade := range m.exchange.Trades[m.booked:] {
+		m.wallet += trade.RealizedPL
+	}
+	m.booked = len(m.exchange.Trades)
+}
+
+func formatMockFloat(value float64) string {
+	return strconv.FormatFloat(value, 'f', 8, 64)
+}
+
+func mockJSON(w http.ResponseWriter, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(value)
+}
+
+func mockOK(w http.ResponseWriter) {
+	mockJSON(w, map[string]interface{}{"code": 200, "msg": "success"})
+}
+
+func mockError(w http.ResponseWriter, status, code int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	fmt.Fprintf(w, `{"code":%d,"msg":%q}`, code, message)
+}
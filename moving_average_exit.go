@@ -1,53 +1,269 @@
 package main
 
 import (
+	"fmt"
 	"log"
 	"math"
 )
 
-func CalculateMovingAveragePnLSignal(snapshots []PositionSnapshot, currentPnL float64) MovingAveragePnLSignal {
+type MAExitAverage string
+
+const (
+	// MAExitAverageAll is the mean of every snapshot since the position opened.
+	MAExitAverageAll    MAExitAverage = "all"
+	MAExitAverageWindow MAExitAverage = "window"
+	MAExitAverageEMA    MAExitAverage = "ema"
+)
+
+type MAExitBand string
+
+const (
+	// MAExitBandRatio exits below Ratio times the average.
+	MAExitBandRatio MAExitBand = "ratio"
+	// MAExitBandStdDev exits below the average minus Multiplier standard
+	// deviations of P/L over the averaging window.
+	MAExitBandStdDev MAExitBand = "stddev"
+	// MAExitBandATR exits below the average minus Multiplier coin ATRs scaled
+	// by the position amount.
+	MAExitBandATR MAExitBand = "atr"
+)
+
+const (
+	MAExitDefaultRatio = 0.7
+	// MAExitMinSnapshots is the default number of snapshots the moving average
+	// needs before it can signal an exit.
+	MAExitMinSnapshots = 10
+)
+
+// MAExitConfig configures the moving average P/L exit of a pair. The averages
+// run on snapshot P/L, for a position of fixed size P/L is linear in price so
+// this is the same exit as on a price average. A zero Ratio uses the market
+// regime rule, MinProfitPercent is the share of entry notional the average has
+// to exceed before the exit arms.
+type MAExitConfig struct {
+	Average          MAExitAverage
+	Window           int
+	Band             MAExitBand
+	Ratio            float64
+	Multiplier       float64
+	MinProfitPercent float64
+	MinSnapshots     int
+}
+
+func DefaultMAExitConfig() MAExitConfig {
+	return MAExitConfig{
+		Average:          MAExitAverageAll,
+		Window:           60,
+		Band:             MAExitBandRatio,
+		Ratio:            0,
+		Multiplier:       2,
+		MinProfitPercent: 0,
+		MinSnapshots:     MAExitMinSnapshots,
+	}
+}
+
+func GetMAExitConfig(pair TradingPair) MAExitConfig {
+	if pair.MAExit == nil {
+		return DefaultMAExitConfig()
+	}
+	config := *pair.MAExit
+	defaults := DefaultMAExitConfig()
+	if config.Average == "" {
+		config.Average = defaults.Average
+	}
+	if config.Band == "" {
+		config.Band = defaults.Band
+	}
+	if config.Window <= 0 {
+		config.Window = defaults.Window
+	}
+	if config.Multiplier <= 0 {
+		config.Multiplier = defaults.Multiplier
+	}
+	if config.MinSnapshots <= 0 {
+		config.MinSnapshots = defaults.MinSnapshots
+	}
+	return config
+}
+
+// WithParams overrides the numeric fields from strategy params, so shadows and
+// the optimizer can vary them: ma_exit_ratio, ma_min_snapshots,
+// ma_exit_window, ma_exit_multiplier and ma_exit_min_profit_percent.
+func (c MAExitConfig) WithParams(params StrategyParams) MAExitConfig {
+	c.Ratio = params.Float("ma_exit_ratio", c.Ratio)
+	c.MinSnapshots = params.Int("ma_min_snapshots", c.MinSnapshots)
+	c.Window = params.Int("ma_exit_window", c.Window)
+	c.Multiplier = params.Float("ma_exit_multiplier", c.Multiplier)
+	c.MinProfitPercent = params.Float("ma_exit_min_profit_percent", c.MinProfitPercent)
+	return c
+}
+
+// MAExitInput is the position the exit is evaluated for. EntryPrice and Amount
+// fall back to the last snapshot, ATR is the coin ATR in price units.
+type MAExitInput struct {
+	Snapshots  []PositionSnapshot
+	CurrentPnL float64
+	EntryPrice float64
+	Amount     float64
+	ATR        float64
+}
+
+// EvaluateMAExit closes when P/L falls below the band under its moving
+// average, or gives back all profit, once the average has cleared the
+// minimum profit floor. Every step is recorded in the signal reasoning.
+func EvaluateMAExit(config MAExitConfig, input MAExitInput) MovingAveragePnLSignal {
+	snapshots := input.Snapshots
 	signal := MovingAveragePnLSignal{
-		ShouldClose:    false,
-		CurrentPnL:     currentPnL,
+		CurrentPnL:     input.CurrentPnL,
 		SnapshotsCount: len(snapshots),
+		Average:        string(config.Average),
+		Band:           string(config.Band),
+	}
+	reason := func(format string, args ...interface{}) {
+		signal.Reasoning = append(signal.Reasoning, fmt.Sprintf(format, args...))
 	}
 
-	if len(snapshots) < 10 {
-		signal.TriggerReason = "Not enough snapshots for analysis (minimum 10 required)"
+	minSnapshots := config.MinSnapshots
+	if minSnapshots <= 0 {
+		minSnapshots = MAExitMinSnapshots
+	}
+	if len(snapshots) < minSnapshots {
+		signal.TriggerReason = fmt.Sprintf("Not enough snapshots for analysis (minimum %d required)", minSnapshots)
+		reason("%d snapshots, %d required", len(snapshots), minSnapshots)
 		return signal
 	}
 
-	var sum float64
-	for _, snap := range snapshots {
-		sum += snap.UnrealizedPL
+	values := make([]float64, len(snapshots))
+	for i, snap := range snapshots {
+		values[i] = snap.UnrealizedPL
+	}
+	window := values
+	switch config.Average {
+	case MAExitAverageWindow, MAExitAverageEMA:
+		if config.Window > 0 && config.Window < len(values) {
+			window = values[len(values)-config.Window:]
+		}
+		signal.Window = len(window)
+	default:
+		signal.Window = len(values)
 	}
-	movingAverage := sum / float64(len(snapshots))
-	signal.MovingAverage = movingAverage
 
-	if movingAverage <= 0 {
-		signal.TriggerReason = "Moving average is negative or zero - no exit signal"
+	switch config.Average {
+	case MAExitAverageEMA:
+		signal.MovingAverage = emaOf(values, config.Window)
+		reason("EMA(%d) of %d snapshots: $%.2f", config.Window, len(values), signal.MovingAverage)
+	case MAExitAverageWindow:
+		signal.MovingAverage = meanOf(window)
+		reason("Mean of last %d snapshots: $%.2f", len(window), signal.MovingAverage)
+	default:
+		signal.MovingAverage = meanOf(values)
+		reason("Mean of all %d snapshots: $%.2f", len(values), signal.MovingAverage)
+	}
+	movingAverage := signal.MovingAverage
+
+	// Short positions carry a negative amount, the floor and the ATR band
+	// scale with the size.
+	entryPrice, amount := input.EntryPrice, math.Abs(input.Amount)
+	last := snapshots[len(snapshots)-1]
+	if entryPrice <= 0 {
+		entryPrice = last.EntryPrice
+	}
+	if amount == 0 {
+		amount = math.Abs(last.Amount)
+	}
+	signal.MinProfit = config.MinProfitPercent / 100 * entryPrice * amount
+	floor := math.Max(0, signal.MinProfit)
+	if movingAverage <= floor {
+		signal.TriggerReason = fmt.Sprintf("Moving average $%.2f is not above the $%.2f profit floor - no exit signal", movingAverage, floor)
+		reason("Not armed: average below the %.2f%% profit floor ($%.2f)", config.MinProfitPercent, floor)
 		return signal
 	}
+	signal.Armed = true
+	reason("Armed: average above the $%.2f profit floor", floor)
 
-	threshold := movingAverage * 0.7
-	signal.Threshold = threshold
+	band := config.Band
+	switch band {
+	case MAExitBandStdDev:
+		signal.Volatility = stdDevOf(window)
+		signal.Threshold = movingAverage - config.Multiplier*signal.Volatility
+		reason("Band: average - %.2f x P/L std dev $%.2f = $%.2f", config.Multiplier, signal.Volatility, signal.Threshold)
+	case MAExitBandATR:
+		if input.ATR > 0 && amount > 0 {
+			signal.Volatility = input.ATR * amount
+			signal.Threshold = movingAverage - config.Multiplier*signal.Volatility
+			reason("Band: average - %.2f x ATR P/L $%.2f (ATR %.6f x amount %.4f) = $%.2f", config.Multiplier, signal.Volatility, input.ATR, amount, signal.Threshold)
+			break
+		}
+		band = MAExitBandRatio
+		reason("No ATR or position amount, falling back to the ratio band")
+		fallthrough
+	default:
+		ratio := config.Ratio
+		if ratio <= 0 {
+			ratio = MAExitDefaultRatio
+		}
+		signal.Threshold = movingAverage * ratio
+		reason("Band: %.0f%% of average = $%.2f", ratio*100, signal.Threshold)
+	}
+	signal.Band = string(band)
 
-	percentBelowMA := ((movingAverage - currentPnL) / math.Abs(movingAverage)) * 100
-	signal.PercentBelowMA = percentBelowMA
+	signal.PercentBelowMA = ((movingAverage - input.CurrentPnL) / math.Abs(movingAverage)) * 100
 
-	if currentPnL < threshold && currentPnL > 0 {
-		signal.ShouldClose = true
-		signal.TriggerReason = "Current PnL dropped below 70% of moving average - exit signal triggered"
-		log.Printf("⚠️ MA EXIT SIGNAL: Current PnL ($%.2f) is %.1f%% below MA ($%.2f), threshold: $%.2f",
-			currentPnL, percentBelowMA, movingAverage, threshold)
-	} else if currentPnL <= 0 && movingAverage > 0 {
+	if input.CurrentPnL <= 0 {
 		signal.ShouldClose = true
 		signal.TriggerReason = "Current PnL turned negative while MA is positive - exit signal triggered"
+		reason("Exit: P/L $%.2f gave back all profit", input.CurrentPnL)
 		log.Printf("⚠️ MA EXIT SIGNAL: PnL turned negative ($%.2f) while MA is positive ($%.2f)",
-			currentPnL, movingAverage)
+			input.CurrentPnL, movingAverage)
+	} else if input.CurrentPnL < signal.Threshold {
+		signal.ShouldClose = true
+		signal.TriggerReason = fmt.Sprintf("Current PnL dropped below the %s band of the moving average - exit signal triggered", band)
+		reason("Exit: P/L $%.2f below threshold $%.2f (%.1f%% below average)", input.CurrentPnL, signal.Threshold, signal.PercentBelowMA)
+		log.Printf("⚠️ MA EXIT SIGNAL: Current PnL ($%.2f) is %.1f%% below MA ($%.2f), threshold: $%.2f",
+			input.CurrentPnL, signal.PercentBelowMA, movingAverage, signal.Threshold)
 	} else {
 		signal.TriggerReason = "No exit signal - position performing within acceptable range"
+		reason("Hold: P/L $%.2f above threshold $%.2f", input.CurrentPnL, signal.Threshold)
 	}
 
 	return signal
 }
+
+func meanOf(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sum := 0.0
+	for _, v := range values {
+		sum += v
+	}
+	return sum / float64(len(values))
+}
+
+func stdDevOf(values []float64) float64 {
+	if len(values) < 2 {
+		return 0
+	}
+	mean := meanOf(values)
+	variance := 0.0
+	for _, v := range values {
+		variance += (v - mean) * (v - mean)
+	}
+	return math.Sqrt(variance / float64(len(values)-1))
+}
+
+// emaOf seeds the EMA with the first value and runs it over the whole series.
+func emaOf(values []float64, period int) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	if period <= 0 {
+		period = len(values)
+	}
+	alpha := 2 / (float64(period) + 1)
+	ema := values[0]
+	for _, v := range values[1:] {
+		ema = alpha*v + (1-alpha)*ema
+	}
+	return ema
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// rampSnapshots is a position at entry 100 whose P/L climbs from 10 to 28 in
+// steps of 2: mean 19, sample std dev sqrt(330/9).
+func rampSnapshots(amount float64) []PositionSnapshot {
+	snapshots := make([]PositionSnapshot, 10)
+	for i := range snapshots {
+		snapshots[i] = PositionSnapshot{EntryPrice: 100, Amount: amount, UnrealizedPL: 10 + 2*float64(i)}
+	}
+	return snapshots
+}
+
+func TestEvaluateMAExit_Bands(t *testing.T) {
+	ratio := MAExitConfig{Band: MAExitBandRatio, Ratio: 0.7, MinSnapshots: 10}
+	stddev := MAExitConfig{Band: MAExitBandStdDev, Multiplier: 1, MinSnapshots: 10}
+	atr := MAExitConfig{Band: MAExitBandATR, Multiplier: 2, MinSnapshots: 10}
+	stddevThreshold := 19 - math.Sqrt(330.0/9)
+
+	tests := []struct {
+		name      string
+		config    MAExitConfig
+		amount    float64
+		atr       float64
+		current   float64
+		band      MAExitBand
+		threshold float64
+		close     bool
+	}{
+		{"long ratio hold", ratio, 10, 0, 14, MAExitBandRatio, 13.3, false},
+		{"long ratio exit", ratio, 10, 0, 13, MAExitBandRatio, 13.3, true},
+		{"short ratio exit", ratio, -10, 0, 13, MAExitBandRatio, 13.3, true},
+		{"long stddev hold", stddev, 10, 0, 13, MAExitBandStdDev, stddevThreshold, false},
+		{"short stddev exit", stddev, -10, 0, 12.9, MAExitBandStdDev, stddevThreshold, true},
+		{"long atr hold", atr, 10, 0.5, 10, MAExitBandATR, 9, false},
+		{"long atr exit", atr, 10, 0.5, 8.9, MAExitBandATR, 9, true},
+		{"short atr hold", atr, -10, 0.5, 10, MAExitBandATR, 9, false},
+		{"short atr exit", atr, -10, 0.5, 8.9, MAExitBandATR, 9, true},
+		{"atr without ATR falls back to the ratio", atr, -10, 0, 10, MAExitBandRatio, 13.3, true},
+		{"giving back all profit exits", atr, 10, 0.5, -1, MAExitBandATR, 9, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			signal := EvaluateMAExit(tt.config, MAExitInput{Snapshots: rampSnapshots(tt.amount), CurrentPnL: tt.current, Amount: tt.amount, ATR: tt.atr})
+			assert.True(t, signal.Armed, signal.TriggerReason)
+			assert.InDelta(t, 19, signal.MovingAverage, 1e-9)
+			assert.Equal(t, string(tt.band), signal.Band)
+			assert.InDelta(t, tt.threshold, signal.Threshold, 1e-9)
+			assert.Equal(t, tt.close, signal.ShouldClose, signal.Reasoning)
+		})
+	}
+}
+
+func TestEvaluateMAExit_ProfitFloor(t *testing.T) {
+	tests := []struct {
+		name         string
+		minProfit    float64
+		inputAmount  float64
+		snapshotSize float64
+		floor        float64
+		armed        bool
+	}{
+		{"long below the floor", 2, 10, 10, 20, false},
+		{"short below the floor", 2, -10, -10, 20, false},
+		{"short amount from the snapshots", 2, 0, -10, 20, false},
+		{"long above the floor", 1, 10, 10, 10, true},
+		{"short above the floor", 1, -10, -10, 10, true},
+		{"no floor", 0, -10, -10, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := MAExitConfig{Band: MAExitBandRatio, Ratio: 0.7, MinProfitPercent: tt.minProfit, MinSnapshots: 10}
+			signal := EvaluateMAExit(config, MAExitInput{Snapshots: rampSnapshots(tt.snapshotSize), CurrentPnL: 13, Amount: tt.inputAmount})
+			assert.InDelta(t, tt.floor, signal.MinProfit, 1e-9, "percent of the 1000 entry notional")
+			assert.Equal(t, tt.armed, signal.Armed, signal.TriggerReason)
+			assert.Equal(t, tt.armed, signal.ShouldClose, "P/L 13 is below the 13.3 band once armed")
+		})
+	}
+}
+
+func TestEvaluateMAExit_Averages(t *testing.T) {
+	snapshots := rampSnapshots(10)
+
+	window := EvaluateMAExit(MAExitConfig{Average: MAExitAverageWindow, Window: 4, Band: MAExitBandRatio, Ratio: 0.7, MinSnapshots: 10},
+		MAExitInput{Snapshots: snapshots, CurrentPnL: 17, Amount: 10})
+	assert.Equal(t, 4, window.Window)
+	assert.InDelta(t, 25, window.MovingAverage, 1e-9, "mean of the last four snapshots")
+	assert.True(t, window.ShouldClose, "17 is below the 17.5 band of 25")
+
+	stddevWindow := EvaluateMAExit(MAExitConfig{Average: MAExitAverageWindow, Window: 4, Band: MAExitBandStdDev, Multiplier: 1, MinSnapshots: 10},
+		MAExitInput{Snapshots: snapshots, CurrentPnL: 25, Amount: 10})
+	assert.InDelta(t, math.Sqrt(20.0/3), stddevWindow.Volatility, 1e-9, "the std dev runs over the same window")
+
+	ema := EvaluateMAExit(MAExitConfig{Average: MAExitAverageEMA, Window: 3, Band: MAExitBandRatio, Ratio: 0.7, MinSnapshots: 10},
+		MAExitInput{Snapshots: snapshots, CurrentPnL: 20, Amount: 10})
+	assert.Equal(t, 3, ema.Window)
+	assert.InDelta(t, 26+2.0/512, ema.MovingAverage, 1e-9, "alpha 0.5 lags a slope of 2 by 2, the seed error halves each step")
+	assert.False(t, ema.ShouldClose)
+
+	all := EvaluateMAExit(MAExitConfig{Band: MAExitBandRatio, Ratio: 0.7, MinSnapshots: 10}, MAExitInput{Snapshots: snapshots, CurrentPnL: 20, Amount: 10})
+	assert.Equal(t, 10, all.Window)
+	assert.InDelta(t, 19, all.MovingAverage, 1e-9)
+
+	short := EvaluateMAExit(MAExitConfig{MinSnapshots: 11}, MAExitInput{Snapshots: snapshots, CurrentPnL: 20})
+	assert.False(t, short.Armed)
+	assert.Contains(t, short.TriggerReason, "minimum 11")
+}
@@ -0,0 +1,459 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	OptimizeTargetFudShort      = "fud_short"
+	OptimizeTargetFudContrarian = "fud_contrarian"
+
+	OptimizeDefaultDays     = 60
+	OptimizeDefaultFolds    = 4
+	OptimizeDefaultTop      = 10
+	optimizeMinSegmentHours = 48
+	optimizeTrainSegments   = 2
+	optimizeRandomSeed      = 1
+)
+
+// ParameterRange lists the values swept for one parameter.
+type ParameterRange struct {
+	Name   string
+	Values []float64
+}
+
+// optimizeParameterSpaces are the magic numbers the optimizer sweeps per
+// target. Strategy targets use strategy params, FUD targets the fields of
+// FudModeConfig in hours and percent.
+var optimizeParameterSpaces = map[string][]ParameterRange{
+	StrategySentimentIchimoku: {
+		{Name: "ma_exit_ratio", Values: []float64{0, 0.5, 0.6, 0.7, 0.8, 0.9}},
+		{Name: "ma_min_snapshots", Values: []float64{5, 10, 30, 60}},
+		{Name: "sentiment_short_below", Values: []float64{2, 3, 4, 5}},
+	},
+	StrategyIchimoku: {
+		{Name: "btc_filter", Values: []float64{0, 1}},
+		{Name: "ma_exit", Values: []float64{0, 1}},
+		{Name: "ma_exit_ratio", Values: []float64{0, 0.7, 0.85}},
+		{Name: "ma_min_snapshots", Values: []float64{10, 60}},
+		{Name: "ma_exit_min_profit_percent", Values: []float64{0, 0.5, 1}},
+	},
+	OptimizeTargetFudShort: {
+		{Name: "max_attack_age_hours", Values: []float64{1, 2, 4}},
+		{Name: "quiet_period_hours", Values: []float64{6, 12, 24}},
+		{Name: "max_hold_hours", Values: []float64{24, 48, 72}},
+	},
+	OptimizeTargetFudContrarian: {
+		{Name: "quiet_period_hours", Values: []float64{6, 12, 24}},
+		{Name: "max_hold_hours", Values: []float64{24, 72}},
+		{Name: "peak_quiet_hours", Values: []float64{1, 2, 4}},
+		{Name: "peak_decay_ratio", Values: []float64{0.3, 0.5, 0.7}},
+		{Name: "stop_loss_percent", Values: []float64{2, 3, 5}},
+		{Name: "take_profit_percent", Values: []float64{5, 8, 12}},
+	},
+}
+
+func OptimizeTargets() []string {
+	targets := make([]string, 0, len(optimizeParameterSpaces))
+	for target := range optimizeParameterSpaces {
+		targets = append(targets, target)
+	}
+	sort.Strings(targets)
+	return targets
+}
+
+// OptimizationMetrics are computed on per-trade returns in percent of the
+// entry notional after fees. Drawdown is the largest fall of the cumulative
+// return from its peak.
+type OptimizationMetrics struct {
+	Trades      int     `json:"trades"`
+	WinRate     float64 `json:"win_rate"`
+	TotalReturn float64 `json:"total_return"`
+	AvgReturn   float64 `json:"avg_return"`
+	MaxDrawdown float64 `json:"max_drawdown"`
+}
+
+type WalkForwardFold struct {
+	Fold        int                 `json:"fold"`
+	TrainFrom   time.Time           `json:"train_from"`
+	TestFrom    time.Time           `json:"test_from"`
+	TestTo      time.Time           `json:"test_to"`
+	InSample    OptimizationMetrics `json:"in_sample"`
+	OutOfSample OptimizationMetrics `json:"out_of_sample"`
+}
+
+type ParameterSetResult struct {
+	Rank            int                 `json:"rank"`
+	Params          StrategyParams      `json:"params"`
+	InSample        OptimizationMetrics `json:"in_sample"`
+	OutOfSample     OptimizationMetrics `json:"out_of_sample"`
+	ProfitableFolds int                 `json:"profitable_folds"`
+	Folds           []WalkForwardFold   `json:"folds"`
+	Config          string              `json:"config"`
+}
+
+// OptimizationReport ranks parameter sets of one pair and target by their
+// out-of-sample results. WalkForward is the result of picking the best set
+// on every train window and trading it on the following test window, the
+// honest estimate of what the optimization itself is worth.
+type OptimizationReport struct {
+	Symbol           string               `json:"symbol"`
+	Target           string               `json:"target"`
+	From             time.Time            `json:"from"`
+	To               time.Time            `json:"to"`
+	Folds            int                  `json:"folds"`
+	Candidates       int                  `json:"candidates"`
+	WalkForward      OptimizationMetrics  `json:"walk_forward"`
+	WalkForwardPicks []StrategyParams     `json:"walk_forward_picks"`
+	Results          []ParameterSetResult `json:"results"`
+	Error            string               `json:"error,omitempty"`
+}
+
+// OptimizeOptions configures a run. Samples > 0 evaluates a random subset of
+// the grid instead of all of it.
+type OptimizeOptions struct {
+	Target  string
+	Days    int
+	Folds   int
+	Samples int
+	Top     int
+}
+
+type candidateEvaluation struct {
+	params   StrategyParams
+	segments [][]float64
+}
+
+// OptimizeParameters sweeps the parameter space of a target over the history
+// of a pair. The window is cut into Folds+2 equal segments, fold i trains on
+// segments i and i+1 and tests on segment i+2. Every segment is simulated on
+// its own starting flat, so each candidate runs over the history once.
+func OptimizeParameters(data *SimulationData, options OptimizeOptions) OptimizationReport {
+	report := OptimizationReport{Symbol: data.Pair.Symbol, Target: options.Target, From: data.From, To: data.To, Folds: options.Folds}
+
+	space, ok := optimizeParameterSpaces[options.Target]
+	if !ok {
+		report.Error = fmt.Sprintf("unknown optimize target %q, available: %s", options.Target, strings.Join(OptimizeTargets(), ", "))
+		return report
+	}
+	segmentCount := options.Folds + optimizeTrainSegments
+	segmentHours := len(data.Frames) / segmentCount
+	if options.Folds <= 0 || segmentHours < optimizeMinSegmentHours {
+		report.Error = fmt.Sprintf("%d hours of history is too short for %d folds (segments of at least %dh)", len(data.Frames), options.Folds, optimizeMinSegmentHours)
+		return report
+	}
+
+	candidates := parameterGrid(space)
+	if options.Samples > 0 && options.Samples < len(candidates) {
+		random := rand.New(rand.NewSource(optimizeRandomSeed))
+		random.Shuffle(len(candidates), func(i, j int) { candidates[i], candidates[j] = candidates[j], candidates[i] })
+		candidates = candidates[:options.Samples]
+	}
+	report.Candidates = len(candidates)
+
+	evaluations := make([]candidateEvaluation, 0, len(candidates))
+	for _, params := range candidates {
+		evaluation := candidateEvaluation{params: params, segments: make([][]float64, segmentCount)}
+		for s := 0; s < segmentCount; s++ {
+			returns, err := evaluateSegment(data, options.Target, params, s*segmentHours, (s+1)*segmentHours)
+			if err != nil {
+				report.Error = err.Error()
+				return report
+			}
+			evaluation.segments[s] = returns
+		}
+		evaluations = append(evaluations, evaluation)
+	}
+
+	var walkForwardReturns []float64
+	for fold := 0; fold < options.Folds; fold++ {
+		best := -1
+		bestReturn := 0.0
+		for i, evaluation := range evaluations {
+			train := summarizeReturns(concatReturns(evaluation.segments[fold : fold+optimizeTrainSegments]))
+			if best < 0 || train.TotalReturn > bestReturn {
+				best, bestReturn = i, train.TotalReturn
+			}
+		}
+		report.WalkForwardPicks = append(report.WalkForwardPicks, evaluations[best].params)
+		walkForwardReturns = append(walkForwardReturns, evaluations[best].segments[fold+optimizeTrainSegments]...)
+	}
+	report.WalkForward = summarizeReturns(walkForwardReturns)
+
+	segmentStart := func(s int) time.Time { return data.From.Add(hoursToDuration(s * segmentHours)) }
+	for _, evaluation := range evaluations {
+		result := ParameterSetResult{Params: evaluation.params}
+		var trainReturns, testReturns []float64
+		for fold := 0; fold < options.Folds; fold++ {
+			train := concatReturns(evaluation.segments[fold : fold+optimizeTrainSegments])
+			test := evaluation.segments[fold+optimizeTrainSegments]
+			trainReturns = append(trainReturns, train...)
+			testReturns = append(testReturns, test...)
+			foldResult := WalkForwardFold{
+				Fold:        fold + 1,
+				TrainFrom:   segmentStart(fold),
+				TestFrom:    segmentStart(fold + optimizeTrainSegments),
+				TestTo:      segmentStart(fold + optimizeTrainSegments + 1),
+				InSample:    summarizeReturns(train),
+				OutOfSample: summarizeReturns(test),
+			}
+			if foldResult.OutOfSample.TotalReturn > 0 {
+				result.ProfitableFolds++
+			}
+			result.Folds = append(result.Folds, foldResult)
+		}
+		result.InSample = summarizeReturns(trainReturns)
+		result.OutOfSample = summarizeReturns(testReturns)
+		result.Config = optimizedConfigSnippet(data.Pair, options.Target, evaluation.params)
+		report.Results = append(report.Results, result)
+	}
+
+	sort.SliceStable(report.Results, func(i, j int) bool {
+		a, b := report.Results[i], report.Results[j]
+		if a.OutOfSample.TotalReturn != b.OutOfSample.TotalReturn {
+			return a.OutOfSample.TotalReturn > b.OutOfSample.TotalReturn
+		}
+		if a.ProfitableFolds != b.ProfitableFolds {
+			return a.ProfitableFolds > b.ProfitableFolds
+		}
+		return a.InSample.TotalReturn > b.InSample.TotalReturn
+	})
+	if options.Top > 0 && len(report.Results) > options.Top {
+		report.Results = report.Results[:options.Top]
+	}
+	for i := range report.Results {
+		report.Results[i].Rank = i + 1
+	}
+	return report
+}
+
+// evaluateSegment returns the per-trade returns of one parameter set over
+// frames [start, end).
+func evaluateSegment(data *SimulationData, target string, params StrategyParams, start, end int) ([]float64, error) {
+	var returns []float64
+	switch target {
+	case OptimizeTargetFudShort, OptimizeTargetFudContrarian:
+		config := fudModeConfigWithParams(GetFudModeConfig(data.Pair), target, params)
+		episodes, _ := groupFudBacktestEpisodes(data.FudAttacks, config, data.From)
+		roundTripFee := 2 * SimulatedTakerFee * 100
+		for _, episode := range episodes {
+			if episode.startIdx < start || episode.startIdx >= end {
+				continue
+			}
+			var outcome FudModeOutcomeRecord
+			if target == OptimizeTargetFudShort {
+				outcome = simulateFudShort(data.Pair, config, episode, data.From, data.HourlyCloses)
+			} else {
+				outcome = simulateFudContrarian(data.Pair, config, episode, data.From, data.HourlyCloses, data.FudCounts, data.SentimentTrends)
+			}
+			if !outcome.Skipped {
+				returns = append(returns, outcome.ReturnPercent-roundTripFee)
+			}
+		}
+	default:
+		strategyParams := StrategyParams{"fud_mode": 0}
+		for name, value := range params {
+			strategyParams[name] = value
+		}
+		result, err := RunStrategySimulation(data, StrategyConfig{Name: target, Params: strategyParams}, start, end)
+		if err != nil {
+			return nil, err
+		}
+		for _, trade := range result.Trades {
+			returns = append(returns, trade.ReturnPercent)
+		}
+	}
+	return returns, nil
+}
+
+func parameterGrid(space []ParameterRange) []StrategyParams {
+	grid := []StrategyParams{{}}
+	for _, parameter := range space {
+		var next []StrategyParams
+		for _, params := range grid {
+			for _, value := range parameter.Values {
+				combined := StrategyParams{parameter.Name: value}
+				for name, v := range params {
+					combined[name] = v
+				}
+				next = append(next, combined)
+			}
+		}
+		grid = next
+	}
+	return grid
+}
+
+func concatReturns(segments [][]float64) []float64 {
+	var returns []float64
+	for _, segment := range segments {
+		returns = append(returns, segment...)
+	}
+	return returns
+}
+
+func summarizeReturns(returns []float64) OptimizationMetrics {
+	metrics := OptimizationMetrics{Trades: len(returns)}
+	wins := 0
+	var cumulative, peak float64
+	for _, r := range returns {
+		metrics.TotalReturn += r
+		if r > 0 {
+			wins++
+		}
+		cumulative += r
+		if cumulative > peak {
+			peak = cumulative
+		}
+		if peak-cumulative > metrics.MaxDrawdown {
+			metrics.MaxDrawdown = peak - cumulative
+		}
+	}
+	if len(returns) > 0 {
+		metrics.WinRate = float64(wins) / float64(len(returns))
+		metrics.AvgReturn = metrics.TotalReturn / float64(len(returns))
+	}
+	return metrics
+}
+
+func fudModeConfigWithParams(config FudModeConfig, target string, params StrategyParams) FudModeConfig {
+	hours := func(name string, fallback time.Duration) time.Duration {
+		return time.Duration(params.Float(name, fallback.Hours()) * float64(time.Hour))
+	}
+	config.Strategy = FudStrategyShort
+	if target == OptimizeTargetFudContrarian {
+		config.Strategy = FudStrategyContrarian
+	}
+	config.MaxAttackAge = hours("max_attack_age_hours", config.MaxAttackAge)
+	config.QuietPeriod = hours("quiet_period_hours", config.QuietPeriod)
+	config.MaxHoldTime = hours("max_hold_hours", config.MaxHoldTime)
+	config.PeakQuietPeriod = hours("peak_quiet_hours", config.PeakQuietPeriod)
+	config.PeakDecayRatio = params.Float("peak_decay_ratio", config.PeakDecayRatio)
+	config.StopLossPercent = params.Float("stop_loss_percent", config.StopLossPercent)
+	config.TakeProfitPercent = params.Float("take_profit_percent", config.TakeProfitPercent)
+	return config
+}
+
+// optimizedConfigSnippet renders a parameter set as a TradingPair field ready
+// to paste into the pair config in main.go.
+func optimizedConfigSnippet(pair TradingPair, target string, params StrategyParams) string {
+	if target == OptimizeTargetFudShort || target == OptimizeTargetFudContrarian {
+		config := fudModeConfigWithParams(GetFudModeConfig(pair), target, params)
+		strategy := "FudStrategyShort"
+		if config.Strategy == FudStrategyContrarian {
+			strategy = "FudStrategyContrarian"
+		}
+		return fmt.Sprintf("FudMode: &FudModeConfig{Strategy: %s, MinConfidence: %g, MinParticipants: %d, MinMessages: %d, "+
+			"MaxAttackAge: %s, QuietPeriod: %s, MaxHoldTime: %s, PeakQuietPeriod: %s, PeakDecayRatio: %g, MaxPeakWait: %s, "+
+			"StopLossPercent: %g, TakeProfitPercent: %g},",
+			strategy, config.MinConfidence, config.MinParticipants, config.MinMessages,
+			durationLiteral(config.MaxAttackAge), durationLiteral(config.QuietPeriod), durationLiteral(config.MaxHoldTime),
+			durationLiteral(config.PeakQuietPeriod), config.PeakDecayRatio, durationLiteral(config.MaxPeakWait),
+			config.StopLossPercent, config.TakeProfitPercent)
+	}
+
+	names := make([]string, 0, len(params))
+	for name := range params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	values := make([]string, 0, len(names))
+	for _, name := range names {
+		values = append(values, fmt.Sprintf("%q: %g", name, params[name]))
+	}
+	return fmt.Sprintf("Strategy: StrategyConfig{Name: %q, Params: StrategyParams{%s}},", target, strings.Join(values, ", "))
+}
+
+func durationLiteral(d time.Duration) string {
+	if d%time.Hour == 0 {
+		return fmt.Sprintf("%d * time.Hour", int64(d/time.Hour))
+	}
+	return fmt.Sprintf("%d * time.Minute", int64(d/time.Minute))
+}
+
+// runOptimizeCommand is the -optimize entry point. It loads the history of
+// every pair (or only symbol), optimizes the target, prints the ranked sets
+// and optionally writes all reports as JSON.
+func runOptimizeCommand(symbol string, options OptimizeOptions, output string) error {
+	if options.Days <= 0 {
+		options.Days = OptimizeDefaultDays
+	}
+	if options.Days > ResearchMaxDays {
+		options.Days = ResearchMaxDays
+	}
+	if _, ok := optimizeParameterSpaces[options.Target]; !ok {
+		return fmt.Errorf("unknown optimize target %q, available: %s", options.Target, strings.Join(OptimizeTargets(), ", "))
+	}
+
+	exchanges, err := NewExchanges(TradingPairs, os.Getenv(ENV_PROXY_DSN))
+	if err != nil {
+		return err
+	}
+
+	to := time.Now().Truncate(time.Hour)
+	from := to.Add(-time.Duration(options.Days) * 24 * time.Hour)
+	var reports []OptimizationReport
+	for _, pair := range TradingPairs {
+		if symbol != "" && pair.Symbol != symbol {
+			continue
+		}
+		log.Printf("[%s] Loading %d days of history for the optimizer...", pair.Symbol, options.Days)
+		data, err := LoadSimulationData(exchanges.For(pair), pair, from, to)
+		if err != nil {
+			log.Printf("[%s] Optimizer skipped: %v", pair.Symbol, err)
+			continue
+		}
+
+		report := optimizeQuietly(data, options)
+		reports = append(reports, report)
+		printOptimizationReport(os.Stdout, report)
+	}
+
+	if output != "" {
+		file, err := os.Create(output)
+		if err != nil {
+			return fmt.Errorf("failed to create optimizer output: %w", err)
+		}
+		defer file.Close()
+		encoder := json.NewEncoder(file)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(reports)
+	}
+	return nil
+}
+
+// optimizeQuietly mutes the per-cycle strategy logs while the simulations
+// run.
+func optimizeQuietly(data *SimulationData, options OptimizeOptions) OptimizationReport {
+	writer := log.Writer()
+	log.SetOutput(io.Discard)
+	report := OptimizeParameters(data, options)
+	log.SetOutput(writer)
+	log.Printf("[%s] Optimizer %s evaluated %d parameter sets", data.Pair.Symbol, options.Target, report.Candidates)
+	return report
+}
+
+func printOptimizationReport(w io.Writer, report OptimizationReport) {
+	fmt.Fprintf(w, "\n%s %s %s..%s, %d folds, %d parameter sets\n", report.Symbol, report.Target,
+		report.From.Format("2006-01-02"), report.To.Format("2006-01-02"), report.Folds, report.Candidates)
+	if report.Error != "" {
+		fmt.Fprintf(w, "error: %s\n", report.Error)
+		return
+	}
+	fmt.Fprintf(w, "walk-forward (best on train, traded on test): trades=%d\twin_rate=%.1f%%\ttotal_return=%.2f%%\tmax_drawdown=%.2f%%\n",
+		report.WalkForward.Trades, report.WalkForward.WinRate*100, report.WalkForward.TotalReturn, report.WalkForward.MaxDrawdown)
+	for _, result := range report.Results {
+		fmt.Fprintf(w, "#%d\toos_trades=%d\toos_win_rate=%.1f%%\toos_return=%.2f%%\toos_drawdown=%.2f%%\tprofitable_folds=%d/%d\tis_return=%.2f%%\n",
+			result.Rank, result.OutOfSample.Trades, result.OutOfSample.WinRate*100, result.OutOfSample.TotalReturn,
+			result.OutOfSample.MaxDrawdown, result.ProfitableFolds, report.Folds, result.InSample.TotalReturn)
+		fmt.Fprintf(w, "\t%s\n", result.Config)
+	}
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParameterGrid(t *testing.T) {
+	grid := parameterGrid([]ParameterRange{
+		{Name: "a", Values: []float64{1, 2}},
+		{Name: "b", Values: []float64{10, 20, 30}},
+	})
+	assert.Len(t, grid, 6)
+	assert.Contains(t, grid, StrategyParams{"a": 2, "b": 30})
+	assert.Equal(t, []StrategyParams{{}}, parameterGrid(nil), "an empty space is the one default set")
+
+	for target, space := range optimizeParameterSpaces {
+		want := 1
+		for _, parameter := range space {
+			want *= len(parameter.Values)
+		}
+		grid := parameterGrid(space)
+		seen := make(map[string]bool, len(grid))
+		for _, params := range grid {
+			assert.Len(t, params, len(space), target)
+			seen[fmt.Sprint(params)] = true
+		}
+		assert.Len(t, grid, want, target)
+		assert.Len(t, seen, want, "%s: every combination once", target)
+	}
+}
+
+// optimizeSegmentHours is the length of one synthetic FUD cycle in
+// fudOptimizeData, every fold segment holds exactly one.
+const optimizeSegmentHours = 100
+
+// fudOptimizeData repeats one FUD cycle per segment: attacks every 10h from
+// hour 5 to 45, the first one analysed 3h late, and a price falling from 100
+// at hour 5 to 70 at hour 70 before it recovers. Shorting the first attack
+// and holding until hour 70 wins, which needs max_attack_age_hours 4,
+// quiet_period_hours 24 and max_hold_hours 72.
+func fudOptimizeData(segments int) *SimulationData {
+	from := time.Date(2025, 8, 1, 0, 0, 0, 0, time.UTC)
+	hours := segments * optimizeSegmentHours
+	data := &SimulationData{
+		Pair:         scenarioPair(),
+		From:         from,
+		To:           from.Add(hoursToDuration(hours)),
+		Frames:       make([]SimulationFrame, hours),
+		HourlyCloses: make([]float64, hours),
+	}
+	for i := range data.HourlyCloses {
+		h := float64(i % optimizeSegmentHours)
+		switch {
+		case h <= 5:
+			data.HourlyCloses[i] = 100
+		case h <= 70:
+			data.HourlyCloses[i] = 100 - (h-5)*30/65
+		default:
+			data.HourlyCloses[i] = 70 + (h - 70)
+		}
+	}
+	for s := 0; s < segments; s++ {
+		for hour := 5; hour <= 45; hour += 10 {
+			attackTime := from.Add(hoursToDuration(s*optimizeSegmentHours + hour))
+			delay := 30 * time.Minute
+			if hour == 5 {
+				delay = 3 * time.Hour
+			}
+			data.FudAttacks = append(data.FudAttacks, FudAttackRecord{HasAttack: true, Confidence: 0.8, LastAttackTime: attackTime, CreatedAt: attackTime.Add(delay)})
+		}
+	}
+	return data
+}
+
+func TestOptimizeParameters_WalkForward(t *testing.T) {
+	data := fudOptimizeData(4)
+	report := OptimizeParameters(data, OptimizeOptions{Target: OptimizeTargetFudShort, Folds: 2})
+	require.Empty(t, report.Error)
+
+	winner := StrategyParams{"max_attack_age_hours": 4, "quiet_period_hours": 24, "max_hold_hours": 72}
+	tradeReturn := 30 - 2*SimulatedTakerFee*100
+
+	assert.Equal(t, 27, report.Candidates)
+	require.Len(t, report.Results, 27)
+	best := report.Results[0]
+	assert.Equal(t, 1, best.Rank)
+	assert.Equal(t, winner, best.Params)
+	assert.Greater(t, best.OutOfSample.TotalReturn, report.Results[1].OutOfSample.TotalReturn, "the winner is unique")
+	assert.Equal(t, 2, best.OutOfSample.Trades)
+	assert.InDelta(t, 2*tradeReturn, best.OutOfSample.TotalReturn, 1e-9, "one 100 -> 70 short per test segment after fees")
+	assert.InDelta(t, 4*tradeReturn, best.InSample.TotalReturn, 1e-9)
+	assert.Equal(t, 2, best.ProfitableFolds)
+	assert.Contains(t, best.Config, "MaxAttackAge: 4 * time.Hour, QuietPeriod: 24 * time.Hour, MaxHoldTime: 72 * time.Hour")
+
+	assert.Equal(t, []StrategyParams{winner, winner}, report.WalkForwardPicks)
+	assert.InDelta(t, 2*tradeReturn, report.WalkForward.TotalReturn, 1e-9)
+
+	require.Len(t, best.Folds, 2)
+	segment := hoursToDuration(optimizeSegmentHours)
+	for i, fold := range best.Folds {
+		assert.Equal(t, i+1, fold.Fold)
+		assert.Equal(t, data.From.Add(time.Duration(i)*segment), fold.TrainFrom, "fold %d trains on segments %d and %d", i+1, i, i+1)
+		assert.Equal(t, data.From.Add(time.Duration(i+2)*segment), fold.TestFrom)
+		assert.Equal(t, data.From.Add(time.Duration(i+3)*segment), fold.TestTo)
+		assert.Equal(t, 2, fold.InSample.Trades)
+		assert.Equal(t, 1, fold.OutOfSample.Trades)
+	}
+}
+
+func TestOptimizeParameters_Options(t *testing.T) {
+	data := fudOptimizeData(4)
+
+	report := OptimizeParameters(data, OptimizeOptions{Target: OptimizeTargetFudShort, Folds: 2, Samples: 5, Top: 3})
+	require.Empty(t, report.Error)
+	assert.Equal(t, 5, report.Candidates)
+	assert.Len(t, report.Results, 3)
+	assert.Equal(t, 3, report.Results[2].Rank)
+
+	assert.Contains(t, OptimizeParameters(data, OptimizeOptions{Target: "unknown", Folds: 2}).Error, "unknown optimize target")
+	assert.Contains(t, OptimizeParameters(data, OptimizeOptions{Target: OptimizeTargetFudShort, Folds: 7}).Error, "too short")
+	assert.Contains(t, OptimizeParameters(data, OptimizeOptions{Target: OptimizeTargetFudShort}).Error, "too short")
+}
+
+func TestSummarizeReturns(t *testing.T) {
+	metrics := summarizeReturns([]float64{2, -3, -1, 4})
+	assert.Equal(t, 4, metrics.Trades)
+	assert.InDelta(t, 0.5, metrics.WinRate, 1e-9)
+	assert.InDelta(t, 2, metrics.TotalReturn, 1e-9)
+	assert.InDelta(t, 0.5, metrics.AvgReturn, 1e-9)
+	assert.InDelta(t, 4, metrics.MaxDrawdown, 1e-9, "from the 2 peak down to -2")
+
+	assert.Equal(t, OptimizationMetrics{}, summarizeReturns(nil))
+}
@@ -1,21 +1,25 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"github.com/grutapig/fudtradebot/claude"
 )
 
-func ValidateOrderWithAI(claudeClient claude.ClaudeApi, decision TradingDecisionResult, btcIchimoku IchimokuAnalysis, coinIchimoku IchimokuAnalysis, activityAnalysis ActivityAnalysis, fudActivityAnalysis ActivityAnalysis, sentimentAnalysis ClaudeSentimentResponse) (ClaudeOrderValidationResponse, error) {
+func ValidateOrderWithAI(ctx context.Context, llm claude.LLM, decision TradingDecisionResult, btcIchimoku IchimokuAnalysis, coinIchimoku IchimokuAnalysis, activityAnalysis ActivityAnalysis, fudActivityAnalysis ActivityAnalysis, fudShare FudShareAnalysis, sentimentAnalysis ClaudeSentimentResponse, regime RegimeAnalysis, correlation CorrelationAnalysis) (ClaudeOrderValidationResponse, error) {
 	systemPrompt := `You are a cryptocurrency trading assistant. Your task is to validate whether a trading decision should be executed based on the provided market data and technical analysis.
 
 You will receive:
 1. Trading decision from the automated system (LONG/SHORT)
 2. BTC Ichimoku analysis
 3. Coin Ichimoku analysis
-4. Community activity trend
-5. FUD activity trend
+4. Community activity trend with z-score against the hour-of-week baseline, EWMA, CUSUM change point, spikes and a confidence value
+5. FUD activity trend (same metrics)
 6. Sentiment analysis
+7. Market regime (TRENDING_UP, TRENDING_DOWN, RANGING, VOLATILE or ILLIQUID) with ATR, ADX and cloud metrics
+8. Share of FUD messages in total community activity, recent window vs baseline
+9. Rolling correlation and beta of the coin against BTC (and ETH when available)
 
 Your task is to evaluate all this data and decide:
 - Should we open the order? (true/false)
@@ -26,8 +30,11 @@ Consider:
 - Are all indicators aligned?
 - Is there conflicting data?
 - Are market conditions favorable?
-- Are there any red flags in sentiment or FUD activity?
+- Are there any red flags in sentiment or FUD activity? A rising FUD share matters even when total activity is flat
+- How confident are the activity detectors? Low confidence means the baseline is short or the detectors disagree
 - Is the timing appropriate?
+- How strongly does the coin follow BTC? With high correlation a contradicting BTC trend is a serious risk, a decoupled coin can trade on its own signal
+- Does the market regime support this trade? Ichimoku signals are unreliable in RANGING markets, and VOLATILE or ILLIQUID markets carry extra risk
 
 Response must be STRICTLY in JSON format:
 {
@@ -42,7 +49,10 @@ Response must be STRICTLY in JSON format:
 		CoinIchimoku IchimokuAnalysis        `json:"coin_ichimoku"`
 		Activity     ActivityAnalysis        `json:"activity"`
 		FudActivity  ActivityAnalysis        `json:"fud_activity"`
+		FudShare     FudShareAnalysis        `json:"fud_share"`
 		Sentiment    ClaudeSentimentResponse `json:"sentiment"`
+		Regime       RegimeAnalysis          `json:"market_regime"`
+		Correlation  CorrelationAnalysis     `json:"correlation"`
 	}
 
 	requestData := ValidationRequest{
@@ -51,7 +61,10 @@ Response must be STRICTLY in JSON format:
 		CoinIchimoku: coinIchimoku,
 		Activity:     activityAnalysis,
 		FudActivity:  fudActivityAnalysis,
+		FudShare:     fudShare,
 		Sentiment:    sentimentAnalysis,
+		Regime:       regime,
+		Correlation:  correlation,
 	}
 
 	requestJSON, err := json.Marshal(requestData)
@@ -61,29 +74,10 @@ Response must be STRICTLY in JSON format:
 
 	userMessage := fmt.Sprintf("Please validate this trading decision:\n\n%s", string(requestJSON))
 
-	messages := claude.ClaudeMessages{
-		{
-			Role:    claude.ROLE_USER,
-			Content: userMessage,
-		},
-		{
-			Role:    claude.ROLE_ASSISTANT,
-			Content: "{",
-		},
-	}
-
-	response, err := claudeClient.SendMessage(messages, systemPrompt)
+	request := newLLMRequest(LLMPurposeOrderValidation, systemPrompt, userMessage, orderValidationSchema)
+	validationResponse, _, err := claude.CompleteJSON[ClaudeOrderValidationResponse](ctx, llm, request)
 	if err != nil {
-		return ClaudeOrderValidationResponse{}, fmt.Errorf("failed to send message to Claude: %w", err)
-	}
-
-	if len(response.Content) == 0 {
-		return ClaudeOrderValidationResponse{}, fmt.Errorf("empty response from Claude")
-	}
-
-	var validationResponse ClaudeOrderValidationResponse
-	if err := json.Unmarshal([]byte("{"+response.Content[0].Text), &validationResponse); err != nil {
-		return ClaudeOrderValidationResponse{}, fmt.Errorf("failed to parse Claude response: %w", err)
+		return ClaudeOrderValidationResponse{}, fmt.Errorf("order validation failed: %w", err)
 	}
 
 	return validationResponse, nil
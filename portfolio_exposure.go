@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+)
+
+const DEFAULT_MAX_BTC_BETA_EXPOSURE = 150.0
+
+type ExposureCheck struct {
+	Allowed             bool    `json:"allowed"`
+	CurrentBetaExposure float64 `json:"current_beta_exposure"`
+	NewBetaExposure     float64 `json:"new_beta_exposure"`
+	TotalBetaExposure   float64 `json:"total_beta_exposure"`
+	MaxBetaExposure     float64 `json:"max_beta_exposure"`
+	Reason              string  `json:"reason"`
+}
+
+func GetMaxBTCBetaExposure() float64 {
+	valueStr := os.Getenv(ENV_MAX_BTC_BETA_EXPOSURE)
+	if valueStr == "" {
+		return DEFAULT_MAX_BTC_BETA_EXPOSURE
+	}
+	value, err := strconv.ParseFloat(valueStr, 64)
+	if err != nil || value <= 0 {
+		return DEFAULT_MAX_BTC_BETA_EXPOSURE
+	}
+	return value
+}
+
+// CheckPortfolioExposure sums the BTC beta-weighted notional of all open
+// positions and rejects a new entry when it would push the net exposure in
+// the same direction above the configured limit.
+func CheckPortfolioExposure(symbol string, side PositionSide, notional float64, correlation CorrelationAnalysis) (ExposureCheck, error) {
+	check := ExposureCheck{
+		Allowed:         true,
+		MaxBetaExposure: GetMaxBTCBetaExposure(),
+	}
+
+	openPositions, err := GetOpenPositions()
+	if err != nil {
+		return check, fmt.Errorf("failed to get open positions: %w", err)
+	}
+
+	for _, p := range openPositions {
+		if p.Symbol == symbol {
+			continue
+		}
+		check.CurrentBetaExposure += signedNotional(PositionSide(p.Side), p.EntryPrice*p.Quantity) * p.BTCBeta
+	}
+
+	check.NewBetaExposure = signedNotional(side, notional) * correlation.BTCBeta
+	check.TotalBetaExposure = check.CurrentBetaExposure + check.NewBetaExposure
+
+	increasesExposure := math.Abs(check.TotalBetaExposure) > math.Abs(check.CurrentBetaExposure)
+	if increasesExposure && math.Abs(check.TotalBetaExposure) > check.MaxBetaExposure {
+		check.Allowed = false
+		check.Reason = fmt.Sprintf("BTC beta exposure %.2f USDT would exceed limit %.2f USDT (current %.2f, new %.2f)",
+			check.TotalBetaExposure, check.MaxBetaExposure, check.CurrentBetaExposure, check.NewBetaExposure)
+	} else {
+		check.Reason = fmt.Sprintf("BTC beta exposure %.2f USDT within limit %.2f USDT", check.TotalBetaExposure, check.MaxBetaExposure)
+	}
+
+	return check, nil
+}
+
+func signedNotional(side PositionSide, notional float64) float64 {
+	if side == PositionSideShort {
+		return -notional
+	}
+	return notional
+}
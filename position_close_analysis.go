@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"github.com/grutapig/fudtradebot/claude"
@@ -73,7 +74,7 @@ func CalculateSnapshotStatistics(snapshots []PositionSnapshot) SnapshotStatistic
 	}
 }
 
-func AnalyzePositionClose(claudeClient claude.ClaudeApi, position PositionRecord, snapshots []PositionSnapshot, recentTweets []CommunityTweet, btcIchimoku IchimokuAnalysis, coinIchimoku IchimokuAnalysis, ichimoku ClosePositionReason, maSignal MovingAveragePnLSignal) (ClaudePositionCloseResponse, error) {
+func AnalyzePositionClose(ctx context.Context, llm claude.LLM, position PositionRecord, snapshots []PositionSnapshot, recentTweets []CommunityTweet, btcIchimoku IchimokuAnalysis, coinIchimoku IchimokuAnalysis, ichimoku ClosePositionReason, maSignal MovingAveragePnLSignal, regime RegimeAnalysis) (ClaudePositionCloseResponse, error) {
 	systemPrompt := `You are a cryptocurrency trading assistant analyzing whether to close an open position.
 
 You will receive:
@@ -83,6 +84,7 @@ You will receive:
 4. BTC Ichimoku analysis
 5. Coin Ichimoku analysis
 6. Moving Average PnL Exit Signal - THIS IS CRITICAL!
+7. Market regime of the coin (TRENDING_UP, TRENDING_DOWN, RANGING, VOLATILE or ILLIQUID)
 
 Your task is to analyze:
 - Historical P/L statistics and distribution
@@ -103,6 +105,7 @@ Be careful more attention what exactly side for our current position, if it is s
 Same for LONG position, is its long, and price grow up, and all indicators for it, we should continue hold position don't close.
 Also consider the position opening date and how much time has passed, we use candles with 1-hour interval for the coin and 4-hour interval for Bitcoin.
 Also consider the analysis of whether to close based on the Ichimoku cloud.
+Also consider the market regime: a trend that turned into RANGING or VOLATILE weakens the case for holding, while a regime aligned with our position side supports holding.
 
 Response must be STRICTLY in JSON format:
 {
@@ -124,6 +127,7 @@ Response must be STRICTLY in JSON format:
 		MovingAverageSignal        MovingAveragePnLSignal `json:"moving_average_signal"`
 		CurrentDate                string                 `json:"current_date"`
 		PositionOpenDate           string                 `json:"position_open_date"`
+		MarketRegime               RegimeAnalysis         `json:"market_regime"`
 	}
 
 	snapshotStats := CalculateSnapshotStatistics(snapshots)
@@ -139,6 +143,7 @@ Response must be STRICTLY in JSON format:
 		MovingAverageSignal:        maSignal,
 		CurrentDate:                time.Now().Format(time.RFC3339),
 		PositionOpenDate:           position.OpenedAt.Format(time.RFC3339),
+		MarketRegime:               regime,
 	}
 
 	requestJSON, err := json.Marshal(requestData)
@@ -148,29 +153,10 @@ Response must be STRICTLY in JSON format:
 
 	userMessage := fmt.Sprintf("Should we close this position? Analyze the data:\n\n%s", string(requestJSON))
 
-	messages := claude.ClaudeMessages{
-		{
-			Role:    claude.ROLE_USER,
-			Content: userMessage,
-		},
-		{
-			Role:    claude.ROLE_ASSISTANT,
-			Content: "{",
-		},
-	}
-
-	response, err := claudeClient.SendMessage(messages, systemPrompt)
+	request := newLLMRequest(LLMPurposeCloseAnalysis, systemPrompt, userMessage, positionCloseSchema)
+	closeResponse, _, err := claude.CompleteJSON[ClaudePositionCloseResponse](ctx, llm, request)
 	if err != nil {
-		return ClaudePositionCloseResponse{}, fmt.Errorf("failed to send message to Claude: %w", err)
-	}
-
-	if len(response.Content) == 0 {
-		return ClaudePositionCloseResponse{}, fmt.Errorf("empty response from Claude")
-	}
-
-	var closeResponse ClaudePositionCloseResponse
-	if err := json.Unmarshal([]byte("{"+response.Content[0].Text), &closeResponse); err != nil {
-		return ClaudePositionCloseResponse{}, fmt.Errorf("failed to parse Claude response: %w", err)
+		return ClaudePositionCloseResponse{}, fmt.Errorf("close analysis failed: %w", err)
 	}
 
 	return closeResponse, nil
@@ -0,0 +1,893 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+)
+
+type ResearchFeature string
+
+const (
+	ResearchFeatureActivity    ResearchFeature = "activity"
+	ResearchFeatureFudActivity ResearchFeature = "fud_activity"
+	ResearchFeatureFudShare    ResearchFeature = "fud_share"
+	ResearchFeatureSentiment   ResearchFeature = "sentiment"
+	ResearchFeatureFudAttack   ResearchFeature = "fud_attack"
+)
+
+const (
+	ResearchDefaultDays      = 30
+	ResearchMaxDays          = 90
+	ResearchMaxLagHours      = 24
+	ResearchGrangerLags      = 3
+	ResearchSignificance     = 0.05
+	ResearchSentimentMaxAge  = 2 * time.Hour
+	ResearchFudAttackMaxAge  = time.Hour
+	researchMinObservations  = 30
+	researchKlinesPageLimit  = 1000
+	researchCSVFloatDecimals = 6
+)
+
+var ResearchHorizons = []int{1, 4, 24}
+
+// researchFeatureDirection is the price direction each feature is expected to
+// predict when it is high, used for hit rates.
+var researchFeatureDirection = map[ResearchFeature]Signal{
+	ResearchFeatureActivity:    SignalLong,
+	ResearchFeatureFudActivity: SignalShort,
+	ResearchFeatureFudShare:    SignalShort,
+	ResearchFeatureSentiment:   SignalLong,
+	ResearchFeatureFudAttack:   SignalShort,
+}
+
+var researchFeatures = []ResearchFeature{
+	ResearchFeatureActivity,
+	ResearchFeatureFudActivity,
+	ResearchFeatureFudShare,
+	ResearchFeatureSentiment,
+	ResearchFeatureFudAttack,
+}
+
+var researchExchanges Exchanges
+
+func SetResearchExchanges(exchanges Exchanges) {
+	researchExchanges = exchanges
+}
+
+type LagCorrelation struct {
+	LagHours     int     `json:"lag_hours"`
+	Correlation  float64 `json:"correlation"`
+	Observations int     `json:"observations"`
+}
+
+type CrossCorrelationResult struct {
+	Feature         ResearchFeature  `json:"feature"`
+	Lags            []LagCorrelation `json:"lags"`
+	BestLagHours    int              `json:"best_lag_hours"`
+	BestCorrelation float64          `json:"best_correlation"`
+}
+
+type GrangerResult struct {
+	Feature      ResearchFeature `json:"feature"`
+	Lags         int             `json:"lags"`
+	Observations int             `json:"observations"`
+	FStatistic   float64         `json:"f_statistic"`
+	PValue       float64         `json:"p_value"`
+	Significant  bool            `json:"significant"`
+}
+
+type ReturnBucket struct {
+	Bucket        string  `json:"bucket"`
+	FeatureMin    float64 `json:"feature_min"`
+	FeatureMax    float64 `json:"feature_max"`
+	Observations  int     `json:"observations"`
+	MeanReturn    float64 `json:"mean_return"`
+	MedianReturn  float64 `json:"median_return"`
+	StdReturn     float64 `json:"std_return"`
+	P10Return     float64 `json:"p10_return"`
+	P90Return     float64 `json:"p90_return"`
+	PositiveShare float64 `json:"positive_share"`
+}
+
+type ConditionalReturns struct {
+	Feature      ResearchFeature `json:"feature"`
+	HorizonHours int             `json:"horizon_hours"`
+	Buckets      []ReturnBucket  `json:"buckets"`
+}
+
+type HitRateResult struct {
+	Feature         ResearchFeature `json:"feature"`
+	HorizonHours    int             `json:"horizon_hours"`
+	Direction       Signal          `json:"direction"`
+	Signals         int             `json:"signals"`
+	Hits            int             `json:"hits"`
+	HitRate         float64         `json:"hit_rate"`
+	AvgSignedReturn float64         `json:"avg_signed_return"`
+}
+
+type CommunityResearch struct {
+	Symbol             string                   `json:"symbol"`
+	CommunityID        string                   `json:"community_id"`
+	From               time.Time                `json:"from"`
+	To                 time.Time                `json:"to"`
+	Hours              int                      `json:"hours"`
+	PriceHours         int                      `json:"price_hours"`
+	Coverage           map[ResearchFeature]int  `json:"coverage"`
+	CrossCorrelations  []CrossCorrelationResult `json:"cross_correlations"`
+	Granger            []GrangerResult          `json:"granger"`
+	ConditionalReturns []ConditionalReturns     `json:"conditional_returns"`
+	HitRates           []HitRateResult          `json:"hit_rates"`
+	Error              string                   `json:"error,omitempty"`
+}
+
+type ResearchReport struct {
+	GeneratedAt  time.Time           `json:"generated_at"`
+	Days         int                 `json:"days"`
+	Horizons     []int               `json:"horizons"`
+	MaxLagHours  int                 `json:"max_lag_hours"`
+	GrangerLags  int                 `json:"granger_lags"`
+	Communities  []CommunityResearch `json:"communities"`
+	Significance float64             `json:"significance"`
+}
+
+// RunSentimentPriceResearch joins the stored activity, FUD activity, sentiment
+// and FUD attack history of every pair with hourly kline returns and measures
+// how well each series predicts price.
+func RunSentimentPriceResearch(exchanges Exchanges, pairs []TradingPair, days int) ResearchReport {
+	if days <= 0 {
+		days = ResearchDefaultDays
+	}
+	if days > ResearchMaxDays {
+		days = ResearchMaxDays
+	}
+
+	report := ResearchReport{
+		GeneratedAt:  time.Now(),
+		Days:         days,
+		Horizons:     ResearchHorizons,
+		MaxLagHours:  ResearchMaxLagHours,
+		GrangerLags:  ResearchGrangerLags,
+		Significance: ResearchSignificance,
+	}
+
+	to := time.Now().Truncate(time.Hour)
+	from := to.Add(-time.Duration(days) * 24 * time.Hour)
+	for _, pair := range pairs {
+		result, err := researchCommunity(exchanges.For(pair), pair, from, to)
+		if err != nil {
+			log.Printf("[%s] Research failed: %v", pair.Symbol, err)
+			result.Error = err.Error()
+		}
+		report.Communities = append(report.Communities, result)
+	}
+
+	return report
+}
+
+func researchCommunity(exchange Exchange, pair TradingPair, from, to time.Time) (CommunityResearch, error) {
+	result := CommunityResearch{
+		Symbol:      pair.Symbol,
+		CommunityID: pair.CommunityID,
+		From:        from,
+		To:          to,
+		Hours:       int(to.Sub(from).Hours()) + 1,
+		Coverage:    make(map[ResearchFeature]int),
+	}
+
+	closes, err := fetchHourlyCloses(exchange, pair.Symbol, from, to, result.Hours)
+	if err != nil {
+		return result, err
+	}
+	for _, c := range closes {
+		if !math.IsNaN(c) {
+			result.PriceHours++
+		}
+	}
+
+	features, err := buildResearchFeatures(pair, from, result.Hours)
+	if err != nil {
+		return result, err
+	}
+	for feature, series := range features {
+		result.Coverage[feature] = countValid(series)
+	}
+
+	hourlyReturns := make([]float64, result.Hours)
+	for i := range hourlyReturns {
+		hourlyReturns[i] = math.NaN()
+		if i > 0 && closes[i] > 0 && closes[i-1] > 0 {
+			hourlyReturns[i] = math.Log(closes[i] / closes[i-1])
+		}
+	}
+
+	forwardReturns := make(map[int][]float64, len(ResearchHorizons))
+	for _, horizon := range ResearchHorizons {
+		series := make([]float64, result.Hours)
+		for i := range series {
+			series[i] = math.NaN()
+			if i+horizon < len(closes) && closes[i] > 0 && closes[i+horizon] > 0 {
+				series[i] = math.Log(closes[i+horizon] / closes[i])
+			}
+		}
+		forwardReturns[horizon] = series
+	}
+
+	for _, feature := range researchFeatures {
+		series := features[feature]
+		result.CrossCorrelations = append(result.CrossCorrelations, laggedCrossCorrelation(feature, series, hourlyReturns, ResearchMaxLagHours))
+		result.Granger = append(result.Granger, grangerTest(feature, series, hourlyReturns, ResearchGrangerLags))
+		for _, horizon := range ResearchHorizons {
+			result.ConditionalReturns = append(result.ConditionalReturns, conditionalReturns(feature, series, forwardReturns[horizon], horizon))
+			result.HitRates = append(result.HitRates, hitRate(feature, series, forwardReturns[horizon], horizon))
+		}
+	}
+
+	return result, nil
+}
+
+// fetchHourlyCloses returns one close per hour starting at from, NaN where
+// the exchange has no candle.
+func fetchHourlyCloses(exchange Exchange, symbol string, from, to time.Time, hours int) ([]float64, error) {
+	closes := make([]float64, hours)
+	for i := range closes {
+		closes[i] = math.NaN()
+	}
+	if exchange == nil {
+		return closes, fmt.Errorf("no exchange configured for price data")
+	}
+
+	start := from.UnixMilli()
+	end := to.Add(time.Hour).UnixMilli() - 1
+	for start <= end {
+		klines, err := exchange.Klines(symbol, "1h", start, end, researchKlinesPageLimit)
+		if err != nil {
+			return closes, err
+		}
+		if len(klines) == 0 {
+			break
+		}
+		for _, k := range klines {
+			idx := int((k.OpenTime - from.UnixMilli()) / time.Hour.Milliseconds())
+			if idx < 0 || idx >= hours {
+				continue
+			}
+			if k.Close > 0 {
+				closes[idx] = k.Close
+			}
+		}
+		next := klines[len(klines)-1].OpenTime + time.Hour.Milliseconds()
+		if next <= start || len(klines) < researchKlinesPageLimit {
+			break
+		}
+		start = next
+	}
+
+	return closes, nil
+}
+
+func buildResearchFeatures(pair TradingPair, from time.Time, hours int) (map[ResearchFeature][]float64, error) {
+	to := from.Add(time.Duration(hours-1) * time.Hour)
+	features := make(map[ResearchFeature][]float64, len(researchFeatures))
+	for _, feature := range researchFeatures {
+		series := make([]float64, hours)
+		for i := range series {
+			series[i] = math.NaN()
+		}
+		features[feature] = series
+	}
+
+	totals, err := hourlyActivitySeries(pair.CommunityID, ActivityKindTotal, from, to, hours)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load activity: %w", err)
+	}
+	fuds, err := hourlyActivitySeries(pair.CommunityID, ActivityKindFud, from, to, hours)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load FUD activity: %w", err)
+	}
+	for i := 1; i < hours; i++ {
+		if !math.IsNaN(totals[i]) && !math.IsNaN(totals[i-1]) {
+			features[ResearchFeatureActivity][i] = math.Log1p(totals[i]) - math.Log1p(totals[i-1])
+		}
+		if !math.IsNaN(fuds[i]) && !math.IsNaN(fuds[i-1]) {
+			features[ResearchFeatureFudActivity][i] = math.Log1p(fuds[i]) - math.Log1p(fuds[i-1])
+		}
+	}
+	for i := 0; i < hours; i++ {
+		if !math.IsNaN(totals[i]) && totals[i] > 0 && !math.IsNaN(fuds[i]) {
+			features[ResearchFeatureFudShare][i] = fuds[i] / totals[i]
+		}
+	}
+
+	sentiments, err := GetSentimentRecords(pair.Symbol, from.Add(-ResearchSentimentMaxAge), to.Add(time.Hour))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load sentiment: %w", err)
+	}
+	var sentimentTimes []time.Time
+	var sentimentValues []float64
+	for _, s := range sentiments {
+		sentimentTimes = append(sentimentTimes, s.CreatedAt)
+		sentimentValues = append(sentimentValues, float64(s.OverallSentiment))
+	}
+	forwardFill(features[ResearchFeatureSentiment], from, sentimentTimes, sentimentValues, ResearchSentimentMaxAge)
+
+	attacks, err := GetFudAttacksInRange(pair.Symbol, from.Add(-ResearchFudAttackMaxAge), to.Add(time.Hour))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load FUD attacks: %w", err)
+	}
+	var attackTimes []time.Time
+	var attackValues []float64
+	for _, a := range attacks {
+		attackTimes = append(attackTimes, a.CreatedAt)
+		value := 0.0
+		if a.HasAttack {
+			value = a.Confidence
+		}
+		attackValues = append(attackValues, value)
+	}
+	forwardFill(features[ResearchFeatureFudAttack], from, attackTimes, attackValues, ResearchFudAttackMaxAge)
+
+	return features, nil
+}
+
+func hourlyActivitySeries(communityID string, kind string, from, to time.Time, hours int) ([]float64, error) {
+	series := make([]float64, hours)
+	for i := range series {
+		series[i] = math.NaN()
+	}
+
+	records, err := GetActivityPoints(communityID, kind, from, to)
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range records {
+		idx := int(r.Hour.Sub(from) / time.Hour)
+		if idx >= 0 && idx < hours {
+			series[idx] = float64(r.MessageCount)
+		}
+	}
+	return series, nil
+}
+
+// forwardFill assigns each hour the latest observation made before the end of
+// that hour, as long as it is not older than maxAge.
+func forwardFill(series []float64, from time.Time, times []time.Time, values []float64, maxAge time.Duration) {
+	j := -1
+	for i := range series {
+		hourEnd := from.Add(time.Duration(i+1) * time.Hour)
+		for j+1 < len(times) && times[j+1].Before(hourEnd) {
+			j++
+		}
+		if j >= 0 && hourEnd.Sub(times[j]) <= maxAge+time.Hour {
+			series[i] = values[j]
+		}
+	}
+}
+
+// laggedCrossCorrelation correlates the feature at hour t with the 1h return
+// at t+lag. Positive lags mean the feature leads price.
+func laggedCrossCorrelation(feature ResearchFeature, series, returns []float64, maxLag int) CrossCorrelationResult {
+	result := CrossCorrelationResult{Feature: feature}
+	for lag := -maxLag; lag <= maxLag; lag++ {
+		var xs, ys []float64
+		for t := range series {
+			if t+lag < 0 || t+lag >= len(returns) {
+				continue
+			}
+			if math.IsNaN(series[t]) || math.IsNaN(returns[t+lag]) {
+				continue
+			}
+			xs = append(xs, series[t])
+			ys = append(ys, returns[t+lag])
+		}
+
+		lagResult := LagCorrelation{LagHours: lag, Observations: len(xs)}
+		if len(xs) >= researchMinObservations {
+			lagResult.Correlation, _ = correlationAndBeta(xs, ys)
+		}
+		result.Lags = append(result.Lags, lagResult)
+
+		if lag > 0 && math.Abs(lagResult.Correlation) > math.Abs(result.BestCorrelation) {
+			result.BestLagHours = lag
+			result.BestCorrelation = lagResult.Correlation
+		}
+	}
+	return result
+}
+
+// grangerTest compares an AR model of 1h returns against the same model with
+// lagged feature values added, and reports the F-test for the extra terms.
+func grangerTest(feature ResearchFeature, series, returns []float64, lags int) GrangerResult {
+	result := GrangerResult{Feature: feature, Lags: lags, PValue: 1}
+
+	var y []float64
+	var restricted, unrestricted [][]float64
+	for t := lags; t < len(returns); t++ {
+		rowR := []float64{1}
+		valid := !math.IsNaN(returns[t])
+		for l := 1; l <= lags && valid; l++ {
+			if math.IsNaN(returns[t-l]) {
+				valid = false
+			}
+			rowR = append(rowR, returns[t-l])
+		}
+		rowU := append([]float64{}, rowR...)
+		for l := 1; l <= lags && valid; l++ {
+			if math.IsNaN(series[t-l]) {
+				valid = false
+			}
+			rowU = append(rowU, series[t-l])
+		}
+		if !valid {
+			continue
+		}
+		y = append(y, returns[t])
+		restricted = append(restricted, rowR)
+		unrestricted = append(unrestricted, rowU)
+	}
+
+	n := len(y)
+	result.Observations = n
+	dfDenominator := n - 2*lags - 1
+	if n < researchMinObservations || dfDenominator <= 0 {
+		return result
+	}
+
+	rssRestricted, ok := olsResidualSumSquares(restricted, y)
+	if !ok {
+		return result
+	}
+	rssUnrestricted, ok := olsResidualSumSquares(unrestricted, y)
+	if !ok || rssUnrestricted <= 0 {
+		return result
+	}
+
+	result.FStatistic = ((rssRestricted - rssUnrestricted) / float64(lags)) / (rssUnrestricted / float64(dfDenominator))
+	if result.FStatistic < 0 {
+		result.FStatistic = 0
+	}
+	result.PValue = fDistributionSurvival(result.FStatistic, float64(lags), float64(dfDenominator))
+	result.Significant = result.PValue < ResearchSignificance
+	return result
+}
+
+func conditionalReturns(feature ResearchFeature, series, forward []float64, horizon int) ConditionalReturns {
+	result := ConditionalReturns{Feature: feature, HorizonHours: horizon}
+
+	values, returns := pairedValues(series, forward)
+	if len(values) < researchMinObservations {
+		return result
+	}
+
+	for _, bucket := range researchBuckets(feature, values) {
+		var bucketReturns []float64
+		featureMin, featureMax := math.Inf(1), math.Inf(-1)
+		for i, v := range values {
+			if v >= bucket.FeatureMin && v <= bucket.FeatureMax {
+				bucketReturns = append(bucketReturns, returns[i])
+				featureMin = math.Min(featureMin, v)
+				featureMax = math.Max(featureMax, v)
+			}
+		}
+		// Report the observed range, the open bucket bounds cannot be encoded as JSON.
+		bucket.FeatureMin, bucket.FeatureMax = 0, 0
+		if len(bucketReturns) > 0 {
+			bucket.FeatureMin, bucket.FeatureMax = featureMin, featureMax
+		}
+		result.Buckets = append(result.Buckets, describeReturns(bucket, bucketReturns))
+	}
+	return result
+}
+
+// hitRate treats the top tercile (or an active FUD attack) as a signal in the
+// feature's expected direction and the bottom tercile as the opposite signal.
+func hitRate(feature ResearchFeature, series, forward []float64, horizon int) HitRateResult {
+	result := HitRateResult{
+		Feature:      feature,
+		HorizonHours: horizon,
+		Direction:    researchFeatureDirection[feature],
+	}
+
+	values, returns := pairedValues(series, forward)
+	if len(values) < researchMinObservations {
+		return result
+	}
+
+	expected := 1.0
+	if result.Direction == SignalShort {
+		expected = -1.0
+	}
+
+	signedSum := 0.0
+	for _, bucket := range researchBuckets(feature, values) {
+		direction := 0.0
+		switch bucket.Bucket {
+		case "high", "attack":
+			direction = expected
+		case "low":
+			direction = -expected
+		default:
+			continue
+		}
+		for i, v := range values {
+			if v < bucket.FeatureMin || v > bucket.FeatureMax || returns[i] == 0 {
+				continue
+			}
+			result.Signals++
+			signedSum += direction * returns[i]
+			if direction*returns[i] > 0 {
+				result.Hits++
+			}
+		}
+	}
+
+	if result.Signals > 0 {
+		result.HitRate = float64(result.Hits) / float64(result.Signals)
+		result.AvgSignedReturn = signedSum / float64(result.Signals)
+	}
+	return result
+}
+
+func researchBuckets(feature ResearchFeature, values []float64) []ReturnBucket {
+	if feature == ResearchFeatureFudAttack {
+		return []ReturnBucket{
+			{Bucket: "no_attack", FeatureMin: math.Inf(-1), FeatureMax: 0},
+			{Bucket: "attack", FeatureMin: math.SmallestNonzeroFloat64, FeatureMax: math.Inf(1)},
+		}
+	}
+
+	sorted := append([]float64{}, values...)
+	sort.Float64s(sorted)
+	lowCut := quantile(sorted, 1.0/3)
+	highCut := quantile(sorted, 2.0/3)
+	if lowCut == highCut {
+		return []ReturnBucket{{Bucket: "all", FeatureMin: math.Inf(-1), FeatureMax: math.Inf(1)}}
+	}
+	return []ReturnBucket{
+		{Bucket: "low", FeatureMin: math.Inf(-1), FeatureMax: lowCut},
+		{Bucket: "mid", FeatureMin: math.Nextafter(lowCut, math.Inf(1)), FeatureMax: math.Nextafter(highCut, math.Inf(-1))},
+		{Bucket: "high", FeatureMin: highCut, FeatureMax: math.Inf(1)},
+	}
+}
+
+func describeReturns(bucket ReturnBucket, returns []float64) ReturnBucket {
+	bucket.Observations = len(returns)
+	if len(returns) == 0 {
+		return bucket
+	}
+
+	sorted := append([]float64{}, returns...)
+	sort.Float64s(sorted)
+
+	positive := 0
+	for _, r := range returns {
+		bucket.MeanReturn += r
+		if r > 0 {
+			positive++
+		}
+	}
+	bucket.MeanReturn /= float64(len(returns))
+	for _, r := range returns {
+		bucket.StdReturn += (r - bucket.MeanReturn) * (r - bucket.MeanReturn)
+	}
+	if len(returns) > 1 {
+		bucket.StdReturn = math.Sqrt(bucket.StdReturn / float64(len(returns)-1))
+	} else {
+		bucket.StdReturn = 0
+	}
+
+	bucket.MedianReturn = quantile(sorted, 0.5)
+	bucket.P10Return = quantile(sorted, 0.1)
+	bucket.P90Return = quantile(sorted, 0.9)
+	bucket.PositiveShare = float64(positive) / float64(len(returns))
+	return bucket
+}
+
+func pairedValues(series, forward []float64) ([]float64, []float64) {
+	var values, returns []float64
+	for i := range series {
+		if i >= len(forward) || math.IsNaN(series[i]) || math.IsNaN(forward[i]) {
+			continue
+		}
+		values = append(values, series[i])
+		returns = append(returns, forward[i])
+	}
+	return values, returns
+}
+
+func countValid(series []float64) int {
+	count := 0
+	for _, v := range series {
+		if !math.IsNaN(v) {
+			count++
+		}
+	}
+	return count
+}
+
+func quantile(sorted []float64, q float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	pos := q * float64(len(sorted)-1)
+	lower := int(math.Floor(pos))
+	upper := int(math.Ceil(pos))
+	if lower == upper {
+		return sorted[lower]
+	}
+	return sorted[lower] + (sorted[upper]-sorted[lower])*(pos-float64(lower))
+}
+
+// olsResidualSumSquares solves the normal equations with Gaussian elimination,
+// the design matrices here have at most 2*ResearchGrangerLags+1 columns.
+func olsResidualSumSquares(x [][]float64, y []float64) (float64, bool) {
+	if len(x) == 0 {
+		return 0, false
+	}
+	k := len(x[0])
+
+	a := make([][]float64, k)
+	for i := range a {
+		a[i] = make([]float64, k+1)
+	}
+	for row := range x {
+		for i := 0; i < k; i++ {
+			for j := 0; j < k; j++ {
+				a[i][j] += x[row][i] * x[row][j]
+			}
+			a[i][k] += x[row][i] * y[row]
+		}
+	}
+
+	for col := 0; col < k; col++ {
+		pivot := col
+		for row := col + 1; row < k; row++ {
+			if math.Abs(a[row][col]) > math.Abs(a[pivot][col]) {
+				pivot = row
+			}
+		}
+		if math.Abs(a[pivot][col]) < 1e-12 {
+			return 0, false
+		}
+		a[col], a[pivot] = a[pivot], a[col]
+		for row := 0; row < k; row++ {
+			if row == col {
+				continue
+			}
+			factor := a[row][col] / a[col][col]
+			for j := col; j <= k; j++ {
+				a[row][j] -= factor * a[col][j]
+			}
+		}
+	}
+
+	coefficients := make([]float64, k)
+	for i := 0; i < k; i++ {
+		coefficients[i] = a[i][k] / a[i][i]
+	}
+
+	rss := 0.0
+	for row := range x {
+		predicted := 0.0
+		for i := 0; i < k; i++ {
+			predicted += coefficients[i] * x[row][i]
+		}
+		residual := y[row] - predicted
+		rss += residual * residual
+	}
+	return rss, true
+}
+
+// fDistributionSurvival returns P(F > f) for an F(d1, d2) distribution.
+func fDistributionSurvival(f, d1, d2 float64) float64 {
+	if f <= 0 {
+		return 1
+	}
+	return regularizedIncompleteBeta(d2/(d2+d1*f), d2/2, d1/2)
+}
+
+func regularizedIncompleteBeta(x, a, b float64) float64 {
+	if x <= 0 {
+		return 0
+	}
+	if x >= 1 {
+		return 1
+	}
+
+	lgammaA, _ := math.Lgamma(a)
+	lgammaB, _ := math.Lgamma(b)
+	lgammaAB, _ := math.Lgamma(a + b)
+	front := math.Exp(lgammaAB - lgammaA - lgammaB + a*math.Log(x) + b*math.Log(1-x))
+
+	if x < (a+1)/(a+b+2) {
+		return front * betaContinuedFraction(x, a, b) / a
+	}
+	return 1 - front*betaContinuedFraction(1-x, b, a)/b
+}
+
+func betaContinuedFraction(x, a, b float64) float64 {
+	const maxIterations = 200
+	const epsilon = 1e-12
+	const tiny = 1e-300
+
+	c := 1.0
+	d := 1 - (a+b)*x/(a+1)
+	if math.Abs(d) < tiny {
+		d = tiny
+	}
+	d = 1 / d
+	h := d
+
+	for m := 1; m <= maxIterations; m++ {
+		fm := float64(m)
+		numerator := fm * (b - fm) * x / ((a + 2*fm - 1) * (a + 2*fm))
+		d = 1 + numerator*d
+		if math.Abs(d) < tiny {
+			d = tiny
+		}
+		c = 1 + numerator/c
+		if math.Abs(c) < tiny {
+			c = tiny
+		}
+		d = 1 / d
+		h *= d * c
+
+		numerator = -(a + fm) * (a + b + fm) * x / ((a + 2*fm) * (a + 2*fm + 1))
+		d = 1 + numerator*d
+		if math.Abs(d) < tiny {
+			d = tiny
+		}
+		c = 1 + numerator/c
+		if math.Abs(c) < tiny {
+			c = tiny
+		}
+		d = 1 / d
+		delta := d * c
+		h *= delta
+		if math.Abs(delta-1) < epsilon {
+			break
+		}
+	}
+	return h
+}
+
+func WriteResearchJSON(w io.Writer, report ResearchReport) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(report)
+}
+
+// WriteResearchCSV flattens the report into one metric per row so every
+// analysis fits the same columns.
+func WriteResearchCSV(w io.Writer, report ResearchReport) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write([]string{"symbol", "community_id", "analysis", "feature", "horizon_hours", "param", "metric", "value"}); err != nil {
+		return err
+	}
+
+	formatFloat := func(v float64) string {
+		return strconv.FormatFloat(v, 'f', researchCSVFloatDecimals, 64)
+	}
+
+	for _, c := range report.Communities {
+		row := func(analysis string, feature ResearchFeature, horizon int, param string, metric string, value string) error {
+			return writer.Write([]string{c.Symbol, c.CommunityID, analysis, string(feature), strconv.Itoa(horizon), param, metric, value})
+		}
+
+		if c.Error != "" {
+			if err := row("error", "", 0, "", "message", c.Error); err != nil {
+				return err
+			}
+			continue
+		}
+
+		for _, cc := range c.CrossCorrelations {
+			for _, lag := range cc.Lags {
+				param := "lag=" + strconv.Itoa(lag.LagHours)
+				if err := row("cross_correlation", cc.Feature, 1, param, "correlation", formatFloat(lag.Correlation)); err != nil {
+					return err
+				}
+				if err := row("cross_correlation", cc.Feature, 1, param, "observations", strconv.Itoa(lag.Observations)); err != nil {
+					return err
+				}
+			}
+		}
+
+		for _, g := range c.Granger {
+			param := "lags=" + strconv.Itoa(g.Lags)
+			metrics := []struct {
+				name  string
+				value string
+			}{
+				{"f_statistic", formatFloat(g.FStatistic)},
+				{"p_value", formatFloat(g.PValue)},
+				{"observations", strconv.Itoa(g.Observations)},
+			}
+			for _, m := range metrics {
+				if err := row("granger", g.Feature, 1, param, m.name, m.value); err != nil {
+					return err
+				}
+			}
+		}
+
+		for _, cr := range c.ConditionalReturns {
+			for _, b := range cr.Buckets {
+				param := "bucket=" + b.Bucket
+				metrics := []struct {
+					name  string
+					value string
+				}{
+					{"observations", strconv.Itoa(b.Observations)},
+					{"mean_return", formatFloat(b.MeanReturn)},
+					{"median_return", formatFloat(b.MedianReturn)},
+					{"std_return", formatFloat(b.StdReturn)},
+					{"p10_return", formatFloat(b.P10Return)},
+					{"p90_return", formatFloat(b.P90Return)},
+					{"positive_share", formatFloat(b.PositiveShare)},
+				}
+				for _, m := range metrics {
+					if err := row("conditional_returns", cr.Feature, cr.HorizonHours, param, m.name, m.value); err != nil {
+						return err
+					}
+				}
+			}
+		}
+
+		for _, h := range c.HitRates {
+			param := "direction=" + string(h.Direction)
+			metrics := []struct {
+				name  string
+				value string
+			}{
+				{"signals", strconv.Itoa(h.Signals)},
+				{"hit_rate", formatFloat(h.HitRate)},
+				{"avg_signed_return", formatFloat(h.AvgSignedReturn)},
+			}
+			for _, m := range metrics {
+				if err := row("hit_rate", h.Feature, h.HorizonHours, param, m.name, m.value); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// runResearchCommand is the -research entry point, it writes the report to
+// stdout or the given file and exits without starting the trading loops.
+func runResearchCommand(days int, format string, output string) error {
+	exchanges, err := NewExchanges(TradingPairs, os.Getenv(ENV_PROXY_DSN))
+	if err != nil {
+		return err
+	}
+
+	report := RunSentimentPriceResearch(exchanges, TradingPairs, days)
+
+	var w io.Writer = os.Stdout
+	if output != "" {
+		file, err := os.Create(output)
+		if err != nil {
+			return fmt.Errorf("failed to create research output: %w", err)
+		}
+		defer file.Close()
+		w = file
+	}
+
+	switch format {
+	case "csv":
+		return WriteResearchCSV(w, report)
+	case "json", "":
+		return WriteResearchJSON(w, report)
+	default:
+		return fmt.Errorf("unknown research format %q, use json or csv", format)
+	}
+}
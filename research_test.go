@@ -0,0 +1,122 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFDistributionSurvival(t *testing.T) {
+	tests := []struct {
+		name   string
+		f      float64
+		d1, d2 float64
+		p      float64
+	}{
+		{"5% critical value of F(1, 10)", 4.965, 1, 10, 0.05},
+		{"5% critical value of F(2, 20)", 3.493, 2, 20, 0.05},
+		{"5% critical value of F(3, 60)", 2.758, 3, 60, 0.05},
+		{"1% critical value of F(5, 30)", 3.699, 5, 30, 0.01},
+		{"1% critical value of F(3, 100)", 3.984, 3, 100, 0.01},
+		{"median of F(1, 1)", 1, 1, 1, 0.5},
+		{"no statistic", 0, 3, 50, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.InDelta(t, tt.p, fDistributionSurvival(tt.f, tt.d1, tt.d2), 5e-4)
+		})
+	}
+
+	for _, f := range []float64{0.5, 1, 3, 10} {
+		exact := math.Pow(1+2*f/40, -20)
+		assert.InDelta(t, exact, fDistributionSurvival(f, 2, 40), 1e-9, "F(2, d2) survival is (1 + 2f/d2)^(-d2/2)")
+	}
+}
+
+func TestOLSResidualSumSquares(t *testing.T) {
+	x := [][]float64{{1, 0}, {1, 1}, {1, 2}, {1, 3}}
+
+	rss, ok := olsResidualSumSquares(x, []float64{1, 3, 5, 7})
+	require.True(t, ok)
+	assert.InDelta(t, 0, rss, 1e-12, "y = 1 + 2x is fitted exactly")
+
+	rss, ok = olsResidualSumSquares(x, []float64{1, 3, 2, 5})
+	require.True(t, ok)
+	assert.InDelta(t, 2.7, rss, 1e-9, "residuals of y = 1.1 + 1.1x are -0.1, 0.8, -1.3, 0.6")
+
+	_, ok = olsResidualSumSquares([][]float64{{1, 2}, {1, 2}, {1, 2}}, []float64{1, 2, 3})
+	assert.False(t, ok, "collinear columns have no unique fit")
+
+	_, ok = olsResidualSumSquares(nil, nil)
+	assert.False(t, ok)
+}
+
+func TestGrangerTest(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	n := 500
+	series := make([]float64, n)
+	leading := make([]float64, n)
+	noise := make([]float64, n)
+	for i := range series {
+		series[i] = random.NormFloat64()
+		noise[i] = random.NormFloat64()
+		leading[i] = 0.5 * random.NormFloat64()
+		if i > 0 {
+			leading[i] += 0.8 * series[i-1]
+		}
+	}
+
+	led := grangerTest(ResearchFeatureActivity, series, leading, ResearchGrangerLags)
+	assert.Equal(t, n-ResearchGrangerLags, led.Observations)
+	assert.True(t, led.Significant, "p %.4f", led.PValue)
+	assert.Less(t, led.PValue, 1e-6)
+
+	unrelated := grangerTest(ResearchFeatureActivity, series, noise, ResearchGrangerLags)
+	assert.False(t, unrelated.Significant, "p %.4f", unrelated.PValue)
+	assert.Less(t, unrelated.FStatistic, led.FStatistic)
+
+	short := grangerTest(ResearchFeatureActivity, series[:20], leading[:20], ResearchGrangerLags)
+	assert.False(t, short.Significant)
+	assert.Equal(t, 1.0, short.PValue, "too few observations for a test")
+}
+
+func TestGrangerTest_SkipsGaps(t *testing.T) {
+	random := rand.New(rand.NewSource(2))
+	series := make([]float64, 100)
+	returns := make([]float64, 100)
+	for i := range series {
+		series[i] = random.NormFloat64()
+		returns[i] = random.NormFloat64()
+	}
+	series[50] = math.NaN()
+
+	result := grangerTest(ResearchFeatureSentiment, series, returns, 2)
+	assert.Equal(t, 98-2, result.Observations, "rows that lag into the gap are dropped")
+}
+
+func TestForwardFill(t *testing.T) {
+	from := time.Date(2025, 10, 9, 0, 0, 0, 0, time.UTC)
+	series := make([]float64, 6)
+	for i := range series {
+		series[i] = math.NaN()
+	}
+	times := []time.Time{from.Add(10 * time.Minute), from.Add(2*time.Hour + 30*time.Minute)}
+
+	forwardFill(series, from, times, []float64{1, 2}, time.Hour)
+
+	assert.Equal(t, 1.0, series[0])
+	assert.Equal(t, 1.0, series[1], "still within an hour of the hour start")
+	assert.Equal(t, 2.0, series[2], "a newer observation replaces the old one")
+	assert.Equal(t, 2.0, series[3])
+	assert.True(t, math.IsNaN(series[4]), "older than maxAge at the hour start")
+	assert.True(t, math.IsNaN(series[5]))
+
+	series = []float64{math.NaN(), math.NaN()}
+	forwardFill(series, from, []time.Time{from.Add(time.Hour)}, []float64{3}, time.Hour)
+	assert.True(t, math.IsNaN(series[0]), "an observation at the hour end belongs to the next hour")
+	assert.Equal(t, 3.0, series[1])
+}
@@ -0,0 +1,569 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/grutapig/fudtradebot/claude"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// scenarioOrder is an order the scenario exchange filled.
+type scenarioOrder struct {
+	Action   string
+	Side     PositionSide
+	Quantity float64
+	Price    float64
+}
+
+// scenarioExchange adapts the SimulatedExchange to the Exchange interface
+// with scripted klines, a fixed USDT balance and a log of filled orders.
+type scenarioExchange struct {
+	*SimulatedExchange
+	klines  map[string][]Candle
+	balance float64
+	orders  []scenarioOrder
+}
+
+func newScenarioExchange() *scenarioExchange {
+	return &scenarioExchange{SimulatedExchange: NewSimulatedExchange(), klines: make(map[string][]Candle), balance: 10000}
+}
+
+func (e *scenarioExchange) Venue() string { return "scenario" }
+
+func (e *scenarioExchange) OpenPosition(symbol string, side PositionSide, leverage int, quantity float64) (*Position, error) {
+	position, err := e.SimulatedExchange.OpenPosition(symbol, side, leverage, quantity)
+	if err == nil {
+		e.orders = append(e.orders, scenarioOrder{Action: "open", Side: side, Quantity: quantity, Price: position.EntryPrice})
+	}
+	return position, err
+}
+
+func (e *scenarioExchange) ClosePosition(symbol string, side PositionSide) error {
+	position, _ := e.GetPosition(symbol)
+	if err := e.SimulatedExchange.ClosePosition(symbol, side); err != nil {
+		return err
+	}
+	price, _ := e.GetMarkPrice(symbol)
+	e.orders = append(e.orders, scenarioOrder{Action: "close", Side: side, Quantity: position.Amount, Price: price})
+	return nil
+}
+
+func (e *scenarioExchange) ReducePosition(symbol string, side PositionSide, quantity float64) error {
+	if err := e.SimulatedExchange.ReducePosition(symbol, side, quantity); err != nil {
+		return err
+	}
+	price, _ := e.GetMarkPrice(symbol)
+	e.orders = append(e.orders, scenarioOrder{Action: "reduce", Side: side, Quantity: quantity, Price: price})
+	return nil
+}
+
+func (e *scenarioExchange) GetAllPositions() ([]*Position, error) {
+	var positions []*Position
+	for symbol := range e.positions {
+		position, _ := e.GetPosition(symbol)
+		positions = append(positions, position)
+	}
+	return positions, nil
+}
+
+func (e *scenarioExchange) GetBalance() (float64, error) {
+	return e.balance, nil
+}
+
+func (e *scenarioExchange) GetBalanceInfo() (AccountBalanceInfo, error) {
+	return AccountBalanceInfo{Asset: "USDT", Balance: e.balance, AvailableBalance: e.balance, MarginAvailable: true}, nil
+}
+
+func (e *scenarioExchange) GetAllBalances() ([]AccountBalanceInfo, error) {
+	info, _ := e.GetBalanceInfo()
+	return []AccountBalanceInfo{info}, nil
+}
+
+func (e *scenarioExchange) Klines(symbol string, interval string, startTime, endTime int64, limit int) ([]Candle, error) {
+	klines, ok := e.klines[symbol+"/"+interval]
+	if !ok {
+		return nil, fmt.Errorf("no scripted %s klines for %s", interval, symbol)
+	}
+	if limit > 0 && len(klines) > limit {
+		klines = klines[len(klines)-limit:]
+	}
+	return klines, nil
+}
+
+func (e *scenarioExchange) SetMarginType(symbol string, marginType MarginType) error { return nil }
+
+func (e *scenarioExchange) AddIsolatedMargin(symbol string, side PositionSide, amount float64) error {
+	return nil
+}
+
+// trendKlines returns n candles of interval ending now that move linearly
+// from start to end with a small wick, enough for Ichimoku to read the trend.
+func trendKlines(interval time.Duration, n int, start, end float64) []Candle {
+	last := time.Now().Truncate(interval)
+	klines := make([]Candle, n)
+	for i := range klines {
+		open := start + (end-start)*float64(i)/float64(n)
+		closePrice := start + (end-start)*float64(i+1)/float64(n)
+		openTime := last.Add(-time.Duration(n-1-i) * interval)
+		klines[i] = Candle{
+			OpenTime:    openTime.UnixMilli(),
+			Open:        open,
+			High:        math.Max(open, closePrice) * 1.002,
+			Low:         math.Min(open, closePrice) * 0.998,
+			Close:       closePrice,
+			Volume:      1000,
+			CloseTime:   openTime.Add(interval).UnixMilli() - 1,
+			QuoteVolume: 1000 * closePrice,
+		}
+	}
+	return klines
+}
+
+// scenarioServices scripts the Grufender activity and tweets API, the
+// sentiment and FUD-alert service and Claude verdicts behind one handler.
+type scenarioServices struct {
+	mu          sync.Mutex
+	activity    []ActivityDataPoint
+	fudActivity []ActivityDataPoint
+	tweets      []CommunityTweet
+	sentiment   ClaudeSentimentResponse
+	fudAttack   ClaudeFudAttackResponse
+	// verdicts are the Claude JSON answers, served in order as the input of
+	// the tool the request forces.
+	verdicts []string
+	aiCalls  int
+}
+
+func (s *scenarioServices) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	path := r.URL.Path
+	switch {
+	case strings.HasSuffix(path, "/fud-activity"):
+		json.NewEncoder(w).Encode(ActivityResponse{Status: "success", Data: s.fudActivity})
+	case strings.HasSuffix(path, "/activity"):
+		json.NewEncoder(w).Encode(ActivityResponse{Status: "success", Data: s.activity})
+	case strings.HasSuffix(path, "/tweets"):
+		json.NewEncoder(w).Encode(TweetsResponse{Status: "success", Data: s.tweets})
+	case strings.Contains(path, "/api/external/sentiment/"):
+		json.NewEncoder(w).Encode(s.sentiment)
+	case strings.Contains(path, "/api/external/fud-alert/"):
+		json.NewEncoder(w).Encode(s.fudAttack)
+	case path == "/v1/messages":
+		if len(s.verdicts) == 0 {
+			w.WriteHeader(http.StatusInternalServerError)
+			fmt.Fprint(w, `{"type":"error","error":{"type":"api_error","message":"no scripted verdict"}}`)
+			return
+		}
+		var request claude.ClaudeMessageRequest
+		json.NewDecoder(r.Body).Decode(&request)
+		verdict := s.verdicts[0]
+		s.verdicts = s.verdicts[1:]
+		s.aiCalls++
+		content := claude.Content{Type: "text", Text: verdict}
+		if len(request.Tools) > 0 {
+			content = claude.Content{Type: "tool_use", ID: fmt.Sprintf("toolu_%d", s.aiCalls), Name: request.Tools[0].Name, Input: json.RawMessage(verdict)}
+		}
+		json.NewEncoder(w).Encode(claude.ClaudeMessageResponse{
+			Type:       "message",
+			Role:       claude.ROLE_ASSISTANT,
+			Content:    []claude.Content{content},
+			Model:      request.Model,
+			StopReason: "tool_use",
+			Usage:      claude.Usage{InputTokens: 900, OutputTokens: 60},
+		})
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (s *scenarioServices) queueVerdicts(verdicts ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.verdicts = append(s.verdicts, verdicts...)
+}
+
+func (s *scenarioServices) calls() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.aiCalls
+}
+
+// handlerTransport serves requests from an http.Handler in process.
+type handlerTransport struct {
+	handler http.Handler
+}
+
+func (t handlerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	recorder := httptest.NewRecorder()
+	t.handler.ServeHTTP(recorder, req)
+	return recorder.Result(), nil
+}
+
+const (
+	scenarioApprove = `{"should_open_order": true, "confidence_percent": 80, "justification": "signals aligned"}`
+	scenarioReject  = `{"should_open_order": false, "confidence_percent": 35, "justification": "regime too choppy"}`
+)
+
+type scenario struct {
+	t        *testing.T
+	pair     TradingPair
+	exchange *scenarioExchange
+	services *scenarioServices
+	strategy Strategy
+	state    *TradingState
+	activity ExternalActivityClient
+	analysis ExternalAnalysisProvider
+	claude   claude.LLM
+}
+
+// newScenario opens an in-memory database and wires the pair to the scenario
+// exchange and scripted services. Klines default to a neutral market, tests
+// script them with setTrend.
+func newScenario(t *testing.T, pair TradingPair) *scenario {
+	name := strings.NewReplacer("/", "_", " ", "_").Replace(t.Name())
+	require.NoError(t, OpenDatabase(fmt.Sprintf("file:%s?mode=memory&cache=shared", name)))
+	t.Cleanup(func() {
+		if sqlDB, err := DB.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+
+	t.Setenv(ENV_MAX_BTC_BETA_EXPOSURE, "100000")
+	services := &scenarioServices{
+		activity:    hourlyActivity(100),
+		fudActivity: hourlyActivity(5),
+		sentiment:   ClaudeSentimentResponse{OverallSentiment: 6, SentimentTrend: "stable", Confidence: 0.8, KeyThemes: []string{}},
+		fudAttack:   ClaudeFudAttackResponse{HasAttack: false, Confidence: 0.6, Participants: []FudAttackParticipant{}},
+	}
+
+	strategy, err := NewStrategy(pair.Strategy)
+	require.NoError(t, err)
+
+	s := &scenario{
+		t:        t,
+		pair:     pair,
+		exchange: newScenarioExchange(),
+		services: services,
+		strategy: strategy,
+		state:    &TradingState{CurrentPosition: PositionSideBoth, FudState: FudStateIdle},
+		activity: NewExternalActivityClientWithTransport("http://grufender.test", handlerTransport{services}),
+		analysis: NewExternalAnalysisClientWithTransport(ExternalAnalysisConfig{BaseURL: "http://grufender.test", APIKey: "scenario-secret"}, handlerTransport{services}),
+	}
+	s.setTrend("BTCUSDT", 100000, 100000)
+	s.setTrend(pair.Symbol, 1, 1)
+	return s
+}
+
+func (s *scenario) withClaude() *scenario {
+	client, err := claude.NewClaudeClient("sk-scenario", "", claude.CLAUDE_45_MODEL)
+	require.NoError(s.t, err)
+	client.SetTransport(handlerTransport{s.services})
+	s.claude = client
+	return s
+}
+
+// setTrend scripts the klines of a symbol moving from start to end and puts
+// the mark price on the last close.
+func (s *scenario) setTrend(symbol string, start, end float64) {
+	s.exchange.klines[symbol+"/"+KLINES_INTERVAL] = trendKlines(time.Hour, 350, start, end)
+	s.exchange.klines[symbol+"/"+KLINES_BTC_INTERVAL] = trendKlines(4*time.Hour, 200, start, end)
+	s.setPrice(symbol, end)
+}
+
+func (s *scenario) setPrice(symbol string, price float64) {
+	s.exchange.SetPrice(symbol, price, time.Now())
+}
+
+// cycle runs one trading cycle with fresh sentiment and FUD-alert fetches.
+func (s *scenario) cycle() {
+	s.state.LastSentimentFetchTime = time.Time{}
+	s.state.LastFudAttackFetchTime = time.Time{}
+	require.NoError(s.t, processTradingCycle(s.exchange, s.activity, s.analysis, s.claude, s.pair, s.strategy, nil, s.state, 10))
+}
+
+func (s *scenario) positions() []PositionRecord {
+	var records []PositionRecord
+	require.NoError(s.t, DB.Where("symbol = ?", s.pair.Symbol).Order("id").Find(&records).Error)
+	return records
+}
+
+func (s *scenario) decisions() []TradingDecisionRecord {
+	var records []TradingDecisionRecord
+	require.NoError(s.t, DB.Where("symbol = ?", s.pair.Symbol).Order("id").Find(&records).Error)
+	return records
+}
+
+func (s *scenario) validations() []AIOrderValidationRecord {
+	var records []AIOrderValidationRecord
+	require.NoError(s.t, DB.Where("symbol = ?", s.pair.Symbol).Order("id").Find(&records).Error)
+	return records
+}
+
+func (s *scenario) fudTransitions() []string {
+	var records []FudStateTransitionRecord
+	require.NoError(s.t, DB.Where("symbol = ?", s.pair.Symbol).Order("id").Find(&records).Error)
+	var transitions []string
+	for _, record := range records {
+		transitions = append(transitions, record.ToState+":"+record.Trigger)
+	}
+	return transitions
+}
+
+// hourlyActivity is a steady community of about perHour messages over the
+// activity baseline.
+func hourlyActivity(perHour int) []ActivityDataPoint {
+	now := time.Now().Truncate(time.Hour)
+	hours := ActivityBaselineDays * 24
+	points := make([]ActivityDataPoint, hours)
+	for i := range points {
+		points[i] = ActivityDataPoint{
+			Timestamp:    now.Add(-time.Duration(hours-1-i) * time.Hour).Unix(),
+			MessageCount: perHour + (i%3 - 1),
+		}
+	}
+	return points
+}
+
+func scenarioPair() TradingPair {
+	return TradingPair{CommunityID: "1969807538154811438", Symbol: "GIGGLEUSDT", Leverage: 1, Quantity: 10}
+}
+
+func TestScenario_EntersOnAlignedIchimoku(t *testing.T) {
+	s := newScenario(t, scenarioPair()).withClaude()
+	s.setTrend("BTCUSDT", 90000, 110000)
+	s.setTrend(s.pair.Symbol, 80, 120)
+	s.services.queueVerdicts(scenarioApprove)
+
+	s.cycle()
+
+	require.Equal(t, []scenarioOrder{{Action: "open", Side: PositionSideLong, Quantity: 10, Price: 120}}, s.exchange.orders)
+	assert.Equal(t, PositionSideLong, s.state.CurrentPosition)
+	assert.Equal(t, "ichimoku", s.state.OpenReason)
+
+	positions := s.positions()
+	require.Len(t, positions, 1)
+	assert.Equal(t, s.state.PositionUUID, positions[0].UUID)
+	assert.Equal(t, "LONG", positions[0].Side)
+	assert.Equal(t, 120.0, positions[0].EntryPrice)
+	assert.False(t, positions[0].IsClosed)
+
+	decisions := s.decisions()
+	require.Len(t, decisions, 1)
+	assert.Equal(t, "LONG", decisions[0].FinalDecision)
+	assert.Equal(t, "LONG", decisions[0].BTCIchimoku)
+	assert.Equal(t, "LONG", decisions[0].CoinIchimoku)
+	assert.Equal(t, s.state.PositionUUID, decisions[0].PositionUUID)
+
+	validations := s.validations()
+	require.Len(t, validations, 1)
+	assert.True(t, validations[0].ShouldOpenOrder)
+	assert.Equal(t, s.state.PositionUUID, validations[0].PositionUUID)
+	assert.Equal(t, decisions[0].ID, validations[0].DecisionRecordID)
+
+	s.cycle()
+	assert.Len(t, s.exchange.orders, 1, "the open position is held")
+	assert.Equal(t, 1, s.services.calls())
+	var snapshots int64
+	DB.Model(&PositionSnapshot{}).Where("position_uuid = ?", s.state.PositionUUID).Count(&snapshots)
+	assert.Equal(t, int64(1), snapshots)
+}
+
+func TestScenario_AIRejectionCooldown(t *testing.T) {
+	s := newScenario(t, scenarioPair()).withClaude()
+	s.setTrend("BTCUSDT", 90000, 110000)
+	s.setTrend(s.pair.Symbol, 80, 120)
+	s.services.queueVerdicts(scenarioReject, scenarioApprove)
+
+	s.cycle()
+
+	assert.Empty(t, s.exchange.orders)
+	assert.Equal(t, PositionSideBoth, s.state.CurrentPosition)
+	assert.False(t, s.state.LastAIRejectionTime.IsZero())
+	assert.Equal(t, "GIGGLEUSDT:LONG", s.state.LastRejectedDecision)
+	validations := s.validations()
+	require.Len(t, validations, 1)
+	assert.False(t, validations[0].ShouldOpenOrder)
+	assert.Equal(t, "regime too choppy", validations[0].Justification)
+
+	s.cycle()
+	s.cycle()
+	assert.Empty(t, s.exchange.orders, "the rejected signal is not retried while it is unchanged")
+	assert.Equal(t, 1, s.services.calls())
+
+	s.setTrend(s.pair.Symbol, 1, 1)
+	s.cycle()
+	s.setTrend(s.pair.Symbol, 80, 120)
+	s.cycle()
+
+	assert.Equal(t, 2, s.services.calls(), "a new decision is validated again")
+	require.Len(t, s.exchange.orders, 1)
+	assert.Equal(t, PositionSideLong, s.exchange.orders[0].Side)
+}
+
+func TestScenario_FudModeForcedShortToRealShortAndExit(t *testing.T) {
+	s := newScenario(t, scenarioPair()).withClaude()
+	s.setTrend("BTCUSDT", 110000, 90000)
+	s.setTrend(s.pair.Symbol, 120, 80)
+	attackTime := time.Now().Add(-10 * time.Minute)
+	s.services.fudAttack = ClaudeFudAttackResponse{
+		HasAttack:      true,
+		Confidence:     0.8,
+		MessageCount:   40,
+		Participants:   []FudAttackParticipant{{Username: "bear_whale", MessageCount: 25}, {Username: "exitliq", MessageCount: 15}},
+		FudType:        "rug_claims",
+		LastAttackTime: &attackTime,
+		Justification:  "coordinated rug accusations",
+	}
+	s.services.queueVerdicts(scenarioReject)
+
+	s.cycle()
+
+	require.Equal(t, []scenarioOrder{{Action: "open", Side: PositionSideShort, Quantity: 10, Price: 80}}, s.exchange.orders)
+	assert.Equal(t, FudStateRealShort, s.state.FudState)
+	assert.Equal(t, []string{
+		"armed:" + FudTriggerAttackDetected,
+		"short_open:" + FudTriggerShortOpened,
+		"real_short:" + FudTriggerCoinShort,
+	}, s.fudTransitions())
+	positions := s.positions()
+	require.Len(t, positions, 1)
+	assert.Equal(t, "fud_attack_forced", positions[0].OpenReason)
+	assert.Equal(t, 0, s.services.calls(), "FUD mode opens without AI validation")
+
+	s.setTrend(s.pair.Symbol, 110, 76)
+	s.cycle()
+	assert.Len(t, s.exchange.orders, 1, "holding while the coin stays below the cloud")
+	assert.Equal(t, FudStateRealShort, s.state.FudState)
+
+	s.setTrend(s.pair.Symbol, 60, 110)
+	s.cycle()
+
+	require.GreaterOrEqual(t, len(s.exchange.orders), 2)
+	assert.Equal(t, scenarioOrder{Action: "close", Side: PositionSideShort, Quantity: 10, Price: 110}, s.exchange.orders[1])
+	assert.Equal(t, FudStateIdle, s.state.FudState)
+	transitions := s.fudTransitions()
+	assert.Equal(t, []string{"exiting:" + FudTriggerCoinLong, "idle:" + FudTriggerPositionClosed}, transitions[len(transitions)-2:])
+
+	positions = s.positions()
+	require.NotEmpty(t, positions)
+	assert.True(t, positions[0].IsClosed)
+	assert.Equal(t, "fud_mode_long_signal", positions[0].CloseReason)
+
+	var outcomes []FudModeOutcomeRecord
+	require.NoError(t, DB.Find(&outcomes).Error)
+	require.Len(t, outcomes, 1)
+	assert.Equal(t, "SHORT", outcomes[0].Side)
+	assert.Equal(t, 80.0, outcomes[0].EntryPrice)
+	assert.Equal(t, 110.0, outcomes[0].ExitPrice)
+	assert.InDelta(t, -37.5, outcomes[0].ReturnPercent, 1e-9)
+}
+
+func TestScenario_MovingAverageExit(t *testing.T) {
+	pair := scenarioPair()
+	pair.MAExit = &MAExitConfig{Ratio: 0.7, MinSnapshots: 3}
+	pair.Strategy = StrategyConfig{Params: StrategyParams{"ai_close_every_snapshots": 0}}
+	s := newScenario(t, pair)
+	s.setTrend("BTCUSDT", 90000, 110000)
+	s.setTrend(s.pair.Symbol, 80, 100)
+
+	s.cycle()
+	require.Len(t, s.exchange.orders, 1)
+	require.Equal(t, PositionSideLong, s.state.CurrentPosition)
+
+	for _, price := range []float64{104, 106, 108} {
+		s.setPrice(s.pair.Symbol, price)
+		s.cycle()
+	}
+	require.Len(t, s.exchange.orders, 1, "P/L rising above its average keeps the position")
+
+	s.setPrice(s.pair.Symbol, 101)
+	s.cycle()
+
+	require.Len(t, s.exchange.orders, 2)
+	assert.Equal(t, scenarioOrder{Action: "close", Side: PositionSideLong, Quantity: 10, Price: 101}, s.exchange.orders[1])
+	assert.Equal(t, PositionSideBoth, s.state.CurrentPosition)
+	positions := s.positions()
+	require.Len(t, positions, 1)
+	assert.True(t, positions[0].IsClosed)
+	assert.Equal(t, "moving_average_exit", positions[0].CloseReason)
+	assert.Equal(t, 101.0, positions[0].ClosePrice)
+}
+
+func TestScenario_RestartRecovery(t *testing.T) {
+	t.Run("imports the open database record", func(t *testing.T) {
+		s := newScenario(t, scenarioPair())
+		s.setPrice(s.pair.Symbol, 2)
+		_, err := s.exchange.SimulatedExchange.OpenPosition(s.pair.Symbol, PositionSideShort, 1, 10)
+		require.NoError(t, err)
+		require.NoError(t, SavePositionOpen(PositionRecord{UUID: "short-uuid", Symbol: s.pair.Symbol, Side: "SHORT", Leverage: 1, Quantity: 10, EntryPrice: 2, OpenedAt: time.Now(), OpenReason: "ichimoku"}))
+		require.NoError(t, SavePositionOpen(PositionRecord{UUID: "stale-long", Symbol: s.pair.Symbol, Side: "LONG", Leverage: 1, Quantity: 10, EntryPrice: 2, OpenedAt: time.Now(), OpenReason: "ichimoku"}))
+
+		s.state = restoreTradingState(s.exchange, s.pair)
+
+		assert.Equal(t, PositionSideShort, s.state.CurrentPosition)
+		assert.Equal(t, "short-uuid", s.state.PositionUUID)
+		positions := s.positions()
+		require.Len(t, positions, 1, "the opposite side is dropped")
+		assert.Equal(t, "short-uuid", positions[0].UUID)
+
+		s.cycle()
+		var snapshot PositionSnapshot
+		require.NoError(t, DB.Where("position_uuid = ?", "short-uuid").First(&snapshot).Error)
+		assert.Equal(t, "SHORT", snapshot.Side)
+	})
+
+	t.Run("records a position only the exchange knows", func(t *testing.T) {
+		s := newScenario(t, scenarioPair())
+		s.setPrice(s.pair.Symbol, 3)
+		_, err := s.exchange.SimulatedExchange.OpenPosition(s.pair.Symbol, PositionSideLong, 1, 10)
+		require.NoError(t, err)
+
+		s.state = restoreTradingState(s.exchange, s.pair)
+
+		assert.Equal(t, PositionSideLong, s.state.CurrentPosition)
+		require.NotEmpty(t, s.state.PositionUUID)
+		positions := s.positions()
+		require.Len(t, positions, 1)
+		assert.Equal(t, s.state.PositionUUID, positions[0].UUID)
+		assert.Equal(t, "restored_from_exchange", positions[0].OpenReason)
+		assert.Equal(t, 3.0, positions[0].EntryPrice)
+	})
+
+	t.Run("closes database positions the exchange no longer has", func(t *testing.T) {
+		s := newScenario(t, scenarioPair())
+		require.NoError(t, SavePositionOpen(PositionRecord{UUID: "orphan", Symbol: s.pair.Symbol, Side: "LONG", Leverage: 1, Quantity: 10, EntryPrice: 2, OpenedAt: time.Now(), OpenReason: "ichimoku"}))
+
+		s.state = restoreTradingState(s.exchange, s.pair)
+
+		assert.Equal(t, PositionSideBoth, s.state.CurrentPosition)
+		positions := s.positions()
+		require.Len(t, positions, 1)
+		assert.True(t, positions[0].IsClosed)
+	})
+
+	t.Run("resumes FUD mode", func(t *testing.T) {
+		s := newScenario(t, scenarioPair())
+		s.setPrice(s.pair.Symbol, 2)
+		_, err := s.exchange.SimulatedExchange.OpenPosition(s.pair.Symbol, PositionSideShort, 1, 10)
+		require.NoError(t, err)
+		require.NoError(t, SavePositionOpen(PositionRecord{UUID: "fud-short", Symbol: s.pair.Symbol, Side: "SHORT", Leverage: 1, Quantity: 10, EntryPrice: 2, OpenedAt: time.Now(), OpenReason: "fud_attack_forced"}))
+		state := &TradingState{CurrentPosition: PositionSideShort, PositionUUID: "fud-short", FudState: FudStateShortOpen, FudStrategy: FudStrategyShort}
+		transitionFudState(s.pair, state, FudStateRealShort, FudTriggerCoinShort, "coin Ichimoku confirms SHORT", ClaudeFudAttackResponse{})
+
+		s.state = restoreTradingState(s.exchange, s.pair)
+
+		assert.Equal(t, FudStateRealShort, s.state.FudState)
+		assert.Equal(t, "fud-short", s.state.PositionUUID)
+	})
+}
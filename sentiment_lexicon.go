@@ -0,0 +1,260 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Where the sentiment of a trading decision came from.
+const (
+	SentimentSourceAnalysis = "analysis"
+	SentimentSourceCache    = "cache"
+	SentimentSourceLexicon  = "lexicon"
+)
+
+const (
+	lexiconNegationScope  = 3
+	lexiconNegationFactor = -0.75
+	lexiconFudThreshold   = -0.35
+	lexiconFullConfidence = 50
+)
+
+var sentimentLexicon = map[string]float64{
+	"moon": 2.5, "mooning": 2.5, "pump": 1.5, "pumping": 1.5, "bullish": 2.5, "bull": 1.5,
+	"ath": 2, "gem": 2, "hodl": 1.5, "hold": 0.5, "holding": 0.5, "buy": 1, "buying": 1,
+	"accumulate": 1.5, "accumulating": 1.5, "breakout": 2, "rally": 2, "send": 1, "sending": 1.5,
+	"gm": 0.5, "wagmi": 2, "lfg": 2, "based": 1, "strong": 1.5, "love": 2, "great": 2,
+	"good": 1.5, "amazing": 2.5, "huge": 1.5, "win": 1.5, "winning": 2, "up": 0.5,
+	"partnership": 1.5, "listing": 1.5, "listed": 1.5, "building": 1, "legit": 2, "safe": 1.5,
+	"rug": -3, "rugged": -3, "rugpull": -3, "scam": -3, "scammer": -3, "scammers": -3,
+	"ponzi": -3, "fraud": -3, "honeypot": -3, "dump": -2, "dumping": -2.5, "dumped": -2,
+	"bearish": -2.5, "bear": -1.5, "crash": -2.5, "crashing": -2.5, "rekt": -2.5, "dead": -2.5,
+	"exit": -1, "sell": -1.5, "selling": -1.5, "sold": -1, "down": -0.5, "bleeding": -2,
+	"hack": -2.5, "hacked": -3, "exploit": -2.5, "exploited": -3, "drained": -3,
+	"delist": -2.5, "delisted": -3, "delisting": -2.5, "fud": -1.5, "fake": -2, "liar": -2.5,
+	"lies": -2, "shady": -2, "stolen": -3, "ngmi": -2, "worthless": -3, "trash": -2.5,
+	"bad": -1.5, "worst": -2.5, "hate": -2, "avoid": -2, "warning": -1.5, "careful": -1,
+	"jeet": -1.5, "jeets": -1.5, "paperhands": -1, "insider": -1.5, "insiders": -1.5,
+}
+
+var sentimentLexiconPhrases = map[string]float64{
+	"rug pull":      -3,
+	"exit scam":     -3.5,
+	"to the moon":   2.5,
+	"all time high": 2,
+	"buy the dip":   1.5,
+	"dev sold":      -2.5,
+	"devs sold":     -2.5,
+	"team sold":     -2.5,
+	"pump and dump": -3,
+	"lets go":       2,
+}
+
+var sentimentEmoji = map[rune]float64{
+	'🚀': 2, '🌕': 2, '🌙': 1.5, '🔥': 1.5, '💎': 1.5, '🙌': 1, '📈': 1.5, '💪': 1, '❤': 1,
+	'🐂': 1, '💰': 1, '✅': 1, '🟢': 1,
+	'🤡': -1.5, '💩': -2, '📉': -1.5, '🩸': -1.5, '😭': -1.5, '😡': -2, '🤬': -2.5, '⚠': -1.5,
+	'🚨': -1.5, '💀': -1.5, '🐻': -1, '🔴': -1, '🪦': -2, '🗑': -2,
+}
+
+var sentimentNegations = map[string]bool{
+	"not": true, "no": true, "never": true, "nothing": true, "without": true, "aint": true,
+	"isnt": true, "dont": true, "doesnt": true, "wont": true, "cant": true, "wasnt": true,
+	"arent": true, "didnt": true, "nor": true, "neither": true,
+}
+
+// lexiconThemes maps a theme to words that mark it, checked in this order.
+var lexiconThemes = []struct {
+	name  string
+	words []string
+}{
+	{"rug claims", []string{"rug", "rugged", "rugpull", "honeypot"}},
+	{"scam accusations", []string{"scam", "scammer", "scammers", "ponzi", "fraud", "fake", "liar", "lies"}},
+	{"dumping", []string{"dump", "dumping", "dumped", "sell", "selling", "sold", "jeet", "jeets"}},
+	{"hack rumours", []string{"hack", "hacked", "exploit", "exploited", "drained", "stolen"}},
+	{"delisting rumours", []string{"delist", "delisted", "delisting"}},
+	{"team and devs", []string{"team", "dev", "devs", "insider", "insiders", "wallet", "wallets"}},
+	{"price rally", []string{"moon", "mooning", "pump", "pumping", "ath", "breakout", "rally", "🚀", "🌕", "📈"}},
+	{"listings and partnerships", []string{"listing", "listed", "partnership", "cex", "exchange"}},
+	{"holding", []string{"hodl", "hold", "holding", "accumulate", "accumulating", "💎"}},
+}
+
+// ScoreTweetsOffline estimates the community sentiment from tweet texts with
+// a fixed lexicon, without any network call. It is the fallback when neither
+// the sentiment service nor the tweet analysis answer.
+func ScoreTweetsOffline(tweets []CommunityTweet) ClaudeSentimentResponse {
+	if len(tweets) == 0 {
+		return ClaudeSentimentResponse{}
+	}
+
+	ordered := make([]CommunityTweet, len(tweets))
+	copy(ordered, tweets)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		if !ordered[i].Date.Equal(ordered[j].Date) {
+			return ordered[i].Date.Before(ordered[j].Date)
+		}
+		return compareTweetIDs(ordered[i].ID, ordered[j].ID) < 0
+	})
+
+	scores := make([]float64, len(ordered))
+	themeCounts := make(map[string]int)
+	fudTweets := 0
+	total := 0.0
+	for i, tweet := range ordered {
+		tokens := tokenizeTweet(tweet.Text)
+		scores[i] = scoreTokens(tokens)
+		total += scores[i]
+		if tweet.IsFud || (scores[i] <= lexiconFudThreshold && hasFudTheme(tokens)) {
+			fudTweets++
+		}
+		for _, theme := range tweetThemes(tokens) {
+			themeCounts[theme]++
+		}
+	}
+
+	mean := total / float64(len(scores))
+	trend := "stable"
+	if len(scores) >= 4 {
+		half := len(scores) / 2
+		change := averageOf(scores[half:]) - averageOf(scores[:half])
+		if change >= 0.15 {
+			trend = "improving"
+		} else if change <= -0.15 {
+			trend = "declining"
+		}
+	}
+
+	return ClaudeSentimentResponse{
+		OverallSentiment: clampInt(int(math.Round(5+5*mean)), 0, 10),
+		SentimentTrend:   trend,
+		FudLevel:         clampInt(int(math.Round(10*float64(fudTweets)/float64(len(ordered)))), 0, 10),
+		Confidence:       math.Round(0.5*min(1, float64(len(ordered))/lexiconFullConfidence)*100) / 100,
+		KeyThemes:        topThemes(themeCounts, 3),
+		Recommendation:   fmt.Sprintf("Offline lexicon estimate from %d tweets, %d read as FUD", len(ordered), fudTweets),
+	}
+}
+
+// tokenizeTweet lowercases the text into words and single emoji. Apostrophes
+// are dropped so "don't" and "dont" match alike.
+func tokenizeTweet(text string) []string {
+	var tokens []string
+	var word strings.Builder
+	flush := func() {
+		if word.Len() > 0 {
+			tokens = append(tokens, word.String())
+			word.Reset()
+		}
+	}
+	for _, r := range strings.ToLower(text) {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			word.WriteRune(r)
+		case r == '\'' || r == '\u2019':
+		case r == '\ufe0f' || r == '\u200d':
+		default:
+			flush()
+			if _, ok := sentimentEmoji[r]; ok {
+				tokens = append(tokens, string(r))
+			}
+		}
+	}
+	flush()
+	return tokens
+}
+
+// scoreTokens sums the lexicon values of a tweet and normalizes the sum to
+// -1..1. A negation flips and weakens the next few scored tokens.
+func scoreTokens(tokens []string) float64 {
+	sum := 0.0
+	negatedUntil := -1
+	for i := 0; i < len(tokens); i++ {
+		if sentimentNegations[tokens[i]] {
+			negatedUntil = i + lexiconNegationScope
+			continue
+		}
+
+		value, width := tokenValue(tokens, i)
+		if i <= negatedUntil {
+			value *= lexiconNegationFactor
+		}
+		sum += value
+		i += width - 1
+	}
+	return sum / math.Sqrt(sum*sum+15)
+}
+
+// tokenValue scores the phrase or single token at position i and returns how
+// many tokens it covers.
+func tokenValue(tokens []string, i int) (float64, int) {
+	for width := 3; width >= 2; width-- {
+		if i+width > len(tokens) {
+			continue
+		}
+		if value, ok := sentimentLexiconPhrases[strings.Join(tokens[i:i+width], " ")]; ok {
+			return value, width
+		}
+	}
+	if runes := []rune(tokens[i]); len(runes) == 1 {
+		if value, ok := sentimentEmoji[runes[0]]; ok {
+			return value, 1
+		}
+	}
+	return sentimentLexicon[tokens[i]], 1
+}
+
+func hasFudTheme(tokens []string) bool {
+	for _, theme := range tweetThemes(tokens) {
+		switch theme {
+		case "rug claims", "scam accusations", "dumping", "hack rumours", "delisting rumours":
+			return true
+		}
+	}
+	return false
+}
+
+func tweetThemes(tokens []string) []string {
+	present := make(map[string]bool, len(tokens))
+	for _, token := range tokens {
+		present[token] = true
+	}
+	var themes []string
+	for _, theme := range lexiconThemes {
+		for _, word := range theme.words {
+			if present[word] {
+				themes = append(themes, theme.name)
+				break
+			}
+		}
+	}
+	return themes
+}
+
+func topThemes(counts map[string]int, limit int) []string {
+	var themes []string
+	for _, theme := range lexiconThemes {
+		if counts[theme.name] > 0 {
+			themes = append(themes, theme.name)
+		}
+	}
+	sort.SliceStable(themes, func(i, j int) bool {
+		return counts[themes[i]] > counts[themes[j]]
+	})
+	if len(themes) > limit {
+		themes = themes[:limit]
+	}
+	return themes
+}
+
+func averageOf(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sum := 0.0
+	for _, value := range values {
+		sum += value
+	}
+	return sum / float64(len(values))
+}
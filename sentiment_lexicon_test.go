@@ -0,0 +1,92 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func lexiconTweets(texts ...string) []CommunityTweet {
+	base := time.Date(2025, 10, 9, 11, 0, 0, 0, time.UTC)
+	tweets := make([]CommunityTweet, len(texts))
+	for i, text := range texts {
+		tweets[i] = CommunityTweet{ID: fmt.Sprintf("19800000000000000%02d", i), Date: base.Add(time.Duration(i) * time.Minute), Text: text}
+	}
+	return tweets
+}
+
+func TestScoreTokens(t *testing.T) {
+	tests := []struct {
+		text string
+		sign int
+	}{
+		{"to the moon 🚀🚀", 1},
+		{"devs rugged us, total scam", -1},
+		{"this is not a scam", 1},
+		{"Don't sell, we're not dead", 1},
+		{"📉💀 dev sold", -1},
+		{"gm", 1},
+		{"price update at 12:00", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			score := scoreTokens(tokenizeTweet(tt.text))
+			assert.GreaterOrEqual(t, score, -1.0)
+			assert.LessOrEqual(t, score, 1.0)
+			switch tt.sign {
+			case 1:
+				assert.Greater(t, score, 0.0)
+			case -1:
+				assert.Less(t, score, 0.0)
+			default:
+				assert.Zero(t, score)
+			}
+		})
+	}
+}
+
+func TestScoreTweetsOffline(t *testing.T) {
+	assert.Equal(t, ClaudeSentimentResponse{}, ScoreTweetsOffline(nil))
+
+	fud := ScoreTweetsOffline(lexiconTweets(
+		"gm, building 💎",
+		"new cex listing soon 🚀",
+		"hodl strong",
+		"team wallet dumping, this is a rug pull",
+		"scam, devs sold everything 🤡",
+		"rugged again, exit scam confirmed",
+	))
+	assert.Less(t, fud.OverallSentiment, 5)
+	assert.Equal(t, "declining", fud.SentimentTrend)
+	assert.Equal(t, 5, fud.FudLevel, "three of six tweets read as FUD")
+	assert.Equal(t, []string{"rug claims", "scam accusations", "dumping"}, fud.KeyThemes)
+	assert.Equal(t, 0.06, fud.Confidence, "six tweets give little confidence")
+	assert.Contains(t, fud.Recommendation, "Offline lexicon")
+
+	calm := ScoreTweetsOffline(lexiconTweets("not a rug, team is legit", "lfg 🚀", "bullish on this gem", "to the moon"))
+	assert.Greater(t, calm.OverallSentiment, 5)
+	assert.Zero(t, calm.FudLevel)
+	assert.Contains(t, calm.KeyThemes, "price rally")
+
+	again := ScoreTweetsOffline(lexiconTweets("not a rug, team is legit", "lfg 🚀", "bullish on this gem", "to the moon"))
+	assert.Equal(t, calm, again, "the scorer is deterministic")
+}
+
+func TestScenario_LexiconSentimentFallback(t *testing.T) {
+	s := newScenario(t, scenarioPair())
+	s.setTrend("BTCUSDT", 90000, 110000)
+	s.setTrend(s.pair.Symbol, 120, 80)
+	s.analysis = fakeAnalysisProvider{err: errors.New("sentiment API error (status 503)")}
+	s.services.tweets = lexiconTweets("gm", "dump 📉", "rug pull, scam, dump", "rugged, exit scam 🤡")
+
+	s.cycle()
+
+	decisions := s.decisions()
+	require.Len(t, decisions, 1)
+	assert.Equal(t, SentimentSourceLexicon, decisions[0].SentimentSource)
+	assert.Equal(t, "SHORT", decisions[0].Sentiment, "declining offline sentiment gives a signal instead of EMPTY")
+}
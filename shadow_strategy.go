@@ -0,0 +1,444 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+)
+
+const ShadowLiveLabel = "live"
+
+var ErrShadowOrder = errors.New("shadow strategies cannot send orders")
+
+// ShadowRunner runs one alternative strategy of a pair on the live cycle
+// inputs. Its positions are virtual: opened and closed at the mark price and
+// stored in the shadow tables, its exchange refuses every order. AI
+// validation, AI close checks and the portfolio exposure check are skipped.
+type ShadowRunner struct {
+	Label    string
+	Strategy Strategy
+	State    TradingState
+}
+
+// shadowExchange gives shadow runners the live market data but refuses every
+// order, so a strategy that forgets to check ctx.Shadow cannot trade.
+type shadowExchange struct {
+	Exchange
+	label string
+}
+
+func (e shadowExchange) refuse(action string, symbol string) error {
+	log.Printf("[%s] Shadow %s tried to %s on the exchange, refused", symbol, e.label, action)
+	return fmt.Errorf("%w: %s %s", ErrShadowOrder, e.label, action)
+}
+
+func (e shadowExchange) OpenPosition(symbol string, side PositionSide, leverage int, quantity float64) (*Position, error) {
+	return nil, e.refuse("open "+string(side), symbol)
+}
+
+func (e shadowExchange) ClosePosition(symbol string, side PositionSide) error {
+	return e.refuse("close "+string(side), symbol)
+}
+
+func (e shadowExchange) ReducePosition(symbol string, side PositionSide, quantity float64) error {
+	return e.refuse("reduce "+string(side), symbol)
+}
+
+func (e shadowExchange) SetMarginType(symbol string, marginType MarginType) error {
+	return e.refuse("set margin type", symbol)
+}
+
+func (e shadowExchange) AddIsolatedMargin(symbol string, side PositionSide, amount float64) error {
+	return e.refuse("add isolated margin", symbol)
+}
+
+func shadowLabel(config StrategyConfig) string {
+	if config.Label != "" {
+		return config.Label
+	}
+	if config.Name != "" {
+		return config.Name
+	}
+	return StrategySentimentIchimoku
+}
+
+// NewShadowRunners builds the shadow strategies of a pair and restores their
+// open virtual positions.
+func NewShadowRunners(pair TradingPair) ([]*ShadowRunner, error) {
+	seen := map[string]bool{ShadowLiveLabel: true}
+	var runners []*ShadowRunner
+	for _, config := range pair.Shadows {
+		label := shadowLabel(config)
+		if seen[label] {
+			return nil, fmt.Errorf("duplicate shadow label %q", label)
+		}
+		seen[label] = true
+
+		strategy, err := NewStrategy(config)
+		if err != nil {
+			return nil, fmt.Errorf("shadow %s: %w", label, err)
+		}
+		runner := &ShadowRunner{
+			Label:    label,
+			Strategy: strategy,
+			State:    TradingState{CurrentPosition: PositionSideBoth},
+		}
+
+		open, err := GetOpenShadowPosition(pair.Symbol, label)
+		if err != nil {
+			log.Printf("[%s] Failed to restore shadow %s position: %v", pair.Symbol, label, err)
+		} else if open != nil {
+			runner.State.CurrentPosition = PositionSide(open.Side)
+			runner.State.OpenedAt = open.OpenedAt
+			runner.State.OpenReason = open.OpenReason
+			runner.State.PositionUUID = open.UUID
+			log.Printf("[%s] ✓ Restored shadow %s position %s %s", pair.Symbol, label, open.Side, open.UUID)
+		}
+		runners = append(runners, runner)
+	}
+	return runners, nil
+}
+
+// runShadowStrategies gives every shadow a copy of the live cycle context with
+// its own state and executes its intents virtually.
+func runShadowStrategies(live *StrategyContext, runners []*ShadowRunner) {
+	for _, runner := range runners {
+		ctx := *live
+		ctx.Shadow = runner.Label
+		ctx.State = &runner.State
+		ctx.Exchange = shadowExchange{Exchange: live.Exchange, label: runner.Label}
+		ctx.Claude = nil
+		ctx.Position = nil
+		pair := ctx.Pair
+
+		log.Printf("[%s] --- shadow %s (%s) ---", pair.Symbol, runner.Label, runner.Strategy.Name())
+
+		if runner.State.CurrentPosition != PositionSideBoth && ctx.MarkPrice > 0 {
+			position, err := updateShadowPosition(&ctx)
+			if err != nil {
+				log.Printf("[%s] Shadow %s failed to update position: %v", pair.Symbol, runner.Label, err)
+			} else {
+				ctx.Position = position
+				count, _ := CountShadowSnapshots(runner.State.PositionUUID)
+				intents, err := runner.Strategy.OnPositionUpdate(&ctx, PositionUpdate{Position: *position, MarkPrice: ctx.MarkPrice, SnapshotCount: count})
+				if err != nil {
+					log.Printf("[%s] Shadow %s failed on position update: %v", pair.Symbol, runner.Label, err)
+				} else {
+					executeShadowIntents(&ctx, intents)
+				}
+				applyVirtualTimeExits(&ctx, *position, func(reason string) {
+					if err := closeShadowPosition(&ctx, reason); err != nil {
+						log.Printf("[%s] Shadow %s failed to close on time exit: %v", pair.Symbol, runner.Label, err)
+					}
+				})
+			}
+		}
+
+		intents, err := runner.Strategy.OnCycle(&ctx)
+		if err != nil {
+			log.Printf("[%s] Shadow %s failed: %v", pair.Symbol, runner.Label, err)
+			continue
+		}
+		executeShadowIntents(&ctx, intents)
+	}
+}
+
+// updateShadowPosition marks the virtual position to market, stores a
+// snapshot and returns it as a Position.
+func updateShadowPosition(ctx *StrategyContext) (*Position, error) {
+	record, err := GetShadowPositionByUUID(ctx.State.PositionUUID)
+	if err != nil {
+		return nil, err
+	}
+
+	pnl := shadowPnL(PositionSide(record.Side), record.EntryPrice, ctx.MarkPrice, record.Quantity)
+	if pnl > record.MaxPnL {
+		record.MaxPnL = pnl
+	}
+	if pnl < record.MinPnL {
+		record.MinPnL = pnl
+	}
+	if err := SaveShadowPosition(record); err != nil {
+		return nil, err
+	}
+
+	if err := SaveShadowSnapshot(ShadowSnapshotRecord{
+		PositionUUID: record.UUID,
+		Symbol:       record.Symbol,
+		Strategy:     record.Strategy,
+		Side:         record.Side,
+		EntryPrice:   record.EntryPrice,
+		Amount:       record.Quantity,
+		UnrealizedPL: pnl,
+		MarkPrice:    ctx.MarkPrice,
+		CreatedAt:    time.Now(),
+	}); err != nil {
+		return nil, err
+	}
+
+	return &Position{
+		Symbol:       record.Symbol,
+		Side:         PositionSide(record.Side),
+		Leverage:     ctx.Pair.Leverage,
+		EntryPrice:   record.EntryPrice,
+		Amount:       record.Quantity,
+		UnrealizedPL: pnl,
+		Timestamp:    record.OpenedAt,
+	}, nil
+}
+
+func executeShadowIntents(ctx *StrategyContext, intents []TradeIntent) {
+	pair := ctx.Pair
+	state := ctx.State
+	for _, intent := range intents {
+		if ctx.MarkPrice <= 0 {
+			log.Printf("[%s] Shadow %s has no mark price, intent %s skipped", pair.Symbol, ctx.Shadow, intent.Action)
+			return
+		}
+
+		switch intent.Action {
+		case IntentClose:
+			if state.CurrentPosition == PositionSideBoth || state.CurrentPosition != intent.Side {
+				continue
+			}
+			if intent.AICheck == CloseAIConfirm {
+				continue
+			}
+			if err := closeShadowPosition(ctx, intent.Reason); err != nil {
+				log.Printf("[%s] Shadow %s failed to close: %v", pair.Symbol, ctx.Shadow, err)
+			}
+		case IntentOpen:
+			if state.CurrentPosition != PositionSideBoth {
+				continue
+			}
+			if err := openShadowPosition(ctx, intent); err != nil {
+				log.Printf("[%s] Shadow %s failed to open: %v", pair.Symbol, ctx.Shadow, err)
+			}
+		}
+	}
+}
+
+func openShadowPosition(ctx *StrategyContext, intent TradeIntent) error {
+	pair := ctx.Pair
+	state := ctx.State
+
+	record := &ShadowPositionRecord{
+		UUID:       GeneratePositionUUID(),
+		Symbol:     pair.Symbol,
+		Strategy:   ctx.Shadow,
+		Side:       string(intent.Side),
+		Quantity:   pair.Quantity,
+		EntryPrice: ctx.MarkPrice,
+		OpenedAt:   time.Now(),
+		OpenReason: intent.Reason,
+		CreatedAt:  time.Now(),
+	}
+	if err := SaveShadowPosition(record); err != nil {
+		return err
+	}
+	if intent.DecisionRecordID > 0 {
+		if err := UpdateShadowDecisionPositionUUID(intent.DecisionRecordID, record.UUID); err != nil {
+			log.Printf("[%s] Failed to link shadow decision %d: %v", pair.Symbol, intent.DecisionRecordID, err)
+		}
+	}
+
+	state.CurrentPosition = intent.Side
+	state.OpenedAt = record.OpenedAt
+	state.OpenReason = intent.Reason
+	state.PositionUUID = record.UUID
+	log.Printf("[%s] 👻 Shadow %s opened %s at %.6f (%s)", pair.Symbol, ctx.Shadow, intent.Side, ctx.MarkPrice, intent.Reason)
+	return nil
+}
+
+func closeShadowPosition(ctx *StrategyContext, reason string) error {
+	pair := ctx.Pair
+	state := ctx.State
+
+	record, err := GetShadowPositionByUUID(state.PositionUUID)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	record.IsClosed = true
+	record.ClosedAt = &now
+	record.ClosePrice = ctx.MarkPrice
+	record.RealizedPL = shadowPnL(PositionSide(record.Side), record.EntryPrice, ctx.MarkPrice, record.Quantity)
+	record.CloseReason = reason
+	if err := SaveShadowPosition(record); err != nil {
+		return err
+	}
+
+	log.Printf("[%s] 👻 Shadow %s closed %s at %.6f, P/L %.2f (%s)", pair.Symbol, ctx.Shadow, record.Side, ctx.MarkPrice, record.RealizedPL, reason)
+	state.CurrentPosition = PositionSideBoth
+	state.PositionUUID = ""
+	state.OpenReason = ""
+	return nil
+}
+
+func shadowPnL(side PositionSide, entryPrice, markPrice, quantity float64) float64 {
+	pnl := (markPrice - entryPrice) * quantity
+	if side == PositionSideShort {
+		pnl = -pnl
+	}
+	return pnl
+}
+
+// recordShadowDecision is recordTradingDecision for shadow runs, decisions go
+// to the shadow table and are deduplicated per shadow label.
+func recordShadowDecision(ctx *StrategyContext, decision TradingDecisionResult) (*TradingDecisionRecord, bool) {
+	fudAttackInfo := "no"
+	if ctx.FudAttack.HasAttack {
+		fudAttackInfo = "yes"
+	}
+	record := &ShadowDecisionRecord{
+		PositionUUID:        ctx.State.PositionUUID,
+		Symbol:              ctx.Pair.Symbol,
+		Strategy:            ctx.Shadow,
+		BTCIchimoku:         decision.BTCIchimokuSignal,
+		CoinIchimoku:        decision.CoinIchimokuSignal,
+		Activity:            decision.ActivitySignal,
+		FudActivity:         decision.FudActivitySignal,
+		FudShare:            decision.FudShareSignal,
+		Sentiment:           decision.SentimentSignal,
+		FudAttack:           fudAttackInfo,
+		Regime:              decision.RegimeSignal,
+		FinalDecision:       string(decision.Signal),
+		DecisionExplanation: decision.Explanation,
+		CreatedAt:           time.Now(),
+	}
+
+	last, err := GetLatestShadowDecision(ctx.Pair.Symbol, ctx.Shadow)
+	if err != nil {
+		log.Printf("[%s] Failed to get last shadow %s decision: %v", ctx.Pair.Symbol, ctx.Shadow, err)
+	} else if last != nil &&
+		last.BTCIchimoku == record.BTCIchimoku &&
+		last.CoinIchimoku == record.CoinIchimoku &&
+		last.Activity == record.Activity &&
+		last.FudActivity == record.FudActivity &&
+		last.FudShare == record.FudShare &&
+		last.Sentiment == record.Sentiment &&
+		last.FudAttack == record.FudAttack &&
+		last.Regime == record.Regime &&
+		last.FinalDecision == record.FinalDecision {
+		return nil, false
+	}
+
+	if err := SaveShadowDecision(record); err != nil {
+		log.Printf("[%s] Failed to save shadow %s decision: %v", ctx.Pair.Symbol, ctx.Shadow, err)
+		return nil, true
+	}
+	return &TradingDecisionRecord{ID: record.ID, Symbol: record.Symbol, FinalDecision: record.FinalDecision}, true
+}
+
+type StrategyPerformance struct {
+	Strategy     string  `json:"strategy"`
+	Trades       int     `json:"trades"`
+	Wins         int     `json:"wins"`
+	WinRate      float64 `json:"win_rate"`
+	TotalPnL     float64 `json:"total_pnl"`
+	AvgPnL       float64 `json:"avg_pnl"`
+	MaxDrawdown  float64 `json:"max_drawdown"`
+	OpenPosition string  `json:"open_position,omitempty"`
+}
+
+type closedTrade struct {
+	closedAt time.Time
+	pnl      float64
+}
+
+// CompareShadowPerformance returns, per symbol, the live strategy followed by
+// every shadow with closed trade statistics over the last hoursBack hours.
+// Drawdown is the largest fall of cumulative realized P/L from its peak.
+func CompareShadowPerformance(symbol string, hoursBack int) (map[string][]StrategyPerformance, error) {
+	result := make(map[string][]StrategyPerformance)
+
+	live, err := GetClosedPositions(hoursBack)
+	if err != nil {
+		return nil, err
+	}
+	liveTrades := make(map[string][]closedTrade)
+	for _, p := range live {
+		if symbol != "" && p.Symbol != symbol || p.ClosedAt == nil {
+			continue
+		}
+		liveTrades[p.Symbol] = append(liveTrades[p.Symbol], closedTrade{*p.ClosedAt, p.RealizedPL})
+	}
+	open, err := GetOpenPositions()
+	if err != nil {
+		return nil, err
+	}
+	liveOpen := make(map[string]string)
+	for _, p := range open {
+		liveOpen[p.Symbol] = p.Side
+	}
+
+	for _, pair := range TradingPairs {
+		if symbol != "" && pair.Symbol != symbol {
+			continue
+		}
+		performance := summarizeTrades(ShadowLiveLabel, liveTrades[pair.Symbol])
+		performance.OpenPosition = liveOpen[pair.Symbol]
+		result[pair.Symbol] = append(result[pair.Symbol], performance)
+	}
+
+	shadows, err := GetShadowPositions(symbol, hoursBack)
+	if err != nil {
+		return nil, err
+	}
+	shadowTrades := make(map[string]map[string][]closedTrade)
+	shadowOpen := make(map[string]map[string]string)
+	var order []string
+	for _, p := range shadows {
+		key := p.Symbol + "/" + p.Strategy
+		if shadowTrades[p.Symbol] == nil {
+			shadowTrades[p.Symbol] = make(map[string][]closedTrade)
+			shadowOpen[p.Symbol] = make(map[string]string)
+		}
+		if _, ok := shadowTrades[p.Symbol][p.Strategy]; !ok {
+			shadowTrades[p.Symbol][p.Strategy] = nil
+			order = append(order, key)
+		}
+		if !p.IsClosed {
+			shadowOpen[p.Symbol][p.Strategy] = p.Side
+			continue
+		}
+		if p.ClosedAt != nil {
+			shadowTrades[p.Symbol][p.Strategy] = append(shadowTrades[p.Symbol][p.Strategy], closedTrade{*p.ClosedAt, p.RealizedPL})
+		}
+	}
+	for _, key := range order {
+		sym, label, _ := strings.Cut(key, "/")
+		performance := summarizeTrades(label, shadowTrades[sym][label])
+		performance.OpenPosition = shadowOpen[sym][label]
+		result[sym] = append(result[sym], performance)
+	}
+	return result, nil
+}
+
+func summarizeTrades(label string, trades []closedTrade) StrategyPerformance {
+	sort.Slice(trades, func(i, j int) bool { return trades[i].closedAt.Before(trades[j].closedAt) })
+
+	performance := StrategyPerformance{Strategy: label, Trades: len(trades)}
+	var cumulative, peak float64
+	for _, t := range trades {
+		performance.TotalPnL += t.pnl
+		if t.pnl > 0 {
+			performance.Wins++
+		}
+		cumulative += t.pnl
+		if cumulative > peak {
+			peak = cumulative
+		}
+		if peak-cumulative > performance.MaxDrawdown {
+			performance.MaxDrawdown = peak - cumulative
+		}
+	}
+	if len(trades) > 0 {
+		performance.WinRate = float64(performance.Wins) / float64(len(trades))
+		performance.AvgPnL = performance.TotalPnL / float64(len(trades))
+	}
+	return performance
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// careless trades straight on the exchange without checking ctx.Shadow.
+type careless struct {
+	errs []error
+}
+
+func (c *careless) Name() string { return "careless" }
+
+func (c *careless) OnPositionUpdate(ctx *StrategyContext, update PositionUpdate) ([]TradeIntent, error) {
+	return nil, nil
+}
+
+func (c *careless) OnCycle(ctx *StrategyContext) ([]TradeIntent, error) {
+	_, err := ctx.Exchange.OpenPosition(ctx.Pair.Symbol, PositionSideLong, ctx.Pair.Leverage, ctx.Pair.Quantity)
+	c.errs = append(c.errs, err)
+	c.errs = append(c.errs, ctx.Exchange.ClosePosition(ctx.Pair.Symbol, PositionSideLong))
+	c.errs = append(c.errs, ctx.Exchange.ReducePosition(ctx.Pair.Symbol, PositionSideLong, 1))
+	c.errs = append(c.errs, ctx.Exchange.SetMarginType(ctx.Pair.Symbol, MarginTypeIsolated))
+	c.errs = append(c.errs, ctx.Exchange.AddIsolatedMargin(ctx.Pair.Symbol, PositionSideLong, 10))
+	return nil, nil
+}
+
+func TestShadowRunners_CannotSendOrders(t *testing.T) {
+	exchange := newScenarioExchange()
+	exchange.SetPrice("GIGGLEUSDT", 100, exchange.now)
+	strategy := &careless{}
+	runner := &ShadowRunner{Label: "careless", Strategy: strategy, State: TradingState{CurrentPosition: PositionSideBoth}}
+	live := &StrategyContext{Pair: scenarioPair(), State: &TradingState{}, Exchange: exchange, MarkPrice: 100}
+
+	runShadowStrategies(live, []*ShadowRunner{runner})
+
+	require.Len(t, strategy.errs, 5)
+	for _, err := range strategy.errs {
+		assert.ErrorIs(t, err, ErrShadowOrder)
+	}
+	assert.Empty(t, exchange.orders)
+	assert.Equal(t, exchange, live.Exchange, "the live context keeps the real exchange")
+	price, err := (shadowExchange{Exchange: exchange}).GetMarkPrice("GIGGLEUSDT")
+	require.NoError(t, err)
+	assert.Equal(t, 100.0, price, "market data is read from the live exchange")
+}
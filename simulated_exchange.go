@@ -0,0 +1,153 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// SimulatedTakerFee is charged on the notional of every simulated fill.
+const SimulatedTakerFee = 0.0005
+
+// SimulatedExchange is an in-memory futures account for replaying strategies
+// over historical prices. Orders fill at the last price set for the symbol,
+// every fill pays FeeRate on its notional and closed positions are kept as
+// trades.
+type SimulatedExchange struct {
+	FeeRate   float64
+	Trades    []SimulatedTrade
+	now       time.Time
+	prices    map[string]float64
+	positions map[string]*Position
+}
+
+type SimulatedTrade struct {
+	Symbol        string
+	Side          PositionSide
+	Quantity      float64
+	EntryPrice    float64
+	ExitPrice     float64
+	OpenedAt      time.Time
+	ClosedAt      time.Time
+	Fees          float64
+	RealizedPL    float64
+	ReturnPercent float64
+}
+
+func NewSimulatedExchange() *SimulatedExchange {
+	return &SimulatedExchange{
+		FeeRate:   SimulatedTakerFee,
+		prices:    make(map[string]float64),
+		positions: make(map[string]*Position),
+	}
+}
+
+// SetPrice moves the simulated clock and the price of a symbol.
+func (e *SimulatedExchange) SetPrice(symbol string, price float64, at time.Time) {
+	e.prices[symbol] = price
+	e.now = at
+}
+
+func (e *SimulatedExchange) GetMarkPrice(symbol string) (float64, error) {
+	price, ok := e.prices[symbol]
+	if !ok || price <= 0 {
+		return 0, fmt.Errorf("simulated exchange has no price for %s", symbol)
+	}
+	return price, nil
+}
+
+// GetPosition returns the open position marked to the current price, nil when
+// there is none.
+func (e *SimulatedExchange) GetPosition(symbol string) (*Position, error) {
+	position, ok := e.positions[symbol]
+	if !ok {
+		return nil, nil
+	}
+	marked := *position
+	marked.UnrealizedPL = shadowPnL(marked.Side, marked.EntryPrice, e.prices[symbol], marked.Amount)
+	return &marked, nil
+}
+
+func (e *SimulatedExchange) OpenPosition(symbol string, side PositionSide, leverage int, quantity float64) (*Position, error) {
+	if existing, ok := e.positions[symbol]; ok {
+		return nil, fmt.Errorf("simulated exchange already has a %s position on %s", existing.Side, symbol)
+	}
+	price, err := e.GetMarkPrice(symbol)
+	if err != nil {
+		return nil, err
+	}
+	position := &Position{
+		Symbol:     symbol,
+		Side:       side,
+		Leverage:   leverage,
+		EntryPrice: price,
+		Amount:     quantity,
+		Timestamp:  e.now,
+	}
+	e.positions[symbol] = position
+	opened := *position
+	return &opened, nil
+}
+
+func (e *SimulatedExchange) ClosePosition(symbol string, side PositionSide) error {
+	position, ok := e.positions[symbol]
+	if !ok || position.Side != side {
+		return fmt.Errorf("simulated exchange has no %s position on %s", side, symbol)
+	}
+	price, err := e.GetMarkPrice(symbol)
+	if err != nil {
+		return err
+	}
+
+	fees := (position.EntryPrice + price) * position.Amount * e.FeeRate
+	trade := SimulatedTrade{
+		Symbol:     symbol,
+		Side:       side,
+		Quantity:   position.Amount,
+		EntryPrice: position.EntryPrice,
+		ExitPrice:  price,
+		OpenedAt:   position.Timestamp,
+		ClosedAt:   e.now,
+		Fees:       fees,
+		RealizedPL: shadowPnL(side, position.EntryPrice, price, position.Amount) - fees,
+	}
+	if notional := position.EntryPrice * position.Amount; notional > 0 {
+		trade.ReturnPercent = trade.RealizedPL / notional * 100
+	}
+	e.Trades = append(e.Trades, trade)
+	delete(e.positions, symbol)
+	return nil
+}
+
+// ReducePosition closes part of the position and books it as a trade.
+func (e *SimulatedExchange) ReducePosition(symbol string, side PositionSide, quantity float64) error {
+	position, ok := e.positions[symbol]
+	if !ok || position.Side != side {
+		return fmt.Errorf("simulated exchange has no %s position on %s", side, symbol)
+	}
+	if quantity >= position.Amount {
+		return e.ClosePosition(symbol, side)
+	}
+	price, err := e.GetMarkPrice(symbol)
+	if err != nil {
+		return err
+	}
+
+	fees := (position.EntryPrice + price) * quantity * e.FeeRate
+	trade := SimulatedTrade{
+		Symbol:     symbol,
+		Side:       side,
+		Quantity:   quantity,
+		EntryPrice: position.EntryPrice,
+		ExitPrice:  price,
+		OpenedAt:   position.Timestamp,
+		ClosedAt:   e.now,
+		Fees:       fees,
+		RealizedPL: shadowPnL(side, position.EntryPrice, price, quantity) - fees,
+	}
+	if notional := position.EntryPrice * quantity; notional > 0 {
+		trade.ReturnPercent = trade.RealizedPL / notional * 100
+	}
+	e.Trades = append(e.Trades, trade)
+	position.Amount -= quantity
+	return nil
+}
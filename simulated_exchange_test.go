@@ -0,0 +1,79 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSimulatedExchange_RealizedPnL(t *testing.T) {
+	start := time.Date(2025, 10, 1, 0, 0, 0, 0, time.UTC)
+	exchange := NewSimulatedExchange()
+
+	_, err := exchange.OpenPosition("GIGGLEUSDT", PositionSideLong, 1, 10)
+	require.Error(t, err, "no price yet")
+
+	exchange.SetPrice("GIGGLEUSDT", 100, start)
+	_, err = exchange.OpenPosition("GIGGLEUSDT", PositionSideLong, 1, 10)
+	require.NoError(t, err)
+	_, err = exchange.OpenPosition("GIGGLEUSDT", PositionSideShort, 1, 10)
+	require.Error(t, err, "one position per symbol")
+
+	exchange.SetPrice("GIGGLEUSDT", 110, start.Add(time.Hour))
+	position, err := exchange.GetPosition("GIGGLEUSDT")
+	require.NoError(t, err)
+	assert.InDelta(t, 100, position.UnrealizedPL, 1e-9)
+	require.Error(t, exchange.ReducePosition("GIGGLEUSDT", PositionSideShort, 4))
+
+	require.NoError(t, exchange.ReducePosition("GIGGLEUSDT", PositionSideLong, 4))
+	require.Len(t, exchange.Trades, 1)
+	reduce := exchange.Trades[0]
+	assert.Equal(t, 4.0, reduce.Quantity)
+	assert.InDelta(t, 0.42, reduce.Fees, 1e-9, "taker fee on the 400 entry and 440 exit notional")
+	assert.InDelta(t, 39.58, reduce.RealizedPL, 1e-9)
+	assert.InDelta(t, 9.895, reduce.ReturnPercent, 1e-9)
+	position, _ = exchange.GetPosition("GIGGLEUSDT")
+	assert.Equal(t, 6.0, position.Amount)
+
+	exchange.SetPrice("GIGGLEUSDT", 90, start.Add(2*time.Hour))
+	require.NoError(t, exchange.ClosePosition("GIGGLEUSDT", PositionSideLong))
+	require.Len(t, exchange.Trades, 2)
+	closed := exchange.Trades[1]
+	assert.Equal(t, 6.0, closed.Quantity)
+	assert.InDelta(t, 0.57, closed.Fees, 1e-9)
+	assert.InDelta(t, -60.57, closed.RealizedPL, 1e-9)
+	assert.InDelta(t, -10.095, closed.ReturnPercent, 1e-9)
+	assert.Equal(t, start, closed.OpenedAt)
+	assert.Equal(t, start.Add(2*time.Hour), closed.ClosedAt)
+	position, _ = exchange.GetPosition("GIGGLEUSDT")
+	assert.Nil(t, position)
+	require.Error(t, exchange.ClosePosition("GIGGLEUSDT", PositionSideLong))
+}
+
+func TestSimulatedExchange_Short(t *testing.T) {
+	exchange := NewSimulatedExchange()
+	exchange.SetPrice("GIGGLEUSDT", 200, time.Time{})
+	_, err := exchange.OpenPosition("GIGGLEUSDT", PositionSideShort, 1, 5)
+	require.NoError(t, err)
+
+	exchange.SetPrice("GIGGLEUSDT", 180, time.Time{})
+	require.NoError(t, exchange.ReducePosition("GIGGLEUSDT", PositionSideShort, 5), "reducing the whole amount closes")
+
+	require.Len(t, exchange.Trades, 1)
+	trade := exchange.Trades[0]
+	assert.InDelta(t, 0.95, trade.Fees, 1e-9)
+	assert.InDelta(t, 99.05, trade.RealizedPL, 1e-9)
+	assert.InDelta(t, 9.905, trade.ReturnPercent, 1e-9)
+	position, _ := exchange.GetPosition("GIGGLEUSDT")
+	assert.Nil(t, position)
+
+	exchange.FeeRate = 0
+	exchange.SetPrice("GIGGLEUSDT", 100, time.Time{})
+	_, err = exchange.OpenPosition("GIGGLEUSDT", PositionSideShort, 1, 2)
+	require.NoError(t, err)
+	exchange.SetPrice("GIGGLEUSDT", 120, time.Time{})
+	require.NoError(t, exchange.ClosePosition("GIGGLEUSDT", PositionSideShort))
+	assert.InDelta(t, -40, exchange.Trades[1].RealizedPL, 1e-9, "without fees the short loses the price rise")
+}
@@ -0,0 +1,446 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"time"
+)
+
+const (
+	simulationCoinKlines      = 350
+	simulationBTCKlines       = 200
+	simulationMinutesPerFrame = 60
+	simulationIchimokuTail    = 2
+)
+
+// SimulationFrame is the market and community picture of one historical hour,
+// built from closed candles and stored records the way collectCycleData
+// builds it live. MinuteCloses is the price path until the next frame.
+type SimulationFrame struct {
+	At           time.Time
+	Price        float64
+	MinuteCloses []float64
+	BTCIchimoku  IchimokuResult
+	CoinIchimoku IchimokuResult
+	Regime       RegimeAnalysis
+	Correlation  CorrelationAnalysis
+	Activity     ActivityAnalysis
+	FudActivity  ActivityAnalysis
+	FudShare     FudShareAnalysis
+	Sentiment    ClaudeSentimentResponse
+}
+
+// SimulationData is the replayable history of a pair. Frames are hourly from
+// From, the hourly series feed the FUD mode simulations.
+type SimulationData struct {
+	Pair            TradingPair
+	From            time.Time
+	To              time.Time
+	Frames          []SimulationFrame
+	HourlyCloses    []float64
+	FudCounts       []float64
+	SentimentTrends []string
+	FudAttacks      []FudAttackRecord
+}
+
+// LoadSimulationData fetches klines with enough warm-up for the indicators,
+// minute klines for the price path and the stored community history of a
+// pair, and precomputes one frame per hour between from and to.
+func LoadSimulationData(exchange Exchange, pair TradingPair, from, to time.Time) (*SimulationData, error) {
+	from = from.Truncate(time.Hour)
+	to = to.Truncate(time.Hour)
+	hours := int(to.Sub(from).Hours())
+	if hours <= 0 {
+		return nil, fmt.Errorf("empty simulation window")
+	}
+
+	coinKlines, err := fetchKlineRange(exchange, pair.Symbol, KLINES_INTERVAL, from.Add(-simulationCoinKlines*klineIntervalDuration(KLINES_INTERVAL)), to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load coin klines: %w", err)
+	}
+	btcKlines, err := fetchKlineRange(exchange, "BTCUSDT", KLINES_BTC_INTERVAL, from.Add(-simulationBTCKlines*klineIntervalDuration(KLINES_BTC_INTERVAL)), to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load BTC klines: %w", err)
+	}
+	correlationFrom := from.Add(-(CorrelationWindow + 1) * klineIntervalDuration(KLINES_INTERVAL))
+	btcHourlyKlines, err := fetchKlineRange(exchange, "BTCUSDT", KLINES_INTERVAL, correlationFrom, to)
+	if err != nil {
+		log.Printf("[%s] Simulation without BTC correlation: %v", pair.Symbol, err)
+	}
+	var ethHourlyKlines []Candle
+	if pair.CorrelateETH {
+		ethHourlyKlines, err = fetchKlineRange(exchange, "ETHUSDT", KLINES_INTERVAL, correlationFrom, to)
+		if err != nil {
+			log.Printf("[%s] Simulation without ETH correlation: %v", pair.Symbol, err)
+		}
+	}
+	minuteKlines, err := fetchKlineRange(exchange, pair.Symbol, "1m", from, to)
+	if err != nil {
+		log.Printf("[%s] Simulation falls back to hourly prices: %v", pair.Symbol, err)
+	}
+
+	activityFrom := from.Add(-ActivityBaselineDays * 24 * time.Hour)
+	totals, err := GetActivityPoints(pair.CommunityID, ActivityKindTotal, activityFrom, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load activity: %w", err)
+	}
+	fuds, err := GetActivityPoints(pair.CommunityID, ActivityKindFud, activityFrom, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load FUD activity: %w", err)
+	}
+	sentiments, err := GetSentimentRecords(pair.Symbol, from.Add(-ResearchSentimentMaxAge), to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load sentiment: %w", err)
+	}
+	attacks, err := GetFudAttacksInRange(pair.Symbol, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load FUD attacks: %w", err)
+	}
+
+	data := &SimulationData{
+		Pair:            pair,
+		From:            from,
+		To:              to,
+		Frames:          make([]SimulationFrame, hours),
+		HourlyCloses:    hourlyClosesFromKlines(coinKlines, from, hours),
+		FudCounts:       make([]float64, hours),
+		SentimentTrends: hourlySentimentTrends(sentiments, from, hours),
+		FudAttacks:      attacks,
+	}
+	for i := range data.FudCounts {
+		data.FudCounts[i] = math.NaN()
+	}
+	for _, r := range fuds {
+		if idx := int(r.Hour.Sub(from) / time.Hour); idx >= 0 && idx < hours {
+			data.FudCounts[idx] = float64(r.MessageCount)
+		}
+	}
+
+	minuteCloses := minuteClosesFromKlines(minuteKlines, from, hours*simulationMinutesPerFrame)
+	var btcIchimoku IchimokuResult
+	btcEnd := -1
+	sentimentIdx := -1
+	for i := range data.Frames {
+		at := from.Add(hoursToDuration(i))
+		frame := &data.Frames[i]
+		frame.At = at
+
+		coin, _ := closedKlines(coinKlines, at, simulationCoinKlines)
+		if len(coin) == 0 {
+			continue
+		}
+		frame.Price = coin[len(coin)-1].Close
+
+		btc, end := closedKlines(btcKlines, at, simulationBTCKlines)
+		if end != btcEnd {
+			btcIchimoku = trimIchimokuResult(CalculateIchimoku(btc))
+			btcEnd = end
+		}
+		frame.BTCIchimoku = btcIchimoku
+		frame.CoinIchimoku = trimIchimokuResult(CalculateIchimoku(coin))
+		frame.Regime = DetectMarketRegime(coin, btcIchimoku.Analysis)
+
+		btcHourly, _ := closedKlines(btcHourlyKlines, at, CorrelationWindow+1)
+		ethHourly, _ := closedKlines(ethHourlyKlines, at, CorrelationWindow+1)
+		frame.Correlation = CalculateCorrelation(coin, btcHourly, ethHourly, CorrelationWindow)
+
+		activityData := activityWindow(totals, at)
+		fudActivityData := activityWindow(fuds, at)
+		frame.Activity = AnalyzeActivityTrend(activityData)
+		frame.FudActivity = AnalyzeFudActivityTrend(fudActivityData)
+		frame.FudShare = AnalyzeFudShare(activityData, fudActivityData)
+
+		for sentimentIdx+1 < len(sentiments) && sentiments[sentimentIdx+1].CreatedAt.Before(at) {
+			sentimentIdx++
+		}
+		if sentimentIdx >= 0 && at.Sub(sentiments[sentimentIdx].CreatedAt) <= ResearchSentimentMaxAge {
+			frame.Sentiment = sentimentFromRecord(sentiments[sentimentIdx])
+		}
+
+		frame.MinuteCloses = minuteCloses[i*simulationMinutesPerFrame : (i+1)*simulationMinutesPerFrame]
+		last := frame.Price
+		for m, price := range frame.MinuteCloses {
+			if math.IsNaN(price) {
+				frame.MinuteCloses[m] = last
+			} else {
+				last = price
+			}
+		}
+	}
+	return data, nil
+}
+
+// fetchKlineRange pages klines of any interval between from and to.
+func fetchKlineRange(exchange Exchange, symbol, interval string, from, to time.Time) ([]Candle, error) {
+	if exchange == nil {
+		return nil, fmt.Errorf("no exchange configured for price data")
+	}
+	step := klineIntervalDuration(interval).Milliseconds()
+	start := from.UnixMilli()
+	end := to.UnixMilli() - 1
+	var result []Candle
+	for start <= end {
+		klines, err := exchange.Klines(symbol, interval, start, end, researchKlinesPageLimit)
+		if err != nil {
+			return result, err
+		}
+		if len(klines) == 0 {
+			break
+		}
+		result = append(result, klines...)
+		next := klines[len(klines)-1].OpenTime + step
+		if next <= start || len(klines) < researchKlinesPageLimit {
+			break
+		}
+		start = next
+	}
+	return result, nil
+}
+
+func klineIntervalDuration(interval string) time.Duration {
+	duration, err := time.ParseDuration(interval)
+	if err != nil {
+		return time.Hour
+	}
+	return duration
+}
+
+// closedKlines returns up to limit klines closed before at and the index after
+// the last of them.
+func closedKlines(klines []Candle, at time.Time, limit int) ([]Candle, int) {
+	atMs := at.UnixMilli()
+	end := sort.Search(len(klines), func(i int) bool { return klines[i].CloseTime >= atMs })
+	start := end - limit
+	if start < 0 {
+		start = 0
+	}
+	return klines[start:end], end
+}
+
+// trimIchimokuResult keeps the tail of the Ichimoku lines that exit checks
+// read, so a frame does not hold the full series.
+func trimIchimokuResult(result IchimokuResult) IchimokuResult {
+	tail := func(line []IchimokuLine) []IchimokuLine {
+		if len(line) > simulationIchimokuTail {
+			line = line[len(line)-simulationIchimokuTail:]
+		}
+		return append([]IchimokuLine(nil), line...)
+	}
+	result.Data = IchimokuData{
+		Tenkan:  tail(result.Data.Tenkan),
+		Kijun:   tail(result.Data.Kijun),
+		SenkouA: tail(result.Data.SenkouA),
+		SenkouB: tail(result.Data.SenkouB),
+		Chikou:  tail(result.Data.Chikou),
+		Price:   tail(result.Data.Price),
+	}
+	return result
+}
+
+func hourlyClosesFromKlines(klines []Candle, from time.Time, hours int) []float64 {
+	closes := make([]float64, hours)
+	for i := range closes {
+		closes[i] = math.NaN()
+	}
+	for _, k := range klines {
+		idx := int((k.OpenTime - from.UnixMilli()) / time.Hour.Milliseconds())
+		if idx < 0 || idx >= hours {
+			continue
+		}
+		if k.Close > 0 {
+			closes[idx] = k.Close
+		}
+	}
+	return closes
+}
+
+func minuteClosesFromKlines(klines []Candle, from time.Time, minutes int) []float64 {
+	closes := make([]float64, minutes)
+	for i := range closes {
+		closes[i] = math.NaN()
+	}
+	for _, k := range klines {
+		idx := int((k.OpenTime - from.UnixMilli()) / time.Minute.Milliseconds())
+		if idx < 0 || idx >= minutes {
+			continue
+		}
+		if k.Close > 0 {
+			closes[idx] = k.Close
+		}
+	}
+	return closes
+}
+
+// activityWindow returns the closed hours of the activity baseline before at.
+func activityWindow(records []ActivityRecord, at time.Time) []ActivityDataPoint {
+	from := at.Add(-ActivityBaselineDays * 24 * time.Hour)
+	start := sort.Search(len(records), func(i int) bool { return !records[i].Hour.Before(from) })
+	end := sort.Search(len(records), func(i int) bool { return records[i].Hour.After(at.Add(-time.Hour)) })
+	points := make([]ActivityDataPoint, 0, end-start)
+	for _, r := range records[start:end] {
+		points = append(points, ActivityDataPoint{Timestamp: r.Hour.UnixMilli(), MessageCount: r.MessageCount})
+	}
+	return points
+}
+
+func sentimentFromRecord(record SentimentRecord) ClaudeSentimentResponse {
+	return ClaudeSentimentResponse{
+		OverallSentiment: record.OverallSentiment,
+		SentimentTrend:   record.SentimentTrend,
+		FudLevel:         record.FudLevel,
+		Confidence:       record.Confidence,
+		Recommendation:   record.Recommendation,
+	}
+}
+
+// StrategySimulation is the in-memory side of a replayed strategy: the
+// simulated exchange, the snapshots of the open position and the last
+// decision used for deduplication.
+type StrategySimulation struct {
+	Exchange     *SimulatedExchange
+	Snapshots    []PositionSnapshot
+	ExitReasons  map[string]int
+	lastDecision *TradingDecisionRecord
+}
+
+func (s *StrategySimulation) recordDecision(ctx *StrategyContext, decision TradingDecisionResult) (*TradingDecisionRecord, bool) {
+	record := newTradingDecisionRecord(ctx, decision)
+	if !tradingDecisionChanged(s.lastDecision, record) {
+		return nil, false
+	}
+	s.lastDecision = &record
+	return &record, true
+}
+
+type SimulationResult struct {
+	Trades      []SimulatedTrade `json:"trades"`
+	ExitReasons map[string]int   `json:"exit_reasons"`
+}
+
+// RunStrategySimulation replays a strategy over frames [start, end) with one
+// cycle per minute like the live loop. FUD mode, AI validation and AI close
+// checks are not replayed. A position still open at the end is closed at the
+// last price.
+func RunStrategySimulation(data *SimulationData, config StrategyConfig, start, end int) (SimulationResult, error) {
+	strategy, err := NewStrategy(config)
+	if err != nil {
+		return SimulationResult{}, err
+	}
+
+	pair := data.Pair
+	sim := &StrategySimulation{Exchange: NewSimulatedExchange(), ExitReasons: make(map[string]int)}
+	state := &TradingState{CurrentPosition: PositionSideBoth}
+	if end > len(data.Frames) {
+		end = len(data.Frames)
+	}
+
+	var ctx *StrategyContext
+	for i := start; i < end; i++ {
+		frame := &data.Frames[i]
+		if frame.Price <= 0 {
+			continue
+		}
+		regimeRule := GetRegimeRule(pair, frame.Regime.Regime)
+		for m, price := range frame.MinuteCloses {
+			now := frame.At.Add(time.Duration(m+1) * time.Minute)
+			sim.Exchange.SetPrice(pair.Symbol, price, now)
+			ctx = &StrategyContext{
+				Now:          now,
+				Simulation:   sim,
+				Pair:         pair,
+				State:        state,
+				MarkPrice:    price,
+				BTCIchimoku:  frame.BTCIchimoku,
+				CoinIchimoku: frame.CoinIchimoku,
+				Regime:       frame.Regime,
+				RegimeRule:   regimeRule,
+				Correlation:  frame.Correlation,
+				Activity:     frame.Activity,
+				FudActivity:  frame.FudActivity,
+				FudShare:     frame.FudShare,
+				Sentiment:    frame.Sentiment,
+			}
+
+			if position, _ := sim.Exchange.GetPosition(pair.Symbol); position != nil {
+				sim.Snapshots = append(sim.Snapshots, PositionSnapshot{
+					PositionUUID: state.PositionUUID,
+					Symbol:       pair.Symbol,
+					Side:         string(position.Side),
+					EntryPrice:   position.EntryPrice,
+					Amount:       position.Amount,
+					UnrealizedPL: position.UnrealizedPL,
+					MarkPrice:    price,
+					CreatedAt:    now,
+				})
+				ctx.Position = position
+				intents, err := strategy.OnPositionUpdate(ctx, PositionUpdate{Position: *position, MarkPrice: price, SnapshotCount: int64(len(sim.Snapshots))})
+				if err != nil {
+					return sim.result(), err
+				}
+				sim.execute(ctx, intents)
+				applyVirtualTimeExits(ctx, *position, func(reason string) {
+					sim.close(ctx, state.CurrentPosition, reason)
+				})
+			}
+
+			ctx.Position, _ = sim.Exchange.GetPosition(pair.Symbol)
+			intents, err := strategy.OnCycle(ctx)
+			if err != nil {
+				return sim.result(), err
+			}
+			sim.execute(ctx, intents)
+		}
+	}
+
+	if ctx != nil && state.CurrentPosition != PositionSideBoth {
+		sim.close(ctx, state.CurrentPosition, "simulation_end")
+	}
+	return sim.result(), nil
+}
+
+// execute applies intents like executeShadowIntents: AI confirmed closes are
+// skipped, AI vetoed closes go through.
+func (s *StrategySimulation) execute(ctx *StrategyContext, intents []TradeIntent) {
+	state := ctx.State
+	for _, intent := range intents {
+		switch intent.Action {
+		case IntentClose:
+			if state.CurrentPosition == PositionSideBoth || state.CurrentPosition != intent.Side || intent.AICheck == CloseAIConfirm {
+				continue
+			}
+			s.close(ctx, intent.Side, intent.Reason)
+		case IntentOpen:
+			if state.CurrentPosition != PositionSideBoth {
+				continue
+			}
+			if _, err := s.Exchange.OpenPosition(ctx.Pair.Symbol, intent.Side, ctx.Pair.Leverage, ctx.Pair.Quantity); err != nil {
+				log.Printf("[%s] Simulated open failed: %v", ctx.Pair.Symbol, err)
+				continue
+			}
+			state.CurrentPosition = intent.Side
+			state.OpenedAt = ctx.Now
+			state.OpenReason = intent.Reason
+			state.PositionUUID = GeneratePositionUUID()
+			s.Snapshots = nil
+		}
+	}
+}
+
+func (s *StrategySimulation) close(ctx *StrategyContext, side PositionSide, reason string) {
+	state := ctx.State
+	if err := s.Exchange.ClosePosition(ctx.Pair.Symbol, side); err != nil {
+		log.Printf("[%s] Simulated close failed: %v", ctx.Pair.Symbol, err)
+		return
+	}
+	s.ExitReasons[reason]++
+	s.Snapshots = nil
+	state.CurrentPosition = PositionSideBoth
+	state.PositionUUID = ""
+	state.OpenReason = ""
+}
+
+func (s *StrategySimulation) result() SimulationResult {
+	return SimulationResult{Trades: s.Exchange.Trades, ExitReasons: s.ExitReasons}
+}
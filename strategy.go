@@ -0,0 +1,249 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/grutapig/fudtradebot/claude"
+)
+
+const (
+	StrategySentimentIchimoku = "sentiment_ichimoku"
+	StrategyIchimoku          = "ichimoku"
+)
+
+// StrategyParams are the tunable numbers of a strategy, missing keys fall
+// back to the strategy defaults.
+type StrategyParams map[string]float64
+
+func (p StrategyParams) Float(name string, fallback float64) float64 {
+	if value, ok := p[name]; ok {
+		return value
+	}
+	return fallback
+}
+
+func (p StrategyParams) Int(name string, fallback int) int {
+	if value, ok := p[name]; ok {
+		return int(value)
+	}
+	return fallback
+}
+
+func (p StrategyParams) Bool(name string, fallback bool) bool {
+	if value, ok := p[name]; ok {
+		return value != 0
+	}
+	return fallback
+}
+
+// StrategyConfig selects the strategy of a trading pair. An empty name runs
+// the default sentiment weighted Ichimoku strategy. Label names a shadow run
+// and defaults to the strategy name.
+type StrategyConfig struct {
+	Name   string
+	Label  string
+	Params StrategyParams
+}
+
+// StrategyContext carries everything collected for one pair in one cycle.
+// Strategies read it and answer with intents, the executor places the orders.
+// Shadow is the label of a shadow run and empty for the live strategy.
+// Simulation is set when the strategy is replayed over historical data.
+type StrategyContext struct {
+	Now            time.Time
+	Shadow         string
+	Simulation     *StrategySimulation
+	Pair           TradingPair
+	State          *TradingState
+	Exchange       Exchange
+	ActivityClient ExternalActivityClient
+	Analysis       ExternalAnalysisProvider
+	Claude         claude.LLM
+
+	Position     *Position
+	MarkPrice    float64
+	BTCIchimoku  IchimokuResult
+	CoinIchimoku IchimokuResult
+	Regime       RegimeAnalysis
+	RegimeRule   RegimeRule
+	Correlation  CorrelationAnalysis
+	Activity     ActivityAnalysis
+	FudActivity  ActivityAnalysis
+	FudShare     FudShareAnalysis
+	Sentiment    ClaudeSentimentResponse
+	FudAttack    ClaudeFudAttackResponse
+
+	SentimentSource string
+}
+
+type IntentAction string
+
+const (
+	IntentOpen  IntentAction = "open"
+	IntentClose IntentAction = "close"
+)
+
+// CloseAICheck decides how a close intent uses the AI close analysis.
+type CloseAICheck string
+
+const (
+	// CloseAINone closes without asking the AI.
+	CloseAINone CloseAICheck = ""
+	// CloseAIVeto closes unless the AI recommends holding.
+	CloseAIVeto CloseAICheck = "veto"
+	// CloseAIConfirm closes only when the AI recommends closing.
+	CloseAIConfirm CloseAICheck = "confirm"
+)
+
+// TradeIntent is an order a strategy wants placed. Open intents may carry the
+// decision that produced them, it is validated by the AI and linked to the
+// new position.
+type TradeIntent struct {
+	Action           IntentAction
+	Side             PositionSide
+	Reason           string
+	Decision         *TradingDecisionResult
+	DecisionRecordID uint
+	ValidateWithAI   bool
+	AICheck          CloseAICheck
+}
+
+// PositionUpdate is sent to the strategy after every snapshot of an open
+// position.
+type PositionUpdate struct {
+	Position      Position
+	MarkPrice     float64
+	SnapshotCount int64
+}
+
+type Strategy interface {
+	Name() string
+	// OnPositionUpdate runs before market data is collected, so it only sees
+	// the pair, state and exchange fields of the context.
+	OnPositionUpdate(ctx *StrategyContext, update PositionUpdate) ([]TradeIntent, error)
+	OnCycle(ctx *StrategyContext) ([]TradeIntent, error)
+}
+
+type StrategyFactory func(params StrategyParams) Strategy
+
+var strategyRegistry = map[string]StrategyFactory{
+	StrategySentimentIchimoku: NewSentimentIchimokuStrategy,
+	StrategyIchimoku:          NewIchimokuStrategy,
+}
+
+func StrategyNames() []string {
+	names := make([]string, 0, len(strategyRegistry))
+	for name := range strategyRegistry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func NewStrategy(config StrategyConfig) (Strategy, error) {
+	name := config.Name
+	if name == "" {
+		name = StrategySentimentIchimoku
+	}
+	factory, ok := strategyRegistry[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown strategy %q, available: %s", name, strings.Join(StrategyNames(), ", "))
+	}
+	params := config.Params
+	if params == nil {
+		params = StrategyParams{}
+	}
+	return factory(params), nil
+}
+
+func signalToPositionSide(signal Signal) PositionSide {
+	switch signal {
+	case SignalLong:
+		return PositionSideLong
+	case SignalShort:
+		return PositionSideShort
+	}
+	return PositionSideBoth
+}
+
+func newTradingDecisionRecord(ctx *StrategyContext, decision TradingDecisionResult) TradingDecisionRecord {
+	fudAttackInfo := "no"
+	if ctx.FudAttack.HasAttack {
+		fudAttackInfo = "yes"
+	}
+
+	return TradingDecisionRecord{
+		PositionUUID:        ctx.State.PositionUUID,
+		Symbol:              ctx.Pair.Symbol,
+		BTCIchimoku:         decision.BTCIchimokuSignal,
+		CoinIchimoku:        decision.CoinIchimokuSignal,
+		Activity:            decision.ActivitySignal,
+		FudActivity:         decision.FudActivitySignal,
+		FudShare:            decision.FudShareSignal,
+		ActivityZScore:      ctx.Activity.ZScore,
+		ActivityConfidence:  ctx.Activity.Confidence,
+		Sentiment:           decision.SentimentSignal,
+		SentimentSource:     ctx.SentimentSource,
+		FudAttack:           fudAttackInfo,
+		Regime:              decision.RegimeSignal,
+		Coupling:            decision.Coupling,
+		BTCCorrelation:      decision.BTCCorrelation,
+		BTCBeta:             decision.BTCBeta,
+		FinalDecision:       string(decision.Signal),
+		DecisionExplanation: decision.Explanation,
+		CreatedAt:           time.Now(),
+	}
+}
+
+// recordTradingDecision stores the decision when any of its signals changed
+// since the last stored decision of the pair. It returns the stored record and
+// whether the decision changed.
+func recordTradingDecision(ctx *StrategyContext, decision TradingDecisionResult) (*TradingDecisionRecord, bool) {
+	if ctx.Simulation != nil {
+		return ctx.Simulation.recordDecision(ctx, decision)
+	}
+	if ctx.Shadow != "" {
+		return recordShadowDecision(ctx, decision)
+	}
+
+	pair := ctx.Pair
+	decisionRecord := newTradingDecisionRecord(ctx, decision)
+
+	shouldSave := false
+	lastDecision, err := GetLatestTradingDecision(pair.Symbol)
+	if err != nil {
+		log.Printf("[%s] Failed to get last decision: %v", pair.Symbol, err)
+		shouldSave = true
+	} else {
+		shouldSave = tradingDecisionChanged(lastDecision, decisionRecord)
+	}
+	if !shouldSave {
+		return nil, false
+	}
+
+	if err := SaveTradingDecision(decisionRecord); err != nil {
+		log.Printf("[%s] Failed to save trading decision: %v", pair.Symbol, err)
+		return nil, true
+	}
+	log.Printf("[%s] Trading decision saved to database", pair.Symbol)
+	savedDecision, _ := GetLatestTradingDecision(pair.Symbol)
+	return savedDecision, true
+}
+
+func tradingDecisionChanged(last *TradingDecisionRecord, next TradingDecisionRecord) bool {
+	return last == nil ||
+		last.BTCIchimoku != next.BTCIchimoku ||
+		last.CoinIchimoku != next.CoinIchimoku ||
+		last.Activity != next.Activity ||
+		last.FudActivity != next.FudActivity ||
+		last.FudShare != next.FudShare ||
+		last.Sentiment != next.Sentiment ||
+		last.FudAttack != next.FudAttack ||
+		last.Regime != next.Regime ||
+		last.Coupling != next.Coupling ||
+		last.FinalDecision != next.FinalDecision
+}
@@ -0,0 +1,272 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+)
+
+// executeIntents places the orders a strategy asked for, in order. A close
+// intent for a side that is not open is skipped, so a strategy can return a
+// close followed by an open to reverse.
+func executeIntents(ctx *StrategyContext, intents []TradeIntent) error {
+	for _, intent := range intents {
+		var err error
+		switch intent.Action {
+		case IntentClose:
+			err = executeCloseIntent(ctx, intent)
+		case IntentOpen:
+			err = executeOpenIntent(ctx, intent)
+		default:
+			err = fmt.Errorf("unknown intent action %q", intent.Action)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func executeCloseIntent(ctx *StrategyContext, intent TradeIntent) error {
+	pair := ctx.Pair
+	state := ctx.State
+	if state.CurrentPosition == PositionSideBoth || state.CurrentPosition != intent.Side {
+		return nil
+	}
+
+	reason := intent.Reason
+	if intent.AICheck != CloseAINone && ctx.Claude != nil {
+		shouldClose, err := performAICloseAnalysis(ctx.Claude, ctx.Exchange, ctx.ActivityClient, pair, state)
+		switch {
+		case err != nil && intent.AICheck == CloseAIConfirm:
+			log.Printf("[%s] Failed to perform AI close analysis: %v", pair.Symbol, err)
+			return nil
+		case err != nil:
+			log.Printf("[%s] Failed to perform AI close analysis, closing anyway: %v", pair.Symbol, err)
+		case !shouldClose:
+			log.Printf("[%s] Position held because of AI close analysis", pair.Symbol)
+			return nil
+		default:
+			reason = "ai_close_recommendation"
+		}
+	} else if intent.AICheck == CloseAIConfirm {
+		return nil
+	}
+
+	if _, _, err := closeStatePosition(ctx.Exchange, pair, state, intent.Side, reason); err != nil {
+		return err
+	}
+	log.Printf("[%s] Position closed (%s)", pair.Symbol, reason)
+	return nil
+}
+
+func executeOpenIntent(ctx *StrategyContext, intent TradeIntent) error {
+	pair := ctx.Pair
+	state := ctx.State
+	exchange := ctx.Exchange
+
+	if state.CurrentPosition == intent.Side {
+		log.Printf("[%s] Position already matches signal - holding", pair.Symbol)
+		return nil
+	}
+	if state.CurrentPosition != PositionSideBoth {
+		log.Printf("[%s] Cannot open %s while %s is open", pair.Symbol, intent.Side, state.CurrentPosition)
+		return nil
+	}
+
+	markPrice, err := exchange.GetMarkPrice(pair.Symbol)
+	if err != nil {
+		log.Printf("[%s] Failed to get mark price for exposure check: %v", pair.Symbol, err)
+	} else {
+		exposure, err := CheckPortfolioExposure(pair.Symbol, intent.Side, markPrice*pair.Quantity, ctx.Correlation)
+		if err != nil {
+			log.Printf("[%s] Failed to check portfolio exposure: %v", pair.Symbol, err)
+		} else if !exposure.Allowed {
+			log.Printf("[%s] ❌ Portfolio exposure check failed - not opening position: %s", pair.Symbol, exposure.Reason)
+			return nil
+		} else {
+			log.Printf("[%s] Portfolio exposure check passed: %s", pair.Symbol, exposure.Reason)
+		}
+
+		margin, err := CheckAvailableMargin(exchange, pair, markPrice)
+		if err != nil {
+			log.Printf("[%s] Failed to check available margin: %v", pair.Symbol, err)
+		} else if !margin.Allowed {
+			log.Printf("[%s] ❌ Margin check failed - not opening position: %s", pair.Symbol, margin.Reason)
+			return nil
+		} else {
+			log.Printf("[%s] Margin check passed: %s", pair.Symbol, margin.Reason)
+		}
+	}
+
+	var validationRecord *AIOrderValidationRecord
+	var validationStarted time.Time
+	if intent.ValidateWithAI && ctx.Claude != nil && intent.Decision != nil {
+		validationStarted = time.Now()
+		approved, record := validateOpenIntentWithAI(ctx, intent)
+		if !approved {
+			return nil
+		}
+		validationRecord = record
+	}
+
+	if err := applyPairMarginType(exchange, pair); err != nil {
+		log.Printf("[%s] ❌ Not opening position: %v", pair.Symbol, err)
+		return err
+	}
+
+	log.Printf("[%s] Opening %s position", pair.Symbol, intent.Side)
+	position, err := exchange.OpenPosition(pair.Symbol, intent.Side, pair.Leverage, pair.Quantity)
+	if err != nil {
+		log.Printf("[%s] Failed to open %s: %v", pair.Symbol, intent.Side, err)
+		return err
+	}
+
+	state.CurrentPosition = intent.Side
+	state.OpenedAt = time.Now()
+	state.OpenReason = intent.Reason
+	state.PositionUUID = GeneratePositionUUID()
+	if validationRecord != nil {
+		validationRecord.PositionUUID = state.PositionUUID
+		if err := SaveAIOrderValidation(validationRecord); err != nil {
+			log.Printf("[%s] Failed to save AI validation: %v", pair.Symbol, err)
+		}
+	}
+	if !validationStarted.IsZero() {
+		if err := AssignLLMCallsToPosition(pair.Symbol, state.PositionUUID, validationStarted); err != nil {
+			log.Printf("[%s] Failed to link LLM calls to position: %v", pair.Symbol, err)
+		}
+	}
+
+	if intent.DecisionRecordID > 0 {
+		if err := UpdateDecisionPositionUUIDByID(intent.DecisionRecordID, state.PositionUUID); err != nil {
+			log.Printf("[%s] Failed to update decision ID %d with position UUID: %v", pair.Symbol, intent.DecisionRecordID, err)
+		} else {
+			log.Printf("[%s] Decision ID %d updated with position UUID: %s", pair.Symbol, intent.DecisionRecordID, state.PositionUUID)
+		}
+	} else if intent.Decision != nil {
+		if err := SaveTradingDecision(newTradingDecisionRecord(ctx, *intent.Decision)); err != nil {
+			log.Printf("[%s] Failed to save opening decision: %v", pair.Symbol, err)
+		} else {
+			log.Printf("[%s] New decision saved with position UUID: %s", pair.Symbol, state.PositionUUID)
+		}
+	}
+
+	log.Printf("[%s] Position opened: %s (entry: %.6f, amount: %.6f, reason: %s, UUID: %s)", pair.Symbol, intent.Side, position.EntryPrice, position.Amount, intent.Reason, state.PositionUUID)
+
+	positionRecord := PositionRecord{
+		UUID:           state.PositionUUID,
+		Symbol:         pair.Symbol,
+		Side:           string(intent.Side),
+		Leverage:       pair.Leverage,
+		Quantity:       pair.Quantity,
+		EntryPrice:     position.EntryPrice,
+		MarginType:     effectiveMarginType(position, pair),
+		OpenedAt:       state.OpenedAt,
+		OpenReason:     intent.Reason,
+		MaxPnL:         position.UnrealizedPL,
+		MinPnL:         position.UnrealizedPL,
+		BTCCorrelation: ctx.Correlation.BTCCorrelation,
+		BTCBeta:        ctx.Correlation.BTCBeta,
+		CreatedAt:      time.Now(),
+	}
+	if err := SavePositionOpen(positionRecord); err != nil {
+		log.Printf("[%s] Failed to save position to database: %v", pair.Symbol, err)
+	} else {
+		log.Printf("[%s] Position record saved to database", pair.Symbol)
+	}
+
+	return nil
+}
+
+// validateOpenIntentWithAI asks the AI to validate the decision behind an open
+// intent. Validation errors let the order through, a rejection is stored and
+// blocks the order.
+func validateOpenIntentWithAI(ctx *StrategyContext, intent TradeIntent) (bool, *AIOrderValidationRecord) {
+	pair := ctx.Pair
+	state := ctx.State
+	decision := *intent.Decision
+
+	log.Printf("[%s] Validating order decision with AI...", pair.Symbol)
+	aiValidation, err := ValidateOrderWithAI(withLLMCallInfo(context.Background(), pair.Symbol, state.PositionUUID), ctx.Claude, decision, ctx.BTCIchimoku.Analysis, ctx.CoinIchimoku.Analysis, ctx.Activity, ctx.FudActivity, ctx.FudShare, ctx.Sentiment, ctx.Regime, ctx.Correlation)
+	if err != nil {
+		log.Printf("[%s] AI validation failed: %v", pair.Symbol, err)
+		log.Printf("[%s] Proceeding without AI validation", pair.Symbol)
+		return true, nil
+	}
+
+	log.Printf("[%s] AI Validation Result:", pair.Symbol)
+	log.Printf("[%s]   Should Open: %v", pair.Symbol, aiValidation.ShouldOpenOrder)
+	log.Printf("[%s]   Confidence: %.1f%%", pair.Symbol, aiValidation.ConfidencePercent)
+	log.Printf("[%s]   Justification: %s", pair.Symbol, aiValidation.Justification)
+
+	requestDataJSON, _ := json.Marshal(map[string]interface{}{
+		"decision":      decision,
+		"btc_ichimoku":  ctx.BTCIchimoku.Analysis,
+		"coin_ichimoku": ctx.CoinIchimoku.Analysis,
+		"activity":      ctx.Activity,
+		"fud_activity":  ctx.FudActivity,
+		"fud_share":     ctx.FudShare,
+		"sentiment":     ctx.Sentiment,
+		"market_regime": ctx.Regime,
+		"correlation":   ctx.Correlation,
+	})
+	responseDataJSON, _ := json.Marshal(aiValidation)
+
+	validationRecord := &AIOrderValidationRecord{
+		DecisionRecordID:  intent.DecisionRecordID,
+		Symbol:            pair.Symbol,
+		RequestData:       string(requestDataJSON),
+		ResponseData:      string(responseDataJSON),
+		ShouldOpenOrder:   aiValidation.ShouldOpenOrder,
+		ConfidencePercent: aiValidation.ConfidencePercent,
+		Justification:     aiValidation.Justification,
+		CreatedAt:         time.Now(),
+	}
+
+	if !aiValidation.ShouldOpenOrder {
+		log.Printf("[%s] ❌ AI rejected the order - not opening position", pair.Symbol)
+		state.LastAIRejectionTime = time.Now()
+		state.LastRejectedDecision = fmt.Sprintf("%s:%s", pair.Symbol, decision.Signal)
+		if err := SaveAIOrderValidation(validationRecord); err != nil {
+			log.Printf("[%s] Failed to save AI validation: %v", pair.Symbol, err)
+		} else {
+			log.Printf("[%s] AI validation saved to database", pair.Symbol)
+		}
+		return false, nil
+	}
+
+	log.Printf("[%s] ✅ AI approved the order - proceeding", pair.Symbol)
+	return true, validationRecord
+}
+
+// closeStatePosition closes the tracked position, records the close and
+// resets the position part of the state. It returns the mark price and the
+// last unrealized P/L as realized P/L.
+func closeStatePosition(exchange Exchange, pair TradingPair, state *TradingState, side PositionSide, reason string) (float64, float64, error) {
+	markPrice, _ := exchange.GetMarkPrice(pair.Symbol)
+	closedPosition, _ := exchange.GetPosition(pair.Symbol)
+	realizedPL := 0.0
+	if closedPosition != nil {
+		realizedPL = closedPosition.UnrealizedPL
+	}
+
+	if err := exchange.ClosePosition(pair.Symbol, side); err != nil {
+		log.Printf("[%s] Failed to close %s: %v", pair.Symbol, side, err)
+		return 0, 0, err
+	}
+
+	if state.PositionUUID != "" {
+		if err := UpdatePositionClose(state.PositionUUID, markPrice, realizedPL, reason); err != nil {
+			log.Printf("[%s] Failed to update position close: %v", pair.Symbol, err)
+		}
+	}
+
+	state.CurrentPosition = PositionSideBoth
+	state.PositionUUID = ""
+	state.OpenReason = ""
+	state.LiquidationTopUps = 0
+	return markPrice, realizedPL, nil
+}
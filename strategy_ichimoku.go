@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"log"
+)
+
+// IchimokuStrategy trades the coin Ichimoku signal alone, without community
+// data, AI validation or FUD mode.
+//
+// Params:
+//   - btc_filter: skip signals against a non neutral BTC Ichimoku (1)
+//   - ma_exit: use the moving average P/L exit (0)
+//   - ma_exit_ratio, ma_min_snapshots, ma_exit_window, ma_exit_multiplier,
+//     ma_exit_min_profit_percent: override the pair MA exit config
+type IchimokuStrategy struct {
+	btcFilter    bool
+	maExit       bool
+	maExitParams StrategyParams
+}
+
+func NewIchimokuStrategy(params StrategyParams) Strategy {
+	return &IchimokuStrategy{
+		btcFilter:    params.Bool("btc_filter", true),
+		maExit:       params.Bool("ma_exit", false),
+		maExitParams: params,
+	}
+}
+
+func (s *IchimokuStrategy) Name() string {
+	return StrategyIchimoku
+}
+
+func (s *IchimokuStrategy) OnPositionUpdate(ctx *StrategyContext, update PositionUpdate) ([]TradeIntent, error) {
+	return nil, nil
+}
+
+func (s *IchimokuStrategy) OnCycle(ctx *StrategyContext) ([]TradeIntent, error) {
+	pair := ctx.Pair
+	state := ctx.State
+
+	decision := s.decide(ctx)
+	log.Printf("\n[%s] ===== DECISION: %s (reason: %s) =====", pair.Symbol, decision.Signal, decision.Reason)
+	log.Printf("[%s] Explanation: %s", pair.Symbol, decision.Explanation)
+
+	savedDecision, decisionChanged := recordTradingDecision(ctx, decision)
+
+	if state.CurrentPosition != PositionSideBoth {
+		if s.maExit && movingAverageExitSignal(ctx, s.maExitParams).ShouldClose {
+			return []TradeIntent{{Action: IntentClose, Side: state.CurrentPosition, Reason: "moving_average_exit"}}, nil
+		}
+		if ShouldClosePosition(state.CurrentPosition, ctx.CoinIchimoku) {
+			log.Printf("[%s] Ichimoku signals to close %s position", pair.Symbol, state.CurrentPosition)
+			return []TradeIntent{{Action: IntentClose, Side: state.CurrentPosition, Reason: "ichimoku_exit"}}, nil
+		}
+		return nil, nil
+	}
+
+	desiredPosition := signalToPositionSide(decision.Signal)
+	if desiredPosition == PositionSideBoth || !decisionChanged {
+		return nil, nil
+	}
+
+	intent := TradeIntent{
+		Action:   IntentOpen,
+		Side:     desiredPosition,
+		Reason:   decision.Reason,
+		Decision: &decision,
+	}
+	if savedDecision != nil {
+		intent.DecisionRecordID = savedDecision.ID
+	}
+	return []TradeIntent{intent}, nil
+}
+
+func (s *IchimokuStrategy) decide(ctx *StrategyContext) TradingDecisionResult {
+	btcSignal := convertIchimokuToSignal(ctx.BTCIchimoku.Analysis)
+	coinSignal := convertIchimokuToSignal(ctx.CoinIchimoku.Analysis)
+
+	result := TradingDecisionResult{
+		Signal:             SignalEmpty,
+		BTCIchimokuSignal:  string(btcSignal),
+		CoinIchimokuSignal: string(coinSignal),
+		RegimeSignal:       string(ctx.Regime.Regime),
+		Coupling:           string(ctx.Correlation.Coupling),
+		BTCCorrelation:     ctx.Correlation.BTCCorrelation,
+		BTCBeta:            ctx.Correlation.BTCBeta,
+	}
+
+	switch {
+	case coinSignal == SignalEmpty:
+		result.Explanation = "Coin Ichimoku neutral"
+	case s.btcFilter && btcSignal != SignalEmpty && btcSignal != coinSignal:
+		result.Explanation = fmt.Sprintf("Coin Ichimoku %s against BTC Ichimoku %s", coinSignal, btcSignal)
+	default:
+		result.Signal = coinSignal
+		result.Reason = "ichimoku"
+		result.Explanation = fmt.Sprintf("Coin Ichimoku %s, BTC Ichimoku %s", coinSignal, btcSignal)
+	}
+	return result
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"log"
+)
+
+// SentimentIchimokuStrategy is the original strategy of the bot: the FUD
+// attack state machine first, then the Ichimoku and community signal chain,
+// the moving average P/L exit and the AI checked Ichimoku exit.
+//
+// Params:
+//   - ai_close_every_snapshots: run the AI close analysis every N snapshots (10, 0 disables)
+//   - ma_exit: use the moving average P/L exit (1)
+//   - fud_mode: run the FUD attack state machine (1), never in shadow runs
+//     or simulations because the state machine places its own orders
+//   - ma_exit_ratio, ma_min_snapshots, ma_exit_window, ma_exit_multiplier,
+//     ma_exit_min_profit_percent: override the pair MA exit config
+//   - sentiment_short_below: sentiment score below which a declining
+//     sentiment confirms SHORT (3)
+//   - btc_led_entries: trade the BTC signal when it contradicts a coin
+//     coupled to BTC (0, BTC only vetoes the coin signal)
+//   - fud_share_gate: let the FUD share signal confirm or veto entries
+//     (0, the FUD share is only recorded on the decision)
+type SentimentIchimokuStrategy struct {
+	aiCloseEverySnapshots int
+	maExit                bool
+	maExitParams          StrategyParams
+	fudMode               bool
+	decisionParams        DecisionParams
+}
+
+func NewSentimentIchimokuStrategy(params StrategyParams) Strategy {
+	return &SentimentIchimokuStrategy{
+		aiCloseEverySnapshots: params.Int("ai_close_every_snapshots", 10),
+		maExit:                params.Bool("ma_exit", true),
+		maExitParams:          params,
+		fudMode:               params.Bool("fud_mode", true),
+		decisionParams: DecisionParams{
+			SentimentShortBelow: params.Int("sentiment_short_below", DefaultDecisionParams().SentimentShortBelow),
+			BTCLedEntries:       params.Bool("btc_led_entries", false),
+			FudShareGate:        params.Bool("fud_share_gate", false),
+		},
+	}
+}
+
+func (s *SentimentIchimokuStrategy) Name() string {
+	return StrategySentimentIchimoku
+}
+
+func (s *SentimentIchimokuStrategy) OnPositionUpdate(ctx *StrategyContext, update PositionUpdate) ([]TradeIntent, error) {
+	if s.aiCloseEverySnapshots <= 0 || update.SnapshotCount == 0 || update.SnapshotCount%int64(s.aiCloseEverySnapshots) != 0 {
+		return nil, nil
+	}
+	log.Printf("[%s] 🤖 AI Close Analysis: Triggered at snapshot milestone", ctx.Pair.Symbol)
+	return []TradeIntent{{
+		Action:  IntentClose,
+		Side:    ctx.State.CurrentPosition,
+		Reason:  "ai_close_recommendation",
+		AICheck: CloseAIConfirm,
+	}}, nil
+}
+
+func (s *SentimentIchimokuStrategy) OnCycle(ctx *StrategyContext) ([]TradeIntent, error) {
+	pair := ctx.Pair
+	state := ctx.State
+
+	if s.fudMode && ctx.Shadow == "" && ctx.Simulation == nil {
+		handledByFudMode, err := processFudAttackTradingCycle(ctx.Exchange, pair, state, FudCycleInput{
+			Attack:       ctx.FudAttack,
+			CoinIchimoku: ctx.CoinIchimoku.Analysis,
+			FudActivity:  ctx.FudActivity,
+			Sentiment:    ctx.Sentiment,
+		})
+		if err != nil {
+			log.Printf("[%s] Error in FUD attack trading cycle: %v", pair.Symbol, err)
+		}
+		if handledByFudMode {
+			log.Printf("[%s] Cycle handled by FUD attack mode", pair.Symbol)
+			return nil, nil
+		}
+	}
+
+	decision := MakeTradingDecision(ctx.BTCIchimoku.Analysis, ctx.CoinIchimoku.Analysis, ctx.Activity, ctx.FudActivity, ctx.FudShare, ctx.Sentiment, ctx.Regime, ctx.RegimeRule, ctx.Correlation, s.decisionParams)
+	log.Printf("\n[%s] ===== DECISION: %s (reason: %s) =====", pair.Symbol, decision.Signal, decision.Reason)
+	log.Printf("[%s] Explanation: %s", pair.Symbol, decision.Explanation)
+
+	savedDecision, decisionChanged := recordTradingDecision(ctx, decision)
+
+	if state.CurrentPosition != PositionSideBoth {
+		if s.maExit {
+			maSignal := movingAverageExitSignal(ctx, s.maExitParams)
+			if maSignal.ShouldClose {
+				log.Printf("[%s] 🚨 MOVING AVERAGE EXIT SIGNAL - Force closing position!", pair.Symbol)
+				return []TradeIntent{{Action: IntentClose, Side: state.CurrentPosition, Reason: "moving_average_exit"}}, nil
+			}
+		}
+
+		if ShouldClosePosition(state.CurrentPosition, ctx.CoinIchimoku) && savedDecision != nil {
+			log.Printf("[%s] Ichimoku signals to close %s position", pair.Symbol, state.CurrentPosition)
+			return []TradeIntent{{Action: IntentClose, Side: state.CurrentPosition, Reason: "ichimoku_exit", AICheck: CloseAIVeto}}, nil
+		}
+		log.Printf("[%s] Position held - Ichimoku conditions not met for exit", pair.Symbol)
+		return nil, nil
+	}
+
+	desiredPosition := signalToPositionSide(decision.Signal)
+	if desiredPosition == PositionSideBoth {
+		log.Printf("[%s] No signal - no action", pair.Symbol)
+		return nil, nil
+	}
+
+	if !decisionChanged {
+		log.Printf("[%s] Signal from cache - skipping order processing", pair.Symbol)
+		return nil, nil
+	}
+
+	intent := TradeIntent{
+		Action:         IntentOpen,
+		Side:           desiredPosition,
+		Reason:         decision.Reason,
+		Decision:       &decision,
+		ValidateWithAI: true,
+	}
+	if savedDecision != nil {
+		intent.DecisionRecordID = savedDecision.ID
+	}
+	return []TradeIntent{intent}, nil
+}
+
+// movingAverageExitSignal runs the pair MA exit with the strategy overrides
+// on the context position, a zero ratio uses the market regime rule.
+func movingAverageExitSignal(ctx *StrategyContext, params StrategyParams) MovingAveragePnLSignal {
+	config := GetMAExitConfig(ctx.Pair).WithParams(params)
+	if config.Ratio <= 0 {
+		config.Ratio = ctx.RegimeRule.MAExitRatio
+	}
+
+	input := MAExitInput{ATR: ctx.Regime.ATR}
+	if ctx.Position != nil {
+		input.CurrentPnL = ctx.Position.UnrealizedPL
+		input.EntryPrice = ctx.Position.EntryPrice
+		input.Amount = ctx.Position.Amount
+	}
+	if ctx.Simulation != nil {
+		input.Snapshots = ctx.Simulation.Snapshots
+	} else if ctx.Shadow != "" {
+		input.Snapshots, _ = GetShadowSnapshotsByUUID(ctx.State.PositionUUID)
+	} else {
+		input.Snapshots, _ = GetPositionSnapshotsByUUID(ctx.State.PositionUUID)
+	}
+	maSignal := EvaluateMAExit(config, input)
+
+	log.Printf("[%s] MA Signal: ShouldClose=%v, Current PnL=$%.2f, MA=$%.2f, Threshold=$%.2f (%s/%s)",
+		ctx.Pair.Symbol, maSignal.ShouldClose, maSignal.CurrentPnL, maSignal.MovingAverage, maSignal.Threshold, maSignal.Average, maSignal.Band)
+	log.Printf("[%s] MA Reason: %s", ctx.Pair.Symbol, maSignal.TriggerReason)
+	return maSignal
+}
@@ -49,15 +49,26 @@ const (
 	PositionSideBoth  PositionSide = "BOTH"
 )
 
+type MarginType string
+
+const (
+	MarginTypeIsolated MarginType = "ISOLATED"
+	MarginTypeCrossed  MarginType = "CROSSED"
+)
+
 // Position represents an open futures position
 type Position struct {
-	Symbol       string
-	Side         PositionSide
-	Leverage     int
-	EntryPrice   float64
-	Amount       float64
-	UnrealizedPL float64
-	Timestamp    time.Time
+	Symbol           string
+	Side             PositionSide
+	Leverage         int
+	EntryPrice       float64
+	Amount           float64
+	UnrealizedPL     float64
+	LiquidationPrice float64
+	MarginType       string
+	IsolatedMargin   float64
+	Notional         float64
+	Timestamp        time.Time
 }
 
 // OrderSide represents buy or sell
@@ -107,6 +118,22 @@ type TradingPair struct {
 	Symbol      string
 	Leverage    int
 	Quantity    float64
+	// QuantityStep is the exchange lot step partial reductions are rounded
+	// down to, zero uses the precision of Quantity.
+	QuantityStep float64
+	RegimeRules  map[MarketRegime]RegimeRule
+	CorrelateETH bool
+	FudMode      *FudModeConfig
+	MAExit       *MAExitConfig
+	TimeExit     *TimeExitConfig
+	Liquidation  *LiquidationGuardConfig
+	MarginType   MarginType
+	Venue        string
+	Strategy     StrategyConfig
+	Shadows      []StrategyConfig
+	// TweetAnalysis uses the in-house tweet analysis as the primary source,
+	// as fallback for the external service or as its shadow.
+	TweetAnalysis TweetAnalysisMode
 }
 
 type TradingState struct {
@@ -122,11 +149,26 @@ type TradingState struct {
 	LastFudCheckTime       time.Time
 	LastFudCheckTweetID    string
 	LastDecisionHash       string
-	FudAttackMode          bool
+	FudState               FudState
+	FudStateSince          time.Time
 	FudAttackStartTime     time.Time
-	FudAttackShortStarted  bool
+	FudLastAttackTime      time.Time
+	FudHandledAttackTime   time.Time
+	FudExitReason          string
+	FudStrategy            FudStrategy
+	FudPeakZScore          float64
+	LastParticipantEval    time.Time
 	LastAIRejectionTime    time.Time
 	LastRejectedDecision   string
+	LastRegime             RegimeAnalysis
+	LastCorrelation        CorrelationAnalysis
+	LastActivitySavedHour  time.Time
+	ActivityHistory        []ActivityDataPoint
+	FudActivityHistory     []ActivityDataPoint
+	ActivityFullFetchAt    time.Time
+	TimeExitDeRiskedAt     time.Time
+	LiquidationReducedAt   time.Time
+	LiquidationTopUps      int
 }
 
 type CommunityTweet struct {
@@ -167,6 +209,13 @@ type ClaudeFudAttackResponse struct {
 	StartedHoursAgo int                    `json:"started_hours_ago"`
 	LastAttackTime  *time.Time             `json:"last_attack_time,omitempty"`
 	Justification   string                 `json:"justification"`
+	// ParticipantsUnknown is set by the in-house tweet analysis, whose tweets
+	// carry no authors, so FudModeConfig.MinParticipants cannot apply.
+	ParticipantsUnknown bool `json:"participants_unknown,omitempty"`
+	// Filled locally from the participant registry, Confidence is replaced
+	// by the credibility-weighted value.
+	RawConfidence     float64 `json:"raw_confidence,omitempty"`
+	CredibilityWeight float64 `json:"credibility_weight,omitempty"`
 }
 
 type ClaudeOrderValidationResponse struct {
@@ -184,11 +233,18 @@ type ClaudePositionCloseResponse struct {
 }
 
 type MovingAveragePnLSignal struct {
-	ShouldClose    bool    `json:"should_close"`
-	CurrentPnL     float64 `json:"current_pnl"`
-	MovingAverage  float64 `json:"moving_average"`
-	Threshold      float64 `json:"threshold"`
-	SnapshotsCount int     `json:"snapshots_count"`
-	PercentBelowMA float64 `json:"percent_below_ma"`
-	TriggerReason  string  `json:"trigger_reason"`
+	ShouldClose    bool     `json:"should_close"`
+	CurrentPnL     float64  `json:"current_pnl"`
+	MovingAverage  float64  `json:"moving_average"`
+	Threshold      float64  `json:"threshold"`
+	SnapshotsCount int      `json:"snapshots_count"`
+	PercentBelowMA float64  `json:"percent_below_ma"`
+	TriggerReason  string   `json:"trigger_reason"`
+	Average        string   `json:"average"`
+	Window         int      `json:"window"`
+	Band           string   `json:"band"`
+	Volatility     float64  `json:"volatility"`
+	MinProfit      float64  `json:"min_profit"`
+	Armed          bool     `json:"armed"`
+	Reasoning      []string `json:"reasoning"`
 }
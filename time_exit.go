@@ -0,0 +1,214 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	CloseReasonMaxHold        = "time_exit_max_hold"
+	CloseReasonStale          = "time_exit_stale"
+	CloseReasonSessionClose   = "time_exit_session_close"
+	CloseReasonSessionStop    = "time_exit_session_stop"
+	DeRiskReasonSessionReduce = "time_exit_session_reduce"
+)
+
+type DeRiskAction string
+
+const (
+	DeRiskReduce  DeRiskAction = "reduce"
+	DeRiskTighten DeRiskAction = "tighten"
+	DeRiskClose   DeRiskAction = "close"
+)
+
+// DeRiskWindow is a recurring UTC window from StartDay StartHour to EndDay
+// EndHour, e.g. Friday 20:00 to Monday 00:00 for the weekend. Daily windows
+// ignore the days and repeat every day. Inside the window the position is
+// reduced once by ReduceFraction, closed, or closed when its P/L falls to
+// -StopLossPercent.
+type DeRiskWindow struct {
+	Name            string
+	Daily           bool
+	StartDay        time.Weekday
+	StartHour       int
+	EndDay          time.Weekday
+	EndHour         int
+	Action          DeRiskAction
+	ReduceFraction  float64
+	StopLossPercent float64
+}
+
+// TimeExitConfig holds the per-pair time rules, zero fields are disabled.
+// A position is stale when after StaleAfter its P/L is still within
+// ±StaleBandPercent of entry notional.
+type TimeExitConfig struct {
+	MaxHold          time.Duration
+	StaleAfter       time.Duration
+	StaleBandPercent float64
+	DeRisk           []DeRiskWindow
+}
+
+// WeekendDeRisk halves positions over the weekend.
+func WeekendDeRisk() DeRiskWindow {
+	return DeRiskWindow{
+		Name:           "weekend",
+		StartDay:       time.Friday,
+		StartHour:      20,
+		EndDay:         time.Monday,
+		EndHour:        0,
+		Action:         DeRiskReduce,
+		ReduceFraction: 0.5,
+	}
+}
+
+// activeSince returns the start of the window occurrence containing now.
+func (w DeRiskWindow) activeSince(now time.Time) (time.Time, bool) {
+	now = now.UTC()
+	period := 7 * 24
+	start := int(w.StartDay)*24 + w.StartHour
+	end := int(w.EndDay)*24 + w.EndHour
+	current := hourOfWeek(now)
+	if w.Daily {
+		period = 24
+		start, end, current = w.StartHour, w.EndHour, now.Hour()
+	}
+	length := ((end-start)%period + period) % period
+	elapsed := ((current-start)%period + period) % period
+	if elapsed >= length {
+		return time.Time{}, false
+	}
+	return now.Truncate(time.Hour).Add(-time.Duration(elapsed) * time.Hour), true
+}
+
+type TimeExitDecision struct {
+	Close   bool
+	Reduce  float64
+	Reason  string
+	Details string
+}
+
+// EvaluateTimeExit checks the time rules in order: max hold, stale position,
+// then the de-risk windows. deRiskedAt is the last reduction of the position,
+// so a window occurrence reduces it only once.
+func EvaluateTimeExit(config TimeExitConfig, position Position, openedAt, deRiskedAt, now time.Time) TimeExitDecision {
+	held := now.Sub(openedAt)
+	pnlPercent := 0.0
+	if notional := position.EntryPrice * math.Abs(position.Amount); notional > 0 {
+		pnlPercent = position.UnrealizedPL / notional * 100
+	}
+
+	if config.MaxHold > 0 && held > config.MaxHold {
+		return TimeExitDecision{Close: true, Reason: CloseReasonMaxHold,
+			Details: fmt.Sprintf("held %s, max %s", held.Round(time.Minute), config.MaxHold)}
+	}
+	if config.StaleAfter > 0 && held > config.StaleAfter && math.Abs(pnlPercent) <= config.StaleBandPercent {
+		return TimeExitDecision{Close: true, Reason: CloseReasonStale,
+			Details: fmt.Sprintf("P/L %.2f%% within ±%.2f%% after %s", pnlPercent, config.StaleBandPercent, held.Round(time.Minute))}
+	}
+
+	for _, window := range config.DeRisk {
+		since, active := window.activeSince(now)
+		if !active {
+			continue
+		}
+		switch window.Action {
+		case DeRiskClose:
+			return TimeExitDecision{Close: true, Reason: CloseReasonSessionClose,
+				Details: fmt.Sprintf("%s window since %s", window.Name, since.Format("Mon 15:04"))}
+		case DeRiskTighten:
+			if window.StopLossPercent > 0 && pnlPercent <= -window.StopLossPercent {
+				return TimeExitDecision{Close: true, Reason: CloseReasonSessionStop,
+					Details: fmt.Sprintf("P/L %.2f%% hit the %s stop of -%.2f%%", pnlPercent, window.Name, window.StopLossPercent)}
+			}
+		case DeRiskReduce:
+			reduced := !deRiskedAt.Before(since) && !deRiskedAt.Before(openedAt)
+			if !reduced && window.ReduceFraction > 0 && window.ReduceFraction < 1 {
+				return TimeExitDecision{Reduce: window.ReduceFraction, Reason: DeRiskReasonSessionReduce,
+					Details: fmt.Sprintf("%s window since %s, reducing %.0f%%", window.Name, since.Format("Mon 15:04"), window.ReduceFraction*100)}
+			}
+		}
+	}
+	return TimeExitDecision{}
+}
+
+// applyTimeExits runs the pair time rules on the live position. Positions of
+// the FUD state machine are left to its own hold limits.
+func applyTimeExits(ctx *StrategyContext, position Position) {
+	pair := ctx.Pair
+	state := ctx.State
+	if pair.TimeExit == nil || state.CurrentPosition == PositionSideBoth || (state.FudState != "" && state.FudState != FudStateIdle) {
+		return
+	}
+
+	decision := EvaluateTimeExit(*pair.TimeExit, position, state.OpenedAt, state.TimeExitDeRiskedAt, ctx.Now)
+	switch {
+	case decision.Close:
+		log.Printf("[%s] ⏱️ Time exit %s: %s", pair.Symbol, decision.Reason, decision.Details)
+		if _, _, err := closeStatePosition(ctx.Exchange, pair, state, state.CurrentPosition, decision.Reason); err != nil {
+			log.Printf("[%s] Failed to close on time exit: %v", pair.Symbol, err)
+		}
+	case decision.Reduce > 0:
+		quantity := reduceQuantity(pair, position.Amount, decision.Reduce)
+		if quantity <= 0 {
+			log.Printf("[%s] ⏱️ Time de-risk skipped, position too small to reduce: %s", pair.Symbol, decision.Details)
+			return
+		}
+		log.Printf("[%s] ⏱️ Time de-risk: %s", pair.Symbol, decision.Details)
+		if err := ctx.Exchange.ReducePosition(pair.Symbol, state.CurrentPosition, quantity); err != nil {
+			log.Printf("[%s] Failed to reduce position: %v", pair.Symbol, err)
+			return
+		}
+		state.TimeExitDeRiskedAt = ctx.Now
+		if err := UpdatePositionDeRisk(state.PositionUUID, decision.Reason, quantity); err != nil {
+			log.Printf("[%s] Failed to record position de-risk: %v", pair.Symbol, err)
+		}
+	}
+}
+
+// applyVirtualTimeExits closes shadow and simulated positions on the time
+// rules. Reductions are not replayed, the virtual positions keep their size.
+func applyVirtualTimeExits(ctx *StrategyContext, position Position, closePosition func(reason string)) {
+	if ctx.Pair.TimeExit == nil || ctx.State.CurrentPosition == PositionSideBoth {
+		return
+	}
+	decision := EvaluateTimeExit(*ctx.Pair.TimeExit, position, ctx.State.OpenedAt, time.Time{}, ctx.Now)
+	if decision.Close {
+		closePosition(decision.Reason)
+	}
+}
+
+// quantityStep is the lot step of a pair, QuantityStep or else one unit of the
+// last decimal of Quantity, e.g. 0.1 for 0.2 and 1 for 150.
+func quantityStep(pair TradingPair) float64 {
+	if pair.QuantityStep > 0 {
+		return pair.QuantityStep
+	}
+	if pair.Quantity <= 0 {
+		return 0.01
+	}
+	return math.Pow(10, -float64(decimalPlaces(pair.Quantity)))
+}
+
+// reduceQuantity is fraction of the position amount rounded down to the pair
+// lot step, 0 when less than one step is left.
+func reduceQuantity(pair TradingPair, amount, fraction float64) float64 {
+	step := quantityStep(pair)
+	steps := math.Floor(math.Abs(amount)*fraction/step + 1e-9)
+	if steps <= 0 {
+		return 0
+	}
+	quantity, _ := strconv.ParseFloat(strconv.FormatFloat(steps*step, 'f', decimalPlaces(step), 64), 64)
+	return quantity
+}
+
+func decimalPlaces(value float64) int {
+	formatted := strconv.FormatFloat(value, 'f', -1, 64)
+	if dot := strings.IndexByte(formatted, '.'); dot >= 0 {
+		return len(formatted) - dot - 1
+	}
+	return 0
+}
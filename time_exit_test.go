@@ -0,0 +1,148 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeRiskWindow_ActiveSince(t *testing.T) {
+	friday := time.Date(2025, 10, 10, 0, 0, 0, 0, time.UTC)
+	weekendStart := friday.Add(20 * time.Hour)
+	night := DeRiskWindow{Name: "night", Daily: true, StartHour: 22, EndHour: 2}
+
+	tests := []struct {
+		name   string
+		window DeRiskWindow
+		now    time.Time
+		active bool
+		since  time.Time
+	}{
+		{"before the weekend", WeekendDeRisk(), weekendStart.Add(-time.Minute), false, time.Time{}},
+		{"weekend starts", WeekendDeRisk(), weekendStart, true, weekendStart},
+		{"saturday", WeekendDeRisk(), friday.Add(36*time.Hour + 30*time.Minute), true, weekendStart},
+		{"sunday night wraps past the week start", WeekendDeRisk(), friday.Add(3*24*time.Hour - time.Minute), true, weekendStart},
+		{"monday midnight ends it", WeekendDeRisk(), friday.Add(3 * 24 * time.Hour), false, time.Time{}},
+		{"wednesday", WeekendDeRisk(), friday.Add(-2 * 24 * time.Hour), false, time.Time{}},
+		{"other time zones are read as UTC", WeekendDeRisk(), weekendStart.In(time.FixedZone("UTC+3", 3*3600)), true, weekendStart},
+		{"daily window before midnight", night, friday.Add(23 * time.Hour), true, friday.Add(22 * time.Hour)},
+		{"daily window after midnight", night, friday.Add(25 * time.Hour), true, friday.Add(22 * time.Hour)},
+		{"daily window end", night, friday.Add(26 * time.Hour), false, time.Time{}},
+		{"daily window ignores days", night, friday.Add(-24*time.Hour + 22*time.Hour), true, friday.Add(-2 * time.Hour)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			since, active := tt.window.activeSince(tt.now)
+			assert.Equal(t, tt.active, active)
+			assert.True(t, tt.since.Equal(since), "since %s, want %s", since, tt.since)
+		})
+	}
+}
+
+func TestEvaluateTimeExit(t *testing.T) {
+	saturday := time.Date(2025, 10, 11, 12, 0, 0, 0, time.UTC)
+	weekendStart := time.Date(2025, 10, 10, 20, 0, 0, 0, time.UTC)
+	long := func(pnl float64) Position {
+		return Position{Side: PositionSideLong, EntryPrice: 100, Amount: 10, UnrealizedPL: pnl}
+	}
+	short := func(pnl float64) Position {
+		return Position{Side: PositionSideShort, EntryPrice: 100, Amount: -10, UnrealizedPL: pnl}
+	}
+	stale := TimeExitConfig{MaxHold: 72 * time.Hour, StaleAfter: 12 * time.Hour, StaleBandPercent: 1}
+	weekend := TimeExitConfig{DeRisk: []DeRiskWindow{WeekendDeRisk()}}
+	closeWindow := WeekendDeRisk()
+	closeWindow.Action = DeRiskClose
+	stopWindow := WeekendDeRisk()
+	stopWindow.Action = DeRiskTighten
+	stopWindow.StopLossPercent = 2
+
+	tests := []struct {
+		name       string
+		config     TimeExitConfig
+		position   Position
+		held       time.Duration
+		deRiskedAt time.Time
+		want       TimeExitDecision
+	}{
+		{"no rules", TimeExitConfig{}, long(0), 1000 * time.Hour, time.Time{}, TimeExitDecision{}},
+		{"max hold before stale", stale, long(0), 73 * time.Hour, time.Time{}, TimeExitDecision{Close: true, Reason: CloseReasonMaxHold}},
+		{"stale long", stale, long(5), 13 * time.Hour, time.Time{}, TimeExitDecision{Close: true, Reason: CloseReasonStale}},
+		{"stale short uses the absolute amount", stale, short(-8), 13 * time.Hour, time.Time{}, TimeExitDecision{Close: true, Reason: CloseReasonStale}},
+		{"moving short is not stale", stale, short(15), 13 * time.Hour, time.Time{}, TimeExitDecision{}},
+		{"too early to be stale", stale, long(0), 11 * time.Hour, time.Time{}, TimeExitDecision{}},
+		{"weekend reduce", weekend, long(0), 24 * time.Hour, time.Time{}, TimeExitDecision{Reduce: 0.5, Reason: DeRiskReasonSessionReduce}},
+		{"reduced once per window", weekend, long(0), 24 * time.Hour, weekendStart.Add(time.Hour), TimeExitDecision{}},
+		{"reduced last weekend", weekend, long(0), 10 * 24 * time.Hour, weekendStart.Add(-7 * 24 * time.Hour), TimeExitDecision{Reduce: 0.5, Reason: DeRiskReasonSessionReduce}},
+		{"reduction of an earlier position", weekend, long(0), time.Hour, weekendStart.Add(time.Hour), TimeExitDecision{Reduce: 0.5, Reason: DeRiskReasonSessionReduce}},
+		{"weekend close", TimeExitConfig{DeRisk: []DeRiskWindow{closeWindow}}, long(50), time.Hour, time.Time{}, TimeExitDecision{Close: true, Reason: CloseReasonSessionClose}},
+		{"weekend stop hit", TimeExitConfig{DeRisk: []DeRiskWindow{stopWindow}}, short(-25), time.Hour, time.Time{}, TimeExitDecision{Close: true, Reason: CloseReasonSessionStop}},
+		{"weekend stop not hit", TimeExitConfig{DeRisk: []DeRiskWindow{stopWindow}}, short(-15), time.Hour, time.Time{}, TimeExitDecision{}},
+		{"stale before the window", TimeExitConfig{StaleAfter: time.Hour, StaleBandPercent: 1, DeRisk: []DeRiskWindow{closeWindow}}, long(0), 2 * time.Hour, time.Time{}, TimeExitDecision{Close: true, Reason: CloseReasonStale}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decision := EvaluateTimeExit(tt.config, tt.position, saturday.Add(-tt.held), tt.deRiskedAt, saturday)
+			assert.Equal(t, tt.want.Close, decision.Close, decision.Details)
+			assert.Equal(t, tt.want.Reduce, decision.Reduce, decision.Details)
+			assert.Equal(t, tt.want.Reason, decision.Reason)
+		})
+	}
+
+	outside := EvaluateTimeExit(weekend, long(0), saturday.Add(-24*time.Hour), time.Time{}, saturday.Add(-3*24*time.Hour))
+	assert.Equal(t, TimeExitDecision{}, outside, "no de-risking outside the window")
+}
+
+func TestReduceQuantity(t *testing.T) {
+	tests := []struct {
+		name     string
+		pair     TradingPair
+		amount   float64
+		fraction float64
+		quantity float64
+	}{
+		{"whole units", TradingPair{Quantity: 150}, 150, 0.5, 75},
+		{"rounded down to whole units", TradingPair{Quantity: 150}, 75, 0.5, 37},
+		{"short amount", TradingPair{Quantity: 22000}, -22001, 0.5, 11000},
+		{"one decimal", TradingPair{Quantity: 0.2}, 0.7, 0.5, 0.3},
+		{"less than a step", TradingPair{Quantity: 0.2}, 0.1, 0.5, 0},
+		{"less than one unit", TradingPair{Quantity: 10}, 1, 0.5, 0},
+		{"explicit step", TradingPair{Quantity: 10, QuantityStep: 0.25}, 3, 0.5, 1.5},
+		{"explicit step rounds down", TradingPair{Quantity: 10, QuantityStep: 0.25}, 3.4, 0.5, 1.5},
+		{"no quantity keeps two decimals", TradingPair{}, 0.125, 0.5, 0.06},
+		{"float noise at a step boundary", TradingPair{Quantity: 0.1}, 1.4, 0.5, 0.7},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.quantity, reduceQuantity(tt.pair, tt.amount, tt.fraction))
+		})
+	}
+}
+
+func TestApplyTimeExits_ReduceRoundsToTheLotStep(t *testing.T) {
+	openTestDatabase(t, "time_exit_reduce")
+	saturday := time.Date(2025, 10, 11, 12, 0, 0, 0, time.UTC)
+	pair := scenarioPair()
+	pair.TimeExit = &TimeExitConfig{DeRisk: []DeRiskWindow{WeekendDeRisk()}}
+
+	reduce := func(amount float64) (*scenarioExchange, *TradingState) {
+		exchange := newScenarioExchange()
+		exchange.SetPrice(pair.Symbol, 100, saturday)
+		_, err := exchange.SimulatedExchange.OpenPosition(pair.Symbol, PositionSideLong, 1, amount)
+		require.NoError(t, err)
+		state := &TradingState{CurrentPosition: PositionSideLong, OpenedAt: saturday.Add(-24 * time.Hour)}
+		ctx := &StrategyContext{Now: saturday, Pair: pair, State: state, Exchange: exchange}
+		applyTimeExits(ctx, Position{Symbol: pair.Symbol, Side: PositionSideLong, EntryPrice: 100, Amount: amount})
+		return exchange, state
+	}
+
+	exchange, state := reduce(7)
+	require.Len(t, exchange.orders, 1)
+	assert.Equal(t, 3.0, exchange.orders[0].Quantity, "half of 7 rounded down to the whole units of Quantity 10")
+	assert.Equal(t, saturday, state.TimeExitDeRiskedAt)
+
+	exchange, state = reduce(1)
+	assert.Empty(t, exchange.orders, "half a unit rounds to nothing")
+	assert.True(t, state.TimeExitDeRiskedAt.IsZero())
+}
@@ -1,5 +1,7 @@
 package main
 
+import "fmt"
+
 type Signal string
 
 const (
@@ -16,7 +18,29 @@ type TradingDecisionResult struct {
 	CoinIchimokuSignal string
 	ActivitySignal     string
 	FudActivitySignal  string
+	FudShareSignal     string
 	SentimentSignal    string
+	RegimeSignal       string
+	Coupling           string
+	BTCCorrelation     float64
+	BTCBeta            float64
+}
+
+// DecisionParams are the tunable thresholds of the decision chain.
+type DecisionParams struct {
+	SentimentShortBelow int
+	// BTCLedEntries trades the BTC signal when it contradicts a coin coupled
+	// to BTC, instead of only vetoing the coin signal.
+	BTCLedEntries bool
+	// FudShareGate lets the FUD share signal confirm or veto the chain.
+	// Without it the FUD share is only recorded on the decision.
+	FudShareGate bool
+}
+
+func DefaultDecisionParams() DecisionParams {
+	return DecisionParams{
+		SentimentShortBelow: 3,
+	}
 }
 
 func MakeTradingDecision(
@@ -24,7 +48,12 @@ func MakeTradingDecision(
 	coinIchimoku IchimokuAnalysis,
 	activityAnalysis ActivityAnalysis,
 	fudActivityAnalysis ActivityAnalysis,
+	fudShare FudShareAnalysis,
 	sentimentAnalysis ClaudeSentimentResponse,
+	regime RegimeAnalysis,
+	regimeRule RegimeRule,
+	correlation CorrelationAnalysis,
+	params DecisionParams,
 ) TradingDecisionResult {
 
 	btcSignal := convertIchimokuToSignal(btcIchimoku)
@@ -33,6 +62,10 @@ func MakeTradingDecision(
 	result := TradingDecisionResult{
 		BTCIchimokuSignal:  string(btcSignal),
 		CoinIchimokuSignal: string(coinSignal),
+		RegimeSignal:       string(regime.Regime),
+		Coupling:           string(correlation.Coupling),
+		BTCCorrelation:     correlation.BTCCorrelation,
+		BTCBeta:            correlation.BTCBeta,
 	}
 
 	signal := SignalEmpty
@@ -49,8 +82,26 @@ func MakeTradingDecision(
 			reason = "ichimoku"
 			explanation = "BTC and Coin Ichimoku aligned: " + string(coinSignal)
 		} else {
-			signal = SignalEmpty
-			explanation = "BTC Ichimoku " + string(btcSignal) + " contradicts Coin Ichimoku " + string(coinSignal)
+			switch correlation.Coupling {
+			case CouplingHigh:
+				reason = "btc_correlation"
+				if params.BTCLedEntries {
+					signal = btcSignal
+					explanation = fmt.Sprintf("BTC Ichimoku %s contradicts Coin Ichimoku %s, coin is coupled to BTC (correlation %.2f, beta %.2f) - BTC dominates",
+						btcSignal, coinSignal, correlation.BTCCorrelation, correlation.BTCBeta)
+				} else {
+					explanation = fmt.Sprintf("BTC Ichimoku %s contradicts Coin Ichimoku %s, coin is coupled to BTC (correlation %.2f, beta %.2f) - BTC vetoes the entry",
+						btcSignal, coinSignal, correlation.BTCCorrelation, correlation.BTCBeta)
+				}
+			case CouplingDecoupled:
+				signal = coinSignal
+				reason = "ichimoku"
+				explanation = fmt.Sprintf("BTC Ichimoku %s contradicts Coin Ichimoku %s, coin is decoupled from BTC (correlation %.2f) - coin dominates",
+					btcSignal, coinSignal, correlation.BTCCorrelation)
+			default:
+				signal = SignalEmpty
+				explanation = "BTC Ichimoku " + string(btcSignal) + " contradicts Coin Ichimoku " + string(coinSignal)
+			}
 		}
 	} else {
 		explanation = "Both BTC and Coin Ichimoku neutral"
@@ -58,11 +109,12 @@ func MakeTradingDecision(
 
 	if signal == SignalEmpty {
 		result.Signal = SignalEmpty
-		result.Reason = ""
+		result.Reason = reason
 		result.Explanation = explanation
 		result.ActivitySignal = string(convertActivityToSignal(activityAnalysis))
 		result.FudActivitySignal = string(convertFudActivityToSignal(fudActivityAnalysis))
-		result.SentimentSignal = string(convertSentimentToSignal(sentimentAnalysis))
+		result.FudShareSignal = string(convertFudShareToSignal(fudShare))
+		result.SentimentSignal = string(convertSentimentToSignal(sentimentAnalysis, params.SentimentShortBelow))
 		return result
 	}
 
@@ -86,7 +138,8 @@ func MakeTradingDecision(
 		result.Reason = ""
 		result.Explanation = explanation
 		result.FudActivitySignal = string(convertFudActivityToSignal(fudActivityAnalysis))
-		result.SentimentSignal = string(convertSentimentToSignal(sentimentAnalysis))
+		result.FudShareSignal = string(convertFudShareToSignal(fudShare))
+		result.SentimentSignal = string(convertSentimentToSignal(sentimentAnalysis, params.SentimentShortBelow))
 		return result
 	}
 
@@ -109,11 +162,37 @@ func MakeTradingDecision(
 		result.Signal = SignalEmpty
 		result.Reason = ""
 		result.Explanation = explanation
-		result.SentimentSignal = string(convertSentimentToSignal(sentimentAnalysis))
+		result.FudShareSignal = string(convertFudShareToSignal(fudShare))
+		result.SentimentSignal = string(convertSentimentToSignal(sentimentAnalysis, params.SentimentShortBelow))
 		return result
 	}
 
-	sentimentSignal := convertSentimentToSignal(sentimentAnalysis)
+	fudShareSignal := convertFudShareToSignal(fudShare)
+	result.FudShareSignal = string(fudShareSignal)
+
+	if !params.FudShareGate {
+		explanation += ". FUD share " + string(fudShareSignal) + " recorded, not gating"
+	} else if fudShareSignal == SignalEmpty {
+		explanation += ". FUD share neutral"
+	} else if signal == fudShareSignal {
+		signal = fudShareSignal
+		reason = "fud_share"
+		explanation += ". FUD share confirms: " + string(fudShareSignal)
+	} else {
+		signal = SignalEmpty
+		reason = ""
+		explanation += ". FUD share " + string(fudShareSignal) + " contradicts signal"
+	}
+
+	if signal == SignalEmpty {
+		result.Signal = SignalEmpty
+		result.Reason = ""
+		result.Explanation = explanation
+		result.SentimentSignal = string(convertSentimentToSignal(sentimentAnalysis, params.SentimentShortBelow))
+		return result
+	}
+
+	sentimentSignal := convertSentimentToSignal(sentimentAnalysis, params.SentimentShortBelow)
 	result.SentimentSignal = string(sentimentSignal)
 
 	if sentimentSignal == SignalEmpty {
@@ -128,6 +207,18 @@ func MakeTradingDecision(
 		explanation += ". Sentiment " + string(sentimentSignal) + " contradicts signal"
 	}
 
+	if signal != SignalEmpty {
+		if !regimeRule.AllowEntries {
+			explanation += ". Regime " + string(regime.Regime) + " blocks new entries"
+			signal = SignalEmpty
+			reason = ""
+		} else if (signal == SignalLong && !regimeRule.AllowLong) || (signal == SignalShort && !regimeRule.AllowShort) {
+			explanation += ". Regime " + string(regime.Regime) + " blocks " + string(signal) + " entries"
+			signal = SignalEmpty
+			reason = ""
+		}
+	}
+
 	result.Signal = signal
 	result.Reason = reason
 	result.Explanation = explanation
@@ -163,8 +254,15 @@ func convertFudActivityToSignal(analysis ActivityAnalysis) Signal {
 	return SignalEmpty
 }
 
-func convertSentimentToSignal(analysis ClaudeSentimentResponse) Signal {
-	if analysis.SentimentTrend == "declining" && analysis.OverallSentiment < 3 {
+func convertFudShareToSignal(analysis FudShareAnalysis) Signal {
+	if analysis.Elevated {
+		return SignalShort
+	}
+	return SignalEmpty
+}
+
+func convertSentimentToSignal(analysis ClaudeSentimentResponse, shortBelow int) Signal {
+	if analysis.SentimentTrend == "declining" && analysis.OverallSentiment < shortBelow {
 		return SignalShort
 	}
 	return SignalEmpty
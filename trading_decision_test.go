@@ -0,0 +1,90 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMakeTradingDecision_BTCCorrelation(t *testing.T) {
+	btcLong := IchimokuAnalysis{Signal: IchimokuSignalLong}
+	coinShort := IchimokuAnalysis{Signal: IchimokuSignalShort}
+	btcLed := DefaultDecisionParams()
+	btcLed.BTCLedEntries = true
+
+	tests := []struct {
+		name     string
+		btc      IchimokuAnalysis
+		coupling CorrelationCoupling
+		params   DecisionParams
+		signal   Signal
+		reason   string
+	}{
+		{"coupled coin is vetoed by BTC", btcLong, CouplingHigh, DefaultDecisionParams(), SignalEmpty, "btc_correlation"},
+		{"coupled coin follows BTC when BTC-led entries are on", btcLong, CouplingHigh, btcLed, SignalLong, "btc_correlation"},
+		{"partially coupled coin skips the entry", btcLong, CouplingPartial, DefaultDecisionParams(), SignalEmpty, ""},
+		{"decoupled coin trades its own signal", btcLong, CouplingDecoupled, DefaultDecisionParams(), SignalShort, "ichimoku"},
+		{"agreeing BTC ignores the coupling", IchimokuAnalysis{Signal: IchimokuSignalShort}, CouplingHigh, DefaultDecisionParams(), SignalShort, "ichimoku"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			correlation := CorrelationAnalysis{Coupling: tt.coupling, BTCCorrelation: 0.8, BTCBeta: 1.2}
+			decision := MakeTradingDecision(tt.btc, coinShort, ActivityAnalysis{}, ActivityAnalysis{}, FudShareAnalysis{}, ClaudeSentimentResponse{},
+				RegimeAnalysis{Regime: MarketRegimeRanging}, DefaultRegimeRule(), correlation, tt.params)
+			assert.Equal(t, tt.signal, decision.Signal, decision.Explanation)
+			assert.Equal(t, tt.reason, decision.Reason)
+			assert.Equal(t, string(tt.coupling), decision.Coupling)
+		})
+	}
+}
+
+func TestMakeTradingDecision_RegimeRules(t *testing.T) {
+	coinLong := IchimokuAnalysis{Signal: IchimokuSignalLong}
+	decide := func(regime MarketRegime, rule RegimeRule) TradingDecisionResult {
+		return MakeTradingDecision(IchimokuAnalysis{}, coinLong, ActivityAnalysis{}, ActivityAnalysis{}, FudShareAnalysis{}, ClaudeSentimentResponse{},
+			RegimeAnalysis{Regime: regime}, rule, CorrelationAnalysis{}, DefaultDecisionParams())
+	}
+
+	assert.Equal(t, SignalLong, decide(MarketRegimeRanging, DefaultRegimeRule()).Signal, "the default rule never blocks")
+
+	strict := StrictRegimeRules()
+	blocked := decide(MarketRegimeRanging, strict[MarketRegimeRanging])
+	assert.Equal(t, SignalEmpty, blocked.Signal)
+	assert.Contains(t, blocked.Explanation, "Regime RANGING blocks new entries")
+	assert.Equal(t, SignalEmpty, decide(MarketRegimeTrendingDown, strict[MarketRegimeTrendingDown]).Signal)
+	assert.Equal(t, SignalLong, decide(MarketRegimeTrendingUp, strict[MarketRegimeTrendingUp]).Signal)
+}
+
+func TestMakeTradingDecision_FudShareGate(t *testing.T) {
+	gated := DefaultDecisionParams()
+	gated.FudShareGate = true
+
+	tests := []struct {
+		name     string
+		coin     IchimokuSignal
+		elevated bool
+		params   DecisionParams
+		signal   Signal
+		reason   string
+	}{
+		{"elevated share is only recorded by default", IchimokuSignalLong, true, DefaultDecisionParams(), SignalLong, "ichimoku"},
+		{"recorded share does not confirm a short", IchimokuSignalShort, true, DefaultDecisionParams(), SignalShort, "ichimoku"},
+		{"gate vetoes a contradicted long", IchimokuSignalLong, true, gated, SignalEmpty, ""},
+		{"gate confirms a short", IchimokuSignalShort, true, gated, SignalShort, "fud_share"},
+		{"neutral share passes the gate", IchimokuSignalLong, false, gated, SignalLong, "ichimoku"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decision := MakeTradingDecision(IchimokuAnalysis{}, IchimokuAnalysis{Signal: tt.coin}, ActivityAnalysis{}, ActivityAnalysis{},
+				FudShareAnalysis{Elevated: tt.elevated}, ClaudeSentimentResponse{},
+				RegimeAnalysis{Regime: MarketRegimeRanging}, DefaultRegimeRule(), CorrelationAnalysis{}, tt.params)
+			assert.Equal(t, tt.signal, decision.Signal, decision.Explanation)
+			assert.Equal(t, tt.reason, decision.Reason)
+			expected := SignalEmpty
+			if tt.elevated {
+				expected = SignalShort
+			}
+			assert.Equal(t, string(expected), decision.FudShareSignal, "the FUD share is recorded either way")
+		})
+	}
+}
@@ -0,0 +1,448 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/grutapig/fudtradebot/claude"
+)
+
+// TweetAnalysisMode selects per pair how the in-house tweet analysis is used
+// next to the external sentiment and FUD-alert service.
+type TweetAnalysisMode string
+
+const (
+	TweetAnalysisOff      TweetAnalysisMode = ""
+	TweetAnalysisPrimary  TweetAnalysisMode = "primary"
+	TweetAnalysisFallback TweetAnalysisMode = "fallback"
+	TweetAnalysisShadow   TweetAnalysisMode = "shadow"
+)
+
+var ErrTweetAnalysisRateLimited = errors.New("tweet analysis rate limited")
+
+type TweetAnalysisConfig struct {
+	TweetLimit int
+	// CacheTTL is how long an analysis is reused. It is also reused while the
+	// community has no newer tweet.
+	CacheTTL time.Duration
+	// MinInterval between LLM calls for one community, MaxCallsPerHour
+	// across all communities.
+	MinInterval     time.Duration
+	MaxCallsPerHour int
+}
+
+func DefaultTweetAnalysisConfig() TweetAnalysisConfig {
+	return TweetAnalysisConfig{
+		TweetLimit:      200,
+		CacheTTL:        30 * time.Minute,
+		MinInterval:     15 * time.Minute,
+		MaxCallsPerHour: 12,
+	}
+}
+
+// tweetAnalysis is one LLM reading of a community's recent tweets.
+type tweetAnalysis struct {
+	At            time.Time
+	NewestTweetID string
+	Sentiment     ClaudeSentimentResponse
+	FudAttack     ClaudeFudAttackResponse
+}
+
+// TweetAnalyzer builds the sentiment and FUD-attack answers from the raw
+// community tweets with the prompt in prompt_analyze.txt. One LLM call
+// answers both questions.
+type TweetAnalyzer struct {
+	tweets TweetSource
+	llm    claude.LLM
+	prompt string
+	config TweetAnalysisConfig
+	now    func() time.Time
+
+	mu       sync.Mutex
+	cache    map[string]tweetAnalysis
+	lastCall map[string]time.Time
+	calls    []time.Time
+}
+
+func NewTweetAnalyzer(tweets TweetSource, llm claude.LLM, config TweetAnalysisConfig) (*TweetAnalyzer, error) {
+	prompt, err := os.ReadFile(PROMPT_FILE_ANALYZE)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read analysis prompt: %w", err)
+	}
+	defaults := DefaultTweetAnalysisConfig()
+	if config.TweetLimit <= 0 {
+		config.TweetLimit = defaults.TweetLimit
+	}
+	if config.CacheTTL <= 0 {
+		config.CacheTTL = defaults.CacheTTL
+	}
+	if config.MinInterval <= 0 {
+		config.MinInterval = defaults.MinInterval
+	}
+	if config.MaxCallsPerHour <= 0 {
+		config.MaxCallsPerHour = defaults.MaxCallsPerHour
+	}
+	return &TweetAnalyzer{
+		tweets:   tweets,
+		llm:      llm,
+		prompt:   string(prompt),
+		config:   config,
+		now:      time.Now,
+		cache:    make(map[string]tweetAnalysis),
+		lastCall: make(map[string]time.Time),
+	}, nil
+}
+
+func (a *TweetAnalyzer) FetchSentiment(ctx context.Context, communityID string) (ClaudeSentimentResponse, error) {
+	analysis, err := a.Analyze(ctx, communityID)
+	if err != nil {
+		return ClaudeSentimentResponse{}, err
+	}
+	return analysis.Sentiment, nil
+}
+
+func (a *TweetAnalyzer) FetchFudAttack(ctx context.Context, communityID string) (ClaudeFudAttackResponse, error) {
+	analysis, err := a.Analyze(ctx, communityID)
+	if err != nil {
+		return ClaudeFudAttackResponse{}, err
+	}
+	return analysis.FudAttack, nil
+}
+
+// Analyze returns the cached analysis of a community while it is fresh or
+// no newer tweet arrived, otherwise asks the LLM. When the rate limit is hit
+// an older analysis is served if there is one.
+func (a *TweetAnalyzer) Analyze(ctx context.Context, communityID string) (tweetAnalysis, error) {
+	now := a.now()
+
+	a.mu.Lock()
+	cached, hasCached := a.cache[communityID]
+	a.mu.Unlock()
+	if hasCached && now.Sub(cached.At) < a.config.CacheTTL {
+		return cached, nil
+	}
+
+	tweets, err := a.tweets.GetRecentTweets(communityID, a.config.TweetLimit)
+	if err != nil {
+		return tweetAnalysis{}, fmt.Errorf("failed to get tweets: %w", err)
+	}
+	if len(tweets) == 0 {
+		return tweetAnalysis{}, fmt.Errorf("no tweets for community %s", communityID)
+	}
+	sort.Slice(tweets, func(i, j int) bool { return compareTweetIDs(tweets[i].ID, tweets[j].ID) > 0 })
+	newestID := tweets[0].ID
+
+	a.mu.Lock()
+	if hasCached && cached.NewestTweetID == newestID {
+		cached.At = now
+		a.cache[communityID] = cached
+		a.mu.Unlock()
+		return cached, nil
+	}
+	if err := a.reserveCall(communityID, now); err != nil {
+		a.mu.Unlock()
+		if hasCached {
+			log.Printf("Tweet analysis for %s: %v, serving the analysis from %v ago", communityID, err, now.Sub(cached.At).Round(time.Minute))
+			return cached, nil
+		}
+		return tweetAnalysis{}, err
+	}
+	a.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return tweetAnalysis{}, err
+	}
+	sentiment, fudAttack, err := a.ask(ctx, communityID, tweets, now)
+	if err != nil {
+		return tweetAnalysis{}, err
+	}
+
+	analysis := tweetAnalysis{At: now, NewestTweetID: newestID, Sentiment: sentiment, FudAttack: fudAttack}
+	a.mu.Lock()
+	a.cache[communityID] = analysis
+	a.mu.Unlock()
+	return analysis, nil
+}
+
+// reserveCall books an LLM call for communityID or reports the limit it
+// would break. The caller holds a.mu.
+func (a *TweetAnalyzer) reserveCall(communityID string, now time.Time) error {
+	if last, ok := a.lastCall[communityID]; ok && now.Sub(last) < a.config.MinInterval {
+		return fmt.Errorf("%w: last call %v ago, minimum interval %v", ErrTweetAnalysisRateLimited, now.Sub(last).Round(time.Second), a.config.MinInterval)
+	}
+	recent := a.calls[:0]
+	for _, call := range a.calls {
+		if now.Sub(call) < time.Hour {
+			recent = append(recent, call)
+		}
+	}
+	a.calls = recent
+	if len(a.calls) >= a.config.MaxCallsPerHour {
+		return fmt.Errorf("%w: %d calls in the last hour", ErrTweetAnalysisRateLimited, len(a.calls))
+	}
+	a.calls = append(a.calls, now)
+	a.lastCall[communityID] = now
+	return nil
+}
+
+type tweetAnalysisResponse struct {
+	Sentiment ClaudeSentimentResponse `json:"sentiment"`
+	FudAttack struct {
+		ClaudeFudAttackResponse
+		LastAttackTweetID string `json:"last_attack_tweet_id"`
+	} `json:"fud_attack"`
+}
+
+func (a *TweetAnalyzer) ask(ctx context.Context, communityID string, tweets []CommunityTweet, now time.Time) (ClaudeSentimentResponse, ClaudeFudAttackResponse, error) {
+	tweetsJSON, err := json.Marshal(tweets)
+	if err != nil {
+		return ClaudeSentimentResponse{}, ClaudeFudAttackResponse{}, fmt.Errorf("failed to marshal tweets: %w", err)
+	}
+
+	userMessage := fmt.Sprintf("Community %s, current time %s. Latest %d tweets, newest first:\n\n%s",
+		communityID, now.UTC().Format(time.RFC3339), len(tweets), string(tweetsJSON))
+	request := newLLMRequest(LLMPurposeSentiment, a.prompt, userMessage, tweetAnalysisSchema)
+	parsed, _, err := claude.CompleteJSON[tweetAnalysisResponse](ctx, a.llm, request)
+	if err != nil {
+		return ClaudeSentimentResponse{}, ClaudeFudAttackResponse{}, fmt.Errorf("tweet analysis failed: %w", err)
+	}
+
+	sentiment := parsed.Sentiment
+	sentiment.OverallSentiment = clampInt(sentiment.OverallSentiment, 0, 10)
+	sentiment.FudLevel = clampInt(sentiment.FudLevel, 0, 10)
+	sentiment.Confidence = clampFloat(sentiment.Confidence, 0, 1)
+	if sentiment.KeyThemes == nil {
+		sentiment.KeyThemes = []string{}
+	}
+
+	fudAttack := parsed.FudAttack.ClaudeFudAttackResponse
+	fudAttack.Confidence = clampFloat(fudAttack.Confidence, 0, 1)
+	// Tweets carry no authors, so the registry has no participants to weigh.
+	fudAttack.Participants = []FudAttackParticipant{}
+	fudAttack.ParticipantsUnknown = true
+	fudAttack.LastAttackTime = nil
+	if fudAttack.HasAttack {
+		fudAttack.LastAttackTime = lastAttackTime(tweets, parsed.FudAttack.LastAttackTweetID)
+	}
+	return sentiment, fudAttack, nil
+}
+
+// lastAttackTime is the date of the tweet the LLM named as the newest of the
+// attack, or of the newest FUD-labelled tweet when it named none we know.
+func lastAttackTime(tweets []CommunityTweet, tweetID string) *time.Time {
+	for _, tweet := range tweets {
+		if tweetID != "" && tweet.ID == tweetID {
+			date := tweet.Date
+			return &date
+		}
+	}
+	for _, tweet := range tweets {
+		if tweet.IsFud {
+			date := tweet.Date
+			return &date
+		}
+	}
+	return nil
+}
+
+// compareTweetIDs orders numeric tweet IDs, which are too long for int64
+// comparisons to be safe across sources.
+func compareTweetIDs(a, b string) int {
+	a = strings.TrimLeft(a, "0")
+	b = strings.TrimLeft(b, "0")
+	if len(a) != len(b) {
+		if len(a) < len(b) {
+			return -1
+		}
+		return 1
+	}
+	return strings.Compare(a, b)
+}
+
+func clampInt(value, low, high int) int {
+	return max(low, min(high, value))
+}
+
+func clampFloat(value, low, high float64) float64 {
+	return max(low, min(high, value))
+}
+
+// PairAnalysisProvider combines the external service with the tweet analysis
+// for a pair in fallback or shadow mode.
+type PairAnalysisProvider struct {
+	pair     TradingPair
+	external ExternalAnalysisProvider
+	local    *TweetAnalyzer
+}
+
+// NewPairAnalysisProvider returns the provider of a pair according to
+// TradingPair.TweetAnalysis. Without a tweet analyzer every pair uses the
+// external service.
+func NewPairAnalysisProvider(pair TradingPair, external ExternalAnalysisProvider, local *TweetAnalyzer) ExternalAnalysisProvider {
+	switch pair.TweetAnalysis {
+	case TweetAnalysisOff:
+		return external
+	case TweetAnalysisPrimary, TweetAnalysisFallback, TweetAnalysisShadow:
+	default:
+		log.Printf("[%s] Unknown tweet analysis mode %q, using the external service", pair.Symbol, pair.TweetAnalysis)
+		return external
+	}
+	if local == nil {
+		log.Printf("[%s] Tweet analysis %s needs Claude, using the external service", pair.Symbol, pair.TweetAnalysis)
+		return external
+	}
+	if pair.TweetAnalysis == TweetAnalysisPrimary {
+		return local
+	}
+	return &PairAnalysisProvider{pair: pair, external: external, local: local}
+}
+
+func (p *PairAnalysisProvider) FetchSentiment(ctx context.Context, communityID string) (ClaudeSentimentResponse, error) {
+	sentiment, err := p.external.FetchSentiment(ctx, communityID)
+	switch p.pair.TweetAnalysis {
+	case TweetAnalysisFallback:
+		if err == nil {
+			return sentiment, nil
+		}
+		log.Printf("[%s] External sentiment failed, falling back to tweet analysis: %v", p.pair.Symbol, err)
+		local, localErr := p.local.FetchSentiment(ctx, communityID)
+		if localErr != nil {
+			return sentiment, fmt.Errorf("%w; tweet analysis fallback: %v", err, localErr)
+		}
+		return local, nil
+	case TweetAnalysisShadow:
+		local, localErr := p.local.FetchSentiment(ctx, communityID)
+		record := AnalysisShadowRecord{
+			Symbol:             p.pair.Symbol,
+			CommunityID:        communityID,
+			Kind:               AnalysisKindSentiment,
+			ExternalSentiment:  sentiment.OverallSentiment,
+			ExternalFudLevel:   sentiment.FudLevel,
+			ExternalConfidence: sentiment.Confidence,
+			LocalSentiment:     local.OverallSentiment,
+			LocalFudLevel:      local.FudLevel,
+			LocalConfidence:    local.Confidence,
+		}
+		p.saveShadow(record, err, localErr)
+	}
+	return sentiment, err
+}
+
+func (p *PairAnalysisProvider) FetchFudAttack(ctx context.Context, communityID string) (ClaudeFudAttackResponse, error) {
+	attack, err := p.external.FetchFudAttack(ctx, communityID)
+	switch p.pair.TweetAnalysis {
+	case TweetAnalysisFallback:
+		if err == nil {
+			return attack, nil
+		}
+		log.Printf("[%s] External FUD alert failed, falling back to tweet analysis: %v", p.pair.Symbol, err)
+		local, localErr := p.local.FetchFudAttack(ctx, communityID)
+		if localErr != nil {
+			return attack, fmt.Errorf("%w; tweet analysis fallback: %v", err, localErr)
+		}
+		return local, nil
+	case TweetAnalysisShadow:
+		local, localErr := p.local.FetchFudAttack(ctx, communityID)
+		record := AnalysisShadowRecord{
+			Symbol:             p.pair.Symbol,
+			CommunityID:        communityID,
+			Kind:               AnalysisKindFudAttack,
+			ExternalHasAttack:  attack.HasAttack,
+			ExternalConfidence: attack.Confidence,
+			LocalHasAttack:     local.HasAttack,
+			LocalConfidence:    local.Confidence,
+		}
+		p.saveShadow(record, err, localErr)
+	}
+	return attack, err
+}
+
+func (p *PairAnalysisProvider) saveShadow(record AnalysisShadowRecord, externalErr, localErr error) {
+	if externalErr != nil {
+		record.ExternalError = externalErr.Error()
+	}
+	if localErr != nil {
+		record.LocalError = localErr.Error()
+		log.Printf("[%s] Shadow tweet analysis (%s) failed: %v", p.pair.Symbol, record.Kind, localErr)
+	}
+	if err := SaveAnalysisShadow(record); err != nil {
+		log.Printf("[%s] Failed to save analysis shadow record: %v", p.pair.Symbol, err)
+	}
+}
+
+// AnalysisShadowSummary sums up how often the tweet analysis agreed with the
+// external service for one pair and kind. Sentiments agree within one point,
+// FUD alerts when both report the same attack flag.
+type AnalysisShadowSummary struct {
+	Symbol              string  `json:"symbol"`
+	Kind                string  `json:"kind"`
+	Compared            int     `json:"compared"`
+	Failed              int     `json:"failed"`
+	Agreed              int     `json:"agreed"`
+	AgreementRate       float64 `json:"agreement_rate"`
+	MeanSentimentDiff   float64 `json:"mean_sentiment_diff,omitempty"`
+	LocalOnlyAttacks    int     `json:"local_only_attacks,omitempty"`
+	ExternalOnlyAttacks int     `json:"external_only_attacks,omitempty"`
+	MeanConfidenceDiff  float64 `json:"mean_confidence_diff"`
+	sentimentDiffTotal  float64
+	confidenceDiffTotal float64
+}
+
+func SummarizeAnalysisShadow(records []AnalysisShadowRecord) []AnalysisShadowSummary {
+	byKey := make(map[string]*AnalysisShadowSummary)
+	var keys []string
+	for _, record := range records {
+		key := record.Symbol + "/" + record.Kind
+		summary, ok := byKey[key]
+		if !ok {
+			summary = &AnalysisShadowSummary{Symbol: record.Symbol, Kind: record.Kind}
+			byKey[key] = summary
+			keys = append(keys, key)
+		}
+		if record.ExternalError != "" || record.LocalError != "" {
+			summary.Failed++
+			continue
+		}
+		summary.Compared++
+		summary.confidenceDiffTotal += math.Abs(record.LocalConfidence - record.ExternalConfidence)
+		switch record.Kind {
+		case AnalysisKindSentiment:
+			diff := math.Abs(float64(record.LocalSentiment - record.ExternalSentiment))
+			summary.sentimentDiffTotal += diff
+			if diff <= 1 {
+				summary.Agreed++
+			}
+		case AnalysisKindFudAttack:
+			switch {
+			case record.LocalHasAttack == record.ExternalHasAttack:
+				summary.Agreed++
+			case record.LocalHasAttack:
+				summary.LocalOnlyAttacks++
+			default:
+				summary.ExternalOnlyAttacks++
+			}
+		}
+	}
+
+	sort.Strings(keys)
+	summaries := make([]AnalysisShadowSummary, 0, len(keys))
+	for _, key := range keys {
+		summary := byKey[key]
+		if summary.Compared > 0 {
+			summary.AgreementRate = float64(summary.Agreed) / float64(summary.Compared)
+			summary.MeanSentimentDiff = summary.sentimentDiffTotal / float64(summary.Compared)
+			summary.MeanConfidenceDiff = summary.confidenceDiffTotal / float64(summary.Compared)
+		}
+		summaries = append(summaries, *summary)
+	}
+	return summaries
+}
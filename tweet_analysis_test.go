@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/grutapig/fudtradebot/claude"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const tweetAnalysisVerdict = `{"sentiment": {"overall_sentiment": 12, "sentiment_trend": "declining", "fud_level": 7, "confidence": 0.8, "key_themes": ["rug claims"], "recommendation": "stay cautious"},
+ "fud_attack": {"has_attack": true, "confidence": 0.75, "message_count": 2, "fud_type": "rug_claims", "theme": "team wallet dumping", "started_hours_ago": 1, "last_attack_tweet_id": "1980000000000000002", "justification": "same claim twice within an hour"}}`
+
+type fakeAnalysisProvider struct {
+	sentiment ClaudeSentimentResponse
+	fudAttack ClaudeFudAttackResponse
+	err       error
+}
+
+func (p fakeAnalysisProvider) FetchSentiment(ctx context.Context, communityID string) (ClaudeSentimentResponse, error) {
+	return p.sentiment, p.err
+}
+
+func (p fakeAnalysisProvider) FetchFudAttack(ctx context.Context, communityID string) (ClaudeFudAttackResponse, error) {
+	return p.fudAttack, p.err
+}
+
+func newTestTweetAnalyzer(t *testing.T, config TweetAnalysisConfig) (*TweetAnalyzer, *scenarioServices, *time.Time) {
+	base := time.Date(2025, 10, 9, 11, 0, 0, 0, time.UTC)
+	services := &scenarioServices{tweets: []CommunityTweet{
+		{ID: "980000000000000009", Date: base.Add(-5 * time.Hour), Text: "gm, building", Sentiment: 7},
+		{ID: "1980000000000000002", Date: base.Add(-20 * time.Minute), Text: "team wallet dumping, rug incoming", Sentiment: 1, IsFud: true},
+		{ID: "1980000000000000001", Date: base.Add(-50 * time.Minute), Text: "devs are dumping on us, rug", Sentiment: 2, IsFud: true},
+	}}
+	claudeClient, err := claude.NewClaudeClient("sk-test", "", claude.CLAUDE_45_MODEL)
+	require.NoError(t, err)
+	claudeClient.SetTransport(handlerTransport{services})
+
+	analyzer, err := NewTweetAnalyzer(NewExternalActivityClientWithTransport("http://grufender.test", handlerTransport{services}), claudeClient, config)
+	require.NoError(t, err)
+	now := base
+	analyzer.now = func() time.Time { return now }
+	return analyzer, services, &now
+}
+
+func TestTweetAnalyzer_AnalyzesCachesAndLimits(t *testing.T) {
+	analyzer, services, now := newTestTweetAnalyzer(t, TweetAnalysisConfig{CacheTTL: 30 * time.Minute, MinInterval: time.Hour})
+	services.queueVerdicts(tweetAnalysisVerdict, tweetAnalysisVerdict)
+	ctx := context.Background()
+
+	sentiment, err := analyzer.FetchSentiment(ctx, fixtureCommunityOK)
+	require.NoError(t, err)
+	assert.Equal(t, 10, sentiment.OverallSentiment, "scores are clamped to 0-10")
+	assert.Equal(t, 7, sentiment.FudLevel)
+	assert.Equal(t, "declining", sentiment.SentimentTrend)
+	assert.Equal(t, []string{"rug claims"}, sentiment.KeyThemes)
+
+	attack, err := analyzer.FetchFudAttack(ctx, fixtureCommunityOK)
+	require.NoError(t, err)
+	assert.True(t, attack.HasAttack)
+	assert.Equal(t, "rug_claims", attack.FudType)
+	require.NotNil(t, attack.LastAttackTime)
+	assert.Equal(t, now.Add(-20*time.Minute), *attack.LastAttackTime)
+	assert.Empty(t, attack.Participants)
+	assert.True(t, attack.ParticipantsUnknown)
+	assert.Equal(t, 1, services.calls(), "one call answers sentiment and FUD alert")
+
+	*now = now.Add(45 * time.Minute)
+	_, err = analyzer.FetchSentiment(ctx, fixtureCommunityOK)
+	require.NoError(t, err)
+	assert.Equal(t, 1, services.calls(), "no newer tweet, the analysis is reused")
+
+	services.tweets = append(services.tweets, CommunityTweet{ID: "1980000000000000003", Date: *now, Text: "chart still fine"})
+	*now = now.Add(5 * time.Minute)
+	_, err = analyzer.FetchSentiment(ctx, fixtureCommunityOK)
+	require.NoError(t, err)
+	assert.Equal(t, 1, services.calls(), "within the minimum interval the old analysis is served")
+
+	*now = now.Add(time.Hour)
+	_, err = analyzer.FetchSentiment(ctx, fixtureCommunityOK)
+	require.NoError(t, err)
+	assert.Equal(t, 2, services.calls())
+}
+
+func TestTweetAnalyzer_HourlyLimit(t *testing.T) {
+	analyzer, services, _ := newTestTweetAnalyzer(t, TweetAnalysisConfig{MaxCallsPerHour: 1})
+	services.queueVerdicts(tweetAnalysisVerdict)
+
+	_, err := analyzer.FetchSentiment(context.Background(), fixtureCommunityOK)
+	require.NoError(t, err)
+	_, err = analyzer.FetchSentiment(context.Background(), fixtureCommunityError)
+	assert.True(t, errors.Is(err, ErrTweetAnalysisRateLimited))
+	assert.Equal(t, 1, services.calls())
+}
+
+func TestNewTweetAnalyzer_Defaults(t *testing.T) {
+	analyzer, _, _ := newTestTweetAnalyzer(t, TweetAnalysisConfig{})
+	assert.Equal(t, DefaultTweetAnalysisConfig(), analyzer.config, "zero fields, MinInterval included, take the defaults")
+
+	analyzer, _, _ = newTestTweetAnalyzer(t, TweetAnalysisConfig{MinInterval: time.Minute})
+	assert.Equal(t, time.Minute, analyzer.config.MinInterval)
+}
+
+func TestPairAnalysisProvider_Modes(t *testing.T) {
+	require.NoError(t, OpenDatabase("file:pair_analysis_provider?mode=memory&cache=shared"))
+	t.Cleanup(func() {
+		if sqlDB, err := DB.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	analyzer, services, _ := newTestTweetAnalyzer(t, TweetAnalysisConfig{})
+	services.queueVerdicts(tweetAnalysisVerdict)
+	ctx := context.Background()
+
+	external := fakeAnalysisProvider{
+		sentiment: ClaudeSentimentResponse{OverallSentiment: 6, FudLevel: 2, Confidence: 0.9},
+		fudAttack: ClaudeFudAttackResponse{HasAttack: false, Confidence: 0.7},
+	}
+	pair := scenarioPair()
+
+	assert.Equal(t, ExternalAnalysisProvider(external), NewPairAnalysisProvider(pair, external, analyzer))
+	pair.TweetAnalysis = TweetAnalysisPrimary
+	assert.Equal(t, ExternalAnalysisProvider(analyzer), NewPairAnalysisProvider(pair, external, analyzer))
+	assert.Equal(t, ExternalAnalysisProvider(external), NewPairAnalysisProvider(pair, external, nil), "without Claude the external service answers")
+
+	pair.TweetAnalysis = TweetAnalysisFallback
+	failing := fakeAnalysisProvider{err: errors.New("sentiment API error (status 503)")}
+	sentiment, err := NewPairAnalysisProvider(pair, failing, analyzer).FetchSentiment(ctx, fixtureCommunityOK)
+	require.NoError(t, err)
+	assert.Equal(t, 10, sentiment.OverallSentiment)
+	sentiment, err = NewPairAnalysisProvider(pair, external, analyzer).FetchSentiment(ctx, fixtureCommunityOK)
+	require.NoError(t, err)
+	assert.Equal(t, 6, sentiment.OverallSentiment)
+
+	pair.TweetAnalysis = TweetAnalysisShadow
+	shadow := NewPairAnalysisProvider(pair, external, analyzer)
+	sentiment, err = shadow.FetchSentiment(ctx, fixtureCommunityOK)
+	require.NoError(t, err)
+	assert.Equal(t, 6, sentiment.OverallSentiment, "shadow mode trades on the external answer")
+	attack, err := shadow.FetchFudAttack(ctx, fixtureCommunityOK)
+	require.NoError(t, err)
+	assert.False(t, attack.HasAttack)
+	assert.Equal(t, 1, services.calls())
+
+	records, err := GetAnalysisShadowRecords(pair.Symbol, 24*365*10)
+	require.NoError(t, err)
+	require.Len(t, records, 2)
+	summaries := SummarizeAnalysisShadow(records)
+	require.Len(t, summaries, 2)
+	assert.Equal(t, AnalysisKindFudAttack, summaries[0].Kind)
+	assert.Equal(t, 1, summaries[0].LocalOnlyAttacks)
+	assert.Equal(t, 0.0, summaries[0].AgreementRate)
+	assert.Equal(t, AnalysisKindSentiment, summaries[1].Kind)
+	assert.Equal(t, 4.0, summaries[1].MeanSentimentDiff)
+}
+
+func TestCompareTweetIDs(t *testing.T) {
+	assert.Equal(t, 1, compareTweetIDs("1980000000000000002", "980000000000000009"))
+	assert.Equal(t, -1, compareTweetIDs("1980000000000000001", "1980000000000000002"))
+	assert.Equal(t, 0, compareTweetIDs("0042", "42"))
+}
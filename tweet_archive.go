@@ -0,0 +1,122 @@
+package main
+
+import (
+	"log"
+	"time"
+)
+
+const (
+	TweetIngestInterval = 2 * time.Minute
+	TweetIngestBatch    = 100
+	TweetIngestMaxBatch = 1000
+)
+
+// TweetSource serves the latest tweets of a community.
+type TweetSource interface {
+	GetRecentTweets(communityID string, limit int) ([]CommunityTweet, error)
+}
+
+// IngestCommunityTweets archives the tweets newer than the newest archived
+// one. When a whole batch is new there may be a gap behind it, so the batch
+// grows up to TweetIngestMaxBatch. It returns the number of new tweets.
+func IngestCommunityTweets(client ExternalActivityClient, communityID string) (int, error) {
+	sinceID, err := GetLatestTweetID(communityID)
+	if err != nil {
+		return 0, err
+	}
+
+	limit := TweetIngestBatch
+	var tweets []CommunityTweet
+	for {
+		tweets, err = client.GetTweetsSince(communityID, sinceID, limit)
+		if err != nil {
+			return 0, err
+		}
+		if sinceID == "" || len(tweets) < limit || limit >= TweetIngestMaxBatch {
+			break
+		}
+		limit = min(limit*4, TweetIngestMaxBatch)
+	}
+	if sinceID != "" && len(tweets) >= TweetIngestMaxBatch {
+		log.Printf("Tweet ingestion for %s: %d new tweets since %s, older ones may be missing", communityID, len(tweets), sinceID)
+	}
+
+	return SaveTweets(communityID, tweets)
+}
+
+// runTweetIngestion keeps the tweet archive of every pair's community
+// current.
+func runTweetIngestion(client ExternalActivityClient, pairs []TradingPair) {
+	ticker := time.NewTicker(TweetIngestInterval)
+	defer ticker.Stop()
+
+	for {
+		seen := make(map[string]bool)
+		for _, pair := range pairs {
+			if seen[pair.CommunityID] {
+				continue
+			}
+			seen[pair.CommunityID] = true
+
+			inserted, err := IngestCommunityTweets(client, pair.CommunityID)
+			if err != nil {
+				log.Printf("[%s] Tweet ingestion failed: %v", pair.Symbol, err)
+			} else if inserted > 0 {
+				log.Printf("[%s] Archived %d new tweets", pair.Symbol, inserted)
+			}
+		}
+		<-ticker.C
+	}
+}
+
+// TweetArchive serves tweets from the database. It ingests new tweets
+// before answering and asks the client directly only when the archive is
+// unavailable or still empty for the community.
+type TweetArchive struct {
+	client ExternalActivityClient
+}
+
+func NewTweetArchive(client ExternalActivityClient) TweetArchive {
+	return TweetArchive{client: client}
+}
+
+func (a TweetArchive) GetRecentTweets(communityID string, limit int) ([]CommunityTweet, error) {
+	if _, err := IngestCommunityTweets(a.client, communityID); err != nil {
+		log.Printf("Tweet ingestion for %s failed, serving the archive: %v", communityID, err)
+	}
+
+	records, _, err := SearchTweets(TweetQuery{CommunityID: communityID, Limit: limit})
+	if err != nil || len(records) == 0 {
+		return a.client.GetRecentTweets(communityID, limit)
+	}
+	return tweetsFromRecords(records), nil
+}
+
+func tweetsFromRecords(records []TweetRecord) []CommunityTweet {
+	tweets := make([]CommunityTweet, len(records))
+	for i, record := range records {
+		tweets[i] = CommunityTweet{
+			ID:        record.TweetID,
+			Date:      record.TweetedAt,
+			Text:      record.Text,
+			Sentiment: record.Sentiment,
+			IsFud:     record.IsFud,
+		}
+	}
+	return tweets
+}
+
+// noteNewTweets logs how many tweets were archived since *lastTweetID and
+// moves it to the newest archived tweet.
+func noteNewTweets(pair TradingPair, purpose string, lastTweetID *string) {
+	latest, err := GetLatestTweetID(pair.CommunityID)
+	if err != nil || latest == "" {
+		return
+	}
+	if *lastTweetID != "" && latest != *lastTweetID {
+		if count, err := CountTweetsSince(pair.CommunityID, *lastTweetID); err == nil {
+			log.Printf("[%s] %d new tweets since the last %s", pair.Symbol, count, purpose)
+		}
+	}
+	*lastTweetID = latest
+}
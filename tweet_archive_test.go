@@ -0,0 +1,148 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func openTestDatabase(t *testing.T, name string) {
+	require.NoError(t, OpenDatabase("file:"+name+"?mode=memory&cache=shared"))
+	t.Cleanup(func() {
+		if sqlDB, err := DB.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+}
+
+// pagedTweets serves count tweets newest first, honouring limit but not
+// since_id, and records the requested limits.
+type pagedTweets struct {
+	count  int
+	limits []int
+}
+
+func (p *pagedTweets) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	p.limits = append(p.limits, limit)
+	base := time.Date(2025, 10, 9, 12, 0, 0, 0, time.UTC)
+	var tweets []CommunityTweet
+	for i := p.count; i > 0 && len(tweets) < limit; i-- {
+		tweets = append(tweets, CommunityTweet{
+			ID:   fmt.Sprintf("19800000000000%05d", i),
+			Date: base.Add(time.Duration(i) * time.Second),
+			Text: fmt.Sprintf("tweet %d", i),
+		})
+	}
+	json.NewEncoder(w).Encode(TweetsResponse{Status: "success", Data: tweets})
+}
+
+func TestIngestCommunityTweets_Incremental(t *testing.T) {
+	openTestDatabase(t, "tweet_ingest_incremental")
+	base := time.Date(2025, 10, 9, 11, 0, 0, 0, time.UTC)
+	services := &scenarioServices{tweets: []CommunityTweet{
+		{ID: "1980000000000000002", Date: base.Add(-20 * time.Minute), Text: "Team wallet dumping, RUG incoming", Sentiment: 1, IsFud: true},
+		{ID: "1980000000000000001", Date: base.Add(-50 * time.Minute), Text: "devs are dumping on us", Sentiment: 2, IsFud: true},
+		{ID: "980000000000000009", Date: base.Add(-5 * time.Hour), Text: "gm, building", Sentiment: 7},
+	}}
+	client := NewExternalActivityClientWithTransport("http://grufender.test", handlerTransport{services})
+
+	inserted, err := IngestCommunityTweets(client, fixtureCommunityOK)
+	require.NoError(t, err)
+	assert.Equal(t, 3, inserted)
+	latest, err := GetLatestTweetID(fixtureCommunityOK)
+	require.NoError(t, err)
+	assert.Equal(t, "1980000000000000002", latest, "IDs compare as numbers")
+
+	inserted, err = IngestCommunityTweets(client, fixtureCommunityOK)
+	require.NoError(t, err)
+	assert.Zero(t, inserted, "tweets at or below the newest archived one are dropped")
+
+	services.tweets = append([]CommunityTweet{{ID: "1980000000000000003", Date: base, Text: "chart still fine", Sentiment: 6}}, services.tweets...)
+	inserted, err = IngestCommunityTweets(client, fixtureCommunityOK)
+	require.NoError(t, err)
+	assert.Equal(t, 1, inserted)
+
+	count, err := CountTweetsSince(fixtureCommunityOK, "1980000000000000001")
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), count)
+	count, err = CountTweetsSince(fixtureCommunityOK, "")
+	require.NoError(t, err)
+	assert.Equal(t, int64(4), count)
+
+	records, total, err := SearchTweets(TweetQuery{CommunityID: fixtureCommunityOK, Search: "rug", FudOnly: true})
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), total)
+	require.Len(t, records, 1)
+	assert.Equal(t, "1980000000000000002", records[0].TweetID)
+
+	records, total, err = SearchTweets(TweetQuery{CommunityID: fixtureCommunityOK, Limit: 2, Offset: 1})
+	require.NoError(t, err)
+	assert.Equal(t, int64(4), total)
+	require.Len(t, records, 2)
+	assert.Equal(t, "1980000000000000002", records[0].TweetID, "newest first")
+
+	records, err = GetTweetsInRange(fixtureCommunityOK, base.Add(-time.Hour), base.Add(-10*time.Minute))
+	require.NoError(t, err)
+	require.Len(t, records, 2)
+	assert.Equal(t, "1980000000000000001", records[0].TweetID, "oldest first")
+
+	state := &TradingState{LastAnalyzedTweetID: "1980000000000000001"}
+	pair := scenarioPair()
+	pair.CommunityID = fixtureCommunityOK
+	noteNewTweets(pair, "sentiment analysis", &state.LastAnalyzedTweetID)
+	assert.Equal(t, "1980000000000000003", state.LastAnalyzedTweetID)
+}
+
+func TestIngestCommunityTweets_GrowsBatchOnGap(t *testing.T) {
+	openTestDatabase(t, "tweet_ingest_gap")
+	source := &pagedTweets{count: 50}
+	client := NewExternalActivityClientWithTransport("http://grufender.test", handlerTransport{source})
+
+	inserted, err := IngestCommunityTweets(client, fixtureCommunityOK)
+	require.NoError(t, err)
+	assert.Equal(t, 50, inserted)
+	assert.Equal(t, []int{TweetIngestBatch}, source.limits, "the first ingestion takes one batch")
+
+	source.count = 50 + 150
+	source.limits = nil
+	inserted, err = IngestCommunityTweets(client, fixtureCommunityOK)
+	require.NoError(t, err)
+	assert.Equal(t, 150, inserted)
+	assert.Equal(t, []int{100, 400}, source.limits)
+
+	source.count = 200 + 2000
+	source.limits = nil
+	inserted, err = IngestCommunityTweets(client, fixtureCommunityOK)
+	require.NoError(t, err)
+	assert.Equal(t, TweetIngestMaxBatch, inserted)
+	assert.Equal(t, []int{100, 400, 1000}, source.limits)
+}
+
+func TestTweetArchive_ServesArchiveAndFallsBack(t *testing.T) {
+	openTestDatabase(t, "tweet_archive_fallback")
+	services := &scenarioServices{tweets: []CommunityTweet{
+		{ID: "1980000000000000001", Date: time.Date(2025, 10, 9, 11, 0, 0, 0, time.UTC), Text: "gm", Sentiment: 7},
+	}}
+	archive := NewTweetArchive(NewExternalActivityClientWithTransport("http://grufender.test", handlerTransport{services}))
+
+	tweets, err := archive.GetRecentTweets(fixtureCommunityOK, 50)
+	require.NoError(t, err)
+	require.Len(t, tweets, 1)
+	assert.Equal(t, 7, tweets[0].Sentiment)
+
+	services.tweets = nil
+	tweets, err = archive.GetRecentTweets(fixtureCommunityOK, 50)
+	require.NoError(t, err)
+	assert.Len(t, tweets, 1, "archived tweets are served when the API has nothing new")
+
+	tweets, err = archive.GetRecentTweets(fixtureCommunityError, 50)
+	require.NoError(t, err)
+	assert.Empty(t, tweets, "an empty archive falls back to the API")
+}